@@ -9,12 +9,16 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	_ "github.com/Cakra17/imphnen/docs"
 	"github.com/Cakra17/imphnen/internal/config"
 	"github.com/Cakra17/imphnen/internal/handlers"
+	"github.com/Cakra17/imphnen/internal/jobs"
 	md "github.com/Cakra17/imphnen/internal/middleware"
+	"github.com/Cakra17/imphnen/internal/models"
 	"github.com/Cakra17/imphnen/internal/store"
+	"github.com/Cakra17/imphnen/internal/utils"
 	"github.com/Cakra17/imphnen/pkg/service"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -33,7 +37,7 @@ import (
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
-// @description Type "Bearer" followed by a space and JWT token.
+// @description Type "Bearer" followed by a space and a JWT or personal access token.
 
 // @tag.name Auth
 // @tag.description Authentication endpoints for user registration and login
@@ -51,6 +55,30 @@ import (
 // @tag.description Operations related to order processing
 // @tag.docs.url https://example.com/docs/transactions
 
+// @tag.name Categories
+// @tag.description Income and expense categories for transactions
+// @tag.docs.url https://example.com/docs/categories
+
+// @tag.name Accounts
+// @tag.description Cash drawers, bank accounts and e-wallets with balances and transfers
+// @tag.docs.url https://example.com/docs/accounts
+
+// @tag.name Bank Imports
+// @tag.description Bank statement imports reconciled against transactions and orders
+// @tag.docs.url https://example.com/docs/bank-imports
+
+// @tag.name Recurring Transactions
+// @tag.description Transactions that repeat on a schedule, such as rent and salaries
+// @tag.docs.url https://example.com/docs/recurring-transactions
+
+// @tag.name Reports
+// @tag.description Financial reports such as profit and loss
+// @tag.docs.url https://example.com/docs/reports
+
+// @tag.name Analytics
+// @tag.description Sales and customer analytics such as best sellers, sales velocity, RFM segments and order-time heatmaps
+// @tag.docs.url https://example.com/docs/analytics
+
 // @tag.name Product
 // @tag.description Operations related to pruduct management
 // @tag.docs.url https://example.com/docs/products
@@ -67,6 +95,14 @@ import (
 // @tag.description Telegram bot endpoints for customer operations
 // @tag.docs.url https://example.com/docs/telegram
 
+// @tag.name Audit
+// @tag.description Audit trail of changes made in a merchant's store
+// @tag.docs.url https://example.com/docs/audit
+
+// @tag.name Tokens
+// @tag.description Personal access tokens for scripts and integrations
+// @tag.docs.url https://example.com/docs/tokens
+
 func main() {
 	cfg := config.Load()
 
@@ -96,13 +132,36 @@ func main() {
 		IdleTimeout:  time.Minute,
 	}
 
+	keys, err := utils.LoadKeySet(cfg.JWTKeysDir, cfg.JWTActiveKid, cfg.JWTSecret, cfg.JWTLegacyUntil, cfg.JWTEphemeralKey)
+	if err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+
+	totpBox, err := utils.NewSecretBox(cfg.TOTPEncryptionKey)
+	if err != nil {
+		log.Fatalf("Failed to load TOTP encryption key: %v", err)
+	}
+
 	db := config.ConnectDB(cfg.DSN)
 	cld, err := service.NewCloudinaryService(cfg.CloudinaryName, cfg.CloudinaryApiKey, cfg.CLoudinaryApiSecret, "imphnen")
 	if err != nil {
 		log.Fatalf("%s", err.Error())
 	}
 
-	kol := service.NewKolosalService(cfg.KolosalApiKey)
+	ocrProviders := []service.OCRProvider{}
+	for _, name := range cfg.OCRProviders {
+		switch name {
+		case "kolosal":
+			kol := service.NewKolosalService(cfg.KolosalApiKey, cfg.KolosalBaseURL)
+			ocrProviders = append(ocrProviders, &kol)
+		case "fake":
+			fake := service.NewFakeOCRService(cfg.OCRFixturesDir)
+			ocrProviders = append(ocrProviders, &fake)
+		default:
+			log.Fatalf("Unknown OCR provider %q", name)
+		}
+	}
+	ocr := service.NewOCRChain(ocrProviders...)
 
 	userRepo := store.NewUserRepo(db)
 	receiptRepo := store.NewReceiptRepo(db)
@@ -110,27 +169,90 @@ func main() {
 	productRepo := store.NewProductRepo(db)
 	orderRepo := store.NewOrderRepo(db)
 	customerRepo := store.NewCustomerRepo(db)
+	auditRepo := store.NewAuditRepo(db)
+	accessTokenRepo := store.NewAccessTokenRepo(db)
+	categoryRepo := store.NewCategoryRepo(db)
+	recurringRepo := store.NewRecurringTransactionRepo(db)
+	reportRepo := store.NewReportRepo(db)
+	analyticsRepo := store.NewAnalyticsRepo(db)
+	accountRepo := store.NewAccountRepo(db)
+	bankImportRepo := store.NewBankImportRepo(db)
+
+	sealed, err := userRepo.SealTOTPSecrets(context.Background(), totpBox)
+	if err != nil {
+		log.Fatalf("Failed to encrypt TOTP secrets: %v", err)
+	}
+	if sealed > 0 {
+		log.Printf("Encrypted %d plaintext TOTP secrets", sealed)
+	}
+
+	auth := md.NewAuthMiddleware(md.AuthMiddlewareConfig{
+		Keys:            keys,
+		AccessTokenRepo: accessTokenRepo,
+		UserRepo:        userRepo,
+	})
+
+	auditWriter := md.NewAuditWriter(md.AuditWriterConfig{
+		AuditRepo: auditRepo,
+	})
 
 	userHandler := handlers.NewUserHandler(handlers.UserHandlerConfig{
 		UserRepo:      userRepo,
-		JwtSecret:     cfg.JWTSecret,
+		Keys:          keys,
+		TOTPBox:       totpBox,
 		TokenDuration: time.Hour * 8,
+		DeletionGrace: time.Hour * 24 * time.Duration(cfg.DeletionGraceDays),
 	})
 
 	receiptHandler := handlers.NewReceiptHandler(handlers.ReceiptHandlerConfig{
-		ReceiptRepo:     receiptRepo,
-		TransactionRepo: transactionRepo,
-		Cld:             cld,
-		Kol:             kol,
+		ReceiptRepo:  receiptRepo,
+		CategoryRepo: categoryRepo,
+		AccountRepo:  accountRepo,
 	})
 
 	transactionHandler := handlers.NewTransactionHandler(handlers.TransactionHandlerConfig{
 		TransactionStore: &transactionRepo,
+		CategoryRepo:     categoryRepo,
+		AccountRepo:      accountRepo,
+	})
+
+	categoryHandler := handlers.NewCategoryHandler(handlers.CategoryHandlerConfig{
+		CategoryRepo: categoryRepo,
+	})
+
+	accountHandler := handlers.NewAccountHandler(handlers.AccountHandlerConfig{
+		AccountRepo: accountRepo,
+	})
+
+	bankImportHandler := handlers.NewBankImportHandler(handlers.BankImportHandlerConfig{
+		BankImportRepo: bankImportRepo,
+		AccountRepo:    accountRepo,
+		CategoryRepo:   categoryRepo,
+	})
+
+	reportHandler := handlers.NewReportHandler(handlers.ReportHandlerConfig{
+		ReportRepo:       reportRepo,
+		TransactionStore: &transactionRepo,
+		CategoryRepo:     categoryRepo,
+		ReceiptRepo:      receiptRepo,
+		UserRepo:         userRepo,
+	})
+
+	analyticsHandler := handlers.NewAnalyticsHandler(handlers.AnalyticsHandlerConfig{
+		AnalyticsRepo: analyticsRepo,
+	})
+
+	recurringHandler := handlers.NewRecurringTransactionHandler(handlers.RecurringTransactionHandlerConfig{
+		RecurringRepo: recurringRepo,
+		CategoryRepo:  categoryRepo,
+		AccountRepo:   accountRepo,
 	})
 
 	productHandler := handlers.NewProductHandler(handlers.ProductHandlerConfig{
-		ProductRepo: productRepo,
-		Cld:         cld,
+		ProductRepo:  productRepo,
+		CategoryRepo: categoryRepo,
+		ReceiptRepo:  receiptRepo,
+		Cld:          cld,
 	})
 
 	orderHandler := handlers.NewOrderHandler(handlers.OrderHandlerConfig{
@@ -147,57 +269,191 @@ func main() {
 		CustomerRepo: customerRepo,
 	})
 
+	auditHandler := handlers.NewAuditHandler(handlers.AuditHandlerConfig{
+		AuditRepo: auditRepo,
+	})
+
+	accessTokenHandler := handlers.NewAccessTokenHandler(handlers.AccessTokenHandlerConfig{
+		AccessTokenRepo: accessTokenRepo,
+	})
+
+	jwksHandler := handlers.NewJWKSHandler(handlers.JWKSHandlerConfig{
+		Keys: keys,
+	})
+
+	r.Get("/.well-known/jwks.json", jwksHandler.GetJWKS)
+
 	r.Route("/api/v1", func(r chi.Router) {
+		r.Use(md.Audit(auditWriter))
+
 		r.Get("/docs/*", httpSwagger.WrapHandler)
 
 		r.Post("/auth/login", userHandler.Login)
 		r.Post("/auth/register", userHandler.Register)
+		r.Post("/auth/2fa", userHandler.LoginTwoFactor)
 
 		r.Route("/users", func(r chi.Router) {
-			r.Use(md.Auth)
+			r.Use(auth.Auth)
+			r.Use(auth.RequireSession)
 			r.Get("/me", userHandler.Session)
+			r.Post("/me/2fa/enroll", userHandler.EnrollTwoFactor)
+			r.Post("/me/2fa/verify", userHandler.VerifyTwoFactor)
+			r.Post("/me/2fa/disable", userHandler.DisableTwoFactor)
+			r.Post("/me/2fa/recovery-codes", userHandler.RegenerateRecoveryCodes)
+			r.Post("/me/deletion/cancel", userHandler.CancelDeletion)
+			r.Get("/me/export", userHandler.ExportAccount)
 			r.Put("/{id}", userHandler.UpdateUser)
 			r.Delete("/{id}", userHandler.DeleteUser)
 		})
 
 		r.Route("/receipts", func(r chi.Router) {
-			r.Use(md.Auth)
+			r.Use(auth.Auth)
+			r.Use(auth.RequireScope("receipts"))
 			r.Post("/", receiptHandler.CreateReceipt)
 			r.Get("/", receiptHandler.GetReceipts)
+			r.Get("/export", receiptHandler.ExportReceipts)
 			r.Get("/{id}", receiptHandler.GetReceiptByID)
+			r.Patch("/{id}", receiptHandler.UpdateReceipt)
+			r.Get("/{id}/status", receiptHandler.GetReceiptStatus)
+			r.Post("/{id}/items", receiptHandler.AddReceiptItem)
+			r.Put("/{id}/items/{item_id}", receiptHandler.UpdateReceiptItem)
+			r.Delete("/{id}/items/{item_id}", receiptHandler.DeleteReceiptItem)
+			r.Post("/{id}/confirm", receiptHandler.ConfirmReceipt)
 			r.Get("/items/{id}", receiptHandler.GetItemsByRecieptID)
+			r.Post("/{id}/void", receiptHandler.VoidReceipt)
+			r.Patch("/{id}/category", receiptHandler.SetReceiptCategory)
 		})
 
 		r.Route("/transactions", func(r chi.Router) {
-			r.Use(md.Auth)
+			r.Use(auth.Auth)
+			r.Use(auth.RequireScope("transactions"))
 			r.Post("/", transactionHandler.CreateTransaction)
+			r.Get("/", transactionHandler.GetTransactions)
 			r.Get("/date", transactionHandler.GetTransactionsByDate)
 			r.Get("/range", transactionHandler.GetTransactionsByRange)
 			r.Get("/days", transactionHandler.GetTransactionsByDays)
 			r.Get("/stats", transactionHandler.GetTransactionStats)
 			r.Get("/stats/days", transactionHandler.GetTransactionStatsByDays)
+			r.Get("/stats/categories", transactionHandler.GetTransactionStatsByCategory)
+			r.Get("/series", transactionHandler.GetTransactionSeries)
 			r.Get("/type", transactionHandler.GetTransactionsByType)
 			r.Get("/source", transactionHandler.GetTransactionsBySource)
+			r.Get("/export", transactionHandler.ExportTransactions)
+			r.Get("/{id}", transactionHandler.GetTransactionByID)
+			r.Put("/{id}", transactionHandler.UpdateTransaction)
+			r.Post("/{id}/void", transactionHandler.VoidTransaction)
+			r.Get("/{id}/history", transactionHandler.GetTransactionHistory)
+		})
+
+		r.Route("/categories", func(r chi.Router) {
+			r.Use(auth.Auth)
+			r.Use(auth.RequireScope("transactions"))
+			r.Post("/", categoryHandler.CreateCategory)
+			r.Get("/", categoryHandler.GetCategories)
+			r.Put("/{id}", categoryHandler.UpdateCategory)
+			r.Delete("/{id}", categoryHandler.DeleteCategory)
+		})
+
+		r.Route("/accounts", func(r chi.Router) {
+			r.Use(auth.Auth)
+			r.Use(auth.RequireScope("transactions"))
+			r.Post("/", accountHandler.CreateAccount)
+			r.Get("/", accountHandler.GetAccounts)
+			r.Get("/{id}", accountHandler.GetAccountByID)
+			r.Put("/{id}", accountHandler.UpdateAccount)
+			r.Delete("/{id}", accountHandler.DeleteAccount)
+			r.Get("/{id}/ledger", accountHandler.GetAccountLedger)
+		})
+
+		r.Route("/transfers", func(r chi.Router) {
+			r.Use(auth.Auth)
+			r.Use(auth.RequireScope("transactions"))
+			r.Post("/", accountHandler.CreateTransfer)
+			r.Get("/", accountHandler.GetTransfers)
+			r.Delete("/{id}", accountHandler.DeleteTransfer)
+		})
+
+		r.Route("/bank-imports", func(r chi.Router) {
+			r.Use(auth.Auth)
+			r.Use(auth.RequireScope("transactions"))
+			r.Post("/", bankImportHandler.CreateImport)
+			r.Get("/", bankImportHandler.GetImports)
+			r.Get("/{id}/lines", bankImportHandler.GetImportLines)
+			r.Post("/lines/{id}/match", bankImportHandler.MatchLine)
+			r.Post("/lines/{id}/create", bankImportHandler.CreateFromLine)
+			r.Post("/lines/{id}/ignore", bankImportHandler.IgnoreLine)
+		})
+
+		r.Route("/recurring-transactions", func(r chi.Router) {
+			r.Use(auth.Auth)
+			r.Use(auth.RequireScope("transactions"))
+			r.Post("/", recurringHandler.CreateRecurringTransaction)
+			r.Get("/", recurringHandler.GetRecurringTransactions)
+			r.Get("/{id}", recurringHandler.GetRecurringTransactionByID)
+			r.Put("/{id}", recurringHandler.UpdateRecurringTransaction)
+			r.Delete("/{id}", recurringHandler.DeleteRecurringTransaction)
+			r.Post("/{id}/pause", recurringHandler.PauseRecurringTransaction)
+			r.Post("/{id}/resume", recurringHandler.ResumeRecurringTransaction)
+			r.Post("/{id}/skip", recurringHandler.SkipOccurrence)
+			r.Get("/{id}/occurrences", recurringHandler.GetOccurrences)
+		})
+
+		r.Route("/reports", func(r chi.Router) {
+			r.Use(auth.Auth)
+			r.Use(auth.RequireScope("reports"))
+			r.Get("/profit-loss", reportHandler.GetProfitLoss)
+			r.Get("/statement.pdf", reportHandler.GetStatement)
+			r.Get("/margins/products", reportHandler.GetProductMargins)
+			r.Get("/margins/categories", reportHandler.GetCategoryMargins)
+			r.Get("/margins/periods", reportHandler.GetMarginPeriods)
+		})
+
+		r.Route("/analytics", func(r chi.Router) {
+			r.Use(auth.Auth)
+			r.Use(auth.RequireScope("reports"))
+			r.Get("/products", analyticsHandler.GetProductAnalytics)
+			r.Get("/customers", analyticsHandler.GetCustomerAnalytics)
+			r.Get("/heatmap", analyticsHandler.GetOrderHeatmap)
 		})
 
 		r.Route("/products", func(r chi.Router) {
-			r.Use(md.Auth)
+			r.Use(auth.Auth)
+			r.Use(auth.RequireScope("products"))
 			r.Post("/", productHandler.CreateProduct)
 			r.Get("/", productHandler.GetProducts)
 			r.Get("/{id}", productHandler.GetProductByID)
 			r.Put("/{id}", productHandler.UpdateProduct)
 			r.Delete("/{id}", productHandler.DeleteProduct)
+			r.Post("/{id}/restock", productHandler.RestockProduct)
+			r.Get("/{id}/restocks", productHandler.GetProductRestocks)
 		})
 
 		r.Route("/orders", func(r chi.Router) {
-			r.Use(md.Auth)
+			r.Use(auth.Auth)
+			r.Use(auth.RequireScope("orders"))
 			r.Get("/", orderHandler.GetOrders)
+			r.Get("/export", orderHandler.ExportOrders)
 			r.Get("/customer/{customer_id}", orderHandler.GetOrdersByCustomer)
 			r.Get("/{id}", orderHandler.GetOrderByID)
 			r.Patch("/{id}/status", orderHandler.UpdateOrderStatus)
 		})
 
+		r.Route("/audit-logs", func(r chi.Router) {
+			r.Use(auth.Auth)
+			r.Use(auth.RequireScope("audit_logs"))
+			r.Get("/", auditHandler.GetAuditLogs)
+		})
+
+		r.Route("/tokens", func(r chi.Router) {
+			r.Use(auth.Auth)
+			r.Use(auth.RequireSession)
+			r.Post("/", accessTokenHandler.CreateAccessToken)
+			r.Get("/", accessTokenHandler.GetAccessTokens)
+			r.Delete("/{id}", accessTokenHandler.RevokeAccessToken)
+		})
+
 		r.Route("/telegram", func(r chi.Router) {
+			r.Use(md.AuditActor(models.AuditActorTelegram))
 			r.Get("/merchants/{merchant_id}/products", telegramHandler.ListProductsByMerchant)
 			r.Post("/orders", telegramHandler.CreateOrderForCustomer)
 			r.Get("/customers/{customer_id}/orders", telegramHandler.ListCustomerOrders)
@@ -215,6 +471,41 @@ func main() {
 		})
 	})
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	accountPurger := jobs.NewAccountPurger(jobs.AccountPurgerConfig{
+		UserRepo: userRepo,
+		Cld:      cld,
+		Interval: time.Hour,
+	})
+	go accountPurger.Run(jobsCtx)
+
+	recurringScheduler := jobs.NewRecurringScheduler(jobs.RecurringSchedulerConfig{
+		RecurringRepo: recurringRepo,
+		Interval:      15 * time.Minute,
+	})
+	go recurringScheduler.Run(jobsCtx)
+
+	receiptProcessor := jobs.NewReceiptProcessor(jobs.ReceiptProcessorConfig{
+		ReceiptRepo: receiptRepo,
+		Cld:         cld,
+		OCR:         &ocr,
+		Workers:     cfg.ReceiptWorkers,
+		Interval:    2 * time.Second,
+	})
+	go receiptProcessor.Run(jobsCtx)
+
+	// The audit writer outlives the jobs so the requests still running
+	// during shutdown get their entries written.
+	auditCtx, stopAudit := context.WithCancel(context.Background())
+	defer stopAudit()
+	auditDone := make(chan struct{})
+	go func() {
+		auditWriter.Run(auditCtx)
+		close(auditDone)
+	}()
+
 	closed := make(chan struct{})
 
 	go func() {
@@ -223,6 +514,7 @@ func main() {
 		<-sigint
 
 		log.Println("Received shutdown signal, shutting down server")
+		stopJobs()
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 		defer cancel()
 
@@ -230,6 +522,9 @@ func main() {
 			log.Printf("Failed to shutdown server: %v", err)
 		}
 
+		stopAudit()
+		<-auditDone
+
 		close(closed)
 	}()
 
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_audit_logs_store_entity;
+DROP INDEX IF EXISTS idx_audit_logs_store_created;
+DROP TABLE IF EXISTS audit_logs CASCADE;
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS audit_logs (
+  id UUID PRIMARY KEY,
+  store_id UUID DEFAULT NULL,
+  actor_id VARCHAR(255) DEFAULT NULL,
+  actor_type VARCHAR(50) NOT NULL,
+  action VARCHAR(100) NOT NULL,
+  method VARCHAR(10) NOT NULL,
+  path TEXT NOT NULL,
+  entity_type VARCHAR(100) NOT NULL,
+  entity_id VARCHAR(255) DEFAULT NULL,
+  before JSONB DEFAULT NULL,
+  after JSONB DEFAULT NULL,
+  status_code INT NOT NULL,
+  request_id VARCHAR(255) DEFAULT NULL,
+  ip_address VARCHAR(255) DEFAULT NULL,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  CONSTRAINT fk_audit_logs_store
+    FOREIGN KEY (store_id)
+    REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_store_created ON audit_logs(store_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_store_entity ON audit_logs(store_id, entity_type, entity_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_recovery_codes_user_hash;
+DROP TABLE IF EXISTS user_recovery_codes CASCADE;
+
+ALTER TABLE users
+  DROP COLUMN IF EXISTS totp_last_step,
+  DROP COLUMN IF EXISTS totp_enabled,
+  DROP COLUMN IF EXISTS totp_secret;
@@ -0,0 +1,17 @@
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64) DEFAULT NULL,
+  ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN IF NOT EXISTS totp_last_step BIGINT DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL,
+  code_hash VARCHAR(64) NOT NULL,
+  used_at TIMESTAMPTZ DEFAULT NULL,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  CONSTRAINT fk_recovery_codes_user
+    FOREIGN KEY (user_id)
+    REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_hash ON user_recovery_codes(user_id, code_hash);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_transaction_revisions_transaction;
+DROP TABLE IF EXISTS transaction_revisions CASCADE;
+
+ALTER TABLE receipts
+  DROP COLUMN IF EXISTS void_reason,
+  DROP COLUMN IF EXISTS voided_at;
+
+ALTER TABLE transactions
+  DROP COLUMN IF EXISTS void_reason,
+  DROP COLUMN IF EXISTS voided_at;
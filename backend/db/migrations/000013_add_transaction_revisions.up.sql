@@ -0,0 +1,25 @@
+ALTER TABLE transactions
+  ADD COLUMN IF NOT EXISTS voided_at TIMESTAMPTZ DEFAULT NULL,
+  ADD COLUMN IF NOT EXISTS void_reason VARCHAR(255) DEFAULT NULL;
+
+ALTER TABLE receipts
+  ADD COLUMN IF NOT EXISTS voided_at TIMESTAMPTZ DEFAULT NULL,
+  ADD COLUMN IF NOT EXISTS void_reason VARCHAR(255) DEFAULT NULL;
+
+-- Revisions keep the previous values of a transaction for every edit and
+-- void. Transactions are never deleted on their own, only together with
+-- their merchant, whose revisions go with them.
+CREATE TABLE IF NOT EXISTS transaction_revisions (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  transaction_id UUID NOT NULL,
+  user_id UUID NOT NULL,
+  action VARCHAR(20) NOT NULL,
+  previous JSONB NOT NULL,
+  reason VARCHAR(255) DEFAULT NULL,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  CONSTRAINT fk_transaction_revisions_user
+    FOREIGN KEY (user_id)
+    REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_revisions_transaction ON transaction_revisions(transaction_id, created_at);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_transactions_user_category;
+
+ALTER TABLE receipts
+  DROP CONSTRAINT IF EXISTS fk_receipts_category,
+  DROP COLUMN IF EXISTS category_id;
+
+ALTER TABLE transactions
+  DROP CONSTRAINT IF EXISTS fk_transactions_category,
+  DROP COLUMN IF EXISTS category_id;
+
+DROP TABLE IF EXISTS transaction_categories CASCADE;
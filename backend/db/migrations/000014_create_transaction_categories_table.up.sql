@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS transaction_categories (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  type type NOT NULL,
+  is_default BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  CONSTRAINT fk_transaction_categories_user
+    FOREIGN KEY (user_id)
+    REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT uq_transaction_categories_user_name_type
+    UNIQUE (user_id, name, type)
+);
+
+ALTER TABLE transactions
+  ADD COLUMN IF NOT EXISTS category_id UUID DEFAULT NULL,
+  ADD CONSTRAINT fk_transactions_category
+    FOREIGN KEY (category_id)
+    REFERENCES transaction_categories(id) ON DELETE SET NULL;
+
+ALTER TABLE receipts
+  ADD COLUMN IF NOT EXISTS category_id UUID DEFAULT NULL,
+  ADD CONSTRAINT fk_receipts_category
+    FOREIGN KEY (category_id)
+    REFERENCES transaction_categories(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_user_category ON transactions(user_id, category_id);
+
+-- Existing merchants get the same defaults as new registrations.
+INSERT INTO transaction_categories (user_id, name, type, is_default)
+SELECT u.id, d.name, d.type::type, TRUE
+FROM users u
+CROSS JOIN (VALUES
+  ('Bahan Baku', 'expense'),
+  ('Sewa', 'expense'),
+  ('Gaji', 'expense'),
+  ('Utilitas', 'expense'),
+  ('Lainnya', 'expense'),
+  ('Penjualan', 'income'),
+  ('Lainnya', 'income')
+) AS d(name, type)
+ON CONFLICT (user_id, name, type) DO NOTHING;
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS uq_transactions_recurring_occurrence;
+
+ALTER TABLE transactions
+  DROP CONSTRAINT IF EXISTS fk_transactions_recurring,
+  DROP COLUMN IF EXISTS occurrence_date,
+  DROP COLUMN IF EXISTS recurring_id;
+
+DROP TABLE IF EXISTS recurring_transaction_skips CASCADE;
+DROP INDEX IF EXISTS idx_recurring_transactions_user;
+DROP INDEX IF EXISTS idx_recurring_transactions_due;
+DROP TABLE IF EXISTS recurring_transactions CASCADE;
+
+-- PostgreSQL cannot drop a value from an enum. 'recurring' stays in the
+-- source type; rows that used it keep their value.
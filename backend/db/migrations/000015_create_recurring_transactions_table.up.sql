@@ -0,0 +1,57 @@
+ALTER TYPE source ADD VALUE IF NOT EXISTS 'recurring';
+
+CREATE TABLE IF NOT EXISTS recurring_transactions (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL,
+  type type NOT NULL,
+  amount DECIMAL(18,2) NOT NULL,
+  category_id UUID DEFAULT NULL,
+  description VARCHAR(255) DEFAULT NULL,
+  frequency VARCHAR(20) NOT NULL,
+  interval_count INT NOT NULL DEFAULT 1,
+  day_of_month SMALLINT DEFAULT NULL,
+  start_date DATE NOT NULL,
+  end_date DATE DEFAULT NULL,
+  next_run_date DATE NOT NULL,
+  paused_at TIMESTAMPTZ DEFAULT NULL,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  updated_at TIMESTAMPTZ DEFAULT NOW(),
+  CONSTRAINT fk_recurring_transactions_user
+    FOREIGN KEY (user_id)
+    REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_recurring_transactions_category
+    FOREIGN KEY (category_id)
+    REFERENCES transaction_categories(id) ON DELETE SET NULL,
+  CONSTRAINT chk_recurring_transactions_frequency
+    CHECK (frequency IN ('daily', 'weekly', 'monthly', 'month_end')),
+  CONSTRAINT chk_recurring_transactions_interval
+    CHECK (interval_count > 0),
+  CONSTRAINT chk_recurring_transactions_day_of_month
+    CHECK (day_of_month IS NULL OR day_of_month BETWEEN 1 AND 31)
+);
+
+CREATE INDEX IF NOT EXISTS idx_recurring_transactions_due ON recurring_transactions(next_run_date)
+  WHERE paused_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_recurring_transactions_user ON recurring_transactions(user_id);
+
+CREATE TABLE IF NOT EXISTS recurring_transaction_skips (
+  recurring_id UUID NOT NULL,
+  occurrence_date DATE NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  PRIMARY KEY (recurring_id, occurrence_date),
+  CONSTRAINT fk_recurring_transaction_skips_recurring
+    FOREIGN KEY (recurring_id)
+    REFERENCES recurring_transactions(id) ON DELETE CASCADE
+);
+
+-- Materialised transactions keep their occurrence date so the scheduler can
+-- run the same occurrence twice without creating a duplicate.
+ALTER TABLE transactions
+  ADD COLUMN IF NOT EXISTS recurring_id UUID DEFAULT NULL,
+  ADD COLUMN IF NOT EXISTS occurrence_date DATE DEFAULT NULL,
+  ADD CONSTRAINT fk_transactions_recurring
+    FOREIGN KEY (recurring_id)
+    REFERENCES recurring_transactions(id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_transactions_recurring_occurrence ON transactions(recurring_id, occurrence_date)
+  WHERE recurring_id IS NOT NULL;
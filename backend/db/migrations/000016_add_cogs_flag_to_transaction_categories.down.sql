@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_orders_user_status_date;
+
+ALTER TABLE transaction_categories
+  DROP COLUMN IF EXISTS is_cogs;
@@ -0,0 +1,9 @@
+-- Expenses in cost of goods sold categories are reported as COGS in the
+-- profit and loss report instead of as operating expenses.
+ALTER TABLE transaction_categories
+  ADD COLUMN IF NOT EXISTS is_cogs BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE transaction_categories SET is_cogs = TRUE
+WHERE is_default AND type = 'expense' AND name = 'Bahan Baku';
+
+CREATE INDEX IF NOT EXISTS idx_orders_user_status_date ON orders(user_id, status, order_date);
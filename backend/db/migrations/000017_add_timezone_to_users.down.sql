@@ -0,0 +1,5 @@
+ALTER TABLE transactions
+  ALTER COLUMN transaction_date TYPE TIMESTAMP
+  USING transaction_date AT TIME ZONE 'Asia/Jakarta';
+
+ALTER TABLE users DROP COLUMN IF EXISTS timezone;
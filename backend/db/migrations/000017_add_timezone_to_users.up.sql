@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'Asia/Jakarta';
+
+-- Transaction dates were written as WIB wall-clock times without a zone.
+ALTER TABLE transactions
+  ALTER COLUMN transaction_date TYPE TIMESTAMPTZ
+  USING transaction_date AT TIME ZONE 'Asia/Jakarta';
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS account_transfers;
+
+DROP INDEX IF EXISTS idx_transactions_account_date;
+
+ALTER TABLE transactions
+  DROP CONSTRAINT IF EXISTS fk_transactions_account,
+  DROP COLUMN IF EXISTS account_id;
+
+DROP TABLE IF EXISTS accounts;
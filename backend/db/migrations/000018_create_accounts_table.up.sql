@@ -0,0 +1,55 @@
+-- Accounts are where a merchant's money sits: the cash drawer, a bank account
+-- or an e-wallet. The balance is the opening balance plus the account's
+-- non-voided transactions and transfers.
+CREATE TABLE IF NOT EXISTS accounts (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  kind VARCHAR(20) NOT NULL,
+  opening_balance NUMERIC(15, 2) NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  CONSTRAINT fk_accounts_user
+    FOREIGN KEY (user_id)
+    REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT uq_accounts_user_name
+    UNIQUE (user_id, name),
+  CONSTRAINT chk_accounts_kind
+    CHECK (kind IN ('cash', 'bank', 'ewallet'))
+);
+
+ALTER TABLE transactions
+  ADD COLUMN IF NOT EXISTS account_id UUID DEFAULT NULL,
+  ADD CONSTRAINT fk_transactions_account
+    FOREIGN KEY (account_id)
+    REFERENCES accounts(id) ON DELETE RESTRICT;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_account_date ON transactions(account_id, transaction_date);
+
+-- Transfers move money between two accounts of the same merchant and are not
+-- income or expense.
+CREATE TABLE IF NOT EXISTS account_transfers (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  from_account_id UUID NOT NULL,
+  to_account_id UUID NOT NULL,
+  amount NUMERIC(15, 2) NOT NULL,
+  transfer_date TIMESTAMPTZ NOT NULL,
+  note VARCHAR(255) DEFAULT NULL,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  CONSTRAINT fk_account_transfers_user
+    FOREIGN KEY (user_id)
+    REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_account_transfers_from
+    FOREIGN KEY (from_account_id)
+    REFERENCES accounts(id) ON DELETE RESTRICT,
+  CONSTRAINT fk_account_transfers_to
+    FOREIGN KEY (to_account_id)
+    REFERENCES accounts(id) ON DELETE RESTRICT,
+  CONSTRAINT chk_account_transfers_accounts
+    CHECK (from_account_id <> to_account_id),
+  CONSTRAINT chk_account_transfers_amount
+    CHECK (amount > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_transfers_from_date ON account_transfers(from_account_id, transfer_date);
+CREATE INDEX IF NOT EXISTS idx_account_transfers_to_date ON account_transfers(to_account_id, transfer_date);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS bank_import_lines;
+DROP INDEX IF EXISTS idx_bank_imports_user;
+DROP TABLE IF EXISTS bank_imports;
+
+-- PostgreSQL cannot drop a value from an enum. 'import' stays in the source
+-- type; rows that used it keep their value.
@@ -0,0 +1,53 @@
+ALTER TYPE source ADD VALUE IF NOT EXISTS 'import';
+
+CREATE TABLE IF NOT EXISTS bank_imports (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL,
+  account_id UUID NOT NULL,
+  filename VARCHAR(255) NOT NULL,
+  format VARCHAR(10) NOT NULL,
+  line_count INT NOT NULL DEFAULT 0,
+  duplicate_count INT NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  CONSTRAINT fk_bank_imports_user
+    FOREIGN KEY (user_id)
+    REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_bank_imports_account
+    FOREIGN KEY (account_id)
+    REFERENCES accounts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_bank_imports_user ON bank_imports(user_id, created_at);
+
+-- A line stays pending until the merchant matches it to an existing
+-- transaction, creates a transaction from it or ignores it. The hash of date,
+-- amount and description keeps a line from being imported twice into the same
+-- account.
+CREATE TABLE IF NOT EXISTS bank_import_lines (
+  id UUID PRIMARY KEY,
+  import_id UUID NOT NULL,
+  user_id UUID NOT NULL,
+  account_id UUID NOT NULL,
+  line_date DATE NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  amount NUMERIC(15, 2) NOT NULL,
+  hash CHAR(64) NOT NULL,
+  status VARCHAR(10) NOT NULL DEFAULT 'pending',
+  transaction_id UUID DEFAULT NULL,
+  resolved_at TIMESTAMPTZ DEFAULT NULL,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  CONSTRAINT fk_bank_import_lines_import
+    FOREIGN KEY (import_id)
+    REFERENCES bank_imports(id) ON DELETE CASCADE,
+  CONSTRAINT fk_bank_import_lines_transaction
+    FOREIGN KEY (transaction_id)
+    REFERENCES transactions(id) ON DELETE SET NULL,
+  CONSTRAINT uq_bank_import_lines_account_hash
+    UNIQUE (account_id, hash),
+  CONSTRAINT chk_bank_import_lines_status
+    CHECK (status IN ('pending', 'matched', 'created', 'ignored'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_bank_import_lines_import ON bank_import_lines(import_id, line_date);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_bank_import_lines_transaction
+  ON bank_import_lines(transaction_id) WHERE transaction_id IS NOT NULL;
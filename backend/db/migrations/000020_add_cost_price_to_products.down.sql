@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_order_items_product;
+
+DROP TABLE IF EXISTS product_restocks;
+
+ALTER TABLE order_items
+  DROP COLUMN IF EXISTS unit_cost;
+
+ALTER TABLE products
+  DROP CONSTRAINT IF EXISTS fk_products_category,
+  DROP COLUMN IF EXISTS category_id,
+  DROP COLUMN IF EXISTS cost_price;
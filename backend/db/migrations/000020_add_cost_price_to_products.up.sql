@@ -0,0 +1,43 @@
+-- cost_price is the weighted average cost of the units in stock. Restocks
+-- move it towards the cost they were bought at, and order items keep the cost
+-- at the time of sale so margins do not change when the product is restocked.
+ALTER TABLE products
+  ADD COLUMN IF NOT EXISTS cost_price NUMERIC(18,2) NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS category_id UUID DEFAULT NULL,
+  ADD CONSTRAINT fk_products_category
+    FOREIGN KEY (category_id)
+    REFERENCES transaction_categories(id) ON DELETE SET NULL;
+
+ALTER TABLE order_items
+  ADD COLUMN IF NOT EXISTS unit_cost NUMERIC(18,2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS product_restocks (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL,
+  product_id UUID NOT NULL,
+  receipt_item_id UUID DEFAULT NULL,
+  quantity INT NOT NULL,
+  unit_cost NUMERIC(18,2) NOT NULL,
+  stock_before INT NOT NULL,
+  cost_before NUMERIC(18,2) NOT NULL,
+  cost_after NUMERIC(18,2) NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  CONSTRAINT fk_product_restocks_user
+    FOREIGN KEY (user_id)
+    REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_product_restocks_product
+    FOREIGN KEY (product_id)
+    REFERENCES products(id) ON DELETE CASCADE,
+  CONSTRAINT fk_product_restocks_receipt_item
+    FOREIGN KEY (receipt_item_id)
+    REFERENCES receipt_items(id) ON DELETE SET NULL,
+  CONSTRAINT uq_product_restocks_receipt_item
+    UNIQUE (receipt_item_id),
+  CONSTRAINT chk_product_restocks_quantity
+    CHECK (quantity > 0),
+  CONSTRAINT chk_product_restocks_unit_cost
+    CHECK (unit_cost >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_restocks_product ON product_restocks(product_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_order_items_product ON order_items(product_id);
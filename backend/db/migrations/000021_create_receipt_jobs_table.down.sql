@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_receipt_jobs_run_at;
+
+DROP TABLE IF EXISTS receipt_jobs;
+
+ALTER TABLE receipts
+  DROP CONSTRAINT IF EXISTS chk_receipts_status,
+  DROP COLUMN IF EXISTS failure_reason,
+  DROP COLUMN IF EXISTS status;
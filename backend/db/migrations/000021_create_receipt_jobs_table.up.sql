@@ -0,0 +1,31 @@
+-- Existing receipts were created synchronously and are all ready.
+ALTER TABLE receipts
+  ADD COLUMN IF NOT EXISTS status VARCHAR(10) NOT NULL DEFAULT 'ready',
+  ADD COLUMN IF NOT EXISTS failure_reason VARCHAR(255) DEFAULT NULL,
+  ADD CONSTRAINT chk_receipts_status
+    CHECK (status IN ('processing', 'ready', 'failed'));
+
+-- An uploaded image waits here until a worker has read and stored it. A
+-- worker claims a job by moving locked_until into the future, so the job of a
+-- worker that died is picked up again once the lock expires. The row is
+-- deleted when the receipt is ready or has failed for good.
+CREATE TABLE IF NOT EXISTS receipt_jobs (
+  id UUID PRIMARY KEY,
+  receipt_id UUID NOT NULL UNIQUE,
+  user_id UUID NOT NULL,
+  image BYTEA NOT NULL,
+  filename VARCHAR(255) NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  locked_until TIMESTAMPTZ DEFAULT NULL,
+  last_error TEXT DEFAULT NULL,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  CONSTRAINT fk_receipt_jobs_receipt
+    FOREIGN KEY (receipt_id)
+    REFERENCES receipts(id) ON DELETE CASCADE,
+  CONSTRAINT fk_receipt_jobs_user
+    FOREIGN KEY (user_id)
+    REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_receipt_jobs_run_at ON receipt_jobs(run_at);
@@ -0,0 +1,16 @@
+ALTER TABLE receipt_items
+  DROP CONSTRAINT IF EXISTS chk_receipt_items_quantity,
+  DROP COLUMN IF EXISTS quantity;
+
+-- Drafts have no expense yet; they come back as ready receipts without one.
+ALTER TABLE receipts
+  DROP CONSTRAINT IF EXISTS chk_receipts_status;
+
+UPDATE receipts SET status = 'ready' WHERE status IN ('draft', 'confirmed');
+
+ALTER TABLE receipts
+  ALTER COLUMN status SET DEFAULT 'ready',
+  DROP COLUMN IF EXISTS confirmed_at,
+  DROP COLUMN IF EXISTS invoice_date,
+  ADD CONSTRAINT chk_receipts_status
+    CHECK (status IN ('processing', 'ready', 'failed'));
@@ -0,0 +1,26 @@
+-- Scanned receipts now wait as drafts until the merchant confirms them, and
+-- only then post an expense. Receipts that were ready before were posted
+-- straight away, so they count as confirmed.
+ALTER TABLE receipts
+  DROP CONSTRAINT IF EXISTS chk_receipts_status,
+  ADD COLUMN IF NOT EXISTS invoice_date DATE DEFAULT NULL,
+  ADD COLUMN IF NOT EXISTS confirmed_at TIMESTAMPTZ DEFAULT NULL;
+
+UPDATE receipts SET status = 'confirmed', confirmed_at = created_at WHERE status = 'ready';
+
+UPDATE receipts r
+SET invoice_date = (
+  SELECT (MIN(t.transaction_date) AT TIME ZONE u.timezone)::date
+  FROM transactions t WHERE t.receipt_id = r.id
+)
+FROM users u
+WHERE u.id = r.user_id;
+
+ALTER TABLE receipts
+  ALTER COLUMN status SET DEFAULT 'draft',
+  ADD CONSTRAINT chk_receipts_status
+    CHECK (status IN ('processing', 'draft', 'confirmed', 'failed'));
+
+ALTER TABLE receipt_items
+  ADD COLUMN IF NOT EXISTS quantity INT NOT NULL DEFAULT 1,
+  ADD CONSTRAINT chk_receipt_items_quantity CHECK (quantity > 0);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_receipts_invoice_date;
+DROP INDEX IF EXISTS idx_receipts_content_hash;
+
+ALTER TABLE receipts
+  DROP CONSTRAINT IF EXISTS fk_receipts_duplicate_of,
+  DROP COLUMN IF EXISTS allow_duplicate,
+  DROP COLUMN IF EXISTS duplicate_of,
+  DROP COLUMN IF EXISTS image_hash,
+  DROP COLUMN IF EXISTS content_hash;
@@ -0,0 +1,16 @@
+-- content_hash is the SHA-256 of the uploaded file and image_hash a 64 bit
+-- difference hash that stays close for two photos of the same receipt.
+-- Receipts uploaded before this have neither and are only matched on store,
+-- date and total. duplicate_of points at the receipt a draft seems to repeat
+-- unless the merchant allowed the duplicate.
+ALTER TABLE receipts
+  ADD COLUMN IF NOT EXISTS content_hash CHAR(64) DEFAULT NULL,
+  ADD COLUMN IF NOT EXISTS image_hash BIGINT DEFAULT NULL,
+  ADD COLUMN IF NOT EXISTS duplicate_of UUID DEFAULT NULL,
+  ADD COLUMN IF NOT EXISTS allow_duplicate BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD CONSTRAINT fk_receipts_duplicate_of
+    FOREIGN KEY (duplicate_of)
+    REFERENCES receipts(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_receipts_content_hash ON receipts(user_id, content_hash);
+CREATE INDEX IF NOT EXISTS idx_receipts_invoice_date ON receipts(user_id, invoice_date);
@@ -0,0 +1,3 @@
+ALTER TABLE users
+  DROP COLUMN IF EXISTS two_factor_locked_until,
+  DROP COLUMN IF EXISTS two_factor_attempts;
@@ -0,0 +1,6 @@
+-- Every second factor login attempt is counted until one succeeds, so the
+-- code cannot be guessed by minting new challenge tokens. Reaching the limit
+-- locks the second factor until two_factor_locked_until.
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS two_factor_attempts INT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS two_factor_locked_until TIMESTAMPTZ DEFAULT NULL;
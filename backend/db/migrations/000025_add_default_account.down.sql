@@ -0,0 +1,12 @@
+ALTER TABLE recurring_transactions
+  DROP CONSTRAINT IF EXISTS fk_recurring_transactions_account,
+  DROP COLUMN IF EXISTS account_id;
+
+ALTER TABLE receipts
+  DROP CONSTRAINT IF EXISTS fk_receipts_account,
+  DROP COLUMN IF EXISTS account_id;
+
+DROP INDEX IF EXISTS uq_accounts_user_default;
+
+ALTER TABLE accounts
+  DROP COLUMN IF EXISTS is_default;
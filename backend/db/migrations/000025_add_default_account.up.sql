@@ -0,0 +1,22 @@
+-- Transactions the system creates itself go to the account the receipt or
+-- recurring template names, or otherwise to the merchant's default account.
+-- Every merchant's oldest account becomes the default.
+ALTER TABLE accounts
+  ADD COLUMN IF NOT EXISTS is_default BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE accounts SET is_default = TRUE
+WHERE id IN (SELECT DISTINCT ON (user_id) id FROM accounts ORDER BY user_id, created_at, id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_accounts_user_default ON accounts(user_id) WHERE is_default;
+
+ALTER TABLE receipts
+  ADD COLUMN IF NOT EXISTS account_id UUID DEFAULT NULL,
+  ADD CONSTRAINT fk_receipts_account
+    FOREIGN KEY (account_id)
+    REFERENCES accounts(id) ON DELETE RESTRICT;
+
+ALTER TABLE recurring_transactions
+  ADD COLUMN IF NOT EXISTS account_id UUID DEFAULT NULL,
+  ADD CONSTRAINT fk_recurring_transactions_account
+    FOREIGN KEY (account_id)
+    REFERENCES accounts(id) ON DELETE RESTRICT;
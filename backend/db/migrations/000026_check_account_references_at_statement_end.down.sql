@@ -0,0 +1,27 @@
+ALTER TABLE transactions
+  DROP CONSTRAINT IF EXISTS fk_transactions_account,
+  ADD CONSTRAINT fk_transactions_account
+    FOREIGN KEY (account_id)
+    REFERENCES accounts(id) ON DELETE RESTRICT;
+
+ALTER TABLE account_transfers
+  DROP CONSTRAINT IF EXISTS fk_account_transfers_from,
+  ADD CONSTRAINT fk_account_transfers_from
+    FOREIGN KEY (from_account_id)
+    REFERENCES accounts(id) ON DELETE RESTRICT,
+  DROP CONSTRAINT IF EXISTS fk_account_transfers_to,
+  ADD CONSTRAINT fk_account_transfers_to
+    FOREIGN KEY (to_account_id)
+    REFERENCES accounts(id) ON DELETE RESTRICT;
+
+ALTER TABLE receipts
+  DROP CONSTRAINT IF EXISTS fk_receipts_account,
+  ADD CONSTRAINT fk_receipts_account
+    FOREIGN KEY (account_id)
+    REFERENCES accounts(id) ON DELETE RESTRICT;
+
+ALTER TABLE recurring_transactions
+  DROP CONSTRAINT IF EXISTS fk_recurring_transactions_account,
+  ADD CONSTRAINT fk_recurring_transactions_account
+    FOREIGN KEY (account_id)
+    REFERENCES accounts(id) ON DELETE RESTRICT;
@@ -0,0 +1,33 @@
+-- RESTRICT is checked as soon as an account row is deleted. When a user is
+-- deleted the cascade can reach the accounts before the transactions,
+-- transfers, receipts and recurring templates that point at them, so the
+-- purge failed for every merchant with history. NO ACTION is checked at the
+-- end of the statement, after the cascade has removed those rows too, and
+-- still refuses to delete an account that is in use.
+ALTER TABLE transactions
+  DROP CONSTRAINT IF EXISTS fk_transactions_account,
+  ADD CONSTRAINT fk_transactions_account
+    FOREIGN KEY (account_id)
+    REFERENCES accounts(id) ON DELETE NO ACTION;
+
+ALTER TABLE account_transfers
+  DROP CONSTRAINT IF EXISTS fk_account_transfers_from,
+  ADD CONSTRAINT fk_account_transfers_from
+    FOREIGN KEY (from_account_id)
+    REFERENCES accounts(id) ON DELETE NO ACTION,
+  DROP CONSTRAINT IF EXISTS fk_account_transfers_to,
+  ADD CONSTRAINT fk_account_transfers_to
+    FOREIGN KEY (to_account_id)
+    REFERENCES accounts(id) ON DELETE NO ACTION;
+
+ALTER TABLE receipts
+  DROP CONSTRAINT IF EXISTS fk_receipts_account,
+  ADD CONSTRAINT fk_receipts_account
+    FOREIGN KEY (account_id)
+    REFERENCES accounts(id) ON DELETE NO ACTION;
+
+ALTER TABLE recurring_transactions
+  DROP CONSTRAINT IF EXISTS fk_recurring_transactions_account,
+  ADD CONSTRAINT fk_recurring_transactions_account
+    FOREIGN KEY (account_id)
+    REFERENCES accounts(id) ON DELETE NO ACTION;
@@ -0,0 +1,5 @@
+ALTER TABLE transactions
+  DROP CONSTRAINT IF EXISTS fk_order_transactions,
+  ADD CONSTRAINT fk_order_transactions
+    FOREIGN KEY (order_id)
+    REFERENCES orders(id) ON DELETE CASCADE;
@@ -0,0 +1,7 @@
+-- Cancelling an order voids its transactions, which must stay in the books.
+-- Deleting the order afterwards only unlinks them instead of removing them.
+ALTER TABLE transactions
+  DROP CONSTRAINT IF EXISTS fk_order_transactions,
+  ADD CONSTRAINT fk_order_transactions
+    FOREIGN KEY (order_id)
+    REFERENCES orders(id) ON DELETE SET NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE audit_logs
+  DROP CONSTRAINT IF EXISTS fk_audit_logs_store,
+  ADD CONSTRAINT fk_audit_logs_store
+    FOREIGN KEY (store_id)
+    REFERENCES users(id) ON DELETE CASCADE;
@@ -0,0 +1,7 @@
+-- The audit trail outlives the accounts it describes. Purging an account only
+-- clears store_id; actor_id is plain data and keeps who made each change.
+ALTER TABLE audit_logs
+  DROP CONSTRAINT IF EXISTS fk_audit_logs_store,
+  ADD CONSTRAINT fk_audit_logs_store
+    FOREIGN KEY (store_id)
+    REFERENCES users(id) ON DELETE SET NULL;
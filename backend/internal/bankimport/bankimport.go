@@ -0,0 +1,151 @@
+// Package bankimport reads bank statements exported by internet banking and
+// finance apps into a flat list of mutations.
+package bankimport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	FormatAuto = "auto"
+	FormatCSV  = "csv"
+	FormatOFX  = "ofx"
+	FormatQIF  = "qif"
+)
+
+// MatchWindowDays is how many days a transaction may lie before or after a
+// statement line and still be suggested as its match.
+const MatchWindowDays = 3
+
+var (
+	ErrUnknownFormat = errors.New("unknown statement format")
+	ErrNoLines       = errors.New("statement has no readable lines")
+)
+
+// Line is one mutation on a statement. Date is the calendar date as printed
+// by the bank, at midnight UTC. Amount is positive for money coming in and
+// negative for money going out.
+type Line struct {
+	Date        time.Time
+	Description string
+	Amount      float64
+}
+
+// ValidFormat reports whether format can be passed to Parse.
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatAuto, FormatCSV, FormatOFX, FormatQIF:
+		return true
+	}
+	return false
+}
+
+// DetectFormat picks the format from a file name, falling back to CSV.
+func DetectFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".ofx", ".qfx":
+		return FormatOFX
+	case ".qif":
+		return FormatQIF
+	}
+	return FormatCSV
+}
+
+// Parse reads a statement. now supplies the year for CSV exports that print
+// dates without one and do not state their period.
+func Parse(format string, r io.Reader, now time.Time) ([]Line, error) {
+	var (
+		lines []Line
+		err   error
+	)
+	switch format {
+	case FormatCSV:
+		lines, err = parseCSV(r, now)
+	case FormatOFX:
+		lines, err = parseOFX(r)
+	case FormatQIF:
+		lines, err = parseQIF(r)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, ErrNoLines
+	}
+	return lines, nil
+}
+
+// Fingerprints returns a hash per line from its date, amount and description.
+// Identical lines within one statement, such as two equal purchases on the
+// same day, are told apart by their position among each other, so importing
+// the same statement twice yields the same hashes.
+func Fingerprints(lines []Line) []string {
+	seen := map[string]int{}
+	hashes := make([]string, len(lines))
+	for i, line := range lines {
+		key := fmt.Sprintf("%s|%.2f|%s", line.Date.Format("2006-01-02"), line.Amount, normalize(line.Description))
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d", key, seen[key])))
+		seen[key]++
+		hashes[i] = hex.EncodeToString(sum[:])
+	}
+	return hashes
+}
+
+// Confidence scores a suggested match whose amount equals the line's. Closer
+// dates and descriptions sharing words score higher; the result is between
+// 0.6 and 1.
+func Confidence(description string, days int, candidate string) float64 {
+	days = min(abs(days), MatchWindowDays+1)
+	score := 0.6
+	score += 0.25 * float64(MatchWindowDays+1-days) / float64(MatchWindowDays+1)
+	score += 0.15 * similarity(description, candidate)
+	return math.Round(score*100) / 100
+}
+
+var nonWord = regexp.MustCompile(`[^a-z0-9]+`)
+
+func normalize(s string) string {
+	return strings.TrimSpace(nonWord.ReplaceAllString(strings.ToLower(s), " "))
+}
+
+// similarity is the share of the candidate's words, three letters or longer,
+// that also appear in the description.
+func similarity(description, candidate string) float64 {
+	words := map[string]bool{}
+	for _, word := range strings.Fields(normalize(description)) {
+		words[word] = true
+	}
+
+	total, shared := 0, 0
+	for _, word := range strings.Fields(normalize(candidate)) {
+		if len(word) < 3 {
+			continue
+		}
+		total++
+		if words[word] {
+			shared++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(shared) / float64(total)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
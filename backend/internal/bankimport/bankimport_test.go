@@ -0,0 +1,164 @@
+package bankimport
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"mutasi.csv", FormatCSV},
+		{"statement.OFX", FormatOFX},
+		{"statement.qfx", FormatOFX},
+		{"export.qif", FormatQIF},
+		{"mutasi.txt", FormatCSV},
+		{"mutasi", FormatCSV},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			if got := DetectFormat(tt.filename); got != tt.want {
+				t.Errorf("DetectFormat(%q) = %q, want %q", tt.filename, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	now := date(2026, time.October, 19)
+	tests := []struct {
+		name    string
+		format  string
+		input   string
+		want    []Line
+		wantErr error
+	}{
+		{
+			name:   "csv",
+			format: FormatCSV,
+			input:  "Tanggal,Keterangan,Jumlah\n01/10/2026,Setoran tunai,150000.00\n",
+			want:   []Line{{Date: date(2026, time.October, 1), Description: "Setoran tunai", Amount: 150000}},
+		},
+		{
+			name:    "unknown format",
+			format:  "pdf",
+			input:   "Tanggal,Keterangan,Jumlah\n",
+			wantErr: ErrUnknownFormat,
+		},
+		{
+			name:    "no lines",
+			format:  FormatOFX,
+			input:   "<OFX><BANKTRANLIST></BANKTRANLIST></OFX>",
+			wantErr: ErrNoLines,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.format, strings.NewReader(tt.input), now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFingerprints(t *testing.T) {
+	purchase := Line{Date: date(2026, time.October, 3), Description: "KARTU DEBIT INDOMARET", Amount: -25000}
+
+	tests := []struct {
+		name  string
+		lines []Line
+		diff  [][2]int
+	}{
+		{
+			name:  "identical lines are told apart",
+			lines: []Line{purchase, purchase},
+			diff:  [][2]int{{0, 1}},
+		},
+		{
+			name: "normalised descriptions count as identical",
+			lines: []Line{
+				purchase,
+				{Date: purchase.Date, Description: "Kartu Debit - Indomaret", Amount: purchase.Amount},
+			},
+			diff: [][2]int{{0, 1}},
+		},
+		{
+			name: "amount and date change the hash",
+			lines: []Line{
+				purchase,
+				{Date: purchase.Date, Description: purchase.Description, Amount: -25001},
+				{Date: purchase.Date.AddDate(0, 0, 1), Description: purchase.Description, Amount: purchase.Amount},
+			},
+			diff: [][2]int{{0, 1}, {0, 2}, {1, 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hashes := Fingerprints(tt.lines)
+			if len(hashes) != len(tt.lines) {
+				t.Fatalf("Fingerprints() returned %d hashes for %d lines", len(hashes), len(tt.lines))
+			}
+			for _, pair := range tt.diff {
+				if hashes[pair[0]] == hashes[pair[1]] {
+					t.Errorf("hashes of lines %d and %d are equal", pair[0], pair[1])
+				}
+			}
+
+			// The same statement imported again yields the same hashes.
+			if again := Fingerprints(tt.lines); !reflect.DeepEqual(again, hashes) {
+				t.Errorf("Fingerprints() is not stable: %v, then %v", hashes, again)
+			}
+		})
+	}
+
+	// Descriptions are compared after normalising case and punctuation, so a
+	// bank changing its formatting does not change the hash.
+	first := Fingerprints([]Line{purchase})
+	second := Fingerprints([]Line{{Date: purchase.Date, Description: "Kartu Debit - Indomaret", Amount: purchase.Amount}})
+	if first[0] != second[0] {
+		t.Errorf("normalised descriptions give different hashes: %s and %s", first[0], second[0])
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		days        int
+		candidate   string
+		want        float64
+	}{
+		{"same day, all words shared", "TRSF E-BANKING CR TOKO MAJU", 0, "Toko Maju", 1},
+		{"same day, no words shared", "TRSF E-BANKING CR", 0, "Toko Maju", 0.85},
+		{"same day, two of three words shared", "TOKO MAJU", 0, "toko maju jaya", 0.95},
+		{"one day apart", "SETORAN", 1, "Gaji", 0.79},
+		{"three days before", "SETORAN", -3, "Gaji", 0.66},
+		{"outside the window", "SETORAN", 10, "Gaji", 0.6},
+		{"short candidate words are ignored", "PT AB", 0, "PT AB", 0.85},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Confidence(tt.description, tt.days, tt.candidate)
+			if got != tt.want {
+				t.Errorf("Confidence(%q, %d, %q) = %v, want %v", tt.description, tt.days, tt.candidate, got, tt.want)
+			}
+		})
+	}
+}
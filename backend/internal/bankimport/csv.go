@@ -0,0 +1,344 @@
+package bankimport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Column names used by Indonesian banks (BCA, Mandiri, BNI, BRI, CIMB Niaga
+// and others) and English exports, after normalizeHeader.
+var (
+	dateHeaders = []string{
+		"tanggal", "tanggal transaksi", "tgl", "tgl transaksi", "tanggal posting", "tgl posting",
+		"tanggal valuta", "date", "transaction date", "posting date", "trans date", "value date",
+	}
+	descriptionHeaders = []string{
+		"keterangan", "keterangan transaksi", "deskripsi", "uraian", "uraian transaksi", "detail",
+		"description", "transaction description", "remark", "remarks", "narrative", "details",
+	}
+	debitHeaders  = []string{"debit", "debet", "mutasi debit", "mutasi debet", "withdrawal", "withdrawals", "pengeluaran", "keluar"}
+	creditHeaders = []string{"kredit", "credit", "mutasi kredit", "deposit", "deposits", "pemasukan", "masuk"}
+	amountHeaders = []string{"jumlah", "amount", "nominal", "mutasi", "nilai"}
+	signHeaders   = []string{"db/cr", "cr/db", "d/k", "k/d", "dk", "d/c", "c/d", "dbcr", "jenis", "tipe", "type"}
+)
+
+// periodPattern finds the first full date in a preamble line such as BCA's
+// "Periode : 01/10/2026 - 31/10/2026", for statements whose rows omit the year.
+var periodPattern = regexp.MustCompile(`\b(\d{1,2})[/-](\d{1,2})[/-](\d{4})\b`)
+
+type csvColumns struct {
+	date, description, debit, credit, amount, sign int
+}
+
+// parseCSV reads a statement with a header row. Rows before the header, such
+// as the account holder and period, and rows after it that have no valid date,
+// such as opening balance and totals, are skipped.
+func parseCSV(r io.Reader, now time.Time) ([]Line, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = detectDelimiter(data)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	year := now.Year()
+	var columns *csvColumns
+	lines := []Line{}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				continue
+			}
+			return nil, err
+		}
+
+		if columns == nil {
+			if m := periodPattern.FindStringSubmatch(strings.Join(record, " ")); m != nil {
+				year, _ = strconv.Atoi(m[3])
+			}
+			columns = findColumns(record)
+			continue
+		}
+
+		line, ok := columns.line(record, year)
+		if ok {
+			lines = append(lines, line)
+		}
+	}
+
+	if columns == nil {
+		return nil, errors.New("statement has no header row")
+	}
+	return lines, nil
+}
+
+func findColumns(record []string) *csvColumns {
+	columns := csvColumns{-1, -1, -1, -1, -1, -1}
+	for i, cell := range record {
+		header := normalizeHeader(cell)
+		switch {
+		case columns.date < 0 && contains(dateHeaders, header):
+			columns.date = i
+		case columns.description < 0 && contains(descriptionHeaders, header):
+			columns.description = i
+		case columns.debit < 0 && contains(debitHeaders, header):
+			columns.debit = i
+		case columns.credit < 0 && contains(creditHeaders, header):
+			columns.credit = i
+		case columns.amount < 0 && contains(amountHeaders, header):
+			columns.amount = i
+		case columns.sign < 0 && contains(signHeaders, header):
+			columns.sign = i
+		}
+	}
+
+	if columns.date < 0 || (columns.amount < 0 && (columns.debit < 0 || columns.credit < 0)) {
+		return nil
+	}
+	return &columns
+}
+
+func (c *csvColumns) line(record []string, year int) (Line, bool) {
+	date, ok := parseDate(cell(record, c.date), year)
+	if !ok {
+		return Line{}, false
+	}
+
+	var amount float64
+	if c.debit >= 0 && c.credit >= 0 {
+		debit, debitOK := parseAmount(cell(record, c.debit))
+		credit, creditOK := parseAmount(cell(record, c.credit))
+		if !debitOK && !creditOK {
+			return Line{}, false
+		}
+		amount = abs64(credit) - abs64(debit)
+	} else {
+		value := cell(record, c.amount)
+		parsed, ok := parseAmount(value)
+		if !ok {
+			return Line{}, false
+		}
+		amount = parsed
+
+		// BCA puts CR or DB in the unnamed column after the amount.
+		sign := cell(record, c.sign)
+		if c.sign < 0 {
+			sign = cell(record, c.amount+1)
+		}
+		switch direction(sign) {
+		case 1:
+			amount = abs64(amount)
+		case -1:
+			amount = -abs64(amount)
+		}
+	}
+
+	if amount == 0 {
+		return Line{}, false
+	}
+
+	return Line{
+		Date:        date,
+		Description: strings.Join(strings.Fields(cell(record, c.description)), " "),
+		Amount:      amount,
+	}, true
+}
+
+func detectDelimiter(data []byte) rune {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	counts := map[rune]int{',': 0, ';': 0, '\t': 0}
+	for i := 0; i < 20 && scanner.Scan(); i++ {
+		for delimiter := range counts {
+			counts[delimiter] += strings.Count(scanner.Text(), string(delimiter))
+		}
+	}
+
+	best := ','
+	for _, delimiter := range []rune{';', '\t'} {
+		if counts[delimiter] > counts[best] {
+			best = delimiter
+		}
+	}
+	return best
+}
+
+func normalizeHeader(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.NewReplacer(".", "", ":", "", "(idr)", "", "(rp)", "", "rp", "").Replace(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func cell(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(record[i]), "'")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// direction reads a debit/credit marker: 1 for money in, -1 for money out and
+// 0 when s is not a marker.
+func direction(s string) int {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "CR", "C", "K", "KR", "KREDIT", "CREDIT":
+		return 1
+	case "DB", "D", "DR", "DEBIT", "DEBET":
+		return -1
+	}
+	return 0
+}
+
+var indonesianMonths = strings.NewReplacer(
+	"januari", "jan", "februari", "feb", "pebruari", "feb", "maret", "mar", "april", "apr",
+	"mei", "may", "juni", "jun", "juli", "jul", "agustus", "aug", "agu", "aug", "agt", "aug",
+	"september", "sep", "oktober", "oct", "okt", "oct", "november", "nov", "desember", "dec", "des", "dec",
+)
+
+// dateLayouts are tried in order. Numeric dates are day first, as Indonesian
+// banks print them.
+var dateLayouts = []string{
+	"02/01/2006", "2/1/2006", "02-01-2006", "2-1-2006", "02.01.2006",
+	"2006-01-02", "2006/01/02", "02/01/06", "2/1/06", "02-01-06",
+	"02 Jan 2006", "2 Jan 2006", "02-Jan-2006", "2-Jan-2006", "02 Jan 06", "02-Jan-06",
+	"20060102",
+}
+
+// parseDate reads a statement date, ignoring a trailing time. Dates without a
+// year, such as BCA's "01/10", get year.
+func parseDate(s string, year int) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+
+	lower := strings.ToLower(s)
+	candidates := []string{indonesianMonths.Replace(lower)}
+	if fields := strings.Fields(candidates[0]); len(fields) > 1 {
+		// A trailing time, or the date part of "02 Jan 2006 10:15".
+		candidates = append(candidates, fields[0])
+		if len(fields) > 3 {
+			candidates = append(candidates, strings.Join(fields[:3], " "))
+		}
+	}
+
+	for _, candidate := range candidates {
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, titleMonth(candidate)); err == nil {
+				return t, true
+			}
+		}
+		for _, layout := range []string{"02/01", "2/1", "02-01"} {
+			if t, err := time.Parse(layout, candidate); err == nil {
+				return time.Date(year, t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// titleMonth capitalises month abbreviations so time.Parse accepts them.
+func titleMonth(s string) string {
+	b := []byte(s)
+	for i := range b {
+		if b[i] >= 'a' && b[i] <= 'z' && (i == 0 || !isLetter(b[i-1])) {
+			b[i] -= 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// parseAmount reads an amount written with either Indonesian (1.500.000,00)
+// or English (1,500,000.00) separators, with an optional currency, minus
+// sign, parentheses or trailing CR/DB marker.
+func parseAmount(s string) (float64, bool) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.NewReplacer("RP", "", "IDR", "", " ", "", "'", "").Replace(s)
+	if s == "" || s == "-" {
+		return 0, false
+	}
+
+	sign := 1.0
+	for _, marker := range []string{"CR", "DB", "DR", "D", "K", "C"} {
+		if strings.HasSuffix(s, marker) && len(s) > len(marker) {
+			sign = float64(direction(marker))
+			s = strings.TrimSuffix(s, marker)
+			break
+		}
+	}
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		sign, s = -1, strings.Trim(s, "()")
+	}
+	if strings.HasPrefix(s, "-") {
+		sign, s = -sign, s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+
+	lastDot, lastComma := strings.LastIndex(s, "."), strings.LastIndex(s, ",")
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		if lastComma > lastDot {
+			s = strings.ReplaceAll(s, ".", "")
+			s = strings.Replace(s, ",", ".", 1)
+		} else {
+			s = strings.ReplaceAll(s, ",", "")
+		}
+	case lastComma >= 0:
+		s = decimalOrThousands(s, ",")
+	case lastDot >= 0:
+		s = decimalOrThousands(s, ".")
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return sign * value, true
+}
+
+// decimalOrThousands treats sep as a thousands separator when it occurs more
+// than once or is followed by exactly three digits, and as the decimal point
+// otherwise.
+func decimalOrThousands(s, sep string) string {
+	if strings.Count(s, sep) > 1 || len(s)-strings.LastIndex(s, sep)-1 == 3 {
+		return strings.ReplaceAll(s, sep, "")
+	}
+	return strings.Replace(s, sep, ".", 1)
+}
+
+func abs64(f float64) float64 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
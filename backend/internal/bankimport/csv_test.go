@@ -0,0 +1,150 @@
+package bankimport
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const bcaStatement = `No. Rekening,:,'1234567890
+Nama,:,TOKO SEJAHTERA
+Periode,:,01/10/2025 - 31/10/2025
+Kode Mata Uang,:,Rp
+
+Tanggal Transaksi,Keterangan,Cabang,Jumlah,,Saldo
+'01/10,'TRSF E-BANKING CR 0110/FTSCY/WS95031 TOKO MAJU,'0000,150000.00,CR,1150000.00
+'03/10,'KARTU DEBIT INDOMARET,'0998,25000.00,DB,1125000.00
+'03/10,'BIAYA ADM,'0000,0.00,DB,1125000.00
+Saldo Awal,:,1000000.00
+Mutasi Kredit,:,150000.00,1
+Mutasi Debet,:,25000.00,1
+Saldo Akhir,:,1125000.00
+`
+
+const mandiriStatement = "\ufeffTanggal;Keterangan;Debit;Kredit;Saldo\n" +
+	"02/10/2026;Transfer dari  ANDI WIJAYA;0,00;1.500.000,00;2.500.000,00\n" +
+	"05/10/2026 14:32;Pembayaran PLN Prabayar;250.000,00;0,00;2.250.000,00\n" +
+	"Saldo Akhir;;;;2.250.000,00\n"
+
+func TestParseCSV(t *testing.T) {
+	now := date(2026, time.October, 19)
+	tests := []struct {
+		name    string
+		input   string
+		want    []Line
+		wantErr bool
+	}{
+		{
+			name:  "bca with year from the period",
+			input: bcaStatement,
+			want: []Line{
+				{Date: date(2025, time.October, 1), Description: "TRSF E-BANKING CR 0110/FTSCY/WS95031 TOKO MAJU", Amount: 150000},
+				{Date: date(2025, time.October, 3), Description: "KARTU DEBIT INDOMARET", Amount: -25000},
+			},
+		},
+		{
+			name:  "mandiri with debit and credit columns",
+			input: mandiriStatement,
+			want: []Line{
+				{Date: date(2026, time.October, 2), Description: "Transfer dari ANDI WIJAYA", Amount: 1500000},
+				{Date: date(2026, time.October, 5), Description: "Pembayaran PLN Prabayar", Amount: -250000},
+			},
+		},
+		{
+			name:  "sign column",
+			input: "Date\tDescription\tAmount\tDB/CR\n2026-10-07\tSalary\t5,000,000.00\tCR\n2026-10-08\tRent\t2,000,000.00\tDB\n",
+			want: []Line{
+				{Date: date(2026, time.October, 7), Description: "Salary", Amount: 5000000},
+				{Date: date(2026, time.October, 8), Description: "Rent", Amount: -2000000},
+			},
+		},
+		{
+			name:  "year missing from rows and period",
+			input: "Tanggal,Keterangan,Jumlah\n15/09,Setoran,50.000\n",
+			want:  []Line{{Date: date(2026, time.September, 15), Description: "Setoran", Amount: 50000}},
+		},
+		{
+			name:    "no header row",
+			input:   "01/10/2026,Setoran,50000\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCSV(strings.NewReader(tt.input), now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCSV() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCSV() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   float64
+		wantOK bool
+	}{
+		{"1.500.000,00", 1500000, true},
+		{"1,500,000.00", 1500000, true},
+		{"150000.00", 150000, true},
+		{"Rp 25.000", 25000, true},
+		{"IDR 12,5", 12.5, true},
+		{"-12.500", -12500, true},
+		{"(75.000)", -75000, true},
+		{"25.000 DB", -25000, true},
+		{"150.000,00 CR", 150000, true},
+		{"+1.250", 1250, true},
+		{"0,00", 0, true},
+		{"", 0, false},
+		{"-", 0, false},
+		{"abc", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := parseAmount(tt.input)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseAmount(%q) = %v, %v, want %v, %v", tt.input, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   time.Time
+		wantOK bool
+	}{
+		{"01/10/2026", date(2026, time.October, 1), true},
+		{"1/10/2026", date(2026, time.October, 1), true},
+		{"2026-10-01", date(2026, time.October, 1), true},
+		{"01-10-26", date(2026, time.October, 1), true},
+		{"01 Okt 2026", date(2026, time.October, 1), true},
+		{"1 Agustus 2026", date(2026, time.August, 1), true},
+		{"17-Des-2026", date(2026, time.December, 17), true},
+		{"05/10/2026 14:32", date(2026, time.October, 5), true},
+		{"20261001", date(2026, time.October, 1), true},
+		{"01/10", date(2025, time.October, 1), true},
+		{"Saldo Awal", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := parseDate(tt.input, 2025)
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Errorf("parseDate(%q) = %v, %v, want %v, %v", tt.input, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
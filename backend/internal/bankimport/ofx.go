@@ -0,0 +1,80 @@
+package bankimport
+
+import (
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ofxTransaction = regexp.MustCompile(`(?i)<STMTTRN>`)
+	ofxEnd         = regexp.MustCompile(`(?i)</STMTTRN>|</BANKTRANLIST>`)
+	ofxField       = regexp.MustCompile(`(?i)<(DTPOSTED|TRNAMT|NAME|MEMO)>([^<\r\n]*)`)
+)
+
+// parseOFX reads the transactions of an OFX or QFX file. Both the SGML form
+// of OFX 1.x, where elements are not closed, and the XML form of OFX 2.x are
+// accepted.
+func parseOFX(r io.Reader) ([]Line, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []Line{}
+	blocks := ofxTransaction.Split(string(data), -1)
+	for _, block := range blocks[1:] {
+		if end := ofxEnd.FindStringIndex(block); end != nil {
+			block = block[:end[0]]
+		}
+
+		fields := map[string]string{}
+		for _, field := range ofxField.FindAllStringSubmatch(block, -1) {
+			fields[strings.ToUpper(field[1])] = strings.TrimSpace(field[2])
+		}
+
+		// DTPOSTED is YYYYMMDD, optionally followed by a time and zone.
+		posted := fields["DTPOSTED"]
+		if len(posted) < 8 {
+			continue
+		}
+		date, err := time.Parse("20060102", posted[:8])
+		if err != nil {
+			continue
+		}
+
+		amount, ok := parseOFXAmount(fields["TRNAMT"])
+		if !ok || amount == 0 {
+			continue
+		}
+
+		description := fields["NAME"]
+		if memo := fields["MEMO"]; memo != "" && memo != description {
+			description = strings.TrimSpace(description + " " + memo)
+		}
+
+		lines = append(lines, Line{
+			Date:        date,
+			Description: unescapeOFX(description),
+			Amount:      amount,
+		})
+	}
+	return lines, nil
+}
+
+// parseOFXAmount reads TRNAMT. OFX amounts have no thousands separator and a
+// period or comma as the decimal point, so unlike parseAmount "-12.500" is
+// twelve and a half.
+func parseOFXAmount(s string) (float64, bool) {
+	amount, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(s), ",", ".", 1), 64)
+	if err != nil {
+		return 0, false
+	}
+	return amount, true
+}
+
+func unescapeOFX(s string) string {
+	return strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'").Replace(s)
+}
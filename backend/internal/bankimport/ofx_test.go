@@ -0,0 +1,138 @@
+package bankimport
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const ofxSGMLStatement = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>IDR
+<BANKTRANLIST>
+<DTSTART>20261001
+<DTEND>20261031
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20261001120000[+7:WIB]
+<TRNAMT>1500000.00
+<FITID>2026100101
+<NAME>TRANSFER ANDI
+<MEMO>Pelunasan pesanan
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20261003
+<TRNAMT>-12.500
+<FITID>2026100301
+<NAME>KOPI &amp; ROTI
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20261004
+<TRNAMT>0.00
+<NAME>BIAYA ADM
+</BANKTRANLIST>
+<LEDGERBAL><BALAMT>1487500.00<DTASOF>20261031</LEDGERBAL>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+const ofxXMLStatement = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220"?>
+<OFX>
+  <BANKMSGSRSV1><STMTTRNRS><STMTRS>
+    <BANKTRANLIST>
+      <STMTTRN>
+        <TRNTYPE>DEBIT</TRNTYPE>
+        <DTPOSTED>20261005</DTPOSTED>
+        <TRNAMT>-250000,50</TRNAMT>
+        <NAME>PLN PRABAYAR</NAME>
+        <MEMO>PLN PRABAYAR</MEMO>
+      </STMTTRN>
+      <STMTTRN>
+        <TRNTYPE>DEBIT</TRNTYPE>
+        <DTPOSTED>2026</DTPOSTED>
+        <TRNAMT>-1000</TRNAMT>
+        <NAME>BROKEN DATE</NAME>
+      </STMTTRN>
+      <STMTTRN>
+        <TRNTYPE>CREDIT</TRNTYPE>
+        <DTPOSTED>20261006</DTPOSTED>
+        <TRNAMT>1.500.000</TRNAMT>
+        <NAME>BROKEN AMOUNT</NAME>
+      </STMTTRN>
+    </BANKTRANLIST>
+  </STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+func TestParseOFX(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Line
+	}{
+		{
+			name:  "sgml",
+			input: ofxSGMLStatement,
+			want: []Line{
+				{Date: date(2026, time.October, 1), Description: "TRANSFER ANDI Pelunasan pesanan", Amount: 1500000},
+				{Date: date(2026, time.October, 3), Description: "KOPI & ROTI", Amount: -12.5},
+			},
+		},
+		{
+			name:  "xml",
+			input: ofxXMLStatement,
+			want: []Line{
+				{Date: date(2026, time.October, 5), Description: "PLN PRABAYAR", Amount: -250000.5},
+			},
+		},
+		{
+			name:  "no transactions",
+			input: "<OFX><BANKTRANLIST></BANKTRANLIST></OFX>",
+			want:  []Line{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseOFX(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("parseOFX() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseOFX() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOFXAmount(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   float64
+		wantOK bool
+	}{
+		{"-12.500", -12.5, true},
+		{"1500000.00", 1500000, true},
+		{"+25.75", 25.75, true},
+		{"-250000,50", -250000.5, true},
+		{" 100 ", 100, true},
+		{"1.500.000", 0, false},
+		{"1,500.00", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := parseOFXAmount(tt.input)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseOFXAmount(%q) = %v, %v, want %v, %v", tt.input, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package bankimport
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// parseQIF reads the transactions of a QIF file. Records end with "^"; the
+// D, T (or U), P and M fields are used. Dates are read day first like the
+// CSV exports, with QIF's apostrophe before two-digit years accepted.
+func parseQIF(r io.Reader) ([]Line, error) {
+	lines := []Line{}
+	var (
+		line       Line
+		dateOK     bool
+		amountOK   bool
+		payee      string
+		memo       string
+		hasContent bool
+	)
+
+	flush := func() {
+		if dateOK && amountOK && line.Amount != 0 {
+			line.Description = strings.TrimSpace(payee + " " + memo)
+			if memo == payee {
+				line.Description = payee
+			}
+			lines = append(lines, line)
+		}
+		line, dateOK, amountOK, payee, memo, hasContent = Line{}, false, false, "", "", false
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		text := strings.TrimRight(strings.TrimPrefix(scanner.Text(), "\ufeff"), "\r")
+		if text == "" || strings.HasPrefix(text, "!") {
+			continue
+		}
+
+		value := strings.TrimSpace(text[1:])
+		switch text[0] {
+		case '^':
+			flush()
+			continue
+		case 'D':
+			line.Date, dateOK = parseDate(strings.ReplaceAll(value, "'", "/"), 0)
+		case 'T', 'U':
+			if !amountOK {
+				line.Amount, amountOK = parseAmount(value)
+			}
+		case 'P':
+			payee = value
+		case 'M':
+			memo = value
+		}
+		hasContent = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// The last record may be missing its terminator.
+	if hasContent {
+		flush()
+	}
+	return lines, nil
+}
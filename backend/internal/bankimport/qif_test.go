@@ -0,0 +1,69 @@
+package bankimport
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const qifStatement = `!Type:Bank
+D01/10'26
+T-25,000.00
+PINDOMARET
+MBelanja kantor
+^
+D05/10/2026
+U1,500,000.00
+T1,500,000.00
+PGaji
+MGaji
+^
+D06/10/2026
+T0.00
+PBiaya admin
+^
+D07/10/2026
+T-75.000
+PParkir
+`
+
+func TestParseQIF(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Line
+	}{
+		{
+			name:  "bank",
+			input: qifStatement,
+			want: []Line{
+				{Date: date(2026, time.October, 1), Description: "INDOMARET Belanja kantor", Amount: -25000},
+				{Date: date(2026, time.October, 5), Description: "Gaji", Amount: 1500000},
+				{Date: date(2026, time.October, 7), Description: "Parkir", Amount: -75000},
+			},
+		},
+		{
+			name:  "crlf line endings",
+			input: "!Type:Bank\r\nD02/10/2026\r\nT50.000\r\nPSetoran\r\n^\r\n",
+			want:  []Line{{Date: date(2026, time.October, 2), Description: "Setoran", Amount: 50000}},
+		},
+		{
+			name:  "record without a date",
+			input: "!Type:Bank\nT50.000\nPSetoran\n^\n",
+			want:  []Line{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseQIF(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("parseQIF() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseQIF() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
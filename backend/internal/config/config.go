@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -14,10 +16,20 @@ type Config struct {
 	Port                string
 	DSN                 string
 	JWTSecret           string
+	JWTLegacyUntil      time.Time
+	JWTKeysDir          string
+	JWTActiveKid        string
+	JWTEphemeralKey     bool
+	TOTPEncryptionKey   string
 	KolosalApiKey       string
+	KolosalBaseURL      string
+	OCRProviders        []string
+	OCRFixturesDir      string
 	CloudinaryName      string
 	CloudinaryApiKey    string
 	CLoudinaryApiSecret string
+	DeletionGraceDays   int
+	ReceiptWorkers      int
 }
 
 func Load() Config {
@@ -31,13 +43,63 @@ func Load() Config {
 		Port:                os.Getenv("PORT"),
 		DSN:                 os.Getenv("DSN"),
 		JWTSecret:           os.Getenv("JWT_SECRET"),
+		JWTLegacyUntil:      getEnvTime("JWT_LEGACY_UNTIL"),
+		JWTKeysDir:          os.Getenv("JWT_KEYS_DIR"),
+		JWTActiveKid:        os.Getenv("JWT_ACTIVE_KID"),
+		JWTEphemeralKey:     getEnvBool("JWT_EPHEMERAL_KEY", false),
+		TOTPEncryptionKey:   os.Getenv("TOTP_ENCRYPTION_KEY"),
 		KolosalApiKey:       os.Getenv("KOLOSAL_API_KEY"),
+		KolosalBaseURL:      os.Getenv("KOLOSAL_BASE_URL"),
+		OCRProviders:        getEnvList("OCR_PROVIDERS", "kolosal"),
+		OCRFixturesDir:      getEnvString("OCR_FIXTURES_DIR", "fixtures/ocr"),
 		CloudinaryName:      os.Getenv("CLOUDINARY_NAME"),
 		CloudinaryApiKey:    os.Getenv("CLOUDINARY_API_KEY"),
 		CLoudinaryApiSecret: os.Getenv("CLOUDINARY_API_SECRET"),
+		DeletionGraceDays:   getEnvInt("ACCOUNT_DELETION_GRACE_DAYS", 14),
+		ReceiptWorkers:      getEnvInt("RECEIPT_WORKERS", 4),
 	}
 }
 
+func getEnvInt(key string, fallback int) int {
+	val := os.Getenv(key)
+	if val == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %v", key, err)
+	}
+	return n
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	val := os.Getenv(key)
+	if val == "" {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %v", key, err)
+	}
+	return b
+}
+
+// getEnvTime parses an RFC 3339 timestamp, returning the zero time when unset.
+func getEnvTime(key string) time.Time {
+	val := os.Getenv(key)
+	if val == "" {
+		return time.Time{}
+	}
+
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %v", key, err)
+	}
+	return t
+}
+
 func ConnectDB(dsn string) *sql.DB {
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
@@ -56,3 +118,21 @@ func ConnectDB(dsn string) *sql.DB {
 	log.Println("Connected to database!!")
 	return db
 }
+
+func getEnvString(key string, fallback string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return fallback
+}
+
+// getEnvList splits a comma separated value, dropping empty entries.
+func getEnvList(key string, fallback string) []string {
+	list := []string{}
+	for _, val := range strings.Split(getEnvString(key, fallback), ",") {
+		if val = strings.TrimSpace(val); val != "" {
+			list = append(list, val)
+		}
+	}
+	return list
+}
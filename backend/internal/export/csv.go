@@ -0,0 +1,82 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type csvWriter struct {
+	csv     *csv.Writer
+	columns []Column
+	loc     *time.Location
+	record  []string
+}
+
+func newCSVWriter(w io.Writer, columns []Column, loc *time.Location) (*csvWriter, error) {
+	// The byte order mark makes Excel read the file as UTF-8.
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return nil, err
+	}
+
+	cw := &csvWriter{
+		csv:     csv.NewWriter(w),
+		columns: columns,
+		loc:     loc,
+		record:  make([]string, len(columns)),
+	}
+
+	for i, column := range columns {
+		cw.record[i] = column.Title
+	}
+	if err := cw.csv.Write(cw.record); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (w *csvWriter) WriteRow(values ...any) error {
+	for i := range w.columns {
+		w.record[i] = ""
+		if i < len(values) {
+			w.record[i] = w.format(w.columns[i].Kind, deref(values[i]))
+		}
+	}
+
+	if err := w.csv.Write(w.record); err != nil {
+		return err
+	}
+	// Flush every row so the client receives data while the query runs.
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func (w *csvWriter) Close() error {
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func (w *csvWriter) format(kind Kind, value any) string {
+	if value == nil {
+		return ""
+	}
+
+	if t, ok := value.(time.Time); ok {
+		t = t.In(w.loc)
+		if kind == Date {
+			return t.Format("2006-01-02")
+		}
+		return t.Format("2006-01-02 15:04:05")
+	}
+
+	if n, ok := toFloat(value); ok {
+		if kind == Money {
+			return FormatRupiah(n)
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+
+	return fmt.Sprint(value)
+}
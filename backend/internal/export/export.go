@@ -0,0 +1,139 @@
+// Package export writes tabular data as CSV or XLSX one row at a time, so
+// large exports are streamed to the client instead of held in memory.
+package export
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Kind decides how a column is formatted.
+type Kind int
+
+const (
+	Text Kind = iota
+	Number
+	Money
+	Date
+	DateTime
+)
+
+type Column struct {
+	Title string
+	Kind  Kind
+}
+
+// Writer writes the header on creation and one row per WriteRow call. Values
+// may be strings, numbers, times, their pointers or nil for an empty cell.
+// Close must be called to finish the file.
+type Writer interface {
+	WriteRow(values ...any) error
+	Close() error
+}
+
+// NewWriter returns a writer for format. Times are written in loc.
+func NewWriter(format string, w io.Writer, sheet string, columns []Column, loc *time.Location) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, columns, loc)
+	case FormatXLSX:
+		return newXLSXWriter(w, sheet, columns, loc)
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+func ValidFormat(format string) bool {
+	return format == FormatCSV || format == FormatXLSX
+}
+
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// FormatRupiah formats an amount the Indonesian way, e.g. Rp 1.250.000 or
+// -Rp 12.500,50. Cents are only shown when there are any.
+func FormatRupiah(amount float64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	cents := int64(math.Round(amount * 100))
+	whole := strconv.FormatInt(cents/100, 10)
+
+	var b strings.Builder
+	b.WriteString(sign)
+	b.WriteString("Rp ")
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(digit)
+	}
+	if cents%100 != 0 {
+		fmt.Fprintf(&b, ",%02d", cents%100)
+	}
+	return b.String()
+}
+
+// deref unwraps the pointer values rows are usually scanned into. It returns
+// nil for nil pointers.
+func deref(value any) any {
+	switch v := value.(type) {
+	case *string:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *float64:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *int:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return *v
+	}
+	return value
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	}
+	return 0, false
+}
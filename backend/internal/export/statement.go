@@ -0,0 +1,260 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/go-pdf/fpdf"
+)
+
+var monthNames = [...]string{
+	"Januari", "Februari", "Maret", "April", "Mei", "Juni",
+	"Juli", "Agustus", "September", "Oktober", "November", "Desember",
+}
+
+// Brand colours of the statement.
+var (
+	brandColor = [3]int{37, 99, 235}
+	mutedColor = [3]int{100, 116, 139}
+	lightColor = [3]int{241, 245, 249}
+)
+
+type statementColumn struct {
+	title string
+	width float64
+	align string
+}
+
+// statementPDF wraps fpdf with the layout helpers of the statement. The core
+// fonts only cover cp1252, so every text goes through tr.
+type statementPDF struct {
+	*fpdf.Fpdf
+	tr func(string) string
+}
+
+// WriteStatementPDF renders the monthly financial statement. Times are shown
+// in loc.
+func WriteStatementPDF(w io.Writer, statement models.FinancialStatement, loc *time.Location) error {
+	pdf := &statementPDF{Fpdf: fpdf.New("P", "mm", "A4", "")}
+	pdf.tr = pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 18)
+	pdf.AliasNbPages("")
+	pdf.SetTitle(pdf.tr("Laporan Keuangan "+statement.StoreName), false)
+	pdf.SetCreator("Imphnen", false)
+
+	generated := fmt.Sprintf("Dibuat %s (%s)", statement.GeneratedAt.In(loc).Format("02/01/2006 15:04"), loc.String())
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetTextColor(mutedColor[0], mutedColor[1], mutedColor[2])
+		pdf.CellFormat(90, 5, pdf.tr(generated), "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 5, fmt.Sprintf("Halaman %d dari {nb}", pdf.PageNo()), "", 0, "R", false, 0, "")
+	})
+
+	pdf.AddPage()
+	pdf.header(statement)
+	pdf.summary(statement.Stats)
+
+	pdf.section("Arus Kas Harian")
+	pdf.dailyCashflow(statement.Days, statement.Stats)
+
+	pdf.section("Pengeluaran Terbesar per Kategori")
+	pdf.topExpenses(statement.TopExpenses)
+
+	pdf.section("Daftar Struk sebagai Bukti")
+	pdf.receipts(statement.Receipts, loc)
+
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
+
+func (pdf *statementPDF) header(statement models.FinancialStatement) {
+	pdf.SetFillColor(brandColor[0], brandColor[1], brandColor[2])
+	pdf.Rect(0, 0, 210, 38, "F")
+
+	pdf.SetTextColor(255, 255, 255)
+	pdf.SetXY(15, 9)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(0, 5, "IMPHNEN", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 9, pdf.tr(statement.StoreName), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 11)
+	period := fmt.Sprintf("Laporan Keuangan Bulanan - %s %d", monthNames[statement.Month.Month()-1], statement.Month.Year())
+	pdf.CellFormat(0, 6, pdf.tr(period), "", 1, "L", false, 0, "")
+
+	pdf.SetY(46)
+}
+
+func (pdf *statementPDF) summary(stats models.TransactionStats) {
+	boxes := []struct {
+		label string
+		value string
+	}{
+		{"Total Pemasukan", FormatRupiah(stats.TotalIncome)},
+		{"Total Pengeluaran", FormatRupiah(stats.TotalExpense)},
+		{"Arus Kas Bersih", FormatRupiah(stats.NetAmount)},
+		{"Jumlah Transaksi", fmt.Sprint(stats.TransactionCount)},
+	}
+
+	const gap = 4.0
+	width := (180 - gap*float64(len(boxes)-1)) / float64(len(boxes))
+	y := pdf.GetY()
+	for i, box := range boxes {
+		x := 15 + float64(i)*(width+gap)
+		pdf.SetFillColor(lightColor[0], lightColor[1], lightColor[2])
+		pdf.Rect(x, y, width, 20, "F")
+
+		pdf.SetXY(x+3, y+3)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetTextColor(mutedColor[0], mutedColor[1], mutedColor[2])
+		pdf.CellFormat(width-6, 4, box.label, "", 2, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "B", 11)
+		pdf.SetTextColor(15, 23, 42)
+		pdf.CellFormat(width-6, 8, box.value, "", 0, "L", false, 0, "")
+	}
+	pdf.SetY(y + 26)
+}
+
+func (pdf *statementPDF) section(title string) {
+	if pdf.GetY() > 250 {
+		pdf.AddPage()
+	}
+	pdf.Ln(2)
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.SetTextColor(brandColor[0], brandColor[1], brandColor[2])
+	pdf.CellFormat(0, 8, title, "", 1, "L", false, 0, "")
+}
+
+// table draws rows under a header that is repeated after every page break.
+// links, when given, holds a link for the last cell of each row.
+func (pdf *statementPDF) table(columns []statementColumn, rows [][]string, links []string, footer []string) {
+	drawHeader := func() {
+		pdf.SetFont("Helvetica", "B", 9)
+		pdf.SetFillColor(brandColor[0], brandColor[1], brandColor[2])
+		pdf.SetTextColor(255, 255, 255)
+		for _, column := range columns {
+			pdf.CellFormat(column.width, 7, column.title, "", 0, column.align, true, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	drawRow := func(row []string, link string, style string, fill bool) {
+		_, pageHeight := pdf.GetPageSize()
+		_, _, _, bottom := pdf.GetMargins()
+		if pdf.GetY()+6 > pageHeight-bottom {
+			pdf.AddPage()
+			drawHeader()
+		}
+
+		pdf.SetFont("Helvetica", style, 9)
+		pdf.SetTextColor(15, 23, 42)
+		pdf.SetFillColor(lightColor[0], lightColor[1], lightColor[2])
+		for i, column := range columns {
+			if i == len(columns)-1 && link != "" {
+				pdf.SetTextColor(brandColor[0], brandColor[1], brandColor[2])
+			}
+			pdf.CellFormat(column.width, 6, pdf.tr(row[i]), "B", 0, column.align, fill, 0, link)
+		}
+		pdf.Ln(-1)
+	}
+
+	drawHeader()
+	if len(rows) == 0 {
+		pdf.SetFont("Helvetica", "I", 9)
+		pdf.SetTextColor(mutedColor[0], mutedColor[1], mutedColor[2])
+		pdf.CellFormat(0, 7, "Tidak ada data pada periode ini", "", 1, "C", false, 0, "")
+		return
+	}
+
+	for i, row := range rows {
+		link := ""
+		if i < len(links) {
+			link = links[i]
+		}
+		drawRow(row, link, "", i%2 == 1)
+	}
+	if footer != nil {
+		drawRow(footer, "", "B", true)
+	}
+}
+
+func (pdf *statementPDF) dailyCashflow(days []models.CashflowBucket, stats models.TransactionStats) {
+	columns := []statementColumn{
+		{"Tanggal", 36, "L"},
+		{"Pemasukan", 40, "R"},
+		{"Pengeluaran", 40, "R"},
+		{"Bersih", 40, "R"},
+		{"Transaksi", 24, "R"},
+	}
+
+	rows := make([][]string, 0, len(days))
+	for _, day := range days {
+		rows = append(rows, []string{
+			day.PeriodStart.Format("02/01/2006"),
+			FormatRupiah(day.Income),
+			FormatRupiah(day.Expense),
+			FormatRupiah(day.Net),
+			fmt.Sprint(day.TransactionCount),
+		})
+	}
+
+	footer := []string{
+		"Total",
+		FormatRupiah(stats.TotalIncome),
+		FormatRupiah(stats.TotalExpense),
+		FormatRupiah(stats.NetAmount),
+		fmt.Sprint(stats.TransactionCount),
+	}
+	pdf.table(columns, rows, nil, footer)
+}
+
+func (pdf *statementPDF) topExpenses(categories []models.CategoryStats) {
+	columns := []statementColumn{
+		{"Kategori", 80, "L"},
+		{"Jumlah", 45, "R"},
+		{"Transaksi", 25, "R"},
+		{"Porsi", 30, "R"},
+	}
+
+	rows := make([][]string, 0, len(categories))
+	for _, category := range categories {
+		rows = append(rows, []string{
+			category.CategoryName,
+			FormatRupiah(category.TotalAmount),
+			fmt.Sprint(category.TransactionCount),
+			fmt.Sprintf("%.2f%%", category.Percentage),
+		})
+	}
+	pdf.table(columns, rows, nil, nil)
+}
+
+func (pdf *statementPDF) receipts(receipts []models.StatementReceipt, loc *time.Location) {
+	columns := []statementColumn{
+		{"Tanggal", 28, "L"},
+		{"Toko", 92, "L"},
+		{"Total", 40, "R"},
+		{"Bukti", 20, "C"},
+	}
+
+	rows := make([][]string, 0, len(receipts))
+	links := make([]string, 0, len(receipts))
+	for _, receipt := range receipts {
+		view := "-"
+		if receipt.ImageURL != "" {
+			view = "Lihat"
+		}
+		rows = append(rows, []string{
+			receipt.InvoiceDate.In(loc).Format("02/01/2006"),
+			receipt.StoreName,
+			FormatRupiah(receipt.Total),
+			view,
+		})
+		links = append(links, receipt.ImageURL)
+	}
+	pdf.table(columns, rows, links, nil)
+}
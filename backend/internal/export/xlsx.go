@@ -0,0 +1,229 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cell styles defined in xlsxStyles, by index.
+const (
+	styleDefault = iota
+	styleMoney
+	styleDate
+	styleDateTime
+	styleHeader
+)
+
+// excelEpoch is day zero of the 1900 date system, shifted by Excel's leap
+// year bug so serials after February 1900 come out right.
+var excelEpoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxWriter writes a workbook with a single worksheet. The static parts are
+// written up front and the worksheet is the last zip entry, so rows go
+// straight to the client. Strings are stored inline to avoid keeping a shared
+// string table in memory.
+type xlsxWriter struct {
+	archive *zip.Writer
+	sheet   *bufio.Writer
+	columns []Column
+	loc     *time.Location
+	row     int
+}
+
+func newXLSXWriter(w io.Writer, sheet string, columns []Column, loc *time.Location) (*xlsxWriter, error) {
+	archive := zip.NewWriter(w)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", strings.Replace(xlsxWorkbook, "{sheet}", xmlEscape(sheetName(sheet)), 1)},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, part := range parts {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(file, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	xw := &xlsxWriter{
+		archive: archive,
+		sheet:   bufio.NewWriter(file),
+		columns: columns,
+		loc:     loc,
+	}
+
+	xw.sheet.WriteString(xml.Header)
+	xw.sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	xw.sheet.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	xw.sheet.WriteString(`<cols>`)
+	for i, column := range columns {
+		width := max(len(column.Title)+2, 12)
+		if column.Kind == Money || column.Kind == DateTime {
+			width = max(width, 20)
+		}
+		col := strconv.Itoa(i + 1)
+		xw.sheet.WriteString(`<col min="` + col + `" max="` + col + `" width="` + strconv.Itoa(width) + `" customWidth="1"/>`)
+	}
+	xw.sheet.WriteString(`</cols><sheetData>`)
+
+	xw.row++
+	xw.sheet.WriteString(`<row r="1">`)
+	for i, column := range columns {
+		xw.writeString(i, column.Title, styleHeader)
+	}
+	xw.sheet.WriteString(`</row>`)
+
+	return xw, nil
+}
+
+func (w *xlsxWriter) WriteRow(values ...any) error {
+	w.row++
+	w.sheet.WriteString(`<row r="` + strconv.Itoa(w.row) + `">`)
+	for i, column := range w.columns {
+		if i >= len(values) {
+			break
+		}
+		w.writeCell(i, column.Kind, deref(values[i]))
+	}
+	_, err := w.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (w *xlsxWriter) Close() error {
+	w.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.archive.Close()
+}
+
+func (w *xlsxWriter) writeCell(col int, kind Kind, value any) {
+	if value == nil {
+		return
+	}
+
+	if t, ok := value.(time.Time); ok {
+		t = t.In(w.loc)
+		wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+		style := styleDateTime
+		if kind == Date {
+			wall = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			style = styleDate
+		}
+		w.writeNumber(col, wall.Sub(excelEpoch).Hours()/24, style)
+		return
+	}
+
+	if n, ok := toFloat(value); ok {
+		style := styleDefault
+		if kind == Money {
+			style = styleMoney
+		}
+		w.writeNumber(col, n, style)
+		return
+	}
+
+	w.writeString(col, fmt.Sprint(value), styleDefault)
+}
+
+func (w *xlsxWriter) writeNumber(col int, n float64, style int) {
+	w.sheet.WriteString(`<c r="` + cellRef(col, w.row) + `" s="` + strconv.Itoa(style) + `"><v>`)
+	w.sheet.WriteString(strconv.FormatFloat(n, 'f', -1, 64))
+	w.sheet.WriteString(`</v></c>`)
+}
+
+func (w *xlsxWriter) writeString(col int, s string, style int) {
+	w.sheet.WriteString(`<c r="` + cellRef(col, w.row) + `" s="` + strconv.Itoa(style) + `" t="inlineStr"><is><t xml:space="preserve">`)
+	w.sheet.WriteString(xmlEscape(s))
+	w.sheet.WriteString(`</t></is></c>`)
+}
+
+// cellRef returns the A1 reference of a zero-based column and one-based row.
+func cellRef(col, row int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name + strconv.Itoa(row)
+}
+
+// sheetName trims a name to Excel's 31 character limit and drops the
+// characters Excel does not allow in sheet names.
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "Sheet1"
+	}
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	return name
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+const xlsxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const xlsxRootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="{sheet}" sheetId="1" r:id="rId1"/></sheets>` +
+	`</workbook>`
+
+const xlsxWorkbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="3">` +
+	`<numFmt numFmtId="164" formatCode="&quot;Rp &quot;#,##0;-&quot;Rp &quot;#,##0"/>` +
+	`<numFmt numFmtId="165" formatCode="yyyy-mm-dd"/>` +
+	`<numFmt numFmtId="166" formatCode="yyyy-mm-dd hh:mm:ss"/>` +
+	`</numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="5">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="166" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/middleware"
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/Cakra17/imphnen/internal/store"
+	"github.com/Cakra17/imphnen/internal/utils"
+	"github.com/Cakra17/imphnen/internal/validation"
+	"github.com/google/uuid"
+)
+
+type AccessTokenHandler struct {
+	accessTokenRepo store.AccessTokenRepo
+}
+
+type AccessTokenHandlerConfig struct {
+	AccessTokenRepo store.AccessTokenRepo
+}
+
+func NewAccessTokenHandler(cfg AccessTokenHandlerConfig) AccessTokenHandler {
+	return AccessTokenHandler{
+		accessTokenRepo: cfg.AccessTokenRepo,
+	}
+}
+
+// CreateAccessToken godoc
+// @Summary      Create a personal access token
+// @Description  Create a named, long-lived token for scripts and integrations. The token value is only returned once. Set expires_in_days to 0 for a token that never expires.
+// @Tags         Tokens
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.CreateAccessTokenPayload  true  "Token name, scopes and expiry"
+// @Success      201      {object}  utils.Response{data=models.CreatedAccessTokenResponse}  "Access token created"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid request payload or unknown scope"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      403      {object}  utils.Response{message=string}  "Access tokens cannot create other tokens"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /tokens [post]
+func (h *AccessTokenHandler) CreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	var payload models.CreateAccessTokenPayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return
+			}
+		}
+	}
+
+	for _, scope := range payload.Scopes {
+		if !slices.Contains(models.AccessTokenScopes, scope) {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: fmt.Sprintf("Scope %s tidak dikenal", scope),
+			})
+			return
+		}
+	}
+
+	tokenStr, prefix, hash, err := utils.GenerateAccessToken()
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membuat token",
+		})
+		return
+	}
+
+	id, _ := uuid.NewV7()
+	token := models.PersonalAccessToken{
+		ID:          id.String(),
+		UserID:      userID,
+		Name:        payload.Name,
+		TokenPrefix: prefix,
+		TokenHash:   hash,
+		Scopes:      slices.Compact(slices.Sorted(slices.Values(payload.Scopes))),
+	}
+
+	if payload.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, payload.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := h.accessTokenRepo.Create(ctx, &token); err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal menyimpan token",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityID: token.ID,
+		After:    token,
+	})
+
+	utils.ResponseJson(w, http.StatusCreated, utils.Response{
+		Message: "Token berhasil dibuat, simpan token ini karena tidak akan ditampilkan lagi",
+		Data: models.CreatedAccessTokenResponse{
+			AccessToken: token,
+			Token:       tokenStr,
+		},
+	})
+}
+
+// GetAccessTokens godoc
+// @Summary      List personal access tokens
+// @Description  List the authenticated user's personal access tokens, including revoked and expired ones
+// @Tags         Tokens
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  utils.Response{data=models.AccessTokenListResponse}  "Access tokens retrieved successfully"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      403  {object}  utils.Response{message=string}  "Access tokens cannot list tokens"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /tokens [get]
+func (h *AccessTokenHandler) GetAccessTokens(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	tokens, err := h.accessTokenRepo.GetAccessTokens(ctx, userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data token",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil data token",
+		Data: models.AccessTokenListResponse{
+			AccessTokens: tokens,
+		},
+	})
+}
+
+// RevokeAccessToken godoc
+// @Summary      Revoke a personal access token
+// @Description  Revoke a personal access token. Requests made with it are rejected immediately.
+// @Tags         Tokens
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Access token ID"
+// @Success      200  {object}  utils.Response{message=string}  "Access token revoked"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      403  {object}  utils.Response{message=string}  "Access tokens cannot revoke tokens"
+// @Failure      404  {object}  utils.Response{message=string}  "Access token not found"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /tokens/{id} [delete]
+func (h *AccessTokenHandler) RevokeAccessToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	id := r.PathValue("id")
+
+	revoked, err := h.accessTokenRepo.Revoke(ctx, id, userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mencabut token",
+		})
+		return
+	}
+
+	if !revoked {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Token tidak ditemukan",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		Action:   "revoke",
+		EntityID: id,
+	})
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Token berhasil dicabut",
+	})
+}
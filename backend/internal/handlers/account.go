@@ -0,0 +1,598 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/middleware"
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/Cakra17/imphnen/internal/store"
+	"github.com/Cakra17/imphnen/internal/utils"
+	"github.com/Cakra17/imphnen/internal/validation"
+	"github.com/google/uuid"
+)
+
+type AccountHandler struct {
+	accountRepo store.AccountRepo
+}
+
+type AccountHandlerConfig struct {
+	AccountRepo store.AccountRepo
+}
+
+func NewAccountHandler(cfg AccountHandlerConfig) AccountHandler {
+	return AccountHandler{
+		accountRepo: cfg.AccountRepo,
+	}
+}
+
+// CreateAccount godoc
+// @Summary      Create an account
+// @Description  Create a place where money is kept, such as the cash drawer, a bank account or an e-wallet, with its balance on the day it is added. Receipts and recurring transactions without an account of their own are booked to the default account; the first account becomes the default, and is_default moves the default to a new account.
+// @Tags         Accounts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.CreateAccountPayload  true  "Account details"
+// @Success      201      {object}  utils.Response{data=models.AccountResponse}  "Account created successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid request data"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      409      {object}  utils.Response{message=string}  "Account with the same name already exists"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /accounts [post]
+func (h *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
+	var payload models.CreateAccountPayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return
+			}
+		}
+	}
+
+	id, _ := uuid.NewV7()
+	account := models.Account{
+		ID:             id.String(),
+		UserID:         userID,
+		Name:           payload.Name,
+		Kind:           payload.Kind,
+		OpeningBalance: payload.OpeningBalance,
+		IsDefault:      payload.IsDefault,
+	}
+
+	err := h.accountRepo.Create(ctx, &account)
+	if err == store.ErrAccountExists {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Akun dengan nama tersebut sudah ada",
+		})
+		return
+	}
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membuat akun",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityID: account.ID,
+		After:    account,
+	})
+
+	utils.ResponseJson(w, http.StatusCreated, utils.Response{
+		Message: "Berhasil membuat akun",
+		Data: models.AccountResponse{
+			Account: account,
+		},
+	})
+}
+
+// GetAccounts godoc
+// @Summary      List accounts
+// @Description  List the authenticated user's accounts with their current balance
+// @Tags         Accounts
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  utils.Response{data=models.AccountListResponse}  "Accounts retrieved successfully"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /accounts [get]
+func (h *AccountHandler) GetAccounts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	accounts, err := h.accountRepo.GetAccounts(ctx, userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data akun",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil data akun",
+		Data: models.AccountListResponse{
+			Accounts: accounts,
+		},
+	})
+}
+
+// GetAccountByID godoc
+// @Summary      Get an account
+// @Description  Get an account with its current balance
+// @Tags         Accounts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Account ID"
+// @Success      200  {object}  utils.Response{data=models.AccountResponse}  "Account retrieved successfully"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404  {object}  utils.Response{message=string}  "Account not found"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /accounts/{id} [get]
+func (h *AccountHandler) GetAccountByID(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.getAccount(w, r)
+	if !ok {
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil data akun",
+		Data: models.AccountResponse{
+			Account: *account,
+		},
+	})
+}
+
+// UpdateAccount godoc
+// @Summary      Update an account
+// @Description  Rename an account, change its kind or correct its opening balance. The current balance follows the opening balance. is_default true makes it the default account, false leaves the merchant without one, and an omitted is_default keeps it.
+// @Tags         Accounts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string                       true  "Account ID"
+// @Param        request  body      models.UpdateAccountPayload  true  "New account details"
+// @Success      200      {object}  utils.Response{data=models.AccountResponse}  "Account updated successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid request data"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404      {object}  utils.Response{message=string}  "Account not found"
+// @Failure      409      {object}  utils.Response{message=string}  "Account with the same name already exists"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /accounts/{id} [put]
+func (h *AccountHandler) UpdateAccount(w http.ResponseWriter, r *http.Request) {
+	var payload models.UpdateAccountPayload
+	ctx := r.Context()
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return
+			}
+		}
+	}
+
+	existing, ok := h.getAccount(w, r)
+	if !ok {
+		return
+	}
+
+	account := *existing
+	account.Name = payload.Name
+	account.Kind = payload.Kind
+	account.OpeningBalance = payload.OpeningBalance
+	if payload.IsDefault != nil {
+		account.IsDefault = *payload.IsDefault
+	}
+	account.Balance += payload.OpeningBalance - existing.OpeningBalance
+
+	updated, err := h.accountRepo.UpdateAccount(ctx, account)
+	if err == store.ErrAccountExists {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Akun dengan nama tersebut sudah ada",
+		})
+		return
+	}
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengubah akun",
+		})
+		return
+	}
+
+	if !updated {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Akun tidak ditemukan",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityID: account.ID,
+		Before:   existing,
+		After:    account,
+	})
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengubah akun",
+		Data: models.AccountResponse{
+			Account: account,
+		},
+	})
+}
+
+// DeleteAccount godoc
+// @Summary      Delete an account
+// @Description  Delete an account that has no transactions or transfers. Accounts with history cannot be deleted so past balances stay intact.
+// @Tags         Accounts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Account ID"
+// @Success      200  {object}  utils.Response{message=string}  "Account deleted successfully"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404  {object}  utils.Response{message=string}  "Account not found"
+// @Failure      409  {object}  utils.Response{message=string}  "Account still has transactions or transfers"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /accounts/{id} [delete]
+func (h *AccountHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	id := r.PathValue("id")
+
+	if _, err := uuid.Parse(id); err != nil {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Akun tidak ditemukan",
+		})
+		return
+	}
+
+	deleted, err := h.accountRepo.DeleteAccount(ctx, id, userID)
+	if err == store.ErrAccountInUse {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Akun masih memiliki transaksi atau transfer",
+		})
+		return
+	}
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal menghapus akun",
+		})
+		return
+	}
+
+	if !deleted {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Akun tidak ditemukan",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil menghapus akun",
+	})
+}
+
+// GetAccountLedger godoc
+// @Summary      Get account ledger
+// @Description  Movements of an account in a date range with the running balance after each, for reconciling against a bank or e-wallet statement. Transactions move the balance by their amount and transfers in or out of the account by theirs; voided transactions are left out.
+// @Tags         Accounts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id          path      string  true   "Account ID"
+// @Param        start_date  query     string  false  "Start date in YYYY-MM-DD format (default: 30 days ago)"
+// @Param        end_date    query     string  false  "End date in YYYY-MM-DD format (default: today)"
+// @Success      200         {object}  utils.Response{data=models.AccountLedger}  "Ledger retrieved successfully"
+// @Failure      400         {object}  utils.Response{message=string}  "Invalid date format"
+// @Failure      401         {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404         {object}  utils.Response{message=string}  "Account not found"
+// @Failure      500         {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /accounts/{id}/ledger [get]
+func (h *AccountHandler) GetAccountLedger(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	startDate, endDate, ok := parseDateRange(w, r, 30)
+	if !ok {
+		return
+	}
+
+	account, ok := h.getAccount(w, r)
+	if !ok {
+		return
+	}
+
+	entries, openingBalance, err := h.accountRepo.GetLedger(ctx, *account, startDate, endDate)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil mutasi akun",
+		})
+		return
+	}
+
+	ledger := models.AccountLedger{
+		Account:        *account,
+		From:           startDate.Format("2006-01-02"),
+		To:             endDate.AddDate(0, 0, -1).Format("2006-01-02"),
+		OpeningBalance: openingBalance,
+		ClosingBalance: openingBalance,
+		Entries:        entries,
+	}
+	if len(entries) > 0 {
+		ledger.ClosingBalance = entries[len(entries)-1].Balance
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil mutasi akun",
+		Data:    ledger,
+	})
+}
+
+// CreateTransfer godoc
+// @Summary      Transfer between accounts
+// @Description  Move money from one account to another, for example depositing the cash drawer at the bank. Transfers change both balances but are not income or expense.
+// @Tags         Accounts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.CreateTransferPayload  true  "Transfer details"
+// @Success      201      {object}  utils.Response{data=models.TransferResponse}  "Transfer created successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid request data"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /transfers [post]
+func (h *AccountHandler) CreateTransfer(w http.ResponseWriter, r *http.Request) {
+	var payload models.CreateTransferPayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return
+			}
+		}
+	}
+
+	if payload.FromAccountID == payload.ToAccountID {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Akun asal dan tujuan tidak boleh sama",
+		})
+		return
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", payload.TransferDate, middleware.GetLocation(ctx))
+	if err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Format transfer_date tidak valid, gunakan YYYY-MM-DD",
+		})
+		return
+	}
+
+	for _, accountID := range []string{payload.FromAccountID, payload.ToAccountID} {
+		if status, msg := checkAccount(ctx, &h.accountRepo, &accountID, userID); status != 0 {
+			utils.ResponseJson(w, status, utils.Response{
+				Message: msg,
+			})
+			return
+		}
+	}
+
+	id, _ := uuid.NewV7()
+	transfer := models.AccountTransfer{
+		ID:            id.String(),
+		UserID:        userID,
+		FromAccountID: payload.FromAccountID,
+		ToAccountID:   payload.ToAccountID,
+		Amount:        payload.Amount,
+		TransferDate:  date,
+		Note:          optionalDescription(payload.Note),
+	}
+
+	if err := h.accountRepo.CreateTransfer(ctx, &transfer); err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membuat transfer",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityID: transfer.ID,
+		After:    transfer,
+	})
+
+	utils.ResponseJson(w, http.StatusCreated, utils.Response{
+		Message: "Berhasil membuat transfer",
+		Data: models.TransferResponse{
+			Transfer: transfer,
+		},
+	})
+}
+
+// GetTransfers godoc
+// @Summary      List transfers
+// @Description  List transfers between accounts in a date range, newest first
+// @Tags         Accounts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        start_date  query     string  false  "Start date in YYYY-MM-DD format (default: 30 days ago)"
+// @Param        end_date    query     string  false  "End date in YYYY-MM-DD format (default: today)"
+// @Param        account_id  query     string  false  "Only transfers from or to this account"
+// @Success      200         {object}  utils.Response{data=models.TransferListResponse}  "Transfers retrieved successfully"
+// @Failure      400         {object}  utils.Response{message=string}  "Invalid parameters"
+// @Failure      401         {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500         {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /transfers [get]
+func (h *AccountHandler) GetTransfers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	startDate, endDate, ok := parseDateRange(w, r, 30)
+	if !ok {
+		return
+	}
+
+	var accountID *string
+	if id := r.URL.Query().Get("account_id"); id != "" {
+		if _, err := uuid.Parse(id); err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter account_id tidak valid",
+			})
+			return
+		}
+		accountID = &id
+	}
+
+	transfers, err := h.accountRepo.GetTransfers(ctx, userID, accountID, startDate, endDate)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data transfer",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil data transfer",
+		Data: models.TransferListResponse{
+			Transfers: transfers,
+		},
+	})
+}
+
+// DeleteTransfer godoc
+// @Summary      Delete a transfer
+// @Description  Delete a transfer entered by mistake. Both account balances are restored.
+// @Tags         Accounts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Transfer ID"
+// @Success      200  {object}  utils.Response{message=string}  "Transfer deleted successfully"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404  {object}  utils.Response{message=string}  "Transfer not found"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /transfers/{id} [delete]
+func (h *AccountHandler) DeleteTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	id := r.PathValue("id")
+
+	if _, err := uuid.Parse(id); err != nil {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Transfer tidak ditemukan",
+		})
+		return
+	}
+
+	deleted, err := h.accountRepo.DeleteTransfer(ctx, id, userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal menghapus transfer",
+		})
+		return
+	}
+
+	if !deleted {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Transfer tidak ditemukan",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil menghapus transfer",
+	})
+}
+
+// getAccount loads the account in the id path parameter, writing a 404 or
+// 500 itself when it cannot.
+func (h *AccountHandler) getAccount(w http.ResponseWriter, r *http.Request) (*models.Account, bool) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	id := r.PathValue("id")
+
+	if _, err := uuid.Parse(id); err != nil {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Akun tidak ditemukan",
+		})
+		return nil, false
+	}
+
+	account, err := h.accountRepo.GetAccountByID(ctx, id, userID)
+	if err == sql.ErrNoRows {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Akun tidak ditemukan",
+		})
+		return nil, false
+	}
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data akun",
+		})
+		return nil, false
+	}
+
+	return account, true
+}
+
+// checkAccount verifies that accountID, when set, belongs to the user. It
+// returns the status and message to respond with, or 0 when the account can
+// be used.
+func checkAccount(ctx context.Context, accountRepo *store.AccountRepo, accountID *string, userID string) (int, string) {
+	if accountID == nil {
+		return 0, ""
+	}
+
+	if _, err := uuid.Parse(*accountID); err != nil {
+		return http.StatusBadRequest, "Akun tidak ditemukan"
+	}
+
+	_, err := accountRepo.GetAccountByID(ctx, *accountID, userID)
+	if err == sql.ErrNoRows {
+		return http.StatusBadRequest, "Akun tidak ditemukan"
+	}
+	if err != nil {
+		return http.StatusInternalServerError, "Gagal mengambil data akun"
+	}
+
+	return 0, ""
+}
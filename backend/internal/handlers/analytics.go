@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/middleware"
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/Cakra17/imphnen/internal/store"
+	"github.com/Cakra17/imphnen/internal/utils"
+	"github.com/google/uuid"
+)
+
+// velocityWindowDays is how far back sales velocity looks, long enough to
+// smooth out weekly patterns.
+const velocityWindowDays = 28
+
+type AnalyticsHandler struct {
+	analyticsRepo store.AnalyticsRepo
+}
+
+type AnalyticsHandlerConfig struct {
+	AnalyticsRepo store.AnalyticsRepo
+}
+
+func NewAnalyticsHandler(cfg AnalyticsHandlerConfig) AnalyticsHandler {
+	return AnalyticsHandler{
+		analyticsRepo: cfg.AnalyticsRepo,
+	}
+}
+
+// GetProductAnalytics godoc
+// @Summary      Get best sellers and sales velocity
+// @Description  Units and revenue per product from confirmed orders between start_date and end_date, ranked by units sold. Each product also has the units of the last seven days of the range against the seven days before, its average daily sales over the last 28 days and the number of days its current stock lasts at that pace.
+// @Tags         Analytics
+// @Produce      json
+// @Security     BearerAuth
+// @Param        start_date  query     string  false  "Start date in YYYY-MM-DD format (default: 6 days ago)"
+// @Param        end_date    query     string  false  "End date in YYYY-MM-DD format (default: today)"
+// @Success      200         {object}  utils.Response{data=models.ProductAnalyticsReport}  "Product analytics retrieved successfully"
+// @Failure      400         {object}  utils.Response{message=string}  "Invalid date format"
+// @Failure      401         {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500         {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /analytics/products [get]
+func (h *AnalyticsHandler) GetProductAnalytics(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	startDate, endDate, ok := parseDateRange(w, r, 6)
+	if !ok {
+		return
+	}
+
+	loc := middleware.GetLocation(ctx)
+	tomorrow := utils.StartOfDay(time.Now(), loc).AddDate(0, 0, 1)
+	window := models.ProductSalesWindow{
+		RangeStart:        startDate,
+		RangeEnd:          endDate,
+		LastWeekStart:     endDate.AddDate(0, 0, -7),
+		PreviousWeekStart: endDate.AddDate(0, 0, -14),
+		VelocityStart:     tomorrow.AddDate(0, 0, -velocityWindowDays),
+		VelocityEnd:       tomorrow,
+	}
+
+	products, err := h.analyticsRepo.GetProductSales(ctx, userID, window)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil analitik produk",
+		})
+		return
+	}
+
+	for i := range products {
+		product := &products[i]
+
+		// Products that sold as much as the one before them share its rank.
+		product.Rank = i + 1
+		if i > 0 && product.Units == products[i-1].Units && product.Revenue == products[i-1].Revenue {
+			product.Rank = products[i-1].Rank
+		}
+
+		product.WeekOverWeekChange = percentChange(float64(product.LastWeekUnits), float64(product.PreviousWeekUnits))
+		product.DailyVelocity = math.Round(float64(product.RecentUnits)/velocityWindowDays*100) / 100
+		if product.RecentUnits > 0 {
+			days := math.Round(float64(max(product.Stock, 0))/(float64(product.RecentUnits)/velocityWindowDays)*10) / 10
+			product.DaysOfStock = &days
+		}
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil analitik produk",
+		Data: models.ProductAnalyticsReport{
+			StartDate:    startDate.Format("2006-01-02"),
+			EndDate:      endDate.AddDate(0, 0, -1).Format("2006-01-02"),
+			VelocityDays: velocityWindowDays,
+			Products:     products,
+		},
+	})
+}
+
+// GetCustomerAnalytics godoc
+// @Summary      Get customer analytics
+// @Description  Lifetime value, order count, average basket, first and last order dates and RFM segment of the customers with confirmed orders. Recency, frequency and monetary scores are quintiles among all of the merchant's customers, 5 being the best. Segments: champions, loyal, potential_loyalist, new, need_attention, at_risk, hibernating and lost. Use inactive_days to find customers that have not ordered for a while.
+// @Tags         Analytics
+// @Produce      json
+// @Security     BearerAuth
+// @Param        segment        query     string  false  "Only customers in this segment"
+// @Param        inactive_days  query     int     false  "Only customers whose last order is at least this many days ago"
+// @Param        min_orders     query     int     false  "Only customers with at least this many orders"
+// @Param        sort           query     string  false  "lifetime_value, order_count, average_basket or last_order_date, largest first (default: lifetime_value)"
+// @Param        page           query     int     false  "Page number" default(1)
+// @Param        per_page       query     int     false  "Items per page" default(10)
+// @Success      200            {object}  utils.ResponsePaginate{data=models.CustomerAnalyticsListResponse}  "Customer analytics retrieved successfully"
+// @Failure      400            {object}  utils.Response{message=string}  "Invalid parameters"
+// @Failure      401            {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500            {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /analytics/customers [get]
+func (h *AnalyticsHandler) GetCustomerAnalytics(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	loc := middleware.GetLocation(ctx)
+	query := r.URL.Query()
+
+	filter := models.CustomerAnalyticsFilter{
+		UserID:  userID,
+		Sort:    "lifetime_value",
+		Page:    1,
+		PerPage: 10,
+	}
+
+	if segment := query.Get("segment"); segment != "" {
+		switch segment {
+		case models.SegmentChampions, models.SegmentLoyal, models.SegmentPotentialLoyal, models.SegmentNew,
+			models.SegmentNeedAttention, models.SegmentAtRisk, models.SegmentHibernating, models.SegmentLost:
+			filter.Segment = &segment
+		default:
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter segment tidak valid",
+			})
+			return
+		}
+	}
+
+	today := utils.StartOfDay(time.Now(), loc)
+	if daysStr := query.Get("inactive_days"); daysStr != "" {
+		days, err := strconv.Atoi(daysStr)
+		if err != nil || days < 1 {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter inactive_days harus bilangan bulat positif",
+			})
+			return
+		}
+		// The last order was on today minus days or earlier.
+		since := today.AddDate(0, 0, 1-days)
+		filter.InactiveSince = &since
+	}
+
+	if minStr := query.Get("min_orders"); minStr != "" {
+		minOrders, err := strconv.Atoi(minStr)
+		if err != nil || minOrders < 0 {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter min_orders harus bilangan bulat",
+			})
+			return
+		}
+		filter.MinOrders = minOrders
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		switch sort {
+		case "lifetime_value", "order_count", "average_basket", "last_order_date":
+			filter.Sort = sort
+		default:
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter sort harus 'lifetime_value', 'order_count', 'average_basket', atau 'last_order_date'",
+			})
+			return
+		}
+	}
+
+	if p, err := strconv.ParseUint(query.Get("page"), 10, 32); err == nil && p > 0 {
+		filter.Page = uint(p)
+	}
+	if pp, err := strconv.ParseUint(query.Get("per_page"), 10, 32); err == nil && pp > 0 {
+		filter.PerPage = uint(pp)
+	}
+
+	customers, total, err := h.analyticsRepo.GetCustomerAnalytics(ctx, filter)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil analitik pelanggan",
+		})
+		return
+	}
+
+	for i := range customers {
+		customer := &customers[i]
+		customer.AverageBasket = math.Round(customer.LifetimeValue/float64(customer.OrderCount)*100) / 100
+		customer.FirstOrderDate = customer.FirstOrderDate.In(loc)
+		customer.LastOrderDate = customer.LastOrderDate.In(loc)
+		customer.DaysSinceLastOrder = int(today.Sub(utils.StartOfDay(customer.LastOrderDate, loc)).Hours()/24 + 0.5)
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.ResponsePaginate{
+		Message: "Berhasil mengambil analitik pelanggan",
+		Data: models.CustomerAnalyticsListResponse{
+			Customers: customers,
+		},
+		Meta: utils.Meta{
+			Page:        filter.Page,
+			TotalPage:   uint(math.Ceil(float64(total) / float64(filter.PerPage))),
+			TotalData:   total,
+			DataperPage: filter.PerPage,
+		},
+	})
+}
+
+// GetOrderHeatmap godoc
+// @Summary      Get order heatmap
+// @Description  Number of confirmed orders and their revenue per weekday and hour between start_date and end_date, in the merchant's time zone. Cells cover every hour of the week, Monday 00:00 first; weekday runs from 1 for Monday to 7 for Sunday. With product_id or category_id only orders containing that product or a product in that category count, and revenue only covers those items.
+// @Tags         Analytics
+// @Produce      json
+// @Security     BearerAuth
+// @Param        start_date   query     string  false  "Start date in YYYY-MM-DD format (default: 27 days ago)"
+// @Param        end_date     query     string  false  "End date in YYYY-MM-DD format (default: today)"
+// @Param        product_id   query     string  false  "Product ID"
+// @Param        category_id  query     string  false  "Category ID of the products"
+// @Success      200          {object}  utils.Response{data=models.OrderHeatmap}  "Heatmap retrieved successfully"
+// @Failure      400          {object}  utils.Response{message=string}  "Invalid parameters"
+// @Failure      401          {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500          {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /analytics/heatmap [get]
+func (h *AnalyticsHandler) GetOrderHeatmap(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	loc := middleware.GetLocation(ctx)
+
+	startDate, endDate, ok := parseDateRange(w, r, 27)
+	if !ok {
+		return
+	}
+
+	filter := models.OrderHeatmapFilter{
+		UserID: userID,
+		Start:  startDate,
+		End:    endDate,
+	}
+
+	if productID := r.URL.Query().Get("product_id"); productID != "" {
+		if _, err := uuid.Parse(productID); err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter product_id tidak valid",
+			})
+			return
+		}
+		filter.ProductID = &productID
+	}
+
+	if categoryID := r.URL.Query().Get("category_id"); categoryID != "" {
+		if _, err := uuid.Parse(categoryID); err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter category_id tidak valid",
+			})
+			return
+		}
+		filter.CategoryID = &categoryID
+	}
+
+	found, err := h.analyticsRepo.GetOrderHeatmap(ctx, filter, loc)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil heatmap pesanan",
+		})
+		return
+	}
+
+	cells := make([]models.HeatmapCell, 0, 7*24)
+	for weekday := 1; weekday <= 7; weekday++ {
+		for hour := 0; hour < 24; hour++ {
+			cells = append(cells, models.HeatmapCell{Weekday: weekday, Hour: hour})
+		}
+	}
+	for _, cell := range found {
+		cells[(cell.Weekday-1)*24+cell.Hour] = cell
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil heatmap pesanan",
+		Data: models.OrderHeatmap{
+			StartDate:  startDate.Format("2006-01-02"),
+			EndDate:    endDate.AddDate(0, 0, -1).Format("2006-01-02"),
+			TimeZone:   loc.String(),
+			ProductID:  filter.ProductID,
+			CategoryID: filter.CategoryID,
+			Cells:      cells,
+		},
+	})
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/middleware"
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/Cakra17/imphnen/internal/store"
+	"github.com/Cakra17/imphnen/internal/utils"
+)
+
+type AuditHandler struct {
+	auditRepo store.AuditRepo
+}
+
+type AuditHandlerConfig struct {
+	AuditRepo store.AuditRepo
+}
+
+func NewAuditHandler(cfg AuditHandlerConfig) AuditHandler {
+	return AuditHandler{
+		auditRepo: cfg.AuditRepo,
+	}
+}
+
+// GetAuditLogs godoc
+// @Summary      Get audit logs
+// @Description  Get a paginated list of recorded changes in the authenticated merchant's store
+// @Tags         Audit
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        page         query     int     false  "Page number (default: 1)"
+// @Param        per_page     query     int     false  "Items per page (default: 20)"
+// @Param        action       query     string  false  "Filter by action (create, update, delete, cancel, ...)"
+// @Param        entity_type  query     string  false  "Filter by entity type (products, orders, transactions, ...)"
+// @Param        entity_id    query     string  false  "Filter by entity ID"
+// @Param        actor_id     query     string  false  "Filter by actor ID"
+// @Param        start_date   query     string  false  "Start date in YYYY-MM-DD format"
+// @Param        end_date     query     string  false  "End date in YYYY-MM-DD format"
+// @Success      200          {object}  utils.ResponsePaginate{data=models.AuditLogListResponse}  "Audit logs retrieved successfully"
+// @Failure      400          {object}  utils.Response{message=string}  "Invalid date format"
+// @Failure      401          {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500          {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /audit-logs [get]
+func (h *AuditHandler) GetAuditLogs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	query := r.URL.Query()
+
+	page := uint(1)
+	perPage := uint(20)
+
+	if pageStr := query.Get("page"); pageStr != "" {
+		if p, err := strconv.ParseUint(pageStr, 10, 32); err == nil && p > 0 {
+			page = uint(p)
+		}
+	}
+
+	if perPageStr := query.Get("per_page"); perPageStr != "" {
+		if pp, err := strconv.ParseUint(perPageStr, 10, 32); err == nil && pp > 0 {
+			perPage = uint(pp)
+		}
+	}
+
+	filter := models.AuditLogFilter{
+		StoreID: userID,
+		Page:    page,
+		PerPage: perPage,
+	}
+
+	if action := query.Get("action"); action != "" {
+		filter.Action = &action
+	}
+
+	if entityType := query.Get("entity_type"); entityType != "" {
+		filter.EntityType = &entityType
+	}
+
+	if entityID := query.Get("entity_id"); entityID != "" {
+		filter.EntityID = &entityID
+	}
+
+	if actorID := query.Get("actor_id"); actorID != "" {
+		filter.ActorID = &actorID
+	}
+
+	if startDateStr := query.Get("start_date"); startDateStr != "" {
+		startDate, err := time.ParseInLocation("2006-01-02", startDateStr, middleware.GetLocation(ctx))
+		if err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Format start_date tidak valid, gunakan YYYY-MM-DD",
+			})
+			return
+		}
+		filter.StartDate = &startDate
+	}
+
+	if endDateStr := query.Get("end_date"); endDateStr != "" {
+		endDate, err := time.ParseInLocation("2006-01-02", endDateStr, middleware.GetLocation(ctx))
+		if err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Format end_date tidak valid, gunakan YYYY-MM-DD",
+			})
+			return
+		}
+		endDate = endDate.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+		filter.EndDate = &endDate
+	}
+
+	logs, totalCount, err := h.auditRepo.GetAuditLogs(ctx, filter)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil audit log",
+		})
+		return
+	}
+
+	totalPages := uint(math.Ceil(float64(totalCount) / float64(perPage)))
+
+	utils.ResponseJson(w, http.StatusOK, utils.ResponsePaginate{
+		Message: "Berhasil mengambil audit log",
+		Data: models.AuditLogListResponse{
+			AuditLogs: logs,
+		},
+		Meta: utils.Meta{
+			Page:        page,
+			TotalPage:   totalPages,
+			TotalData:   totalCount,
+			DataperPage: perPage,
+		},
+	})
+}
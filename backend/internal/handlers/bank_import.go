@@ -0,0 +1,569 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/bankimport"
+	"github.com/Cakra17/imphnen/internal/middleware"
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/Cakra17/imphnen/internal/store"
+	"github.com/Cakra17/imphnen/internal/utils"
+	"github.com/google/uuid"
+)
+
+// maxSuggestions is the number of match suggestions returned per line.
+const maxSuggestions = 3
+
+type BankImportHandler struct {
+	bankImportRepo store.BankImportRepo
+	accountRepo    store.AccountRepo
+	categoryRepo   store.CategoryRepo
+}
+
+type BankImportHandlerConfig struct {
+	BankImportRepo store.BankImportRepo
+	AccountRepo    store.AccountRepo
+	CategoryRepo   store.CategoryRepo
+}
+
+func NewBankImportHandler(cfg BankImportHandlerConfig) BankImportHandler {
+	return BankImportHandler{
+		bankImportRepo: cfg.BankImportRepo,
+		accountRepo:    cfg.AccountRepo,
+		categoryRepo:   cfg.CategoryRepo,
+	}
+}
+
+// CreateImport godoc
+// @Summary      Import a bank statement
+// @Description  Upload a bank mutation export into an account. CSV exports of Indonesian banks such as BCA, Mandiri, BNI and BRI are recognised by their column headers; OFX, QFX and QIF are also accepted. Numeric dates are read day first. Lines already imported into the account, by date, amount and description, are skipped. New lines stay pending until they are matched, turned into a transaction or ignored.
+// @Tags         Bank Imports
+// @Accept       mpfd
+// @Produce      json
+// @Security     BearerAuth
+// @Param        file        formData  file    true   "Statement file"
+// @Param        account_id  formData  string  true   "Account the statement belongs to"
+// @Param        format      formData  string  false  "auto, csv, ofx or qif (default: auto, from the file extension)"
+// @Success      201         {object}  utils.Response{data=models.BankImportResponse}  "Statement imported successfully"
+// @Failure      400         {object}  utils.Response{message=string}  "Invalid file or parameters"
+// @Failure      401         {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500         {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /bank-imports [post]
+func (h *BankImportHandler) CreateImport(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadSize)
+	if err := r.ParseMultipartForm(MaxUploadSize); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Ukuran data terlalu besar, Max 5 MB",
+			})
+			return
+		}
+
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Gagal membaca data",
+		})
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Gagal menerima file mutasi",
+		})
+		return
+	}
+	defer file.Close()
+
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	loc := middleware.GetLocation(ctx)
+
+	accountID := r.FormValue("account_id")
+	if accountID == "" {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "account_id diperlukan",
+		})
+		return
+	}
+	if status, msg := checkAccount(ctx, &h.accountRepo, &accountID, userID); status != 0 {
+		utils.ResponseJson(w, status, utils.Response{
+			Message: msg,
+		})
+		return
+	}
+
+	format := r.FormValue("format")
+	if format == "" {
+		format = bankimport.FormatAuto
+	}
+	if !bankimport.ValidFormat(format) {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Parameter format harus 'auto', 'csv', 'ofx', atau 'qif'",
+		})
+		return
+	}
+	if format == bankimport.FormatAuto {
+		format = bankimport.DetectFormat(header.Filename)
+	}
+
+	parsed, err := bankimport.Parse(format, file, time.Now().In(loc))
+	if err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Tidak ada baris mutasi yang dapat dibaca dari file",
+		})
+		return
+	}
+
+	id, _ := uuid.NewV7()
+	bankImport := models.BankImport{
+		ID:        id.String(),
+		UserID:    userID,
+		AccountID: accountID,
+		Filename:  header.Filename,
+		Format:    format,
+	}
+
+	hashes := bankimport.Fingerprints(parsed)
+	lines := make([]models.BankImportLine, 0, len(parsed))
+	for i, line := range parsed {
+		lineID, _ := uuid.NewV7()
+		lines = append(lines, models.BankImportLine{
+			ID:          lineID.String(),
+			Date:        line.Date,
+			Description: line.Description,
+			Amount:      line.Amount,
+			Hash:        hashes[i],
+		})
+	}
+
+	if err := h.bankImportRepo.CreateImport(ctx, &bankImport, lines); err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengimpor mutasi",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityID: bankImport.ID,
+		After:    bankImport,
+	})
+
+	utils.ResponseJson(w, http.StatusCreated, utils.Response{
+		Message: fmt.Sprintf("Berhasil mengimpor %d baris mutasi", bankImport.LineCount),
+		Data: models.BankImportResponse{
+			Import: bankImport,
+		},
+	})
+}
+
+// GetImports godoc
+// @Summary      List bank imports
+// @Description  List the uploaded statements, newest first, with the number of lines still pending
+// @Tags         Bank Imports
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  utils.Response{data=models.BankImportListResponse}  "Imports retrieved successfully"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /bank-imports [get]
+func (h *BankImportHandler) GetImports(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	imports, err := h.bankImportRepo.GetImports(ctx, userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data impor mutasi",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil data impor mutasi",
+		Data: models.BankImportListResponse{
+			Imports: imports,
+		},
+	})
+}
+
+// GetImportLines godoc
+// @Summary      List lines of a bank import
+// @Description  List the lines of a statement in statement order. Pending lines come with up to three suggested matches: transactions, transactions recorded from receipts and confirmed orders with the same amount within three days, scored by how close the date and description are.
+// @Tags         Bank Imports
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string  true   "Import ID"
+// @Param        status  query     string  false  "pending, matched, created or ignored"
+// @Success      200     {object}  utils.Response{data=models.BankImportLineListResponse}  "Lines retrieved successfully"
+// @Failure      400     {object}  utils.Response{message=string}  "Invalid status"
+// @Failure      401     {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404     {object}  utils.Response{message=string}  "Import not found"
+// @Failure      500     {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /bank-imports/{id}/lines [get]
+func (h *BankImportHandler) GetImportLines(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	id := r.PathValue("id")
+
+	var status *string
+	if s := r.URL.Query().Get("status"); s != "" {
+		switch s {
+		case models.BankImportLinePending, models.BankImportLineMatched, models.BankImportLineCreated, models.BankImportLineIgnored:
+			status = &s
+		default:
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter status harus 'pending', 'matched', 'created', atau 'ignored'",
+			})
+			return
+		}
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Impor mutasi tidak ditemukan",
+		})
+		return
+	}
+
+	if _, err := h.bankImportRepo.GetImportByID(ctx, id, userID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+				Message: "Impor mutasi tidak ditemukan",
+			})
+			return
+		}
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data impor mutasi",
+		})
+		return
+	}
+
+	lines, err := h.bankImportRepo.GetLines(ctx, id, userID, status)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data impor mutasi",
+		})
+		return
+	}
+
+	loc := middleware.GetLocation(ctx)
+	for i, line := range lines {
+		if line.Status != models.BankImportLinePending {
+			continue
+		}
+		suggestions, err := h.bankImportRepo.GetSuggestions(ctx, line, loc)
+		if err != nil {
+			utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+				Message: "Gagal mengambil data impor mutasi",
+			})
+			return
+		}
+		lines[i].Suggestions = rankSuggestions(line, suggestions, loc)
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil data impor mutasi",
+		Data: models.BankImportLineListResponse{
+			Lines: lines,
+		},
+	})
+}
+
+// MatchLine godoc
+// @Summary      Accept a match for a bank import line
+// @Description  Link a pending line to an existing transaction, which is moved into the statement's account, or to a confirmed order, for which an income transaction is recorded. The transaction or order must have the line's amount.
+// @Tags         Bank Imports
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string                     true  "Line ID"
+// @Param        request  body      models.AcceptMatchPayload  true  "Transaction or order to match"
+// @Success      200      {object}  utils.Response{data=models.BankImportLineResponse}  "Line matched successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid request data or the record does not match the line"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404      {object}  utils.Response{message=string}  "Line not found"
+// @Failure      409      {object}  utils.Response{message=string}  "Line already resolved or transaction already matched"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /bank-imports/lines/{id}/match [post]
+func (h *BankImportHandler) MatchLine(w http.ResponseWriter, r *http.Request) {
+	var payload models.AcceptMatchPayload
+	ctx := r.Context()
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if (payload.TransactionID == nil) == (payload.OrderID == nil) {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Isi salah satu dari transaction_id atau order_id",
+		})
+		return
+	}
+	for _, id := range []*string{payload.TransactionID, payload.OrderID} {
+		if id == nil {
+			continue
+		}
+		if _, err := uuid.Parse(*id); err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Transaksi atau pesanan tidak sesuai dengan mutasi",
+			})
+			return
+		}
+	}
+
+	line, ok := h.getPendingLine(w, r)
+	if !ok {
+		return
+	}
+
+	if payload.TransactionID != nil {
+		err := h.bankImportRepo.MatchTransaction(ctx, *line, *payload.TransactionID)
+		if !h.handleResolveError(w, err) {
+			return
+		}
+		line.TransactionID = payload.TransactionID
+	} else {
+		transaction := h.lineTransaction(r, *line)
+		transaction.OrderID = payload.OrderID
+		err := h.bankImportRepo.CreateFromLine(ctx, *line, &transaction)
+		if !h.handleResolveError(w, err) {
+			return
+		}
+		line.TransactionID = &transaction.ID
+	}
+	line.Status = models.BankImportLineMatched
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityType: "bank_import_lines",
+		EntityID:   line.ID,
+		After:      line,
+	})
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mencocokkan mutasi",
+		Data: models.BankImportLineResponse{
+			Line: *line,
+		},
+	})
+}
+
+// CreateFromLine godoc
+// @Summary      Create a transaction from a bank import line
+// @Description  Record a new transaction for a pending line in the statement's account: income for money in, expense for money out, on the line's date.
+// @Tags         Bank Imports
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string                        true   "Line ID"
+// @Param        request  body      models.CreateFromLinePayload  false  "Category for the new transaction"
+// @Success      201      {object}  utils.Response{data=models.BankImportLineResponse}  "Transaction created successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid request data"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404      {object}  utils.Response{message=string}  "Line not found"
+// @Failure      409      {object}  utils.Response{message=string}  "Line already resolved"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /bank-imports/lines/{id}/create [post]
+func (h *BankImportHandler) CreateFromLine(w http.ResponseWriter, r *http.Request) {
+	var payload models.CreateFromLinePayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	if r.ContentLength != 0 {
+		if err := utils.ParseJson(r, &payload); err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Data yang dikirim tidak sesuai",
+			})
+			return
+		}
+	}
+
+	line, ok := h.getPendingLine(w, r)
+	if !ok {
+		return
+	}
+
+	if status, msg := checkCategory(ctx, &h.categoryRepo, payload.CategoryID, userID, line.TransactionType()); status != 0 {
+		utils.ResponseJson(w, status, utils.Response{
+			Message: msg,
+		})
+		return
+	}
+
+	transaction := h.lineTransaction(r, *line)
+	transaction.CategoryID = payload.CategoryID
+	err := h.bankImportRepo.CreateFromLine(ctx, *line, &transaction)
+	if !h.handleResolveError(w, err) {
+		return
+	}
+	line.Status = models.BankImportLineCreated
+	line.TransactionID = &transaction.ID
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityType: "bank_import_lines",
+		EntityID:   line.ID,
+		After:      transaction,
+	})
+
+	utils.ResponseJson(w, http.StatusCreated, utils.Response{
+		Message: "Berhasil membuat transaksi dari mutasi",
+		Data: models.BankImportLineResponse{
+			Line: *line,
+		},
+	})
+}
+
+// IgnoreLine godoc
+// @Summary      Ignore a bank import line
+// @Description  Mark a pending line as not needing a transaction, such as an internal movement already recorded as a transfer
+// @Tags         Bank Imports
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Line ID"
+// @Success      200  {object}  utils.Response{data=models.BankImportLineResponse}  "Line ignored successfully"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404  {object}  utils.Response{message=string}  "Line not found"
+// @Failure      409  {object}  utils.Response{message=string}  "Line already resolved"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /bank-imports/lines/{id}/ignore [post]
+func (h *BankImportHandler) IgnoreLine(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	line, ok := h.getPendingLine(w, r)
+	if !ok {
+		return
+	}
+
+	if !h.handleResolveError(w, h.bankImportRepo.IgnoreLine(ctx, line.ID)) {
+		return
+	}
+	line.Status = models.BankImportLineIgnored
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityType: "bank_import_lines",
+		EntityID:   line.ID,
+	})
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengabaikan mutasi",
+		Data: models.BankImportLineResponse{
+			Line: *line,
+		},
+	})
+}
+
+// getPendingLine loads the line in the id path parameter, writing a 404, 409
+// or 500 itself when it cannot be resolved.
+func (h *BankImportHandler) getPendingLine(w http.ResponseWriter, r *http.Request) (*models.BankImportLine, bool) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	id := r.PathValue("id")
+
+	if _, err := uuid.Parse(id); err != nil {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Mutasi tidak ditemukan",
+		})
+		return nil, false
+	}
+
+	line, err := h.bankImportRepo.GetLine(ctx, id, userID)
+	if err == sql.ErrNoRows {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Mutasi tidak ditemukan",
+		})
+		return nil, false
+	}
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data mutasi",
+		})
+		return nil, false
+	}
+
+	if line.Status != models.BankImportLinePending {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Mutasi sudah diproses",
+		})
+		return nil, false
+	}
+
+	return line, true
+}
+
+// handleResolveError writes the response for an error from resolving a line
+// and reports whether there was none.
+func (h *BankImportHandler) handleResolveError(w http.ResponseWriter, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case store.ErrImportLineResolved:
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Mutasi sudah diproses",
+		})
+	case store.ErrAlreadyMatched:
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Transaksi sudah dicocokkan dengan mutasi lain",
+		})
+	case store.ErrMatchMismatch, store.ErrOrderNotMatchable:
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Transaksi atau pesanan tidak sesuai dengan mutasi",
+		})
+	default:
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal memproses mutasi",
+		})
+	}
+	return false
+}
+
+// lineTransaction builds the transaction recorded for a line, dated at the
+// start of the line's day in the merchant's time zone.
+func (h *BankImportHandler) lineTransaction(r *http.Request, line models.BankImportLine) models.Transaction {
+	loc := middleware.GetLocation(r.Context())
+	id, _ := uuid.NewV7()
+
+	amount := line.Amount
+	if amount < 0 {
+		amount = -amount
+	}
+
+	return models.Transaction{
+		ID:              id.String(),
+		UserID:          line.UserID,
+		Type:            line.TransactionType(),
+		Source:          "import",
+		Amount:          amount,
+		TransactionDate: time.Date(line.Date.Year(), line.Date.Month(), line.Date.Day(), 0, 0, 0, 0, loc),
+		AccountID:       &line.AccountID,
+	}
+}
+
+// rankSuggestions scores suggestions and keeps the best maxSuggestions.
+func rankSuggestions(line models.BankImportLine, suggestions []models.MatchSuggestion, loc *time.Location) []models.MatchSuggestion {
+	for i, suggestion := range suggestions {
+		year, month, day := suggestion.Date.In(loc).Date()
+		date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		days := int(date.Sub(line.Date).Hours() / 24)
+		suggestions[i].Confidence = bankimport.Confidence(line.Description, days, suggestion.Description)
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Confidence > suggestions[j].Confidence
+	})
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	return suggestions
+}
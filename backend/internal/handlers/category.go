@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/Cakra17/imphnen/internal/middleware"
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/Cakra17/imphnen/internal/store"
+	"github.com/Cakra17/imphnen/internal/utils"
+	"github.com/Cakra17/imphnen/internal/validation"
+	"github.com/google/uuid"
+)
+
+type CategoryHandler struct {
+	categoryRepo store.CategoryRepo
+}
+
+type CategoryHandlerConfig struct {
+	CategoryRepo store.CategoryRepo
+}
+
+func NewCategoryHandler(cfg CategoryHandlerConfig) CategoryHandler {
+	return CategoryHandler{
+		categoryRepo: cfg.CategoryRepo,
+	}
+}
+
+// CreateCategory godoc
+// @Summary      Create a transaction category
+// @Description  Create a new income or expense category for the authenticated user
+// @Tags         Categories
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.CreateCategoryPayload  true  "Category details"
+// @Success      201      {object}  utils.Response{data=models.CategoryResponse}  "Category created successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid request data"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      409      {object}  utils.Response{message=string}  "Category with the same name already exists"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /categories [post]
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var payload models.CreateCategoryPayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return
+			}
+		}
+	}
+
+	if payload.IsCOGS && payload.Type != "expense" {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Hanya kategori pengeluaran yang dapat ditandai sebagai HPP",
+		})
+		return
+	}
+
+	id, _ := uuid.NewV7()
+	category := models.TransactionCategory{
+		ID:     id.String(),
+		UserID: userID,
+		Name:   payload.Name,
+		Type:   payload.Type,
+		IsCOGS: payload.IsCOGS,
+	}
+
+	err := h.categoryRepo.Create(ctx, &category)
+	if err == store.ErrCategoryExists {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Kategori dengan nama tersebut sudah ada",
+		})
+		return
+	}
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membuat kategori",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityID: category.ID,
+		After:    category,
+	})
+
+	utils.ResponseJson(w, http.StatusCreated, utils.Response{
+		Message: "Berhasil membuat kategori",
+		Data: models.CategoryResponse{
+			Category: category,
+		},
+	})
+}
+
+// GetCategories godoc
+// @Summary      List transaction categories
+// @Description  List the authenticated user's categories, optionally filtered by type
+// @Tags         Categories
+// @Produce      json
+// @Security     BearerAuth
+// @Param        type  query     string  false  "Category type (income/expense)"
+// @Success      200   {object}  utils.Response{data=models.CategoryListResponse}  "Categories retrieved successfully"
+// @Failure      400   {object}  utils.Response{message=string}  "Invalid type"
+// @Failure      401   {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500   {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /categories [get]
+func (h *CategoryHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	var categoryType *string
+	if t := r.URL.Query().Get("type"); t != "" {
+		if t != "income" && t != "expense" {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter type harus 'income' atau 'expense'",
+			})
+			return
+		}
+		categoryType = &t
+	}
+
+	categories, err := h.categoryRepo.GetCategories(ctx, userID, categoryType)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data kategori",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil data kategori",
+		Data: models.CategoryListResponse{
+			Categories: categories,
+		},
+	})
+}
+
+// UpdateCategory godoc
+// @Summary      Update a transaction category
+// @Description  Rename a category or change whether it holds stock purchases (is_cogs). The type cannot change because transactions are already assigned to it.
+// @Tags         Categories
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string                        true  "Category ID"
+// @Param        request  body      models.UpdateCategoryPayload  true  "New category details"
+// @Success      200      {object}  utils.Response{message=string}  "Category updated successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid request data"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404      {object}  utils.Response{message=string}  "Category not found"
+// @Failure      409      {object}  utils.Response{message=string}  "Category with the same name already exists"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /categories/{id} [put]
+func (h *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	var payload models.UpdateCategoryPayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	id := r.PathValue("id")
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return
+			}
+		}
+	}
+
+	if payload.IsCOGS {
+		category, err := h.categoryRepo.GetCategoryByID(ctx, id, userID)
+		if err == sql.ErrNoRows {
+			utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+				Message: "Kategori tidak ditemukan",
+			})
+			return
+		}
+		if err != nil {
+			utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+				Message: "Gagal mengubah kategori",
+			})
+			return
+		}
+		if category.Type != "expense" {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Hanya kategori pengeluaran yang dapat ditandai sebagai HPP",
+			})
+			return
+		}
+	}
+
+	updated, err := h.categoryRepo.UpdateCategory(ctx, id, userID, payload.Name, payload.IsCOGS)
+	if err == store.ErrCategoryExists {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Kategori dengan nama tersebut sudah ada",
+		})
+		return
+	}
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengubah kategori",
+		})
+		return
+	}
+
+	if !updated {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Kategori tidak ditemukan",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengubah kategori",
+	})
+}
+
+// DeleteCategory godoc
+// @Summary      Delete a transaction category
+// @Description  Delete a category. Transactions and receipts that used it become uncategorised.
+// @Tags         Categories
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Category ID"
+// @Success      200  {object}  utils.Response{message=string}  "Category deleted successfully"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404  {object}  utils.Response{message=string}  "Category not found"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /categories/{id} [delete]
+func (h *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	id := r.PathValue("id")
+
+	deleted, err := h.categoryRepo.DeleteCategory(ctx, id, userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal menghapus kategori",
+		})
+		return
+	}
+
+	if !deleted {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Kategori tidak ditemukan",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil menghapus kategori",
+	})
+}
+
+// checkCategory verifies that categoryID, when set, belongs to the user and
+// matches the transaction type. It returns the status and message to respond
+// with, or 0 when the category can be used.
+func checkCategory(ctx context.Context, categoryRepo *store.CategoryRepo, categoryID *string, userID, transactionType string) (int, string) {
+	if categoryID == nil {
+		return 0, ""
+	}
+
+	if _, err := uuid.Parse(*categoryID); err != nil {
+		return http.StatusBadRequest, "Kategori tidak ditemukan"
+	}
+
+	category, err := categoryRepo.GetCategoryByID(ctx, *categoryID, userID)
+	if err == sql.ErrNoRows {
+		return http.StatusBadRequest, "Kategori tidak ditemukan"
+	}
+	if err != nil {
+		return http.StatusInternalServerError, "Gagal mengambil data kategori"
+	}
+
+	if category.Type != transactionType {
+		return http.StatusBadRequest, fmt.Sprintf("Kategori %s bukan kategori %s", category.Name, transactionType)
+	}
+
+	return 0, ""
+}
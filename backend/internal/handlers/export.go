@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/export"
+	"github.com/Cakra17/imphnen/internal/middleware"
+	"github.com/Cakra17/imphnen/internal/utils"
+)
+
+// exportFormat reads the format query parameter, defaulting to csv. It writes
+// a 400 and returns false for other formats.
+func exportFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if !export.ValidFormat(format) {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Parameter format harus 'csv' atau 'xlsx'",
+		})
+		return "", false
+	}
+	return format, true
+}
+
+// startExport sends the download headers and returns a writer for the file.
+// Everything that can be rejected with a 400 must be checked before, since
+// the status code is sent here; a writer that fails to start aborts the
+// response. start and end are the exported range, end exclusive, and only
+// used for the file name.
+func startExport(
+	w http.ResponseWriter, r *http.Request, format, name string, columns []export.Column, start, end time.Time,
+) export.Writer {
+	filename := fmt.Sprintf(
+		"%s-%s-%s.%s", name, start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02"), format,
+	)
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	writer, err := export.NewWriter(format, w, name, columns, middleware.GetLocation(r.Context()))
+	if err != nil {
+		log.Printf("[ERROR] Failed to start %s export: %s", name, err.Error())
+		panic(http.ErrAbortHandler)
+	}
+	return writer
+}
+
+// finishExport completes the file. The export is streamed, so once rows are
+// out the status cannot change anymore. On an error the response is aborted
+// instead, so the client sees a failed download rather than a file that
+// looks complete but is missing rows.
+func finishExport(writer export.Writer, name string, err error) {
+	if err != nil {
+		log.Printf("[ERROR] Failed to write %s export: %s", name, err.Error())
+		panic(http.ErrAbortHandler)
+	}
+	if err := writer.Close(); err != nil {
+		log.Printf("[ERROR] Failed to finish %s export: %s", name, err.Error())
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func transactionTypeLabel(transactionType string) string {
+	if transactionType == "income" {
+		return "Pemasukan"
+	}
+	return "Pengeluaran"
+}
+
+func voidedLabel(voidedAt *time.Time) string {
+	if voidedAt != nil {
+		return "Dibatalkan"
+	}
+	return "Aktif"
+}
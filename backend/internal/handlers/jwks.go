@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Cakra17/imphnen/internal/utils"
+)
+
+type JWKSHandler struct {
+	keys *utils.KeySet
+}
+
+type JWKSHandlerConfig struct {
+	Keys *utils.KeySet
+}
+
+func NewJWKSHandler(cfg JWKSHandlerConfig) JWKSHandler {
+	return JWKSHandler{
+		keys: cfg.Keys,
+	}
+}
+
+// GetJWKS serves the public signing keys as a JSON Web Key Set. It lives
+// outside /api/v1 at the well-known path and is not wrapped in utils.Response
+// because JWKS clients expect the bare document.
+func (h *JWKSHandler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	utils.ResponseJson(w, http.StatusOK, h.keys.JWKS())
+}
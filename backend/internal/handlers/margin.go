@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/middleware"
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/Cakra17/imphnen/internal/utils"
+)
+
+// GetProductMargins godoc
+// @Summary      Get gross margin per product
+// @Description  Revenue, cost and gross margin of every product sold in confirmed orders between from and to, most profitable first. Cost uses the product's cost price at the time of each sale.
+// @Tags         Reports
+// @Produce      json
+// @Security     BearerAuth
+// @Param        from  query     string  false  "Start date in YYYY-MM-DD format (default: first day of the month five months ago)"
+// @Param        to    query     string  false  "End date in YYYY-MM-DD format (default: today)"
+// @Success      200   {object}  utils.Response{data=models.ProductMarginReport}  "Margins retrieved successfully"
+// @Failure      400   {object}  utils.Response{message=string}  "Invalid parameters"
+// @Failure      401   {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500   {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /reports/margins/products [get]
+func (h *ReportHandler) GetProductMargins(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	loc := middleware.GetLocation(ctx)
+
+	start, end, _, ok := parsePeriodRange(w, r, defaultMarginFrom(loc), "day")
+	if !ok {
+		return
+	}
+
+	products, err := h.reportRepo.GetProductMargins(ctx, userID, start, end, loc)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil laporan margin",
+		})
+		return
+	}
+
+	report := models.ProductMarginReport{
+		From:     start.Format("2006-01-02"),
+		To:       end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Products: products,
+	}
+	for _, product := range products {
+		report.Summary.Add(product.MarginFigures)
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil laporan margin",
+		Data:    report,
+	})
+}
+
+// GetCategoryMargins godoc
+// @Summary      Get gross margin per category
+// @Description  Revenue, cost and gross margin of products sold in confirmed orders between from and to, grouped by the products' current category, most profitable first
+// @Tags         Reports
+// @Produce      json
+// @Security     BearerAuth
+// @Param        from  query     string  false  "Start date in YYYY-MM-DD format (default: first day of the month five months ago)"
+// @Param        to    query     string  false  "End date in YYYY-MM-DD format (default: today)"
+// @Success      200   {object}  utils.Response{data=models.CategoryMarginReport}  "Margins retrieved successfully"
+// @Failure      400   {object}  utils.Response{message=string}  "Invalid parameters"
+// @Failure      401   {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500   {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /reports/margins/categories [get]
+func (h *ReportHandler) GetCategoryMargins(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	loc := middleware.GetLocation(ctx)
+
+	start, end, _, ok := parsePeriodRange(w, r, defaultMarginFrom(loc), "day")
+	if !ok {
+		return
+	}
+
+	categories, err := h.reportRepo.GetCategoryMargins(ctx, userID, start, end, loc)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil laporan margin",
+		})
+		return
+	}
+
+	report := models.CategoryMarginReport{
+		From:       start.Format("2006-01-02"),
+		To:         end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Categories: categories,
+	}
+	for _, category := range categories {
+		report.Summary.Add(category.MarginFigures)
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil laporan margin",
+		Data:    report,
+	})
+}
+
+// GetMarginPeriods godoc
+// @Summary      Get gross margin per period
+// @Description  Revenue, cost and gross margin of products sold in confirmed orders per period. from and to are widened to whole periods.
+// @Tags         Reports
+// @Produce      json
+// @Security     BearerAuth
+// @Param        from         query     string  false  "Start date in YYYY-MM-DD format (default: first day of the month five months ago)"
+// @Param        to           query     string  false  "End date in YYYY-MM-DD format (default: today)"
+// @Param        granularity  query     string  false  "Period length: day, week, month, quarter or year (default: month)"
+// @Success      200          {object}  utils.Response{data=models.MarginPeriodReport}  "Margins retrieved successfully"
+// @Failure      400          {object}  utils.Response{message=string}  "Invalid parameters"
+// @Failure      401          {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500          {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /reports/margins/periods [get]
+func (h *ReportHandler) GetMarginPeriods(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	loc := middleware.GetLocation(ctx)
+
+	granularity := r.URL.Query().Get("granularity")
+	if granularity == "" {
+		granularity = "month"
+	}
+	step, ok := reportSteps[granularity]
+	if !ok {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Parameter granularity harus 'day', 'week', 'month', 'quarter', atau 'year'",
+		})
+		return
+	}
+
+	start, end, _, ok := parsePeriodRange(w, r, defaultMarginFrom(loc), granularity)
+	if !ok {
+		return
+	}
+
+	periods, err := h.reportRepo.GetMarginPeriods(ctx, userID, granularity, step, start, end, loc)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil laporan margin",
+		})
+		return
+	}
+
+	report := models.MarginPeriodReport{
+		From:        start.Format("2006-01-02"),
+		To:          end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Granularity: granularity,
+		Periods:     periods,
+	}
+	for i := range report.Periods {
+		report.Periods[i].PeriodEnd = addPeriods(report.Periods[i].PeriodStart, granularity, 1).AddDate(0, 0, -1)
+		report.Summary.Add(report.Periods[i].MarginFigures)
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil laporan margin",
+		Data:    report,
+	})
+}
+
+// defaultMarginFrom is the first day of the month five months ago, so the
+// default range covers the last six months like the profit and loss report.
+func defaultMarginFrom(loc *time.Location) time.Time {
+	today := truncatePeriod(time.Now().In(loc), "day")
+	return time.Date(today.Year(), today.Month()-5, 1, 0, 0, 0, 0, time.UTC)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/Cakra17/imphnen/internal/export"
 	"github.com/Cakra17/imphnen/internal/middleware"
 	"github.com/Cakra17/imphnen/internal/models"
 	"github.com/Cakra17/imphnen/internal/store"
@@ -241,6 +242,86 @@ func (h *OrderHandler) GetOrdersByCustomer(w http.ResponseWriter, r *http.Reques
 	})
 }
 
+// ExportOrders streams the merchant's orders with their line items as a file
+// @Summary Export orders
+// @Description Download orders as CSV or XLSX with one row per line item, streamed row by row. Dates are in the merchant's time zone and amounts in rupiah.
+// @Tags Orders
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv or xlsx (default: csv)"
+// @Param start_date query string false "Start date in YYYY-MM-DD format (default: 30 days ago)"
+// @Param end_date query string false "End date in YYYY-MM-DD format (default: today)"
+// @Param customer_id query string false "Filter by customer ID"
+// @Param status query string false "Filter by status (pending, confirmed, cancelled)"
+// @Success 200 {file} file "Order export"
+// @Failure 400 {object} utils.Response
+// @Security BearerAuth
+// @Router /orders/export [get]
+func (h *OrderHandler) ExportOrders(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	format, ok := exportFormat(w, r)
+	if !ok {
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(w, r, 30)
+	if !ok {
+		return
+	}
+
+	filter := models.OrderExportFilter{
+		UserID:    userID,
+		StartDate: startDate,
+		EndDate:   endDate,
+	}
+
+	if customerID := r.URL.Query().Get("customer_id"); customerID != "" {
+		if _, err := strconv.Atoi(customerID); err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter customer_id harus berupa angka",
+			})
+			return
+		}
+		filter.CustomerID = &customerID
+	}
+
+	if statusStr := r.URL.Query().Get("status"); statusStr != "" {
+		status := models.OrderStatus(statusStr)
+		if status != models.OrderStatusPending &&
+			status != models.OrderStatusConfirmed &&
+			status != models.OrderStatusCancelled {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter status harus 'pending', 'confirmed', atau 'cancelled'",
+			})
+			return
+		}
+		filter.Status = &status
+	}
+
+	writer := startExport(w, r, format, "pesanan", []export.Column{
+		{Title: "Tanggal Pesanan", Kind: export.DateTime},
+		{Title: "ID Pesanan", Kind: export.Text},
+		{Title: "Status", Kind: export.Text},
+		{Title: "Pelanggan", Kind: export.Text},
+		{Title: "Telepon", Kind: export.Text},
+		{Title: "Total Pesanan", Kind: export.Money},
+		{Title: "Produk", Kind: export.Text},
+		{Title: "Jumlah", Kind: export.Number},
+		{Title: "Subtotal", Kind: export.Money},
+	}, startDate, endDate)
+
+	err := h.orderRepo.ExportOrders(ctx, filter, func(row models.OrderExportRow) error {
+		return writer.WriteRow(
+			row.OrderDate, row.OrderID, string(row.Status), row.CustomerName, row.CustomerPhone,
+			row.OrderTotal, row.ProductName, row.Quantity, row.ItemTotal,
+		)
+	})
+	finishExport(writer, "pesanan", err)
+}
+
 // UpdateOrderStatus updates the status of an order
 // @Summary Update order status
 // @Description Update order status (pending, confirmed, cancelled) with automatic stock restoration
@@ -328,8 +409,27 @@ func (h *OrderHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		Action:     orderStatusAction(payload.Status),
+		EntityType: "orders",
+		EntityID:   orderID,
+		Before:     order,
+		After:      updatedOrder,
+	})
+
 	utils.ResponseJson(w, http.StatusOK, utils.Response{
 		Message: "Berhasil mengupdate status pesanan",
 		Data:    updatedOrder,
 	})
 }
+
+func orderStatusAction(status models.OrderStatus) string {
+	switch status {
+	case models.OrderStatusCancelled:
+		return "cancel"
+	case models.OrderStatusConfirmed:
+		return "confirm"
+	default:
+		return "update_status"
+	}
+}
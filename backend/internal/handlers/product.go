@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"math"
@@ -13,24 +16,31 @@ import (
 	"github.com/Cakra17/imphnen/internal/models"
 	"github.com/Cakra17/imphnen/internal/store"
 	"github.com/Cakra17/imphnen/internal/utils"
+	"github.com/Cakra17/imphnen/internal/validation"
 	"github.com/Cakra17/imphnen/pkg/service"
 	"github.com/google/uuid"
 )
 
 type ProductHandler struct {
-	productRepo store.ProductRepo
-	cld         service.CloudinaryService
+	productRepo  store.ProductRepo
+	categoryRepo store.CategoryRepo
+	receiptRepo  store.ReceiptRepo
+	cld          service.CloudinaryService
 }
 
 type ProductHandlerConfig struct {
-	ProductRepo store.ProductRepo
-	Cld         service.CloudinaryService
+	ProductRepo  store.ProductRepo
+	CategoryRepo store.CategoryRepo
+	ReceiptRepo  store.ReceiptRepo
+	Cld          service.CloudinaryService
 }
 
 func NewProductHandler(cfg ProductHandlerConfig) ProductHandler {
 	return ProductHandler{
-		productRepo: cfg.ProductRepo,
-		cld:         cfg.Cld,
+		productRepo:  cfg.ProductRepo,
+		categoryRepo: cfg.CategoryRepo,
+		receiptRepo:  cfg.ReceiptRepo,
+		cld:          cfg.Cld,
 	}
 }
 
@@ -44,6 +54,8 @@ func NewProductHandler(cfg ProductHandlerConfig) ProductHandler {
 // @Param name formData string true "Product name"
 // @Param price formData string true "Product price"
 // @Param stock formData string true "Product stock"
+// @Param cost_price formData string false "Cost of one unit, kept up to date by restocks (default: 0)"
+// @Param category_id formData string false "Income category the product is reported under"
 // @Security BearerAuth
 // @Success 201 {object} utils.Response{data=models.Product}
 // @Failure 400 {object} utils.Response
@@ -106,6 +118,28 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var costPrice float64
+	if costVal := r.FormValue("cost_price"); costVal != "" {
+		costPrice, err = strconv.ParseFloat(costVal, 64)
+		if err != nil || costPrice < 0 {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Format harga pokok tidak sesuai",
+			})
+			return
+		}
+	}
+
+	var categoryID *string
+	if categoryVal := r.FormValue("category_id"); categoryVal != "" {
+		categoryID = &categoryVal
+	}
+	if status, msg := checkCategory(ctx, &h.categoryRepo, categoryID, userID, "income"); status != 0 {
+		utils.ResponseJson(w, status, utils.Response{
+			Message: msg,
+		})
+		return
+	}
+
 	secureUrl, publicID, err := h.cld.UploadMedia(ctx, "products", media)
 	if err != nil {
 		log.Printf("%s", err.Error())
@@ -117,13 +151,15 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 
 	id, _ := uuid.NewV7()
 	product := &models.Product{
-		ID:       id.String(),
-		UserID:   userID,
-		Name:     name,
-		Price:    price,
-		Stock:    int(stock),
-		ImageURL: secureUrl,
-		PublicID: publicID,
+		ID:         id.String(),
+		UserID:     userID,
+		Name:       name,
+		Price:      price,
+		Stock:      int(stock),
+		ImageURL:   secureUrl,
+		PublicID:   publicID,
+		CostPrice:  costPrice,
+		CategoryID: categoryID,
 	}
 
 	err = h.productRepo.AddProduct(ctx, product)
@@ -135,6 +171,12 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityType: "products",
+		EntityID:   product.ID,
+		After:      product,
+	})
+
 	utils.ResponseJson(w, http.StatusCreated, utils.Response{
 		Message: "Berhasil menambahkan product",
 		Data:    product,
@@ -238,6 +280,8 @@ func (h *ProductHandler) GetProductByID(w http.ResponseWriter, r *http.Request)
 // @Param name formData string false "Product name"
 // @Param price formData string false "Product price"
 // @Param stock formData string false "Product stock"
+// @Param cost_price formData string false "Cost of one unit"
+// @Param category_id formData string false "Income category the product is reported under"
 // @Security BearerAuth
 // @Success 200 {object} utils.Response{data=models.Product}
 // @Failure 400 {object} utils.Response
@@ -256,6 +300,7 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	oldUrl := existingProduct.ImageURL
+	before := existingProduct
 
 	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadSize)
 	if err := r.ParseMultipartForm(MaxUploadSize); err != nil {
@@ -302,6 +347,29 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		existingProduct.Stock = int(stock)
 	}
 
+	if costVal := r.FormValue("cost_price"); costVal != "" {
+		costPrice, err := strconv.ParseFloat(costVal, 64)
+		if err != nil || costPrice < 0 {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Format harga pokok tidak sesuai",
+			})
+			return
+		}
+		existingProduct.CostPrice = costPrice
+	}
+
+	if categoryVal := r.FormValue("category_id"); categoryVal != "" {
+		claims, _ := middleware.GetClaims(ctx)
+		userID, _ := claims["user_id"].(string)
+		if status, msg := checkCategory(ctx, &h.categoryRepo, &categoryVal, userID, "income"); status != 0 {
+			utils.ResponseJson(w, status, utils.Response{
+				Message: msg,
+			})
+			return
+		}
+		existingProduct.CategoryID = &categoryVal
+	}
+
 	media, header, err := r.FormFile("image")
 	if err == nil {
 		defer media.Close()
@@ -322,7 +390,7 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
-		if err := h.cld.DeleteMedia(ctx, existingProduct.PublicID); err != nil {
+		if err := h.cld.DeleteMedia(ctx, existingProduct.PublicID); err != nil && !errors.Is(err, service.ErrMediaNotFound) {
 			log.Printf("%s", err.Error())
 			utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
 				Message: "Gagal mengdate gambar",
@@ -345,6 +413,13 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityType: "products",
+		EntityID:   existingProduct.ID,
+		Before:     before,
+		After:      existingProduct,
+	})
+
 	utils.ResponseJson(w, http.StatusOK, utils.Response{
 		Message: "Berhasil mengupdate produk",
 		Data:    existingProduct,
@@ -382,7 +457,13 @@ func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.cld.DeleteMedia(ctx, product.PublicID); err != nil {
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityType: "products",
+		EntityID:   product.ID,
+		Before:     product,
+	})
+
+	if err := h.cld.DeleteMedia(ctx, product.PublicID); err != nil && !errors.Is(err, service.ErrMediaNotFound) {
 		log.Printf("%s", err.Error())
 		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
 			Message: "Gagal mengdate gambar",
@@ -394,3 +475,168 @@ func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 		Message: "Berhasil menghapus produk",
 	})
 }
+
+// RestockProduct godoc
+// @Summary Restock a product
+// @Description Add stock to a product and update its cost price to the weighted average of the units in stock and the new ones. With receipt_item_id the stock is booked against an item of a receipt, once per item, and unit_cost defaults to the item's price divided by the quantity.
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param request body models.RestockProductPayload true "Restock data"
+// @Security BearerAuth
+// @Success 201 {object} utils.Response{data=models.ProductRestock}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /products/{id}/restock [post]
+func (h *ProductHandler) RestockProduct(w http.ResponseWriter, r *http.Request) {
+	var payload models.RestockProductPayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	productID := r.PathValue("id")
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return
+			}
+		}
+	}
+
+	if payload.UnitCost != nil && *payload.UnitCost < 0 {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "unit_cost tidak boleh negatif",
+		})
+		return
+	}
+
+	if _, err := uuid.Parse(productID); err != nil {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Produk tidak ditemukan",
+		})
+		return
+	}
+
+	id, _ := uuid.NewV7()
+	restock := models.ProductRestock{
+		ID:            id.String(),
+		UserID:        userID,
+		ProductID:     productID,
+		ReceiptItemID: payload.ReceiptItemID,
+		Quantity:      payload.Quantity,
+	}
+
+	if payload.ReceiptItemID != nil {
+		if _, err := uuid.Parse(*payload.ReceiptItemID); err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Item receipt tidak ditemukan",
+			})
+			return
+		}
+
+		item, err := h.receiptRepo.GetReceiptItemByID(ctx, *payload.ReceiptItemID, userID)
+		if err == sql.ErrNoRows {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Item receipt tidak ditemukan",
+			})
+			return
+		}
+		if err != nil {
+			utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+				Message: "Gagal mengambil data receipt",
+			})
+			return
+		}
+		restock.UnitCost = math.Round(item.Price/float64(payload.Quantity)*100) / 100
+	}
+
+	if payload.UnitCost != nil {
+		restock.UnitCost = *payload.UnitCost
+	} else if payload.ReceiptItemID == nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "unit_cost diperlukan jika tidak menggunakan item receipt",
+		})
+		return
+	}
+
+	err := h.productRepo.RestockProduct(ctx, &restock)
+	if err == sql.ErrNoRows {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Produk tidak ditemukan",
+		})
+		return
+	}
+	if err == store.ErrReceiptItemRestocked {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Item receipt sudah digunakan untuk menambah stok",
+		})
+		return
+	}
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal menambah stok produk",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityType: "products",
+		EntityID:   productID,
+		After:      restock,
+	})
+
+	utils.ResponseJson(w, http.StatusCreated, utils.Response{
+		Message: "Berhasil menambah stok produk",
+		Data:    restock,
+	})
+}
+
+// GetProductRestocks godoc
+// @Summary Get product restocks
+// @Description List the restocks of a product, newest first, with the cost price before and after each
+// @Tags Product
+// @Produce json
+// @Param id path string true "Product ID"
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.ProductRestock}
+// @Failure 500 {object} utils.Response
+// @Router /products/{id}/restocks [get]
+func (h *ProductHandler) GetProductRestocks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	productID := r.PathValue("id")
+
+	if _, err := uuid.Parse(productID); err != nil {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Produk tidak ditemukan",
+		})
+		return
+	}
+
+	restocks, err := h.productRepo.GetRestocks(ctx, productID, userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil riwayat stok produk",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil riwayat stok produk",
+		Data:    restocks,
+	})
+}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"fmt"
 	"io"
-	"log"
 	"math"
 	"net/http"
 	"path/filepath"
@@ -11,16 +13,20 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Cakra17/imphnen/internal/export"
 	"github.com/Cakra17/imphnen/internal/middleware"
 	"github.com/Cakra17/imphnen/internal/models"
 	"github.com/Cakra17/imphnen/internal/store"
 	"github.com/Cakra17/imphnen/internal/utils"
-	"github.com/Cakra17/imphnen/pkg/service"
+	"github.com/Cakra17/imphnen/internal/validation"
 	"github.com/google/uuid"
 )
 
 const (
 	MaxUploadSize = 5 << 20
+	// receiptImageHashDistance is how many bits the image hashes of two
+	// photos of the same receipt may differ in.
+	receiptImageHashDistance = 6
 )
 
 var allowedType map[string]bool = map[string]bool{
@@ -30,39 +36,40 @@ var allowedType map[string]bool = map[string]bool{
 }
 
 type ReceiptHandler struct {
-	receiptRepo     store.ReceiptRepo
-	transactionRepo store.TransactionRepo
-	cld             service.CloudinaryService
-	kol             service.KolosalService
+	receiptRepo  store.ReceiptRepo
+	categoryRepo store.CategoryRepo
+	accountRepo  store.AccountRepo
 }
 
 type ReceiptHandlerConfig struct {
-	ReceiptRepo     store.ReceiptRepo
-	TransactionRepo store.TransactionRepo
-	Cld             service.CloudinaryService
-	Kol             service.KolosalService
+	ReceiptRepo  store.ReceiptRepo
+	CategoryRepo store.CategoryRepo
+	AccountRepo  store.AccountRepo
 }
 
 func NewReceiptHandler(cfg ReceiptHandlerConfig) ReceiptHandler {
 	return ReceiptHandler{
-		receiptRepo:     cfg.ReceiptRepo,
-		transactionRepo: cfg.TransactionRepo,
-		cld:             cfg.Cld,
-		kol:             cfg.Kol,
+		receiptRepo:  cfg.ReceiptRepo,
+		categoryRepo: cfg.CategoryRepo,
+		accountRepo:  cfg.AccountRepo,
 	}
 }
 
 // CreateReceipt godoc
-// @Summary      Create a new receipt
-// @Description  Create a new receipt with items for the authenticated user
+// @Summary      Upload a receipt
+// @Description  Queue a receipt image for scanning. The receipt is returned right away with status processing; a background worker reads it, stores the image and saves what it read as a draft with its items, retrying with backoff. Poll GET /receipts/{id}/status until the status is draft or failed, then review the draft and confirm it to create the expense transaction. An upload of the same file, or of a photo that looks the same, as an earlier receipt is rejected with a reference to that receipt unless allow_duplicate is true.
 // @Tags         Receipts
 // @Accept       x-www-form-urlencoded
-// @Param        image   formData  file  true  "receipt to scan"
+// @Param        image            formData  file    true   "receipt to scan"
+// @Param        category_id      formData  string  false  "Expense category ID"
+// @Param        account_id       formData  string  false  "Account the receipt was paid from (default: the default account)"
+// @Param        allow_duplicate  formData  bool    false  "Upload even if the receipt looks like a duplicate"
 // @Produce      json
 // @Security     BearerAuth
-// @Success      201      {object}  utils.Response{data=models.ReceiptResponse}  "Receipt created successfully"
+// @Success      202      {object}  utils.Response{data=models.ReceiptResponse}  "Receipt queued for processing"
 // @Failure      400      {object}  utils.Response{message=string}  "Invalid request data"
 // @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      409      {object}  utils.Response{data=models.ReceiptDuplicate}  "Receipt looks like a duplicate"
 // @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
 // @Router       /receipts [post]
 func (h *ReceiptHandler) CreateReceipt(w http.ResponseWriter, r *http.Request) {
@@ -98,100 +105,149 @@ func (h *ReceiptHandler) CreateReceipt(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
-
-	resp, err := h.kol.OCRForm(media, header.Filename)
+	image, err := io.ReadAll(media)
 	if err != nil {
-		log.Printf("[ERROR] %s", err.Error())
-		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
-			Message: "Terjadi kesalahan pada OCR",
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Gagal menerima gambar",
 		})
 		return
 	}
 
-	secureUrl, publidID, err := h.cld.UploadMedia(ctx, "receipts", media)
-	if err != nil {
+	// Rejected here rather than by the worker, which would retry in vain.
+	if !strings.HasPrefix(http.DetectContentType(image), "image/") {
 		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
-			Message: "Terjadi kesalahan dalam menyimpan gambar",
+			Message: "Format tidak sesuai",
 		})
 		return
 	}
 
+	ctx := r.Context()
 	claims, _ := middleware.GetClaims(ctx)
 	userID, _ := claims["user_id"].(string)
 
-	receiptID, _ := uuid.NewV7()
-	receipt := models.Receipt{
-		ID:         receiptID.String(),
-		UserID:     userID,
-		StoreName:  resp.Issuer.Name,
-		TotalItems: uint32(len(resp.InvoiceItems)),
-		TotalPrice: resp.Total,
-		ImageURL:   secureUrl,
-		PublicID:   publidID,
+	var categoryID *string
+	if c := r.FormValue("category_id"); c != "" {
+		categoryID = &c
 	}
 
-	if err := h.receiptRepo.Create(ctx, &receipt); err != nil {
-		h.cld.DeleteMedia(ctx, publidID)
-		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
-			Message: "Gagal membuat receipt",
+	if status, msg := checkCategory(ctx, &h.categoryRepo, categoryID, userID, "expense"); status != 0 {
+		utils.ResponseJson(w, status, utils.Response{
+			Message: msg,
 		})
 		return
 	}
 
-	// Create receipt items
-	items := []models.ReceiptItem{}
-	for _, itemPayload := range resp.InvoiceItems {
-		itemID, _ := uuid.NewV7()
-		item := models.ReceiptItem{
-			ID:        itemID.String(),
-			ReceiptID: receiptID.String(),
-			Name:      itemPayload.Description,
-			Price:     itemPayload.Total,
-		}
-		items = append(items, item)
+	var accountID *string
+	if a := r.FormValue("account_id"); a != "" {
+		accountID = &a
 	}
 
-	if err := h.receiptRepo.CreateItems(ctx, items); err != nil {
-		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
-			Message: "Gagal membuat item receipt",
+	if status, msg := checkAccount(ctx, &h.accountRepo, accountID, userID); status != 0 {
+		utils.ResponseJson(w, status, utils.Response{
+			Message: msg,
 		})
 		return
 	}
 
-	// Create Transaction
-	tscID, _ := uuid.NewV7()
-	date, err := time.Parse("2006-01-02", resp.InvoiceDate)
-	if err != nil {
-		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
-			Message: "Gagal membuat transaksi",
-		})
-		return
+	sum := sha256.Sum256(image)
+	contentHash := hex.EncodeToString(sum[:])
+	// Images that cannot be decoded or are too large to decode are still
+	// matched on their content.
+	var imageHash *int64
+	if hash, err := utils.DifferenceHash(image); err == nil {
+		signed := int64(hash)
+		imageHash = &signed
+	}
+
+	allowDuplicate := r.FormValue("allow_duplicate") == "true"
+	if !allowDuplicate {
+		duplicate, err := h.receiptRepo.FindUploadDuplicate(ctx, userID, contentHash, imageHash, receiptImageHashDistance)
+		if err != nil {
+			utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+				Message: "Gagal membuat receipt",
+			})
+			return
+		}
+
+		if duplicate != nil {
+			utils.ResponseJson(w, http.StatusConflict, utils.Response{
+				Message: "Receipt ini kemungkinan sudah pernah diunggah",
+				Data:    duplicate,
+			})
+			return
+		}
 	}
 
-	tsc := models.Transaction{
-		ID:              tscID.String(),
-		ReceiptID:       &receipt.ID,
-		UserID:          userID,
-		Type:            "expense",
-		Source:          "receipt",
-		Amount:          resp.Total,
-		OrderID:         nil,
-		TransactionDate: date,
+	receiptID, _ := uuid.NewV7()
+	receipt := models.Receipt{
+		ID:             receiptID.String(),
+		UserID:         userID,
+		CategoryID:     categoryID,
+		AccountID:      accountID,
+		ContentHash:    &contentHash,
+		ImageHash:      imageHash,
+		AllowDuplicate: allowDuplicate,
 	}
 
-	if err := h.transactionRepo.AddTransaction(ctx, &tsc); err != nil {
+	jobID, _ := uuid.NewV7()
+	job := models.ReceiptJob{
+		ID:       jobID.String(),
+		Image:    image,
+		Filename: header.Filename,
+	}
+
+	if err := h.receiptRepo.EnqueueReceipt(ctx, &receipt, job); err != nil {
 		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
-			Message: "Gagal membuat transaksi",
+			Message: "Gagal membuat receipt",
 		})
 		return
 	}
 
-	utils.ResponseJson(w, http.StatusCreated, utils.Response{
-		Message: "Berhasil membuat receipt",
+	utils.ResponseJson(w, http.StatusAccepted, utils.Response{
+		Message: "Receipt sedang diproses",
 		Data: models.ReceiptResponse{
 			Receipt: receipt,
-			Items:   items,
+		},
+	})
+}
+
+// GetReceiptStatus godoc
+// @Summary      Get receipt processing status
+// @Description  Status of an uploaded receipt: processing while it is being read, draft once it can be reviewed, confirmed once it is posted as an expense, or failed with the reason when every attempt failed
+// @Tags         Receipts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Receipt ID"
+// @Success      200  {object}  utils.Response{data=models.ReceiptStatusResponse}  "Receipt status retrieved successfully"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404  {object}  utils.Response{message=string}  "Receipt not found"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /receipts/{id}/status [get]
+func (h *ReceiptHandler) GetReceiptStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	receipt, err := h.receiptRepo.GetReceiptByID(ctx, r.PathValue("id"), userID)
+	if err == sql.ErrNoRows {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Receipt tidak ditemukan",
+		})
+		return
+	}
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data receipt",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil status receipt",
+		Data: models.ReceiptStatusResponse{
+			ID:            receipt.ID,
+			Status:        receipt.Status,
+			FailureReason: receipt.FailureReason,
 		},
 	})
 }
@@ -255,6 +311,60 @@ func (h *ReceiptHandler) GetReceipts(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// ExportReceipts godoc
+// @Summary      Export receipts
+// @Description  Download receipts as CSV or XLSX with one row per item, streamed row by row. Receipts are selected by invoice date in the merchant's time zone and amounts are in rupiah. Voided receipts are included and marked as such.
+// @Tags         Receipts
+// @Produce      text/csv
+// @Produce      application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security     BearerAuth
+// @Param        format      query     string  false  "csv or xlsx (default: csv)"
+// @Param        start_date  query     string  false  "Start date in YYYY-MM-DD format (default: 30 days ago)"
+// @Param        end_date    query     string  false  "End date in YYYY-MM-DD format (default: today)"
+// @Success      200         {file}    file    "Receipt export"
+// @Failure      400         {object}  utils.Response{message=string}  "Invalid parameters"
+// @Failure      401         {object}  utils.Response{message=string}  "Unauthorized"
+// @Router       /receipts/export [get]
+func (h *ReceiptHandler) ExportReceipts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	format, ok := exportFormat(w, r)
+	if !ok {
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(w, r, 30)
+	if !ok {
+		return
+	}
+
+	writer := startExport(w, r, format, "struk", []export.Column{
+		{Title: "Tanggal Struk", Kind: export.Date},
+		{Title: "ID Struk", Kind: export.Text},
+		{Title: "Toko", Kind: export.Text},
+		{Title: "Kategori", Kind: export.Text},
+		{Title: "Total", Kind: export.Money},
+		{Title: "Status", Kind: export.Text},
+		{Title: "Item", Kind: export.Text},
+		{Title: "Harga Item", Kind: export.Money},
+	}, startDate, endDate)
+
+	filter := models.ReceiptExportFilter{
+		UserID:    userID,
+		StartDate: startDate,
+		EndDate:   endDate,
+	}
+	err := h.receiptRepo.ExportReceipts(ctx, filter, func(row models.ReceiptExportRow) error {
+		return writer.WriteRow(
+			row.InvoiceDate, row.ReceiptID, row.StoreName, row.CategoryName,
+			row.Total, voidedLabel(row.VoidedAt), row.ItemName, row.ItemPrice,
+		)
+	})
+	finishExport(writer, "struk", err)
+}
+
 // GetReceiptByID godoc
 // @Summary      Get receipt by ID
 // @Description  Get a specific receipt with its items by ID for the authenticated user
@@ -312,6 +422,610 @@ func (h *ReceiptHandler) GetReceiptByID(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// VoidReceipt godoc
+// @Summary      Void a receipt
+// @Description  Void a receipt together with the expense transaction it generated. Both are kept but excluded from statistics.
+// @Tags         Receipts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string              true  "Receipt ID"
+// @Param        request  body      models.VoidPayload  true  "Reason for the void"
+// @Success      200      {object}  utils.Response{message=string}  "Receipt voided successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid request data"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404      {object}  utils.Response{message=string}  "Receipt not found"
+// @Failure      409      {object}  utils.Response{message=string}  "Receipt already voided or still processing"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /receipts/{id}/void [post]
+func (h *ReceiptHandler) VoidReceipt(w http.ResponseWriter, r *http.Request) {
+	var payload models.VoidPayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	receiptID := r.PathValue("id")
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return
+			}
+		}
+	}
+
+	receipt, err := h.receiptRepo.GetReceiptByID(ctx, receiptID, userID)
+	if err == sql.ErrNoRows {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Receipt tidak ditemukan",
+		})
+		return
+	}
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data receipt",
+		})
+		return
+	}
+
+	if receipt.Status == models.ReceiptProcessing {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Receipt masih diproses",
+		})
+		return
+	}
+
+	voided, err := h.receiptRepo.VoidReceipt(ctx, receiptID, userID, payload.Reason)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membatalkan receipt",
+		})
+		return
+	}
+
+	if !voided {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Receipt sudah dibatalkan",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityID: receiptID,
+		Before:   receipt,
+	})
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil membatalkan receipt",
+	})
+}
+
+// SetReceiptCategory godoc
+// @Summary      Set receipt category
+// @Description  Assign an expense category to a receipt and the transaction it generated. Send a null category_id to remove the category.
+// @Tags         Receipts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string                              true  "Receipt ID"
+// @Param        request  body      models.UpdateReceiptCategoryPayload  true  "Expense category"
+// @Success      200      {object}  utils.Response{message=string}  "Receipt category updated successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid category"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404      {object}  utils.Response{message=string}  "Receipt not found"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /receipts/{id}/category [patch]
+func (h *ReceiptHandler) SetReceiptCategory(w http.ResponseWriter, r *http.Request) {
+	var payload models.UpdateReceiptCategoryPayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if status, msg := checkCategory(ctx, &h.categoryRepo, payload.CategoryID, userID, "expense"); status != 0 {
+		utils.ResponseJson(w, status, utils.Response{
+			Message: msg,
+		})
+		return
+	}
+
+	updated, err := h.receiptRepo.SetReceiptCategory(ctx, r.PathValue("id"), userID, payload.CategoryID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengubah kategori receipt",
+		})
+		return
+	}
+
+	if !updated {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Receipt tidak ditemukan",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengubah kategori receipt",
+	})
+}
+
+// UpdateReceipt godoc
+// @Summary      Correct a receipt
+// @Description  Correct the store name, invoice date or total read from a receipt, or the account it was paid from. Fields that are left out keep their value. Editing a confirmed receipt turns it back into a draft and voids its expense transaction until the receipt is confirmed again. duplicate_of is set when an earlier receipt has the same store, invoice date and total.
+// @Tags         Receipts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string                       true  "Receipt ID"
+// @Param        request  body      models.UpdateReceiptPayload  true  "Corrected fields"
+// @Success      200      {object}  utils.Response{data=models.Receipt}  "Receipt updated successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid request data"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404      {object}  utils.Response{message=string}  "Receipt not found"
+// @Failure      409      {object}  utils.Response{message=string}  "Receipt cannot be edited"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /receipts/{id} [patch]
+func (h *ReceiptHandler) UpdateReceipt(w http.ResponseWriter, r *http.Request) {
+	var payload models.UpdateReceiptPayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	receipt, ok := h.getEditableReceipt(w, r, userID)
+	if !ok {
+		return
+	}
+	before := *receipt
+
+	if payload.StoreName != nil {
+		storeName := strings.TrimSpace(*payload.StoreName)
+		if storeName == "" || len(storeName) > 255 {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "store_name wajib diisi dan maksimal 255 karakter",
+			})
+			return
+		}
+		receipt.StoreName = storeName
+	}
+
+	if payload.InvoiceDate != nil {
+		date, err := time.Parse("2006-01-02", *payload.InvoiceDate)
+		if err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Format invoice_date tidak valid, gunakan YYYY-MM-DD",
+			})
+			return
+		}
+		receipt.InvoiceDate = &date
+	}
+
+	if payload.TotalPrice != nil {
+		if *payload.TotalPrice <= 0 {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "total harus lebih dari 0",
+			})
+			return
+		}
+		receipt.TotalPrice = *payload.TotalPrice
+	}
+
+	if payload.AccountID != nil {
+		if status, msg := checkAccount(ctx, &h.accountRepo, payload.AccountID, userID); status != 0 {
+			utils.ResponseJson(w, status, utils.Response{
+				Message: msg,
+			})
+			return
+		}
+		receipt.AccountID = payload.AccountID
+	}
+
+	updated, err := h.receiptRepo.UpdateReceipt(ctx, receipt)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengubah receipt",
+		})
+		return
+	}
+
+	if !updated {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Receipt tidak dapat diubah",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityID: receipt.ID,
+		Before:   before,
+		After:    receipt,
+	})
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengubah receipt",
+		Data:    receipt,
+	})
+}
+
+// AddReceiptItem godoc
+// @Summary      Add a receipt item
+// @Description  Add an item the OCR missed. price is the line total. The receipt total and item count are recomputed from the items. Editing a confirmed receipt turns it back into a draft and voids its expense transaction until the receipt is confirmed again.
+// @Tags         Receipts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string                     true  "Receipt ID"
+// @Param        request  body      models.ReceiptItemPayload  true  "Item"
+// @Success      201      {object}  utils.Response{data=models.ReceiptItem}  "Item added successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid request data"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404      {object}  utils.Response{message=string}  "Receipt not found"
+// @Failure      409      {object}  utils.Response{message=string}  "Receipt cannot be edited"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /receipts/{id}/items [post]
+func (h *ReceiptHandler) AddReceiptItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	payload, ok := parseReceiptItemPayload(w, r)
+	if !ok {
+		return
+	}
+
+	receipt, ok := h.getEditableReceipt(w, r, userID)
+	if !ok {
+		return
+	}
+
+	itemID, _ := uuid.NewV7()
+	item := models.ReceiptItem{
+		ID:        itemID.String(),
+		ReceiptID: receipt.ID,
+		Name:      strings.TrimSpace(payload.Name),
+		Quantity:  payload.Quantity,
+		Price:     payload.Price,
+	}
+
+	added, err := h.receiptRepo.AddReceiptItem(ctx, &item, userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal menambah item receipt",
+		})
+		return
+	}
+
+	if !added {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Receipt tidak dapat diubah",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityType: "receipt_items",
+		EntityID:   item.ID,
+		After:      item,
+	})
+
+	utils.ResponseJson(w, http.StatusCreated, utils.Response{
+		Message: "Berhasil menambah item receipt",
+		Data:    item,
+	})
+}
+
+// UpdateReceiptItem godoc
+// @Summary      Correct a receipt item
+// @Description  Replace the name, quantity and price of a receipt item. price is the line total. The receipt total and item count are recomputed from the items. Editing a confirmed receipt turns it back into a draft and voids its expense transaction until the receipt is confirmed again.
+// @Tags         Receipts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string                     true  "Receipt ID"
+// @Param        item_id  path      string                     true  "Item ID"
+// @Param        request  body      models.ReceiptItemPayload  true  "Item"
+// @Success      200      {object}  utils.Response{data=models.ReceiptItem}  "Item updated successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid request data"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404      {object}  utils.Response{message=string}  "Receipt or item not found"
+// @Failure      409      {object}  utils.Response{message=string}  "Receipt cannot be edited"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /receipts/{id}/items/{item_id} [put]
+func (h *ReceiptHandler) UpdateReceiptItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	payload, ok := parseReceiptItemPayload(w, r)
+	if !ok {
+		return
+	}
+
+	receipt, ok := h.getEditableReceipt(w, r, userID)
+	if !ok {
+		return
+	}
+
+	before, ok := h.getReceiptItem(w, r, receipt.ID)
+	if !ok {
+		return
+	}
+
+	item := *before
+	item.Name = strings.TrimSpace(payload.Name)
+	item.Quantity = payload.Quantity
+	item.Price = payload.Price
+
+	updated, err := h.receiptRepo.UpdateReceiptItem(ctx, &item, userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengubah item receipt",
+		})
+		return
+	}
+
+	if !updated {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Receipt tidak dapat diubah",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityType: "receipt_items",
+		EntityID:   item.ID,
+		Before:     before,
+		After:      item,
+	})
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengubah item receipt",
+		Data:    item,
+	})
+}
+
+// DeleteReceiptItem godoc
+// @Summary      Remove a receipt item
+// @Description  Remove an item that was misread or does not belong on the receipt. The receipt total and item count are recomputed from the items. Editing a confirmed receipt turns it back into a draft and voids its expense transaction until the receipt is confirmed again.
+// @Tags         Receipts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string  true  "Receipt ID"
+// @Param        item_id  path      string  true  "Item ID"
+// @Success      200      {object}  utils.Response{message=string}  "Item removed successfully"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404      {object}  utils.Response{message=string}  "Receipt or item not found"
+// @Failure      409      {object}  utils.Response{message=string}  "Receipt cannot be edited"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /receipts/{id}/items/{item_id} [delete]
+func (h *ReceiptHandler) DeleteReceiptItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	receipt, ok := h.getEditableReceipt(w, r, userID)
+	if !ok {
+		return
+	}
+
+	item, ok := h.getReceiptItem(w, r, receipt.ID)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.receiptRepo.DeleteReceiptItem(ctx, receipt.ID, item.ID, userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal menghapus item receipt",
+		})
+		return
+	}
+
+	if !deleted {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Receipt tidak dapat diubah",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityType: "receipt_items",
+		EntityID:   item.ID,
+		Before:     item,
+	})
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil menghapus item receipt",
+	})
+}
+
+// ConfirmReceipt godoc
+// @Summary      Confirm a receipt
+// @Description  Post a reviewed draft to the books. Confirming creates the expense transaction, dated on the invoice date in the merchant's time zone and booked to the receipt's account or the default account. The expense of an earlier confirmation was voided when the receipt was edited and stays in the transaction history. The receipt needs an invoice date and a total. A receipt whose duplicate_of is set is rejected with a reference to that receipt unless allow_duplicate is true, which also stops it from being flagged again.
+// @Tags         Receipts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id               path      string  true   "Receipt ID"
+// @Param        allow_duplicate  query     bool    false  "Confirm even if the receipt looks like a duplicate"
+// @Success      200  {object}  utils.Response{data=models.Transaction}  "Receipt confirmed successfully"
+// @Failure      400  {object}  utils.Response{message=string}  "Receipt is incomplete"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404  {object}  utils.Response{message=string}  "Receipt not found"
+// @Failure      409  {object}  utils.Response{message=string}  "Receipt is not a draft or looks like a duplicate"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /receipts/{id}/confirm [post]
+func (h *ReceiptHandler) ConfirmReceipt(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	receipt, ok := h.getEditableReceipt(w, r, userID)
+	if !ok {
+		return
+	}
+
+	if receipt.Status != models.ReceiptDraft {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Receipt sudah dikonfirmasi",
+		})
+		return
+	}
+
+	if receipt.InvoiceDate == nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Tanggal receipt belum diisi",
+		})
+		return
+	}
+
+	if receipt.TotalPrice <= 0 {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Total receipt harus lebih dari 0",
+		})
+		return
+	}
+
+	allowDuplicate := r.URL.Query().Get("allow_duplicate") == "true"
+	if receipt.DuplicateOf != nil && !allowDuplicate {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Receipt ini kemungkinan duplikat dari receipt lain",
+			Data: models.ReceiptDuplicate{
+				ReceiptID: *receipt.DuplicateOf,
+				Match:     models.ReceiptDuplicateFields,
+			},
+		})
+		return
+	}
+
+	transaction, err := h.receiptRepo.ConfirmReceipt(ctx, receipt.ID, userID, allowDuplicate, middleware.GetLocation(ctx))
+	if err == store.ErrReceiptNotConfirmable {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Receipt tidak dapat dikonfirmasi",
+		})
+		return
+	}
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengonfirmasi receipt",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityID: receipt.ID,
+		After:    transaction,
+	})
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengonfirmasi receipt",
+		Data:    transaction,
+	})
+}
+
+// getEditableReceipt loads the receipt in the id path value and checks that
+// it is a draft or confirmed and not voided. It writes the error response
+// itself and returns false otherwise.
+func (h *ReceiptHandler) getEditableReceipt(w http.ResponseWriter, r *http.Request, userID string) (*models.Receipt, bool) {
+	receipt, err := h.receiptRepo.GetReceiptByID(r.Context(), r.PathValue("id"), userID)
+	if err == sql.ErrNoRows {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Receipt tidak ditemukan",
+		})
+		return nil, false
+	}
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data receipt",
+		})
+		return nil, false
+	}
+
+	message := ""
+	switch {
+	case receipt.VoidedAt != nil:
+		message = "Receipt sudah dibatalkan"
+	case receipt.Status == models.ReceiptProcessing:
+		message = "Receipt masih diproses"
+	case receipt.Status == models.ReceiptFailed:
+		message = "Receipt gagal diproses"
+	}
+	if message != "" {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: message,
+		})
+		return nil, false
+	}
+
+	return receipt, true
+}
+
+// getReceiptItem finds the item in the item_id path value among the items of
+// the receipt. It writes a 404 and returns false when there is none.
+func (h *ReceiptHandler) getReceiptItem(w http.ResponseWriter, r *http.Request, receiptID string) (*models.ReceiptItem, bool) {
+	items, err := h.receiptRepo.GetReceiptItemsByReceiptID(r.Context(), receiptID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil item receipt",
+		})
+		return nil, false
+	}
+
+	for i := range items {
+		if items[i].ID == r.PathValue("item_id") {
+			return &items[i], true
+		}
+	}
+
+	utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+		Message: "Item receipt tidak ditemukan",
+	})
+	return nil, false
+}
+
+func parseReceiptItemPayload(w http.ResponseWriter, r *http.Request) (models.ReceiptItemPayload, bool) {
+	var payload models.ReceiptItemPayload
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return payload, false
+	}
+
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return payload, false
+			}
+		}
+	}
+
+	return payload, true
+}
+
 func (h *ReceiptHandler) GetItemsByRecieptID(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -0,0 +1,633 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/middleware"
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/Cakra17/imphnen/internal/store"
+	"github.com/Cakra17/imphnen/internal/utils"
+	"github.com/Cakra17/imphnen/internal/validation"
+	"github.com/google/uuid"
+)
+
+type RecurringTransactionHandler struct {
+	recurringRepo store.RecurringTransactionRepo
+	categoryRepo  store.CategoryRepo
+	accountRepo   store.AccountRepo
+}
+
+type RecurringTransactionHandlerConfig struct {
+	RecurringRepo store.RecurringTransactionRepo
+	CategoryRepo  store.CategoryRepo
+	AccountRepo   store.AccountRepo
+}
+
+func NewRecurringTransactionHandler(cfg RecurringTransactionHandlerConfig) RecurringTransactionHandler {
+	return RecurringTransactionHandler{
+		recurringRepo: cfg.RecurringRepo,
+		categoryRepo:  cfg.CategoryRepo,
+		accountRepo:   cfg.AccountRepo,
+	}
+}
+
+// CreateRecurringTransaction godoc
+// @Summary      Create a recurring transaction
+// @Description  Create a template that is turned into a transaction on every occurrence: daily, weekly on the start date's weekday, monthly on day_of_month (clamped to shorter months) or at the end of every month. interval repeats every N periods. Occurrences between a past start_date and today are created right away. Occurrences go to account_id, or the default account when it is omitted.
+// @Tags         Recurring Transactions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.CreateRecurringTransactionPayload  true  "Recurring transaction details"
+// @Success      201      {object}  utils.Response{data=models.RecurringTransactionResponse}  "Recurring transaction created successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid request data"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /recurring-transactions [post]
+func (h *RecurringTransactionHandler) CreateRecurringTransaction(w http.ResponseWriter, r *http.Request) {
+	var payload models.CreateRecurringTransactionPayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return
+			}
+		}
+	}
+
+	startDate, err := time.Parse("2006-01-02", payload.StartDate)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Format start_date tidak valid, gunakan YYYY-MM-DD",
+		})
+		return
+	}
+
+	id, _ := uuid.NewV7()
+	recurring := models.RecurringTransaction{
+		ID:          id.String(),
+		UserID:      userID,
+		Type:        payload.Type,
+		Amount:      payload.Amount,
+		CategoryID:  payload.CategoryID,
+		AccountID:   payload.AccountID,
+		Description: optionalDescription(payload.Description),
+		StartDate:   startDate,
+	}
+
+	if msg := applyRecurringSchedule(&recurring, payload.Frequency, payload.Interval, payload.DayOfMonth, payload.EndDate); msg != "" {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: msg,
+		})
+		return
+	}
+
+	if status, msg := checkCategory(ctx, &h.categoryRepo, payload.CategoryID, userID, payload.Type); status != 0 {
+		utils.ResponseJson(w, status, utils.Response{
+			Message: msg,
+		})
+		return
+	}
+
+	if status, msg := checkAccount(ctx, &h.accountRepo, payload.AccountID, userID); status != 0 {
+		utils.ResponseJson(w, status, utils.Response{
+			Message: msg,
+		})
+		return
+	}
+
+	recurring.NextRunDate = recurring.FirstOccurrence()
+
+	if err := h.recurringRepo.Create(ctx, &recurring); err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membuat transaksi berulang",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityID: recurring.ID,
+		After:    recurring,
+	})
+
+	utils.ResponseJson(w, http.StatusCreated, utils.Response{
+		Message: "Berhasil membuat transaksi berulang",
+		Data: models.RecurringTransactionResponse{
+			RecurringTransaction: recurring,
+		},
+	})
+}
+
+// GetRecurringTransactions godoc
+// @Summary      List recurring transactions
+// @Description  List the authenticated user's recurring transactions, ordered by their next occurrence
+// @Tags         Recurring Transactions
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  utils.Response{data=models.RecurringTransactionListResponse}  "Recurring transactions retrieved successfully"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /recurring-transactions [get]
+func (h *RecurringTransactionHandler) GetRecurringTransactions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	recurrings, err := h.recurringRepo.GetRecurringTransactions(ctx, userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data transaksi berulang",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil data transaksi berulang",
+		Data: models.RecurringTransactionListResponse{
+			RecurringTransactions: recurrings,
+		},
+	})
+}
+
+// GetRecurringTransactionByID godoc
+// @Summary      Get a recurring transaction
+// @Description  Get a single recurring transaction of the authenticated user
+// @Tags         Recurring Transactions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Recurring transaction ID"
+// @Success      200  {object}  utils.Response{data=models.RecurringTransactionResponse}  "Recurring transaction retrieved successfully"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404  {object}  utils.Response{message=string}  "Recurring transaction not found"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /recurring-transactions/{id} [get]
+func (h *RecurringTransactionHandler) GetRecurringTransactionByID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	recurring, ok := h.getRecurringTransaction(w, r, userID)
+	if !ok {
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil data transaksi berulang",
+		Data: models.RecurringTransactionResponse{
+			RecurringTransaction: *recurring,
+		},
+	})
+}
+
+// UpdateRecurringTransaction godoc
+// @Summary      Update a recurring transaction
+// @Description  Change the amount, category or schedule of future occurrences. Transactions that were already created keep their values. The start date cannot change.
+// @Tags         Recurring Transactions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string                                    true  "Recurring transaction ID"
+// @Param        request  body      models.UpdateRecurringTransactionPayload  true  "New recurring transaction details"
+// @Success      200      {object}  utils.Response{data=models.RecurringTransactionResponse}  "Recurring transaction updated successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid request data"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404      {object}  utils.Response{message=string}  "Recurring transaction not found"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /recurring-transactions/{id} [put]
+func (h *RecurringTransactionHandler) UpdateRecurringTransaction(w http.ResponseWriter, r *http.Request) {
+	var payload models.UpdateRecurringTransactionPayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return
+			}
+		}
+	}
+
+	existing, ok := h.getRecurringTransaction(w, r, userID)
+	if !ok {
+		return
+	}
+
+	recurring := *existing
+	recurring.Type = payload.Type
+	recurring.Amount = payload.Amount
+	recurring.CategoryID = payload.CategoryID
+	recurring.AccountID = payload.AccountID
+	recurring.Description = optionalDescription(payload.Description)
+
+	if msg := applyRecurringSchedule(&recurring, payload.Frequency, payload.Interval, payload.DayOfMonth, payload.EndDate); msg != "" {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: msg,
+		})
+		return
+	}
+
+	if status, msg := checkCategory(ctx, &h.categoryRepo, payload.CategoryID, userID, payload.Type); status != 0 {
+		utils.ResponseJson(w, status, utils.Response{
+			Message: msg,
+		})
+		return
+	}
+
+	if status, msg := checkAccount(ctx, &h.accountRepo, payload.AccountID, userID); status != 0 {
+		utils.ResponseJson(w, status, utils.Response{
+			Message: msg,
+		})
+		return
+	}
+
+	// Occurrences before today were created with the old values already, so
+	// the new schedule only takes over from today.
+	recurring.NextRunDate = recurring.OccurrenceOnOrAfter(time.Now().In(middleware.GetLocation(ctx)))
+
+	updated, err := h.recurringRepo.UpdateRecurringTransaction(ctx, recurring)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengubah transaksi berulang",
+		})
+		return
+	}
+
+	if !updated {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Transaksi berulang tidak ditemukan",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityID: recurring.ID,
+		Before:   existing,
+		After:    recurring,
+	})
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengubah transaksi berulang",
+		Data: models.RecurringTransactionResponse{
+			RecurringTransaction: recurring,
+		},
+	})
+}
+
+// DeleteRecurringTransaction godoc
+// @Summary      Delete a recurring transaction
+// @Description  Stop a recurring transaction for good. Transactions it already created stay in the books.
+// @Tags         Recurring Transactions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Recurring transaction ID"
+// @Success      200  {object}  utils.Response{message=string}  "Recurring transaction deleted successfully"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404  {object}  utils.Response{message=string}  "Recurring transaction not found"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /recurring-transactions/{id} [delete]
+func (h *RecurringTransactionHandler) DeleteRecurringTransaction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	id := r.PathValue("id")
+
+	deleted, err := h.recurringRepo.DeleteRecurringTransaction(ctx, id, userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal menghapus transaksi berulang",
+		})
+		return
+	}
+
+	if !deleted {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Transaksi berulang tidak ditemukan",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil menghapus transaksi berulang",
+	})
+}
+
+// PauseRecurringTransaction godoc
+// @Summary      Pause a recurring transaction
+// @Description  Stop creating transactions until the recurring transaction is resumed
+// @Tags         Recurring Transactions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Recurring transaction ID"
+// @Success      200  {object}  utils.Response{message=string}  "Recurring transaction paused successfully"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404  {object}  utils.Response{message=string}  "Recurring transaction not found"
+// @Failure      409  {object}  utils.Response{message=string}  "Recurring transaction is already paused"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /recurring-transactions/{id}/pause [post]
+func (h *RecurringTransactionHandler) PauseRecurringTransaction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	recurring, ok := h.getRecurringTransaction(w, r, userID)
+	if !ok {
+		return
+	}
+
+	paused, err := h.recurringRepo.PauseRecurringTransaction(ctx, recurring.ID, userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal menjeda transaksi berulang",
+		})
+		return
+	}
+
+	if !paused {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Transaksi berulang sudah dijeda",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil menjeda transaksi berulang",
+	})
+}
+
+// ResumeRecurringTransaction godoc
+// @Summary      Resume a recurring transaction
+// @Description  Continue creating transactions from the next occurrence on or after today. Occurrences that fell inside the pause are not created.
+// @Tags         Recurring Transactions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Recurring transaction ID"
+// @Success      200  {object}  utils.Response{message=string}  "Recurring transaction resumed successfully"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404  {object}  utils.Response{message=string}  "Recurring transaction not found"
+// @Failure      409  {object}  utils.Response{message=string}  "Recurring transaction is not paused"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /recurring-transactions/{id}/resume [post]
+func (h *RecurringTransactionHandler) ResumeRecurringTransaction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	recurring, ok := h.getRecurringTransaction(w, r, userID)
+	if !ok {
+		return
+	}
+
+	nextRunDate := recurring.OccurrenceOnOrAfter(time.Now().In(middleware.GetLocation(ctx)))
+	resumed, err := h.recurringRepo.ResumeRecurringTransaction(ctx, recurring.ID, userID, nextRunDate)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal melanjutkan transaksi berulang",
+		})
+		return
+	}
+
+	if !resumed {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Transaksi berulang tidak sedang dijeda",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil melanjutkan transaksi berulang",
+	})
+}
+
+// SkipOccurrence godoc
+// @Summary      Skip an occurrence
+// @Description  Do not create the transaction for one future occurrence. Later occurrences are not affected.
+// @Tags         Recurring Transactions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string                        true  "Recurring transaction ID"
+// @Param        request  body      models.SkipOccurrencePayload  true  "Occurrence date in YYYY-MM-DD format"
+// @Success      200      {object}  utils.Response{message=string}  "Occurrence skipped successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Date is not an occurrence"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404      {object}  utils.Response{message=string}  "Recurring transaction not found"
+// @Failure      409      {object}  utils.Response{message=string}  "Occurrence was already created"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /recurring-transactions/{id}/skip [post]
+func (h *RecurringTransactionHandler) SkipOccurrence(w http.ResponseWriter, r *http.Request) {
+	var payload models.SkipOccurrencePayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return
+			}
+		}
+	}
+
+	date, err := time.Parse("2006-01-02", payload.Date)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Format date tidak valid, gunakan YYYY-MM-DD",
+		})
+		return
+	}
+
+	recurring, ok := h.getRecurringTransaction(w, r, userID)
+	if !ok {
+		return
+	}
+
+	if !recurring.OccurrenceOnOrAfter(date).Equal(date) || recurring.Ended(date) {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Tanggal tersebut bukan jadwal transaksi berulang",
+		})
+		return
+	}
+
+	if date.Before(recurring.NextRunDate) {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Transaksi untuk tanggal tersebut sudah dibuat",
+		})
+		return
+	}
+
+	if err := h.recurringRepo.SkipOccurrence(ctx, recurring.ID, date); err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal melewati jadwal transaksi berulang",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityID: recurring.ID,
+		After:    payload,
+	})
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil melewati jadwal transaksi berulang",
+	})
+}
+
+// GetOccurrences godoc
+// @Summary      Preview upcoming occurrences
+// @Description  List the next occurrences that have not been created yet, including skipped ones
+// @Tags         Recurring Transactions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id     path      string  true   "Recurring transaction ID"
+// @Param        count  query     int     false  "Number of occurrences (default: 10, max: 100)"
+// @Success      200    {object}  utils.Response{data=models.RecurringOccurrenceListResponse}  "Occurrences retrieved successfully"
+// @Failure      400    {object}  utils.Response{message=string}  "Invalid count"
+// @Failure      401    {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404    {object}  utils.Response{message=string}  "Recurring transaction not found"
+// @Failure      500    {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /recurring-transactions/{id}/occurrences [get]
+func (h *RecurringTransactionHandler) GetOccurrences(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	count := 10
+	if countStr := r.URL.Query().Get("count"); countStr != "" {
+		c, err := strconv.Atoi(countStr)
+		if err != nil || c < 1 || c > 100 {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter count harus berupa angka antara 1 dan 100",
+			})
+			return
+		}
+		count = c
+	}
+
+	recurring, ok := h.getRecurringTransaction(w, r, userID)
+	if !ok {
+		return
+	}
+
+	next := recurring.OccurrenceOnOrAfter(recurring.NextRunDate)
+	skipped, err := h.recurringRepo.GetSkippedDates(ctx, recurring.ID, next)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil jadwal transaksi berulang",
+		})
+		return
+	}
+
+	occurrences := []models.RecurringOccurrence{}
+	for len(occurrences) < count && !recurring.Ended(next) {
+		occurrences = append(occurrences, models.RecurringOccurrence{
+			Date:    next.Format("2006-01-02"),
+			Skipped: skipped[next],
+		})
+		next = recurring.NextOccurrence(next)
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil jadwal transaksi berulang",
+		Data: models.RecurringOccurrenceListResponse{
+			Occurrences: occurrences,
+		},
+	})
+}
+
+// getRecurringTransaction loads the recurring transaction named in the path
+// and writes a 404 when it does not belong to the user.
+func (h *RecurringTransactionHandler) getRecurringTransaction(w http.ResponseWriter, r *http.Request, userID string) (*models.RecurringTransaction, bool) {
+	recurring, err := h.recurringRepo.GetRecurringTransactionByID(r.Context(), r.PathValue("id"), userID)
+	if err == sql.ErrNoRows {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Transaksi berulang tidak ditemukan",
+		})
+		return nil, false
+	}
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data transaksi berulang",
+		})
+		return nil, false
+	}
+	return recurring, true
+}
+
+// applyRecurringSchedule sets the schedule fields shared by create and update.
+// It returns a message to respond with when the schedule is invalid.
+func applyRecurringSchedule(recurring *models.RecurringTransaction, frequency string, interval int, dayOfMonth *int, endDate *string) string {
+	recurring.Frequency = frequency
+	recurring.Interval = max(interval, 1)
+
+	if dayOfMonth != nil && (*dayOfMonth < 1 || *dayOfMonth > 31) {
+		return "day_of_month harus antara 1 dan 31"
+	}
+
+	recurring.DayOfMonth = nil
+	if frequency == models.RecurringMonthly {
+		day := recurring.StartDate.Day()
+		if dayOfMonth != nil {
+			day = *dayOfMonth
+		}
+		recurring.DayOfMonth = &day
+	}
+
+	recurring.EndDate = nil
+	if endDate != nil {
+		end, err := time.Parse("2006-01-02", *endDate)
+		if err != nil {
+			return "Format end_date tidak valid, gunakan YYYY-MM-DD"
+		}
+		if end.Before(recurring.StartDate) {
+			return "end_date tidak boleh sebelum start_date"
+		}
+		recurring.EndDate = &end
+	}
+
+	return ""
+}
+
+func optionalDescription(description string) *string {
+	if description == "" {
+		return nil
+	}
+	return &description
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/export"
+	"github.com/Cakra17/imphnen/internal/middleware"
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/Cakra17/imphnen/internal/store"
+	"github.com/Cakra17/imphnen/internal/utils"
+)
+
+// maxReportPeriods keeps a report, and the previous range it is compared
+// with, to a size that is still useful to read.
+const maxReportPeriods = 366
+
+// reportSteps maps a granularity to the interval between two periods.
+var reportSteps = map[string]string{
+	"day":     "1 day",
+	"week":    "1 week",
+	"month":   "1 month",
+	"quarter": "3 months",
+	"year":    "1 year",
+}
+
+// statementTopExpenses is the number of expense categories listed on the
+// monthly statement.
+const statementTopExpenses = 5
+
+type ReportHandler struct {
+	reportRepo       store.ReportRepo
+	transactionStore *store.TransactionRepo
+	categoryRepo     store.CategoryRepo
+	receiptRepo      store.ReceiptRepo
+	userRepo         store.UserRepo
+}
+
+type ReportHandlerConfig struct {
+	ReportRepo       store.ReportRepo
+	TransactionStore *store.TransactionRepo
+	CategoryRepo     store.CategoryRepo
+	ReceiptRepo      store.ReceiptRepo
+	UserRepo         store.UserRepo
+}
+
+func NewReportHandler(cfg ReportHandlerConfig) ReportHandler {
+	return ReportHandler{
+		reportRepo:       cfg.ReportRepo,
+		transactionStore: cfg.TransactionStore,
+		categoryRepo:     cfg.CategoryRepo,
+		receiptRepo:      cfg.ReceiptRepo,
+		userRepo:         cfg.UserRepo,
+	}
+}
+
+// GetProfitLoss godoc
+// @Summary      Get profit and loss report
+// @Description  Profit and loss per period: revenue from confirmed orders, other income, cost of goods sold (the cost price of the items sold, as in the margin reports), operating expenses by category, and gross and net profit. Expenses in COGS categories are stock purchases, reported separately and left out of the profit so they are not counted twice. from and to are widened to whole periods. Every period is compared with the one before it, and the summary with the same number of periods before from.
+// @Tags         Reports
+// @Produce      json
+// @Security     BearerAuth
+// @Param        from         query     string  false  "Start date in YYYY-MM-DD format (default: first day of the month five months ago)"
+// @Param        to           query     string  false  "End date in YYYY-MM-DD format (default: today)"
+// @Param        granularity  query     string  false  "Period length: day, week, month, quarter or year (default: month)"
+// @Success      200          {object}  utils.Response{data=models.ProfitLossReport}  "Profit and loss retrieved successfully"
+// @Failure      400          {object}  utils.Response{message=string}  "Invalid parameters"
+// @Failure      401          {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500          {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /reports/profit-loss [get]
+func (h *ReportHandler) GetProfitLoss(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	granularity := r.URL.Query().Get("granularity")
+	if granularity == "" {
+		granularity = "month"
+	}
+	step, ok := reportSteps[granularity]
+	if !ok {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Parameter granularity harus 'day', 'week', 'month', 'quarter', atau 'year'",
+		})
+		return
+	}
+
+	loc := middleware.GetLocation(ctx)
+	today := truncatePeriod(time.Now().In(loc), "day")
+	start, end, count, ok := parsePeriodRange(w, r, time.Date(today.Year(), today.Month()-5, 1, 0, 0, 0, 0, time.UTC), granularity)
+	if !ok {
+		return
+	}
+
+	// The previous range has the same number of periods and is fetched in the
+	// same query, so the first period also has something to compare with.
+	previousStart := addPeriods(start, granularity, -count)
+	periods, err := h.reportRepo.GetProfitLossPeriods(ctx, userID, granularity, step, previousStart, end, loc)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil laporan laba rugi",
+		})
+		return
+	}
+
+	if len(periods) != 2*count {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil laporan laba rugi",
+		})
+		return
+	}
+
+	report := models.ProfitLossReport{
+		From:        start.Format("2006-01-02"),
+		To:          end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Granularity: granularity,
+		Periods:     make([]models.ProfitLossPeriod, 0, count),
+	}
+
+	for i := count; i < len(periods); i++ {
+		period := periods[i]
+		period.PeriodEnd = addPeriods(period.PeriodStart, granularity, 1).AddDate(0, 0, -1)
+		period.Previous = periods[i-1].ProfitLossFigures
+		period.Change = profitLossChange(period.ProfitLossFigures, period.Previous)
+		report.Periods = append(report.Periods, period)
+
+		report.Summary.Add(period.ProfitLossFigures)
+		report.Summary.Previous.Add(periods[i-count].ProfitLossFigures)
+	}
+	report.Summary.Change = profitLossChange(report.Summary.ProfitLossFigures, report.Summary.Previous)
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil laporan laba rugi",
+		Data:    report,
+	})
+}
+
+// GetStatement godoc
+// @Summary      Download monthly financial statement
+// @Description  A PDF statement for one month: income, expense and net totals, daily cashflow, the largest expense categories and the receipts backing the month's transactions. Dates follow the merchant's time zone and voided transactions and receipts are left out.
+// @Tags         Reports
+// @Produce      application/pdf
+// @Security     BearerAuth
+// @Param        month  query     string  false  "Month in YYYY-MM format (default: previous month)"
+// @Success      200    {file}    file    "Statement PDF"
+// @Failure      400    {object}  utils.Response{message=string}  "Invalid month"
+// @Failure      401    {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500    {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /reports/statement.pdf [get]
+func (h *ReportHandler) GetStatement(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	loc := middleware.GetLocation(ctx)
+	now := time.Now().In(loc)
+	month := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.UTC)
+	if monthStr := r.URL.Query().Get("month"); monthStr != "" {
+		parsed, err := time.Parse("2006-01", monthStr)
+		if err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Format month tidak valid, gunakan YYYY-MM",
+			})
+			return
+		}
+		if parsed.After(truncatePeriod(now, "month")) {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Laporan belum tersedia untuk bulan yang akan datang",
+			})
+			return
+		}
+		month = parsed
+	}
+	nextMonth := month.AddDate(0, 1, 0)
+
+	// The series takes calendar dates, the other queries instants.
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, loc)
+	end := time.Date(nextMonth.Year(), nextMonth.Month(), 1, 0, 0, 0, 0, loc)
+
+	user, err := h.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membuat laporan keuangan",
+		})
+		return
+	}
+
+	stats, err := h.transactionStore.GetTransactionStats(ctx, userID, start, end)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membuat laporan keuangan",
+		})
+		return
+	}
+
+	days, err := h.transactionStore.GetTransactionSeries(ctx, userID, "day", reportSteps["day"], month, nextMonth, loc)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membuat laporan keuangan",
+		})
+		return
+	}
+
+	categories, err := h.categoryRepo.GetCategoryStats(ctx, userID, start, end)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membuat laporan keuangan",
+		})
+		return
+	}
+
+	receipts, err := h.receiptRepo.GetStatementReceipts(ctx, userID, start, end)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membuat laporan keuangan",
+		})
+		return
+	}
+
+	statement := models.FinancialStatement{
+		StoreName:   user.StoreName,
+		Month:       month,
+		GeneratedAt: now,
+		Stats:       stats,
+		Days:        days,
+		Receipts:    receipts,
+	}
+
+	// Categories come sorted by amount, largest first.
+	for _, c := range categories {
+		if c.Type != "expense" || len(statement.TopExpenses) == statementTopExpenses {
+			continue
+		}
+		if stats.TotalExpense > 0 {
+			c.Percentage = math.Round(c.TotalAmount/stats.TotalExpense*10000) / 100
+		}
+		statement.TopExpenses = append(statement.TopExpenses, c)
+	}
+
+	// Rendered into a buffer first so a failure can still be reported as a 500.
+	var buf bytes.Buffer
+	if err := export.WriteStatementPDF(&buf, statement, loc); err != nil {
+		log.Printf("[ERROR] Failed to render statement: %s", err.Error())
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membuat laporan keuangan",
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("laporan-keuangan-%s.pdf", month.Format("2006-01"))
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
+
+// parsePeriodRange reads the from and to query parameters as calendar dates,
+// defaulting to defaultFrom and the merchant's today, and widens them to whole periods. end is exclusive
+// and count is the number of periods in between. It writes a 400 and returns
+// false when the range is invalid or longer than maxReportPeriods.
+func parsePeriodRange(w http.ResponseWriter, r *http.Request, defaultFrom time.Time, granularity string) (time.Time, time.Time, int, bool) {
+	from := defaultFrom
+	to := truncatePeriod(time.Now().In(middleware.GetLocation(r.Context())), "day")
+
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		parsed, err := time.Parse("2006-01-02", fromStr)
+		if err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Format from tidak valid, gunakan YYYY-MM-DD",
+			})
+			return time.Time{}, time.Time{}, 0, false
+		}
+		from = parsed
+	}
+
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		parsed, err := time.Parse("2006-01-02", toStr)
+		if err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Format to tidak valid, gunakan YYYY-MM-DD",
+			})
+			return time.Time{}, time.Time{}, 0, false
+		}
+		to = parsed
+	}
+
+	if to.Before(from) {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Parameter to tidak boleh sebelum from",
+		})
+		return time.Time{}, time.Time{}, 0, false
+	}
+
+	start := truncatePeriod(from, granularity)
+	end := addPeriods(truncatePeriod(to, granularity), granularity, 1)
+
+	count := 0
+	for t := start; t.Before(end); t = addPeriods(t, granularity, 1) {
+		count++
+		if count > maxReportPeriods {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: fmt.Sprintf("Rentang laporan maksimal %d periode", maxReportPeriods),
+			})
+			return time.Time{}, time.Time{}, 0, false
+		}
+	}
+
+	return start, end, count, true
+}
+
+// truncatePeriod returns the start of the period containing t, matching
+// PostgreSQL's date_trunc. Weeks start on Monday.
+func truncatePeriod(t time.Time, granularity string) time.Time {
+	year, month, day := t.Date()
+	switch granularity {
+	case "week":
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, time.UTC)
+	case "month":
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	case "quarter":
+		return time.Date(year, (month-1)/3*3+1, 1, 0, 0, 0, 0, time.UTC)
+	case "year":
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// addPeriods moves a period start n periods forward or back.
+func addPeriods(t time.Time, granularity string, n int) time.Time {
+	switch granularity {
+	case "week":
+		return t.AddDate(0, 0, 7*n)
+	case "month":
+		return t.AddDate(0, n, 0)
+	case "quarter":
+		return t.AddDate(0, 3*n, 0)
+	case "year":
+		return t.AddDate(n, 0, 0)
+	default:
+		return t.AddDate(0, 0, n)
+	}
+}
+
+func profitLossChange(current, previous models.ProfitLossFigures) models.ProfitLossChange {
+	return models.ProfitLossChange{
+		Revenue:     percentChange(current.Revenue, previous.Revenue),
+		GrossProfit: percentChange(current.GrossProfit, previous.GrossProfit),
+		NetProfit:   percentChange(current.NetProfit, previous.NetProfit),
+	}
+}
+
+// percentChange is rounded to two decimals and measured against the absolute
+// previous value, so a loss shrinking towards zero reads as an improvement.
+func percentChange(current, previous float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := math.Round((current-previous)/math.Abs(previous)*10000) / 100
+	return &change
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/Cakra17/imphnen/internal/middleware"
 	"github.com/Cakra17/imphnen/internal/models"
 	"github.com/Cakra17/imphnen/internal/store"
 	"github.com/Cakra17/imphnen/internal/utils"
@@ -84,6 +85,10 @@ func (h *TelegramHandler) ListProductsByMerchant(w http.ResponseWriter, r *http.
 		return
 	}
 
+	for i := range products {
+		hideProductCost(&products[i])
+	}
+
 	totalPage := uint(math.Ceil(float64(total) / float64(perPage)))
 
 	utils.ResponseJson(w, http.StatusOK, utils.ResponsePaginate{
@@ -195,9 +200,16 @@ func (h *TelegramHandler) CreateOrderForCustomer(w http.ResponseWriter, r *http.
 
 	order.Customer = customer
 
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityType: "orders",
+		EntityID:   order.ID,
+		StoreID:    order.UserID,
+		After:      order,
+	})
+
 	utils.ResponseJson(w, http.StatusCreated, utils.Response{
 		Message: "Berhasil membuat pesanan",
-		Data:    order,
+		Data:    hideOrderCosts(order),
 	})
 }
 
@@ -261,7 +273,7 @@ func (h *TelegramHandler) ListCustomerOrders(w http.ResponseWriter, r *http.Requ
 
 	utils.ResponseJson(w, http.StatusOK, utils.ResponsePaginate{
 		Message: "Berhasil mendapatkan pesanan",
-		Data:    orders,
+		Data:    hideOrdersCosts(orders),
 		Meta: utils.Meta{
 			Page:        page,
 			TotalData:   total,
@@ -330,9 +342,18 @@ func (h *TelegramHandler) CancelCustomerOrder(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		Action:     "cancel",
+		EntityType: "orders",
+		EntityID:   orderID,
+		StoreID:    order.UserID,
+		Before:     order,
+		After:      updatedOrder,
+	})
+
 	utils.ResponseJson(w, http.StatusOK, utils.Response{
 		Message: "Berhasil membatalkan pesanan",
-		Data:    updatedOrder,
+		Data:    hideOrderCosts(updatedOrder),
 	})
 }
 
@@ -402,15 +423,24 @@ func (h *TelegramHandler) AcceptCustomerOrder(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		Action:     "confirm",
+		EntityType: "orders",
+		EntityID:   orderID,
+		StoreID:    order.UserID,
+		Before:     order,
+		After:      updatedOrder,
+	})
+
 	utils.ResponseJson(w, http.StatusOK, utils.Response{
 		Message: "Berhasil membatalkan pesanan",
-		Data:    updatedOrder,
+		Data:    hideOrderCosts(updatedOrder),
 	})
 }
 
 // DeleteCustomerOrder deletes an order (customer side)
 // @Summary Delete customer order (Telegram bot)
-// @Description Delete a pending or cancelled order
+// @Description Delete a pending or cancelled order. The voided transactions of a cancelled order stay in the books
 // @Tags Telegram
 // @Accept json
 // @Produce json
@@ -459,6 +489,13 @@ func (h *TelegramHandler) DeleteCustomerOrder(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityType: "orders",
+		EntityID:   orderID,
+		StoreID:    order.UserID,
+		Before:     order,
+	})
+
 	utils.ResponseJson(w, http.StatusOK, utils.Response{
 		Message: "Berhasil menghapus pesanan",
 	})
@@ -489,3 +526,27 @@ func (h *TelegramHandler) GetAllMerchants(w http.ResponseWriter, r *http.Request
 		Data:    merchants,
 	})
 }
+
+// hideProductCost clears what a merchant pays for a product before it is
+// shown to customers.
+func hideProductCost(product *models.Product) {
+	product.CostPrice = 0
+	product.CategoryID = nil
+}
+
+func hideOrderCosts(order *models.Order) *models.Order {
+	for i := range order.OrderItems {
+		order.OrderItems[i].UnitCost = 0
+		if order.OrderItems[i].Product != nil {
+			hideProductCost(order.OrderItems[i].Product)
+		}
+	}
+	return order
+}
+
+func hideOrdersCosts(orders []models.Order) []models.Order {
+	for i := range orders {
+		hideOrderCosts(&orders[i])
+	}
+	return orders
+}
@@ -1,12 +1,18 @@
 package handlers
 
 import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Cakra17/imphnen/internal/export"
 	"github.com/Cakra17/imphnen/internal/middleware"
 	"github.com/Cakra17/imphnen/internal/models"
 	"github.com/Cakra17/imphnen/internal/store"
@@ -15,23 +21,34 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	defaultTransactionPage = 20
+	maxTransactionPage     = 100
+)
+
 type TransactionHandler struct {
 	transactionStore *store.TransactionRepo
+	categoryRepo     store.CategoryRepo
+	accountRepo      store.AccountRepo
 }
 
 type TransactionHandlerConfig struct {
 	TransactionStore *store.TransactionRepo
+	CategoryRepo     store.CategoryRepo
+	AccountRepo      store.AccountRepo
 }
 
 func NewTransactionHandler(cfg TransactionHandlerConfig) TransactionHandler {
 	return TransactionHandler{
 		transactionStore: cfg.TransactionStore,
+		categoryRepo:     cfg.CategoryRepo,
+		accountRepo:      cfg.AccountRepo,
 	}
 }
 
 // CreateTransaction godoc
 // @Summary      Create a new transaction
-// @Description  Create a new transaction for the authenticated user
+// @Description  Create a new transaction for the authenticated user. Without account_id it is booked to the default account.
 // @Tags         Transactions
 // @Accept       json
 // @Produce      json
@@ -67,8 +84,22 @@ func (h *TransactionHandler) CreateTransaction(w http.ResponseWriter, r *http.Re
 	claims, _ := middleware.GetClaims(ctx)
 	userID, _ := claims["user_id"].(string)
 
+	if status, msg := checkCategory(ctx, &h.categoryRepo, payload.CategoryID, userID, payload.Type); status != 0 {
+		utils.ResponseJson(w, status, utils.Response{
+			Message: msg,
+		})
+		return
+	}
+
+	if status, msg := checkAccount(ctx, &h.accountRepo, payload.AccountID, userID); status != 0 {
+		utils.ResponseJson(w, status, utils.Response{
+			Message: msg,
+		})
+		return
+	}
+
 	tscID, _ := uuid.NewV7()
-	date, err := time.Parse("2006-01-02", payload.TransactionDate)
+	date, err := time.ParseInLocation("2006-01-02", payload.TransactionDate, middleware.GetLocation(ctx))
 	if err != nil {
 		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
 			Message: "Gagal membuat transaksi",
@@ -85,6 +116,8 @@ func (h *TransactionHandler) CreateTransaction(w http.ResponseWriter, r *http.Re
 		TransactionDate: date,
 		ReceiptID:       payload.ReceiptID,
 		OrderID:         payload.OrderID,
+		CategoryID:      payload.CategoryID,
+		AccountID:       payload.AccountID,
 	}
 
 	if err := h.transactionStore.AddTransaction(ctx, &transaction); err != nil {
@@ -102,6 +135,134 @@ func (h *TransactionHandler) CreateTransaction(w http.ResponseWriter, r *http.Re
 	})
 }
 
+// GetTransactions godoc
+// @Summary      List transactions
+// @Description  List transactions with filters that can be combined, a page at a time. Pass next_cursor from a response as cursor to get the page after it, keeping the same filters and sort. Voided transactions are included unless voided=false.
+// @Tags         Transactions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        start_date   query     string  false  "Start date in YYYY-MM-DD format"
+// @Param        end_date     query     string  false  "End date in YYYY-MM-DD format"
+// @Param        type         query     string  false  "Transaction type (income/expense)"
+// @Param        source       query     string  false  "Transaction source (receipt/bot/manual/recurring/import)"
+// @Param        category_id  query     string  false  "Category ID"
+// @Param        account_id   query     string  false  "Account ID"
+// @Param        min_amount   query     number  false  "Minimum amount"
+// @Param        max_amount   query     number  false  "Maximum amount"
+// @Param        q            query     string  false  "Text in the category, receipt store, customer or recurring description"
+// @Param        voided       query     bool    false  "true for only voided transactions, false for only active ones"
+// @Param        sort         query     string  false  "date_desc, date_asc, amount_desc or amount_asc (default: date_desc)"
+// @Param        limit        query     int     false  "Transactions per page, at most 100" default(20)
+// @Param        cursor       query     string  false  "next_cursor of the previous page"
+// @Success      200          {object}  utils.Response{data=models.TransactionPageResponse}  "Transactions retrieved successfully"
+// @Failure      400          {object}  utils.Response{message=string}  "Invalid parameters"
+// @Failure      401          {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500          {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /transactions [get]
+func (h *TransactionHandler) GetTransactions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+	loc := middleware.GetLocation(ctx)
+	params := r.URL.Query()
+
+	query := models.TransactionQuery{
+		TransactionFilter: models.TransactionFilter{UserID: userID},
+		Sort:              models.TransactionSortDateDesc,
+		Limit:             defaultTransactionPage,
+	}
+
+	if startStr := params.Get("start_date"); startStr != "" {
+		start, err := time.ParseInLocation("2006-01-02", startStr, loc)
+		if err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Format start_date tidak valid, gunakan YYYY-MM-DD",
+			})
+			return
+		}
+		query.StartDate = &start
+	}
+
+	if endStr := params.Get("end_date"); endStr != "" {
+		end, err := time.ParseInLocation("2006-01-02", endStr, loc)
+		if err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Format end_date tidak valid, gunakan YYYY-MM-DD",
+			})
+			return
+		}
+		end = end.AddDate(0, 0, 1)
+		query.EndDate = &end
+	}
+
+	if query.StartDate != nil && query.EndDate != nil && !query.StartDate.Before(*query.EndDate) {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "end_date tidak boleh sebelum start_date",
+		})
+		return
+	}
+
+	if !parseTransactionFilter(w, r, &query.TransactionFilter) {
+		return
+	}
+
+	if sort := params.Get("sort"); sort != "" {
+		switch sort {
+		case models.TransactionSortDateDesc, models.TransactionSortDateAsc,
+			models.TransactionSortAmountDesc, models.TransactionSortAmountAsc:
+			query.Sort = sort
+		default:
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter sort harus 'date_desc', 'date_asc', 'amount_desc', atau 'amount_asc'",
+			})
+			return
+		}
+	}
+
+	if limitStr := params.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxTransactionPage {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: fmt.Sprintf("Parameter limit harus antara 1 dan %d", maxTransactionPage),
+			})
+			return
+		}
+		query.Limit = limit
+	}
+
+	if cursorStr := params.Get("cursor"); cursorStr != "" {
+		cursor, ok := decodeTransactionCursor(cursorStr)
+		if !ok || cursor.Sort != query.Sort {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter cursor tidak valid",
+			})
+			return
+		}
+		query.Cursor = cursor
+	}
+
+	transactions, next, err := h.transactionStore.QueryTransactions(ctx, query)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data transaksi",
+		})
+		return
+	}
+
+	page := models.TransactionPageResponse{
+		Transactions: transactions,
+	}
+	if next != nil {
+		cursor := encodeTransactionCursor(*next)
+		page.NextCursor = &cursor
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil data transaksi",
+		Data:    page,
+	})
+}
+
 // GetTransactionsByDate godoc
 // @Summary      Get transactions by date
 // @Description  Get all transactions for a specific date for the authenticated user
@@ -114,6 +275,7 @@ func (h *TransactionHandler) CreateTransaction(w http.ResponseWriter, r *http.Re
 // @Failure      400    {object}  utils.Response{message=string}  "Invalid date format"
 // @Failure      401    {object}  utils.Response{message=string}  "Unauthorized"
 // @Failure      500    {object}  utils.Response{message=string}  "Internal server error"
+// @Deprecated
 // @Router       /transactions/date [get]
 func (h *TransactionHandler) GetTransactionsByDate(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -128,7 +290,7 @@ func (h *TransactionHandler) GetTransactionsByDate(w http.ResponseWriter, r *htt
 		return
 	}
 
-	date, err := time.Parse("2006-01-02", dateStr)
+	date, err := time.ParseInLocation("2006-01-02", dateStr, middleware.GetLocation(ctx))
 	if err != nil {
 		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
 			Message: "Format date tidak valid, gunakan YYYY-MM-DD",
@@ -170,6 +332,7 @@ func (h *TransactionHandler) GetTransactionsByDate(w http.ResponseWriter, r *htt
 // @Failure      400         {object}  utils.Response{message=string}  "Invalid date format"
 // @Failure      401         {object}  utils.Response{message=string}  "Unauthorized"
 // @Failure      500         {object}  utils.Response{message=string}  "Internal server error"
+// @Deprecated
 // @Router       /transactions/range [get]
 func (h *TransactionHandler) GetTransactionsByRange(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -186,7 +349,8 @@ func (h *TransactionHandler) GetTransactionsByRange(w http.ResponseWriter, r *ht
 		return
 	}
 
-	startDate, err := time.Parse("2006-01-02", startDateStr)
+	loc := middleware.GetLocation(ctx)
+	startDate, err := time.ParseInLocation("2006-01-02", startDateStr, loc)
 	if err != nil {
 		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
 			Message: "Format start_date tidak valid, gunakan YYYY-MM-DD",
@@ -194,7 +358,7 @@ func (h *TransactionHandler) GetTransactionsByRange(w http.ResponseWriter, r *ht
 		return
 	}
 
-	endDate, err := time.Parse("2006-01-02", endDateStr)
+	endDate, err := time.ParseInLocation("2006-01-02", endDateStr, loc)
 	if err != nil {
 		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
 			Message: "Format end_date tidak valid, gunakan YYYY-MM-DD",
@@ -202,8 +366,8 @@ func (h *TransactionHandler) GetTransactionsByRange(w http.ResponseWriter, r *ht
 		return
 	}
 
-	// Add time to end date to include the entire day
-	endDate = endDate.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+	// Move the end to the next midnight to include the entire day
+	endDate = endDate.AddDate(0, 0, 1)
 
 	transactions, err := h.transactionStore.GetTransactionsByRange(ctx, userID, startDate, endDate)
 	if err != nil {
@@ -239,6 +403,7 @@ func (h *TransactionHandler) GetTransactionsByRange(w http.ResponseWriter, r *ht
 // @Failure      400   {object}  utils.Response{message=string}  "Invalid days parameter"
 // @Failure      401   {object}  utils.Response{message=string}  "Unauthorized"
 // @Failure      500   {object}  utils.Response{message=string}  "Internal server error"
+// @Deprecated
 // @Router       /transactions/days [get]
 func (h *TransactionHandler) GetTransactionsByDays(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -261,7 +426,7 @@ func (h *TransactionHandler) GetTransactionsByDays(w http.ResponseWriter, r *htt
 		return
 	}
 
-	transactions, err := h.transactionStore.GetTransactionsByDays(ctx, userID, days)
+	transactions, err := h.transactionStore.GetTransactionsByDays(ctx, userID, days, middleware.GetLocation(ctx))
 	if err != nil {
 		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
 			Message: "Gagal mengambil data transaksi",
@@ -291,8 +456,9 @@ func (h *TransactionHandler) GetTransactionsByDays(w http.ResponseWriter, r *htt
 // @Security     BearerAuth
 // @Param        start_date  query     string  false  "Start date in YYYY-MM-DD format (default: 30 days ago)"
 // @Param        end_date    query     string  false  "End date in YYYY-MM-DD format (default: today)"
-// @Success      200         {object}  utils.Response{data=models.TransactionStats}  "Statistics retrieved successfully"
-// @Failure      400         {object}  utils.Response{message=string}  "Invalid date format"
+// @Param        compare     query     string  false  "Also return the stats of the previous_period (same length, right before) or previous_year (same dates a year earlier)"
+// @Success      200         {object}  utils.Response{data=models.TransactionStatsResponse}  "Statistics retrieved successfully"
+// @Failure      400         {object}  utils.Response{message=string}  "Invalid parameters"
 // @Failure      401         {object}  utils.Response{message=string}  "Unauthorized"
 // @Failure      500         {object}  utils.Response{message=string}  "Internal server error"
 // @Router       /transactions/stats [get]
@@ -301,38 +467,43 @@ func (h *TransactionHandler) GetTransactionStats(w http.ResponseWriter, r *http.
 	claims, _ := middleware.GetClaims(ctx)
 	userID, _ := claims["user_id"].(string)
 
-	startDateStr := r.URL.Query().Get("start_date")
-	endDateStr := r.URL.Query().Get("end_date")
-
-	var startDate, endDate time.Time
+	startDate, endDate, ok := parseDateRange(w, r, 30)
+	if !ok {
+		return
+	}
 
-	if startDateStr != "" {
-		start, err := time.Parse("2006-01-02", startDateStr)
-		if err != nil {
-			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
-				Message: "Format start_date tidak valid, gunakan YYYY-MM-DD",
-			})
-			return
-		}
-		startDate = start
-	} else {
-		startDate = time.Now().AddDate(0, 0, -30) // Default 30 days ago
+	compare, ok := parseStatsCompare(w, r)
+	if !ok {
+		return
 	}
 
-	if endDateStr != "" {
-		end, err := time.Parse("2006-01-02", endDateStr)
-		if err != nil {
-			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
-				Message: "Format end_date tidak valid, gunakan YYYY-MM-DD",
-			})
-			return
-		}
-		endDate = end.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
-	} else {
-		endDate = time.Now() // Default today
+	h.respondTransactionStats(w, r, userID, startDate, endDate, compare)
+}
+
+// GetTransactionStatsByCategory godoc
+// @Summary      Get transaction statistics per category
+// @Description  Break the transaction statistics of a date range down by category. Voided transactions are excluded and transactions without a category are grouped together.
+// @Tags         Transactions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        start_date  query     string  false  "Start date in YYYY-MM-DD format (default: 30 days ago)"
+// @Param        end_date    query     string  false  "End date in YYYY-MM-DD format (default: today)"
+// @Success      200         {object}  utils.Response{data=models.CategoryStatsResponse}  "Statistics retrieved successfully"
+// @Failure      400         {object}  utils.Response{message=string}  "Invalid date format"
+// @Failure      401         {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500         {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /transactions/stats/categories [get]
+func (h *TransactionHandler) GetTransactionStatsByCategory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	startDate, endDate, ok := parseDateRange(w, r, 30)
+	if !ok {
+		return
 	}
 
-	stats, err := h.transactionStore.GetTransactionStats(ctx, userID, startDate, endDate)
+	categories, err := h.categoryRepo.GetCategoryStats(ctx, userID, startDate, endDate)
 	if err != nil {
 		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
 			Message: "Gagal mengambil statistik transaksi",
@@ -340,9 +511,28 @@ func (h *TransactionHandler) GetTransactionStats(w http.ResponseWriter, r *http.
 		return
 	}
 
+	response := models.CategoryStatsResponse{Categories: categories}
+	for _, c := range categories {
+		if c.Type == "income" {
+			response.TotalIncome += c.TotalAmount
+		} else {
+			response.TotalExpense += c.TotalAmount
+		}
+	}
+
+	for i, c := range response.Categories {
+		total := response.TotalExpense
+		if c.Type == "income" {
+			total = response.TotalIncome
+		}
+		if total > 0 {
+			response.Categories[i].Percentage = math.Round(c.TotalAmount/total*10000) / 100
+		}
+	}
+
 	utils.ResponseJson(w, http.StatusOK, utils.Response{
 		Message: "Berhasil mengambil statistik transaksi",
-		Data:    stats,
+		Data:    response,
 	})
 }
 
@@ -353,11 +543,12 @@ func (h *TransactionHandler) GetTransactionStats(w http.ResponseWriter, r *http.
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        days  query     int  false  "Number of days (default: 30)"
-// @Success      200   {object}  utils.Response{data=models.TransactionStats}  "Statistics retrieved successfully"
-// @Failure      400   {object}  utils.Response{message=string}  "Invalid days parameter"
-// @Failure      401   {object}  utils.Response{message=string}  "Unauthorized"
-// @Failure      500   {object}  utils.Response{message=string}  "Internal server error"
+// @Param        days     query     int     false  "Number of days (default: 30)"
+// @Param        compare  query     string  false  "Also return the stats of the previous_period (same length, right before) or previous_year (same dates a year earlier)"
+// @Success      200      {object}  utils.Response{data=models.TransactionStatsResponse}  "Statistics retrieved successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid parameters"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
 // @Router       /transactions/stats/days [get]
 func (h *TransactionHandler) GetTransactionStatsByDays(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -378,7 +569,24 @@ func (h *TransactionHandler) GetTransactionStatsByDays(w http.ResponseWriter, r
 		days = d
 	}
 
-	stats, err := h.transactionStore.GetTransactionStatsByDays(ctx, userID, days)
+	compare, ok := parseStatsCompare(w, r)
+	if !ok {
+		return
+	}
+
+	endDate := utils.StartOfDay(time.Now(), middleware.GetLocation(ctx)).AddDate(0, 0, 1)
+	startDate := endDate.AddDate(0, 0, -days-1)
+	h.respondTransactionStats(w, r, userID, startDate, endDate, compare)
+}
+
+// respondTransactionStats writes the stats from startDate up to endDate and,
+// when compare is set, those of the window they are compared with.
+func (h *TransactionHandler) respondTransactionStats(
+	w http.ResponseWriter, r *http.Request, userID string, startDate, endDate time.Time, compare string,
+) {
+	ctx := r.Context()
+
+	stats, err := h.transactionStore.GetTransactionStats(ctx, userID, startDate, endDate)
 	if err != nil {
 		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
 			Message: "Gagal mengambil statistik transaksi",
@@ -386,12 +594,200 @@ func (h *TransactionHandler) GetTransactionStatsByDays(w http.ResponseWriter, r
 		return
 	}
 
+	response := models.TransactionStatsResponse{TransactionStats: stats}
+	if compare != models.CompareNone {
+		previousStart, previousEnd := comparisonWindow(startDate, endDate, compare)
+		previous, err := h.transactionStore.GetTransactionStats(ctx, userID, previousStart, previousEnd)
+		if err != nil {
+			utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+				Message: "Gagal mengambil statistik transaksi",
+			})
+			return
+		}
+
+		response.Comparison = &models.TransactionStatsComparison{
+			Compare:   compare,
+			StartDate: previousStart.Format("2006-01-02"),
+			EndDate:   previousEnd.AddDate(0, 0, -1).Format("2006-01-02"),
+			Stats:     previous,
+			Change:    transactionStatsChange(stats, previous),
+		}
+	}
+
 	utils.ResponseJson(w, http.StatusOK, utils.Response{
 		Message: "Berhasil mengambil statistik transaksi",
-		Data:    stats,
+		Data:    response,
 	})
 }
 
+// parseStatsCompare reads the compare query parameter. It writes a 400 and
+// returns false when the value is not a known comparison.
+func parseStatsCompare(w http.ResponseWriter, r *http.Request) (string, bool) {
+	compare := r.URL.Query().Get("compare")
+	switch compare {
+	case models.CompareNone, models.ComparePreviousPeriod, models.ComparePreviousYear:
+		return compare, true
+	default:
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Parameter compare harus 'previous_period' atau 'previous_year'",
+		})
+		return "", false
+	}
+}
+
+// comparisonWindow returns the window startDate up to endDate is compared
+// with. The previous period has the same number of days and ends where the
+// window starts; the previous year covers the same dates a year earlier.
+// Both bounds are midnights in the merchant's time zone.
+func comparisonWindow(startDate, endDate time.Time, compare string) (time.Time, time.Time) {
+	if compare == models.ComparePreviousYear {
+		return startDate.AddDate(-1, 0, 0), endDate.AddDate(-1, 0, 0)
+	}
+	// Days are counted by hand since a window across a DST change is not a
+	// whole number of 24 hour days.
+	days := int(math.Round(endDate.Sub(startDate).Hours() / 24))
+	return startDate.AddDate(0, 0, -days), startDate
+}
+
+func transactionStatsChange(current, previous models.TransactionStats) models.TransactionStatsChange {
+	return models.TransactionStatsChange{
+		TotalIncome:             current.TotalIncome - previous.TotalIncome,
+		TotalIncomePercent:      percentChange(current.TotalIncome, previous.TotalIncome),
+		TotalExpense:            current.TotalExpense - previous.TotalExpense,
+		TotalExpensePercent:     percentChange(current.TotalExpense, previous.TotalExpense),
+		NetAmount:               current.NetAmount - previous.NetAmount,
+		NetAmountPercent:        percentChange(current.NetAmount, previous.NetAmount),
+		TransactionCount:        current.TransactionCount - previous.TransactionCount,
+		TransactionCountPercent: percentChange(float64(current.TransactionCount), float64(previous.TransactionCount)),
+	}
+}
+
+// GetTransactionSeries godoc
+// @Summary      Get cashflow series
+// @Description  Income, expense and net per day, week or month for charts, including empty buckets. from and to are widened to whole buckets; weeks start on Monday. Voided transactions are excluded.
+// @Tags         Transactions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        from    query     string  false  "Start date in YYYY-MM-DD format (default: 29 days ago)"
+// @Param        to      query     string  false  "End date in YYYY-MM-DD format (default: today)"
+// @Param        bucket  query     string  false  "Bucket size: day, week or month (default: day)"
+// @Success      200     {object}  utils.Response{data=models.CashflowSeries}  "Cashflow series retrieved successfully"
+// @Failure      400     {object}  utils.Response{message=string}  "Invalid parameters"
+// @Failure      401     {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500     {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /transactions/series [get]
+func (h *TransactionHandler) GetTransactionSeries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	bucket := r.URL.Query().Get("bucket")
+	if bucket == "" {
+		bucket = "day"
+	}
+	if bucket != "day" && bucket != "week" && bucket != "month" {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Parameter bucket harus 'day', 'week', atau 'month'",
+		})
+		return
+	}
+
+	loc := middleware.GetLocation(ctx)
+	start, end, _, ok := parsePeriodRange(w, r, truncatePeriod(time.Now().In(loc), "day").AddDate(0, 0, -29), bucket)
+	if !ok {
+		return
+	}
+
+	buckets, err := h.transactionStore.GetTransactionSeries(ctx, userID, bucket, reportSteps[bucket], start, end, loc)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data arus kas",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil data arus kas",
+		Data: models.CashflowSeries{
+			From:    start.Format("2006-01-02"),
+			To:      end.AddDate(0, 0, -1).Format("2006-01-02"),
+			Bucket:  bucket,
+			Buckets: buckets,
+		},
+	})
+}
+
+// ExportTransactions godoc
+// @Summary      Export transactions
+// @Description  Download transactions as CSV or XLSX, streamed row by row. Takes the same filters as the list endpoints. Dates are in the merchant's time zone and amounts in rupiah. Voided transactions are included and marked as such unless voided=false.
+// @Tags         Transactions
+// @Produce      text/csv
+// @Produce      application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security     BearerAuth
+// @Param        format       query     string  false  "csv or xlsx (default: csv)"
+// @Param        start_date   query     string  false  "Start date in YYYY-MM-DD format (default: 30 days ago)"
+// @Param        end_date     query     string  false  "End date in YYYY-MM-DD format (default: today)"
+// @Param        type         query     string  false  "Transaction type (income/expense)"
+// @Param        source       query     string  false  "Transaction source (receipt/bot/manual/recurring/import)"
+// @Param        category_id  query     string  false  "Category ID"
+// @Param        account_id   query     string  false  "Account ID"
+// @Param        min_amount   query     number  false  "Minimum amount"
+// @Param        max_amount   query     number  false  "Maximum amount"
+// @Param        q            query     string  false  "Text in the category, receipt store, customer or recurring description"
+// @Param        voided       query     bool    false  "true for only voided transactions, false for only active ones"
+// @Success      200          {file}    file    "Transaction export"
+// @Failure      400          {object}  utils.Response{message=string}  "Invalid parameters"
+// @Failure      401          {object}  utils.Response{message=string}  "Unauthorized"
+// @Router       /transactions/export [get]
+func (h *TransactionHandler) ExportTransactions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	format, ok := exportFormat(w, r)
+	if !ok {
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(w, r, 30)
+	if !ok {
+		return
+	}
+
+	filter := models.TransactionFilter{
+		UserID:    userID,
+		StartDate: &startDate,
+		EndDate:   &endDate,
+	}
+	if !parseTransactionFilter(w, r, &filter) {
+		return
+	}
+
+	writer := startExport(w, r, format, "transaksi", []export.Column{
+		{Title: "Tanggal", Kind: export.Date},
+		{Title: "Jenis", Kind: export.Text},
+		{Title: "Sumber", Kind: export.Text},
+		{Title: "Kategori", Kind: export.Text},
+		{Title: "Akun", Kind: export.Text},
+		{Title: "Jumlah", Kind: export.Money},
+		{Title: "Status", Kind: export.Text},
+		{Title: "Alasan Pembatalan", Kind: export.Text},
+		{Title: "ID Transaksi", Kind: export.Text},
+		{Title: "ID Struk", Kind: export.Text},
+		{Title: "ID Pesanan", Kind: export.Text},
+		{Title: "Dibuat Pada", Kind: export.DateTime},
+	}, startDate, endDate)
+
+	err := h.transactionStore.ExportTransactions(ctx, filter, func(row models.TransactionExportRow) error {
+		return writer.WriteRow(
+			row.TransactionDate, transactionTypeLabel(row.Type), row.Source, row.CategoryName, row.AccountName,
+			row.Amount, voidedLabel(row.VoidedAt), row.VoidReason,
+			row.ID, row.ReceiptID, row.OrderID, row.CreatedAt,
+		)
+	})
+	finishExport(writer, "transaksi", err)
+}
+
 // GetTransactionsByType godoc
 // @Summary      Get transactions by type
 // @Description  Get transactions by type for a date range for the authenticated user
@@ -406,6 +802,7 @@ func (h *TransactionHandler) GetTransactionStatsByDays(w http.ResponseWriter, r
 // @Failure      400        {object}  utils.Response{message=string}  "Invalid parameters"
 // @Failure      401        {object}  utils.Response{message=string}  "Unauthorized"
 // @Failure      500        {object}  utils.Response{message=string}  "Internal server error"
+// @Deprecated
 // @Router       /transactions/type [get]
 func (h *TransactionHandler) GetTransactionsByType(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -427,39 +824,11 @@ func (h *TransactionHandler) GetTransactionsByType(w http.ResponseWriter, r *htt
 		return
 	}
 
-	startDateStr := r.URL.Query().Get("start_date")
-	endDateStr := r.URL.Query().Get("end_date")
-
-	var startDate, endDate time.Time
-
-	if startDateStr != "" {
-		start, err := time.Parse("2006-01-02", startDateStr)
-		if err != nil {
-			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
-				Message: "Format start_date tidak valid, gunakan YYYY-MM-DD",
-			})
-			return
-		}
-		startDate = start
-	} else {
-		startDate = time.Now().AddDate(0, 0, -30) // Default 30 days ago
-	}
-
-	if endDateStr != "" {
-		end, err := time.Parse("2006-01-02", endDateStr)
-		if err != nil {
-			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
-				Message: "Format end_date tidak valid, gunakan YYYY-MM-DD",
-			})
-			return
-		}
-		endDate = end.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
-	} else {
-		endDate = time.Now() // Default today
+	startDate, endDate, ok := parseDateRange(w, r, 30)
+	if !ok {
+		return
 	}
 
-	fmt.Println(startDate, endDate, transactionType)
-
 	transactions, err := h.transactionStore.GetTransactionsByType(ctx, userID, transactionType, startDate, endDate)
 	if err != nil {
 		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
@@ -488,13 +857,14 @@ func (h *TransactionHandler) GetTransactionsByType(w http.ResponseWriter, r *htt
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        source     query     string  true  "Transaction source (receipt/whatsapp/manual)"
+// @Param        source     query     string  true  "Transaction source (receipt/bot/manual/recurring/import)"
 // @Param        start_date query     string  false  "Start date in YYYY-MM-DD format (default: 30 days ago)"
 // @Param        end_date   query     string  false  "End date in YYYY-MM-DD format (default: today)"
 // @Success      200        {object}  utils.Response{data=models.TransactionListResponse}  "Transactions retrieved successfully"
 // @Failure      400        {object}  utils.Response{message=string}  "Invalid parameters"
 // @Failure      401        {object}  utils.Response{message=string}  "Unauthorized"
 // @Failure      500        {object}  utils.Response{message=string}  "Internal server error"
+// @Deprecated
 // @Router       /transactions/source [get]
 func (h *TransactionHandler) GetTransactionsBySource(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -504,66 +874,503 @@ func (h *TransactionHandler) GetTransactionsBySource(w http.ResponseWriter, r *h
 	source := r.URL.Query().Get("source")
 	if source == "" {
 		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
-			Message: "Parameter source diperlukan (receipt/bot/manual)",
+			Message: "Parameter source diperlukan (receipt/bot/manual/recurring/import)",
+		})
+		return
+	}
+
+	if source != "receipt" && source != "bot" && source != "manual" && source != "recurring" && source != "import" {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Parameter source harus 'receipt', 'bot', 'manual', 'recurring', atau 'import'",
+		})
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(w, r, 30)
+	if !ok {
+		return
+	}
+
+	transactions, err := h.transactionStore.GetTransactionsBySource(ctx, userID, source, startDate, endDate)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data transaksi",
+		})
+		return
+	}
+
+	transactionList := make([]models.Transaction, len(transactions))
+	for i, t := range transactions {
+		transactionList[i] = t
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil data transaksi",
+		Data: models.TransactionListResponse{
+			Transactions: transactionList,
+		},
+	})
+}
+
+// GetTransactionByID godoc
+// @Summary      Get transaction by ID
+// @Description  Get a single transaction of the authenticated user, including voided ones
+// @Tags         Transactions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Transaction ID"
+// @Success      200  {object}  utils.Response{data=models.TransactionResponse}  "Transaction retrieved successfully"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404  {object}  utils.Response{message=string}  "Transaction not found"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /transactions/{id} [get]
+func (h *TransactionHandler) GetTransactionByID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	transaction, ok := h.getTransaction(w, r, userID, false)
+	if !ok {
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil data transaksi",
+		Data: models.TransactionResponse{
+			Transaction: *transaction,
+		},
+	})
+}
+
+// UpdateTransaction godoc
+// @Summary      Update a transaction
+// @Description  Change the type, amount or date of a manual transaction. transaction_date is a YYYY-MM-DD date, which keeps the current time when the day is unchanged, or an RFC 3339 timestamp. Without account_id it is booked to the default account. Voided transactions and transactions generated from a receipt or an order cannot be edited. The previous values are kept in the transaction history.
+// @Tags         Transactions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string                           true  "Transaction ID"
+// @Param        request  body      models.UpdateTransactionPayload  true  "New transaction values"
+// @Success      200      {object}  utils.Response{data=models.TransactionResponse}  "Transaction updated successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid request data"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404      {object}  utils.Response{message=string}  "Transaction not found"
+// @Failure      409      {object}  utils.Response{message=string}  "Transaction is voided or belongs to a receipt or order"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /transactions/{id} [put]
+func (h *TransactionHandler) UpdateTransaction(w http.ResponseWriter, r *http.Request) {
+	var payload models.UpdateTransactionPayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return
+			}
+		}
+	}
+
+	existing, ok := h.getTransaction(w, r, userID, true)
+	if !ok {
+		return
+	}
+
+	date, err := editedTransactionDate(payload.TransactionDate, existing.TransactionDate, middleware.GetLocation(ctx))
+	if err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Format transaction_date tidak valid, gunakan YYYY-MM-DD atau RFC 3339",
+		})
+		return
+	}
+
+	if status, msg := checkCategory(ctx, &h.categoryRepo, payload.CategoryID, userID, payload.Type); status != 0 {
+		utils.ResponseJson(w, status, utils.Response{
+			Message: msg,
+		})
+		return
+	}
+
+	if status, msg := checkAccount(ctx, &h.accountRepo, payload.AccountID, userID); status != 0 {
+		utils.ResponseJson(w, status, utils.Response{
+			Message: msg,
 		})
 		return
 	}
 
-	if source != "receipt" && source != "bot" && source != "manual" {
+	transaction := *existing
+	transaction.Type = payload.Type
+	transaction.Amount = payload.Amount
+	transaction.TransactionDate = date
+	transaction.CategoryID = payload.CategoryID
+	transaction.AccountID = payload.AccountID
+
+	updated, err := h.transactionStore.UpdateTransaction(ctx, &transaction)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengubah transaksi",
+		})
+		return
+	}
+
+	if !updated {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Transaksi tidak dapat diubah",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityID: transaction.ID,
+		Before:   existing,
+		After:    transaction,
+	})
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengubah transaksi",
+		Data: models.TransactionResponse{
+			Transaction: transaction,
+		},
+	})
+}
+
+// editedTransactionDate parses the transaction_date of an edit. An RFC 3339
+// timestamp is taken as is. A YYYY-MM-DD date keeps the time of the current
+// transaction date when the day is unchanged, so an edit that only touches
+// the amount does not move the transaction to midnight, and starts at
+// midnight in loc otherwise.
+func editedTransactionDate(value string, current time.Time, loc *time.Location) (time.Time, error) {
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, nil
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if current.In(loc).Format("2006-01-02") == value {
+		return current, nil
+	}
+	return date, nil
+}
+
+// VoidTransaction godoc
+// @Summary      Void a transaction
+// @Description  Void a manual transaction. The transaction stays in the books but is excluded from statistics. Transactions generated from a receipt or an order are voided through their receipt or order.
+// @Tags         Transactions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string              true  "Transaction ID"
+// @Param        request  body      models.VoidPayload  true  "Reason for the void"
+// @Success      200      {object}  utils.Response{message=string}  "Transaction voided successfully"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid request data"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404      {object}  utils.Response{message=string}  "Transaction not found"
+// @Failure      409      {object}  utils.Response{message=string}  "Transaction is already voided or belongs to a receipt or order"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /transactions/{id}/void [post]
+func (h *TransactionHandler) VoidTransaction(w http.ResponseWriter, r *http.Request) {
+	var payload models.VoidPayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	if err := utils.ParseJson(r, &payload); err != nil {
 		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
-			Message: "Parameter source harus 'receipt', 'bot', atau 'manual'",
+			Message: "Data yang dikirim tidak sesuai",
 		})
 		return
 	}
 
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return
+			}
+		}
+	}
+
+	existing, ok := h.getTransaction(w, r, userID, true)
+	if !ok {
+		return
+	}
+
+	voided, err := h.transactionStore.VoidTransaction(ctx, existing.ID, userID, payload.Reason)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membatalkan transaksi",
+		})
+		return
+	}
+
+	if !voided {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Transaksi sudah dibatalkan",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		EntityID: existing.ID,
+		Before:   existing,
+	})
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil membatalkan transaksi",
+	})
+}
+
+// GetTransactionHistory godoc
+// @Summary      Get transaction history
+// @Description  List the previous values of a transaction for every edit and void, newest first.
+// @Tags         Transactions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Transaction ID"
+// @Success      200  {object}  utils.Response{data=models.TransactionRevisionListResponse}  "Transaction history retrieved successfully"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /transactions/{id}/history [get]
+func (h *TransactionHandler) GetTransactionHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	revisions, err := h.transactionStore.GetTransactionRevisions(ctx, r.PathValue("id"), userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil riwayat transaksi",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Berhasil mengambil riwayat transaksi",
+		Data: models.TransactionRevisionListResponse{
+			Revisions: revisions,
+		},
+	})
+}
+
+// getTransaction loads the transaction named in the path and writes
+// the error response itself when it cannot be used. With requireEditable it
+// also rejects voided transactions and ones owned by a receipt or an order.
+func (h *TransactionHandler) getTransaction(
+	w http.ResponseWriter, r *http.Request, userID string, requireEditable bool,
+) (*models.Transaction, bool) {
+	transaction, err := h.transactionStore.GetTransactionByID(r.Context(), r.PathValue("id"), userID)
+	if err == sql.ErrNoRows {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Transaksi tidak ditemukan",
+		})
+		return nil, false
+	}
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengambil data transaksi",
+		})
+		return nil, false
+	}
+
+	if !requireEditable {
+		return transaction, true
+	}
+
+	if transaction.HasParent() {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Transaksi dari struk atau pesanan hanya dapat dibatalkan melalui struk atau pesanan terkait",
+		})
+		return nil, false
+	}
+
+	if transaction.VoidedAt != nil {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Transaksi sudah dibatalkan",
+		})
+		return nil, false
+	}
+
+	return transaction, true
+}
+
+// parseDateRange reads the start_date and end_date query parameters as days
+// in the merchant's time zone, falling back to today and the defaultDays days
+// before it. The returned end is the midnight after end_date, so it is
+// exclusive. It writes the error response itself when a date is malformed.
+func parseDateRange(w http.ResponseWriter, r *http.Request, defaultDays int) (time.Time, time.Time, bool) {
 	startDateStr := r.URL.Query().Get("start_date")
 	endDateStr := r.URL.Query().Get("end_date")
 
-	var startDate, endDate time.Time
+	loc := middleware.GetLocation(r.Context())
+	today := utils.StartOfDay(time.Now(), loc)
+	startDate := today.AddDate(0, 0, -defaultDays)
+	endDate := today.AddDate(0, 0, 1)
 
 	if startDateStr != "" {
-		start, err := time.Parse("2006-01-02", startDateStr)
+		start, err := time.ParseInLocation("2006-01-02", startDateStr, loc)
 		if err != nil {
 			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
 				Message: "Format start_date tidak valid, gunakan YYYY-MM-DD",
 			})
-			return
+			return time.Time{}, time.Time{}, false
 		}
 		startDate = start
-	} else {
-		startDate = time.Now().AddDate(0, 0, -30) // Default 30 days ago
 	}
 
 	if endDateStr != "" {
-		end, err := time.Parse("2006-01-02", endDateStr)
+		end, err := time.ParseInLocation("2006-01-02", endDateStr, loc)
 		if err != nil {
 			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
 				Message: "Format end_date tidak valid, gunakan YYYY-MM-DD",
 			})
-			return
+			return time.Time{}, time.Time{}, false
 		}
-		endDate = end.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
-	} else {
-		endDate = time.Now() // Default today
+		endDate = end.AddDate(0, 0, 1)
 	}
 
-	transactions, err := h.transactionStore.GetTransactionsBySource(ctx, userID, source, startDate, endDate)
-	if err != nil {
-		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
-			Message: "Gagal mengambil data transaksi",
+	if !startDate.Before(endDate) {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "end_date tidak boleh sebelum start_date",
 		})
-		return
+		return time.Time{}, time.Time{}, false
 	}
 
-	transactionList := make([]models.Transaction, len(transactions))
-	for i, t := range transactions {
-		transactionList[i] = t
+	return startDate, endDate, true
+}
+
+// parseTransactionFilter reads the type, source, category_id, account_id,
+// min_amount, max_amount, q and voided query parameters into filter. It
+// writes a 400 and returns false when one of them is invalid.
+func parseTransactionFilter(w http.ResponseWriter, r *http.Request, filter *models.TransactionFilter) bool {
+	query := r.URL.Query()
+
+	if transactionType := query.Get("type"); transactionType != "" {
+		if transactionType != "income" && transactionType != "expense" {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter type harus 'income' atau 'expense'",
+			})
+			return false
+		}
+		filter.Type = &transactionType
 	}
 
-	utils.ResponseJson(w, http.StatusOK, utils.Response{
-		Message: "Berhasil mengambil data transaksi",
-		Data: models.TransactionListResponse{
-			Transactions: transactionList,
-		},
-	})
+	if source := query.Get("source"); source != "" {
+		if source != "receipt" && source != "bot" && source != "manual" && source != "recurring" && source != "import" {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter source harus 'receipt', 'bot', 'manual', 'recurring', atau 'import'",
+			})
+			return false
+		}
+		filter.Source = &source
+	}
+
+	if categoryID := query.Get("category_id"); categoryID != "" {
+		if _, err := uuid.Parse(categoryID); err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter category_id tidak valid",
+			})
+			return false
+		}
+		filter.CategoryID = &categoryID
+	}
+
+	if accountID := query.Get("account_id"); accountID != "" {
+		if _, err := uuid.Parse(accountID); err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter account_id tidak valid",
+			})
+			return false
+		}
+		filter.AccountID = &accountID
+	}
+
+	for _, bound := range []struct {
+		name  string
+		value **float64
+	}{{"min_amount", &filter.MinAmount}, {"max_amount", &filter.MaxAmount}} {
+		amountStr := query.Get(bound.name)
+		if amountStr == "" {
+			continue
+		}
+		amount, err := strconv.ParseFloat(amountStr, 64)
+		if err != nil || amount < 0 {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: fmt.Sprintf("Parameter %s harus angka positif", bound.name),
+			})
+			return false
+		}
+		*bound.value = &amount
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Parameter min_amount tidak boleh lebih besar dari max_amount",
+		})
+		return false
+	}
+
+	if search := strings.TrimSpace(query.Get("q")); search != "" {
+		filter.Search = &search
+	}
+
+	if voidedStr := query.Get("voided"); voidedStr != "" {
+		voided, err := strconv.ParseBool(voidedStr)
+		if err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Parameter voided harus 'true' atau 'false'",
+			})
+			return false
+		}
+		filter.Voided = &voided
+	}
+
+	return true
+}
+
+// encodeTransactionCursor makes a cursor opaque to clients, so they pass it
+// back as is rather than build their own.
+func encodeTransactionCursor(cursor models.TransactionCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeTransactionCursor reads a cursor made by encodeTransactionCursor and
+// checks that its values can be compared with the sort column.
+func decodeTransactionCursor(s string) (*models.TransactionCursor, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, false
+	}
+
+	var cursor models.TransactionCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, false
+	}
+	if _, err := uuid.Parse(cursor.ID); err != nil {
+		return nil, false
+	}
+
+	switch cursor.Sort {
+	case models.TransactionSortDateDesc, models.TransactionSortDateAsc:
+		_, err = time.Parse(time.RFC3339Nano, cursor.Value)
+	case models.TransactionSortAmountDesc, models.TransactionSortAmountAsc:
+		_, err = strconv.ParseFloat(cursor.Value, 64)
+	default:
+		return nil, false
+	}
+	return &cursor, err == nil
 }
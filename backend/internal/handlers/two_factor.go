@@ -0,0 +1,553 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/middleware"
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/Cakra17/imphnen/internal/utils"
+	"github.com/Cakra17/imphnen/internal/validation"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	twoFactorIssuer            = "Imphnen"
+	twoFactorChallengeDuration = 5 * time.Minute
+	recoveryCodeCount          = 10
+	// twoFactorMaxAttempts is how many second factor attempts a user gets,
+	// over all challenge tokens and 2FA settings endpoints, before it is
+	// locked for twoFactorLockout.
+	twoFactorMaxAttempts = 5
+	twoFactorLockout     = 15 * time.Minute
+)
+
+// EnrollTwoFactor godoc
+// @Summary      Start two-factor enrolment
+// @Description  Generate a new TOTP secret for the authenticated user. Two-factor authentication stays inactive until the first code is verified.
+// @Tags         Users
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  utils.Response{data=models.TwoFactorEnrollResponse}  "Secret and otpauth URI generated"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      409  {object}  utils.Response{message=string}  "Two-factor authentication already enabled"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /users/me/2fa/enroll [post]
+func (h *UserHandler) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	user, _ := h.userRepo.GetUserByID(ctx, userID)
+	if user == nil {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "User tidak ditemukan",
+		})
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Verifikasi dua langkah sudah aktif",
+		})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membuat kode rahasia",
+		})
+		return
+	}
+
+	sealed, err := h.totpBox.Seal(secret)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membuat kode rahasia",
+		})
+		return
+	}
+
+	if err := h.userRepo.SetTOTPSecret(ctx, userID, sealed); err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal menyimpan kode rahasia",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Pindai kode QR dengan aplikasi authenticator lalu verifikasi kodenya",
+		Data: models.TwoFactorEnrollResponse{
+			Secret:     secret,
+			OtpauthURI: utils.TOTPURI(twoFactorIssuer, user.Email, secret),
+		},
+	})
+}
+
+// VerifyTwoFactor godoc
+// @Summary      Activate two-factor authentication
+// @Description  Verify the first code from the authenticator app, activate two-factor authentication and return one-time recovery codes
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.TwoFactorCodePayload  true  "Code from the authenticator app"
+// @Success      200      {object}  utils.Response{data=models.RecoveryCodesResponse}  "Two-factor authentication enabled"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid code or enrolment not started"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      409      {object}  utils.Response{message=string}  "Two-factor authentication already enabled"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /users/me/2fa/verify [post]
+func (h *UserHandler) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var payload models.TwoFactorCodePayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return
+			}
+		}
+	}
+
+	user, _ := h.userRepo.GetUserByID(ctx, userID)
+	if user == nil {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "User tidak ditemukan",
+		})
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Verifikasi dua langkah sudah aktif",
+		})
+		return
+	}
+
+	if user.TOTPSecret == nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Lakukan pendaftaran verifikasi dua langkah terlebih dahulu",
+		})
+		return
+	}
+
+	secret, err := h.totpBox.Open(*user.TOTPSecret)
+	if err != nil {
+		log.Printf("[ERROR] Failed to open totp secret: %s", err.Error())
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal memeriksa kode verifikasi",
+		})
+		return
+	}
+
+	step, ok := utils.ValidateTOTP(secret, payload.Code, time.Now())
+	if !ok {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Kode verifikasi salah",
+		})
+		return
+	}
+
+	codes, recoveryCodes, err := newRecoveryCodes(userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membuat kode pemulihan",
+		})
+		return
+	}
+
+	if err := h.userRepo.EnableTOTP(ctx, userID, step, recoveryCodes); err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal mengaktifkan verifikasi dua langkah",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Verifikasi dua langkah berhasil diaktifkan, simpan kode pemulihan di tempat yang aman",
+		Data: models.RecoveryCodesResponse{
+			RecoveryCodes: codes,
+		},
+	})
+}
+
+// DisableTwoFactor godoc
+// @Summary      Disable two-factor authentication
+// @Description  Disable two-factor authentication after confirming the password and a current code. Failed codes count towards the same lockout as /auth/2fa.
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.DisableTwoFactorPayload  true  "Password and current code"
+// @Success      200      {object}  utils.Response{message=string}  "Two-factor authentication disabled"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid password or code"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      429      {object}  utils.Response{message=string}  "Too many failed attempts"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /users/me/2fa/disable [post]
+func (h *UserHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var payload models.DisableTwoFactorPayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return
+			}
+		}
+	}
+
+	user, _ := h.userRepo.GetUserByID(ctx, userID)
+	if user == nil {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "User tidak ditemukan",
+		})
+		return
+	}
+
+	if !user.TwoFactorEnabled {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Verifikasi dua langkah belum aktif",
+		})
+		return
+	}
+
+	if !comparePassword(payload.Password, user.PasswordHash) {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Password salah",
+		})
+		return
+	}
+
+	if !h.reserveTwoFactorAttempt(w, r, userID) {
+		return
+	}
+
+	if !h.checkTOTP(w, r, user, payload.Code) {
+		return
+	}
+
+	if !h.resetTwoFactorAttempts(w, r, userID) {
+		return
+	}
+
+	if err := h.userRepo.DisableTOTP(ctx, userID); err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal menonaktifkan verifikasi dua langkah",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Verifikasi dua langkah berhasil dinonaktifkan",
+	})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary      Regenerate recovery codes
+// @Description  Replace all recovery codes of the authenticated user after confirming a current code. Failed codes count towards the same lockout as /auth/2fa.
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      models.TwoFactorCodePayload  true  "Code from the authenticator app"
+// @Success      200      {object}  utils.Response{data=models.RecoveryCodesResponse}  "New recovery codes"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid code"
+// @Failure      401      {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      429      {object}  utils.Response{message=string}  "Too many failed attempts"
+// @Failure      500      {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /users/me/2fa/recovery-codes [post]
+func (h *UserHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var payload models.TwoFactorCodePayload
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return
+			}
+		}
+	}
+
+	user, _ := h.userRepo.GetUserByID(ctx, userID)
+	if user == nil {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "User tidak ditemukan",
+		})
+		return
+	}
+
+	if !user.TwoFactorEnabled {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Verifikasi dua langkah belum aktif",
+		})
+		return
+	}
+
+	if !h.reserveTwoFactorAttempt(w, r, userID) {
+		return
+	}
+
+	if !h.checkTOTP(w, r, user, payload.Code) {
+		return
+	}
+
+	if !h.resetTwoFactorAttempts(w, r, userID) {
+		return
+	}
+
+	codes, recoveryCodes, err := newRecoveryCodes(userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membuat kode pemulihan",
+		})
+		return
+	}
+
+	if err := h.userRepo.ReplaceRecoveryCodes(ctx, userID, recoveryCodes); err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal menyimpan kode pemulihan",
+		})
+		return
+	}
+
+	utils.ResponseJson(w, http.StatusOK, utils.Response{
+		Message: "Kode pemulihan berhasil dibuat ulang",
+		Data: models.RecoveryCodesResponse{
+			RecoveryCodes: codes,
+		},
+	})
+}
+
+// LoginTwoFactor godoc
+// @Summary      Complete login with a second factor
+// @Description  Exchange the challenge token returned by /auth/login and a TOTP or recovery code for an access token. After 5 failed attempts, over all challenge tokens, the second factor is locked for 15 minutes.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.TwoFactorLoginPayload  true  "Challenge token with a TOTP code or a recovery code"
+// @Success      200      {object}  utils.Response{data=models.UserResponse}  "Login successful with access token"
+// @Failure      400      {object}  utils.Response{message=string}  "Invalid code"
+// @Failure      401      {object}  utils.Response{message=string}  "Invalid or expired challenge token"
+// @Failure      429      {object}  utils.Response{message=string}  "Too many failed attempts"
+// @Failure      500      {object}  utils.Response{message=string}  "Failed to generate token"
+// @Router       /auth/2fa [post]
+func (h *UserHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var payload models.TwoFactorLoginPayload
+	ctx := r.Context()
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Data yang dikirim tidak sesuai",
+		})
+		return
+	}
+
+	if err := validation.Validate(payload); err != nil {
+		if errs, ok := err.(validation.ValidationErrors); ok {
+			for _, e := range errs {
+				utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+					Message: fmt.Sprintf("%s %s", e.Field, e.Message),
+				})
+				return
+			}
+		}
+	}
+
+	if payload.Code == "" && payload.RecoveryCode == "" {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Kode verifikasi atau kode pemulihan diperlukan",
+		})
+		return
+	}
+
+	token, err := utils.ValidateChallengeToken(payload.ChallengeToken, h.keys)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusUnauthorized, utils.Response{
+			Message: "Sesi verifikasi tidak valid atau sudah kedaluwarsa",
+		})
+		return
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		utils.ResponseJson(w, http.StatusUnauthorized, utils.Response{
+			Message: "Sesi verifikasi tidak valid atau sudah kedaluwarsa",
+		})
+		return
+	}
+	userID, _ := claims["user_id"].(string)
+
+	user, _ := h.userRepo.GetUserByID(ctx, userID)
+	if user == nil || !user.TwoFactorEnabled {
+		utils.ResponseJson(w, http.StatusUnauthorized, utils.Response{
+			Message: "Sesi verifikasi tidak valid atau sudah kedaluwarsa",
+		})
+		return
+	}
+
+	if !h.reserveTwoFactorAttempt(w, r, userID) {
+		return
+	}
+
+	if payload.RecoveryCode != "" {
+		used, err := h.userRepo.UseRecoveryCode(ctx, userID, utils.HashRecoveryCode(payload.RecoveryCode))
+		if err != nil {
+			utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+				Message: "Gagal memeriksa kode pemulihan",
+			})
+			return
+		}
+		if !used {
+			utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+				Message: "Kode pemulihan salah atau sudah digunakan",
+			})
+			return
+		}
+	} else if !h.checkTOTP(w, r, user, payload.Code) {
+		return
+	}
+
+	if !h.resetTwoFactorAttempts(w, r, userID) {
+		return
+	}
+
+	h.respondWithToken(w, user)
+}
+
+// reserveTwoFactorAttempt counts a second factor attempt against userID. Every
+// endpoint that checks a code shares the counter, so none of them can be used
+// to guess codes past the lockout. It writes the error response itself and
+// reports whether the caller may go on.
+func (h *UserHandler) reserveTwoFactorAttempt(w http.ResponseWriter, r *http.Request, userID string) bool {
+	allowed, err := h.userRepo.ReserveTwoFactorAttempt(r.Context(), userID, twoFactorMaxAttempts, twoFactorLockout)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal memeriksa kode verifikasi",
+		})
+		return false
+	}
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(twoFactorLockout.Seconds())))
+		utils.ResponseJson(w, http.StatusTooManyRequests, utils.Response{
+			Message: "Terlalu banyak percobaan verifikasi, coba lagi nanti",
+		})
+		return false
+	}
+	return true
+}
+
+// resetTwoFactorAttempts clears the counter after a correct code.
+func (h *UserHandler) resetTwoFactorAttempts(w http.ResponseWriter, r *http.Request, userID string) bool {
+	if err := h.userRepo.ResetTwoFactorAttempts(r.Context(), userID); err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal memeriksa kode verifikasi",
+		})
+		return false
+	}
+	return true
+}
+
+// checkTOTP validates code for user and marks its time step as used. It
+// writes the error response itself and reports whether the caller may go on.
+func (h *UserHandler) checkTOTP(w http.ResponseWriter, r *http.Request, user *models.User, code string) bool {
+	if user.TOTPSecret == nil {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Verifikasi dua langkah belum aktif",
+		})
+		return false
+	}
+
+	secret, err := h.totpBox.Open(*user.TOTPSecret)
+	if err != nil {
+		log.Printf("[ERROR] Failed to open totp secret: %s", err.Error())
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal memeriksa kode verifikasi",
+		})
+		return false
+	}
+
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Kode verifikasi salah",
+		})
+		return false
+	}
+
+	fresh, err := h.userRepo.ConsumeTOTPStep(r.Context(), user.ID, step)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal memeriksa kode verifikasi",
+		})
+		return false
+	}
+	if !fresh {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Kode verifikasi sudah digunakan, tunggu kode berikutnya",
+		})
+		return false
+	}
+
+	return true
+}
+
+func newRecoveryCodes(userID string) ([]string, []models.RecoveryCode, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recoveryCodes := make([]models.RecoveryCode, len(codes))
+	for i, code := range codes {
+		id, _ := uuid.NewV7()
+		recoveryCodes[i] = models.RecoveryCode{
+			ID:       id.String(),
+			UserID:   userID,
+			CodeHash: utils.HashRecoveryCode(code),
+		}
+	}
+	return codes, recoveryCodes, nil
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"archive/zip"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,23 +16,31 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+const accountExportURL = "/api/v1/users/me/export"
+
 type UserHandler struct {
 	userRepo      store.UserRepo
-	jwtSecret     string
+	keys          *utils.KeySet
+	totpBox       *utils.SecretBox
 	tokenDuration time.Duration
+	deletionGrace time.Duration
 }
 
 type UserHandlerConfig struct {
 	UserRepo      store.UserRepo
-	JwtSecret     string
+	Keys          *utils.KeySet
+	TOTPBox       *utils.SecretBox
 	TokenDuration time.Duration
+	DeletionGrace time.Duration
 }
 
 func NewUserHandler(cfg UserHandlerConfig) UserHandler {
 	return UserHandler{
 		userRepo:      cfg.UserRepo,
-		jwtSecret:     cfg.JwtSecret,
+		keys:          cfg.Keys,
+		totpBox:       cfg.TOTPBox,
 		tokenDuration: cfg.TokenDuration,
+		deletionGrace: cfg.DeletionGrace,
 	}
 }
 
@@ -69,6 +78,16 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if payload.Timezone == "" {
+		payload.Timezone = utils.DefaultTimezone
+	}
+	if !utils.ValidTimezone(payload.Timezone) {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Zona waktu tidak valid, gunakan nama zona IANA seperti Asia/Jakarta",
+		})
+		return
+	}
+
 	userExist, _ := h.userRepo.GetUserbyEmail(ctx, payload.Email)
 	if userExist != nil {
 		utils.ResponseJson(w, http.StatusConflict, utils.Response{
@@ -87,6 +106,7 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 		FirstName:    payload.FistName,
 		LastName:     payload.LastName,
 		StoreName:    payload.StoreName,
+		Timezone:     payload.Timezone,
 	}
 
 	err := h.userRepo.Create(ctx, user)
@@ -104,12 +124,13 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 // Login godoc
 // @Summary      Login to user account
-// @Description  Authenticate user with email and password, returns access token
+// @Description  Authenticate user with email and password, returns access token. When two-factor authentication is enabled a challenge token is returned instead, to be exchanged at /auth/2fa.
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
 // @Param        request  body      models.AuthPayload  true  "Login credentials"
 // @Success      200      {object}  utils.Response{data=models.UserResponse}  "Login successful with access token"
+// @Success      202      {object}  utils.Response{data=models.TwoFactorChallengeResponse}  "Two-factor code required"
 // @Failure      400      {object}  utils.Response{message=string}  "Invalid request data or wrong password"
 // @Failure      404      {object}  utils.Response{message=string}  "User not found"
 // @Failure      500      {object}  utils.Response{message=string}  "Failed to generate token"
@@ -151,7 +172,30 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := utils.GenerateToken(user.ID, user.Email, h.tokenDuration, h.jwtSecret)
+	if user.TwoFactorEnabled {
+		challenge, err := utils.GenerateChallengeToken(user.ID, twoFactorChallengeDuration, h.keys)
+		if err != nil {
+			utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+				Message: "Gagal membuat token",
+			})
+			return
+		}
+
+		utils.ResponseJson(w, http.StatusAccepted, utils.Response{
+			Message: "Masukkan kode verifikasi dua langkah",
+			Data: models.TwoFactorChallengeResponse{
+				TwoFactorRequired: true,
+				ChallengeToken:    challenge,
+			},
+		})
+		return
+	}
+
+	h.respondWithToken(w, user)
+}
+
+func (h *UserHandler) respondWithToken(w http.ResponseWriter, user *models.User) {
+	token, err := utils.GenerateToken(user.ID, user.Email, h.tokenDuration, h.keys)
 	if err != nil {
 		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
 			Message: "Gagal membuat token",
@@ -166,11 +210,14 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 				AccessToken: token,
 			},
 			User: models.User{
-				ID:        user.ID,
-				Email:     user.Email,
-				FirstName: user.FirstName,
-				LastName:  user.LastName,
-				StoreName: user.StoreName,
+				ID:                  user.ID,
+				Email:               user.Email,
+				FirstName:           user.FirstName,
+				LastName:            user.LastName,
+				StoreName:           user.StoreName,
+				Timezone:            user.Timezone,
+				TwoFactorEnabled:    user.TwoFactorEnabled,
+				DeletionScheduledAt: user.DeletionScheduledAt,
 			},
 		},
 	})
@@ -205,11 +252,14 @@ func (h *UserHandler) Session(w http.ResponseWriter, r *http.Request) {
 		Message: "Session Valid",
 		Data: models.SessionResponse{
 			User: models.User{
-				ID:        user.ID,
-				Email:     user.Email,
-				FirstName: user.FirstName,
-				LastName:  user.LastName,
-				StoreName: user.StoreName,
+				ID:                  user.ID,
+				Email:               user.Email,
+				FirstName:           user.FirstName,
+				LastName:            user.LastName,
+				StoreName:           user.StoreName,
+				Timezone:            user.Timezone,
+				TwoFactorEnabled:    user.TwoFactorEnabled,
+				DeletionScheduledAt: user.DeletionScheduledAt,
 			},
 		},
 	})
@@ -217,7 +267,7 @@ func (h *UserHandler) Session(w http.ResponseWriter, r *http.Request) {
 
 // UpdateUser godoc
 // @Summary      Update user profile
-// @Description  Update authenticated user's profile information
+// @Description  Update authenticated user's profile information. timezone is the IANA zone every date of the merchant is evaluated in; it is kept when omitted.
 // @Tags         Users
 // @Accept       json
 // @Produce      json
@@ -253,13 +303,21 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if payload.Timezone != "" && !utils.ValidTimezone(payload.Timezone) {
+		utils.ResponseJson(w, http.StatusBadRequest, utils.Response{
+			Message: "Zona waktu tidak valid, gunakan nama zona IANA seperti Asia/Jakarta",
+		})
+		return
+	}
+
 	user := models.User{
 		FirstName: payload.FirstName,
 		LastName:  payload.LastName,
 		StoreName: payload.StoreName,
+		Timezone:  payload.Timezone,
 	}
 
-	err := h.userRepo.UpdateUser(ctx, userID, user)
+	err := h.userRepo.UpdateUser(ctx, userID, &user)
 	if err != nil {
 		log.Printf("%s", err.Error())
 		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
@@ -275,19 +333,21 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 			FirstName: payload.FirstName,
 			LastName:  payload.LastName,
 			StoreName: payload.StoreName,
+			Timezone:  user.Timezone,
 		},
 	})
 }
 
 // DeleteUser godoc
-// @Summary      Delete user account
-// @Description  Delete authenticated user's account permanently
+// @Summary      Schedule account deletion
+// @Description  Schedule the authenticated user's account for permanent deletion after a grace period. Everything stays available, including the data export, until the grace period ends.
 // @Tags         Users
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Success      200  {object}  utils.Response{message=string}  "Account deleted successfully"
+// @Success      202  {object}  utils.Response{data=models.AccountDeletionResponse}  "Account deletion scheduled"
 // @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      409  {object}  utils.Response{message=string}  "Account deletion already scheduled"
 // @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
 // @Router       /users/me [delete]
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
@@ -296,7 +356,27 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 
 	userID, _ := claims["user_id"].(string)
 
-	err := h.userRepo.DeleteUser(ctx, userID)
+	user, _ := h.userRepo.GetUserByID(ctx, userID)
+	if user == nil {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "User tidak ditemukan",
+		})
+		return
+	}
+
+	if user.DeletionScheduledAt != nil {
+		utils.ResponseJson(w, http.StatusConflict, utils.Response{
+			Message: "Penghapusan akun sudah dijadwalkan",
+			Data: models.AccountDeletionResponse{
+				DeletionScheduledAt: *user.DeletionScheduledAt,
+				ExportURL:           accountExportURL,
+			},
+		})
+		return
+	}
+
+	scheduledAt := time.Now().Add(h.deletionGrace)
+	err := h.userRepo.ScheduleDeletion(ctx, userID, scheduledAt)
 	if err != nil {
 		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
 			Message: err.Error(),
@@ -304,11 +384,101 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		Action:   "schedule_deletion",
+		EntityID: userID,
+		After:    models.AccountDeletionResponse{DeletionScheduledAt: scheduledAt},
+	})
+
+	utils.ResponseJson(w, http.StatusAccepted, utils.Response{
+		Message: "Akun akan dihapus permanen setelah masa tenggang berakhir",
+		Data: models.AccountDeletionResponse{
+			DeletionScheduledAt: scheduledAt,
+			ExportURL:           accountExportURL,
+		},
+	})
+}
+
+// CancelDeletion godoc
+// @Summary      Cancel account deletion
+// @Description  Cancel a scheduled account deletion during the grace period
+// @Tags         Users
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  utils.Response{message=string}  "Account deletion cancelled"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Failure      404  {object}  utils.Response{message=string}  "No deletion scheduled"
+// @Failure      500  {object}  utils.Response{message=string}  "Internal server error"
+// @Router       /users/me/deletion/cancel [post]
+func (h *UserHandler) CancelDeletion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	cancelled, err := h.userRepo.CancelDeletion(ctx, userID)
+	if err != nil {
+		utils.ResponseJson(w, http.StatusInternalServerError, utils.Response{
+			Message: "Gagal membatalkan penghapusan akun",
+		})
+		return
+	}
+
+	if !cancelled {
+		utils.ResponseJson(w, http.StatusNotFound, utils.Response{
+			Message: "Tidak ada penghapusan akun yang dijadwalkan",
+		})
+		return
+	}
+
+	middleware.RecordAudit(ctx, middleware.AuditRecord{
+		Action:   "cancel_deletion",
+		EntityID: userID,
+	})
+
 	utils.ResponseJson(w, http.StatusOK, utils.Response{
-		Message: "Akun berhasil dihapus",
+		Message: "Penghapusan akun dibatalkan",
 	})
 }
 
+// ExportAccount godoc
+// @Summary      Export account data
+// @Description  Download a zip archive with one JSON file per dataset: profile, categories, accounts, account transfers, recurring transactions, products, product restocks, receipts, customers who ordered from the store, orders, transactions and audit logs
+// @Tags         Users
+// @Produce      application/zip
+// @Security     BearerAuth
+// @Success      200  {file}    file  "Zip archive of the account data"
+// @Failure      401  {object}  utils.Response{message=string}  "Unauthorized"
+// @Router       /users/me/export [get]
+func (h *UserHandler) ExportAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := middleware.GetClaims(ctx)
+	userID, _ := claims["user_id"].(string)
+
+	filename := fmt.Sprintf("imphnen-export-%s.zip", time.Now().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	// The archive is streamed, so once the first byte is out an error can only
+	// be logged and the download cut short.
+	archive := zip.NewWriter(w)
+	for _, dataset := range store.AccountExportDatasets {
+		file, err := archive.Create(dataset.Name + ".json")
+		if err != nil {
+			log.Printf("[ERROR] Failed to create export entry %s: %s", dataset.Name, err.Error())
+			return
+		}
+
+		if err := h.userRepo.WriteAccountExport(ctx, userID, dataset, file); err != nil {
+			return
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		log.Printf("[ERROR] Failed to finish export archive: %s", err.Error())
+	}
+}
+
 func hashPassword(pass string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
 	if err != nil {
@@ -0,0 +1,107 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/store"
+	"github.com/Cakra17/imphnen/pkg/service"
+)
+
+// AccountPurger permanently removes accounts whose deletion grace period has
+// ended. Stored media is deleted first, while the rows referencing it still
+// exist.
+type AccountPurger struct {
+	userRepo store.UserRepo
+	cld      service.CloudinaryService
+	interval time.Duration
+}
+
+type AccountPurgerConfig struct {
+	UserRepo store.UserRepo
+	Cld      service.CloudinaryService
+	Interval time.Duration
+}
+
+func NewAccountPurger(cfg AccountPurgerConfig) AccountPurger {
+	return AccountPurger{
+		userRepo: cfg.UserRepo,
+		cld:      cfg.Cld,
+		interval: cfg.Interval,
+	}
+}
+
+// Run purges due accounts once on start and then on every tick until ctx is
+// cancelled.
+func (p *AccountPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purgeDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *AccountPurger) purgeDue(ctx context.Context) {
+	userIDs, err := p.userRepo.GetUsersDueForDeletion(ctx, time.Now())
+	if err != nil {
+		return
+	}
+
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			return
+		}
+
+		purged, err := p.purge(ctx, userID)
+		if err != nil {
+			log.Printf("[ERROR] Failed to purge account %s, retrying next run: %s", userID, err.Error())
+			continue
+		}
+		if !purged {
+			log.Printf("[INFO] Skipped purging account %s, its deletion was cancelled", userID)
+			continue
+		}
+		log.Printf("[INFO] Purged account %s", userID)
+	}
+}
+
+// purge deletes the account's media and then the account. The schedule is
+// checked again before each media deletion and by the final delete, so a
+// deletion cancelled while the purge runs stops it and keeps the account.
+// Media that is already gone counts as deleted, and media that fails to
+// delete is logged and left behind rather than keeping the account forever.
+func (p *AccountPurger) purge(ctx context.Context, userID string) (bool, error) {
+	publicIDs, err := p.userRepo.GetMediaPublicIDs(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, publicID := range publicIDs {
+		due, err := p.userRepo.IsDueForDeletion(ctx, userID)
+		if err != nil {
+			return false, err
+		}
+		if !due {
+			return false, nil
+		}
+
+		err = p.cld.DeleteMedia(ctx, publicID)
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if err != nil && !errors.Is(err, service.ErrMediaNotFound) {
+			log.Printf("[ERROR] Failed to delete media %s of account %s, leaving it behind: %s", publicID, userID, err.Error())
+		}
+	}
+
+	return p.userRepo.DeleteUser(ctx, userID)
+}
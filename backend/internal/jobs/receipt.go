@@ -0,0 +1,195 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/Cakra17/imphnen/internal/store"
+	"github.com/Cakra17/imphnen/pkg/service"
+	"github.com/google/uuid"
+)
+
+const (
+	// receiptJobLease is how long a claimed job stays locked. It has to
+	// outlast the OCR and upload timeouts.
+	receiptJobLease = 5 * time.Minute
+	// receiptJobAttempts is how often a job runs before its receipt fails.
+	receiptJobAttempts = 5
+	// receiptJobBackoff is the wait before the first retry; it doubles with
+	// every further attempt.
+	receiptJobBackoff = 30 * time.Second
+)
+
+// ReceiptProcessor reads and stores uploaded receipts from the receipt job
+// queue. Every worker claims one job at a time, so several processors, even
+// on different servers, can share the queue.
+type ReceiptProcessor struct {
+	receiptRepo store.ReceiptRepo
+	cld         service.CloudinaryService
+	ocr         service.OCRProvider
+	workers     int
+	interval    time.Duration
+}
+
+type ReceiptProcessorConfig struct {
+	ReceiptRepo store.ReceiptRepo
+	Cld         service.CloudinaryService
+	OCR         service.OCRProvider
+	Workers     int
+	Interval    time.Duration
+}
+
+func NewReceiptProcessor(cfg ReceiptProcessorConfig) ReceiptProcessor {
+	return ReceiptProcessor{
+		receiptRepo: cfg.ReceiptRepo,
+		cld:         cfg.Cld,
+		ocr:         cfg.OCR,
+		workers:     cfg.Workers,
+		interval:    cfg.Interval,
+	}
+}
+
+// receiptJobError carries the reason shown to the merchant when a job runs
+// out of attempts.
+type receiptJobError struct {
+	reason string
+	err    error
+}
+
+func (e *receiptJobError) Error() string {
+	return e.err.Error()
+}
+
+func (e *receiptJobError) Unwrap() error {
+	return e.err
+}
+
+// Run starts the workers and waits for them to stop once ctx is cancelled.
+// A worker drains the due jobs and then sleeps until the next tick.
+func (p *ReceiptProcessor) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *ReceiptProcessor) work(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil && p.processNext(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processNext runs one due job and reports whether it was handled, so the
+// worker should look for the next one right away.
+func (p *ReceiptProcessor) processNext(ctx context.Context) bool {
+	job, err := p.receiptRepo.ClaimReceiptJob(ctx, receiptJobLease)
+	if err != nil || job == nil {
+		return false
+	}
+
+	err = p.process(ctx, job)
+	if err == nil {
+		log.Printf("[INFO] Processed receipt %s", job.ReceiptID)
+		return true
+	}
+	if errors.Is(err, store.ErrReceiptNotProcessing) {
+		return true
+	}
+	// A job cut short by shutdown is picked up again once its lock expires.
+	if ctx.Err() != nil {
+		return false
+	}
+
+	reason := "Gagal memproses receipt"
+	var jobErr *receiptJobError
+	if errors.As(err, &jobErr) {
+		reason = jobErr.reason
+	}
+
+	// When the job cannot be updated it stays locked until its lease expires
+	// and is claimed again then. The worker waits for the next tick instead
+	// of draining the queue against a database that just failed.
+	if job.Attempts >= receiptJobAttempts {
+		log.Printf("[ERROR] Failed to process receipt %s, giving up after %d attempts: %s", job.ReceiptID, job.Attempts, err.Error())
+		if err := p.receiptRepo.FailReceiptJob(ctx, *job, reason); err != nil {
+			log.Printf("[ERROR] Failed to mark receipt %s as failed, retrying after the lease: %s", job.ReceiptID, err.Error())
+			return false
+		}
+		return true
+	}
+
+	backoff := receiptJobBackoff << (job.Attempts - 1)
+	log.Printf("[ERROR] Failed to process receipt %s, retrying in %s: %s", job.ReceiptID, backoff, err.Error())
+	if err := p.receiptRepo.RetryReceiptJob(ctx, job.ID, time.Now().Add(backoff), err.Error()); err != nil {
+		log.Printf("[ERROR] Failed to reschedule receipt %s, retrying after the lease: %s", job.ReceiptID, err.Error())
+		return false
+	}
+	return true
+}
+
+// process reads the receipt image, stores it and saves the receipt with its
+// items as a draft for the merchant to review. The uploaded image is removed
+// again when the receipt cannot be saved, so a retry starts from scratch.
+func (p *ReceiptProcessor) process(ctx context.Context, job *models.ReceiptJob) error {
+	resp, err := p.ocr.OCRForm(ctx, bytes.NewReader(job.Image), job.Filename)
+	if err != nil {
+		return &receiptJobError{reason: "Terjadi kesalahan pada OCR", err: err}
+	}
+
+	secureURL, publicID, err := p.cld.UploadMedia(ctx, "receipts", bytes.NewReader(job.Image))
+	if err != nil {
+		return &receiptJobError{reason: "Terjadi kesalahan dalam menyimpan gambar", err: err}
+	}
+
+	receipt := models.Receipt{
+		ID:         job.ReceiptID,
+		UserID:     job.UserID,
+		StoreName:  resp.Issuer.Name,
+		TotalItems: uint32(len(resp.InvoiceItems)),
+		TotalPrice: resp.Total,
+		ImageURL:   secureURL,
+		PublicID:   publicID,
+	}
+	// A date that cannot be read is left for the merchant to fill in.
+	if date, err := time.Parse("2006-01-02", resp.InvoiceDate); err == nil {
+		receipt.InvoiceDate = &date
+	}
+
+	items := []models.ReceiptItem{}
+	for _, invoiceItem := range resp.InvoiceItems {
+		itemID, _ := uuid.NewV7()
+		items = append(items, models.ReceiptItem{
+			ID:        itemID.String(),
+			ReceiptID: job.ReceiptID,
+			Name:      invoiceItem.Description,
+			Quantity:  1,
+			Price:     invoiceItem.Total,
+		})
+	}
+
+	if err := p.receiptRepo.CompleteReceiptJob(ctx, job.ID, &receipt, items); err != nil {
+		p.cld.DeleteMedia(context.WithoutCancel(ctx), publicID)
+		return &receiptJobError{reason: "Gagal membuat receipt", err: err}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/store"
+)
+
+// RecurringScheduler turns due recurring transactions into transactions.
+// Every run catches up on all occurrences up to today in each merchant's time
+// zone, so nothing is lost while the server is down, and running the same
+// occurrence twice is a no-op.
+type RecurringScheduler struct {
+	recurringRepo store.RecurringTransactionRepo
+	interval      time.Duration
+}
+
+type RecurringSchedulerConfig struct {
+	RecurringRepo store.RecurringTransactionRepo
+	Interval      time.Duration
+}
+
+func NewRecurringScheduler(cfg RecurringSchedulerConfig) RecurringScheduler {
+	return RecurringScheduler{
+		recurringRepo: cfg.RecurringRepo,
+		interval:      cfg.Interval,
+	}
+}
+
+// Run materialises due occurrences once on start and then on every tick
+// until ctx is cancelled.
+func (s *RecurringScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.runDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *RecurringScheduler) runDue(ctx context.Context) {
+	ids, err := s.recurringRepo.GetDueRecurringIDs(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+
+		created, err := s.recurringRepo.MaterializeDue(ctx, id)
+		if err != nil {
+			log.Printf("[ERROR] Failed to run recurring transaction %s, retrying next run: %s", id, err.Error())
+			continue
+		}
+		if created > 0 {
+			log.Printf("[INFO] Created %d transaction(s) for recurring transaction %s", created, id)
+		}
+	}
+}
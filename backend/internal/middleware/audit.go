@@ -0,0 +1,416 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/Cakra17/imphnen/internal/store"
+	"github.com/go-chi/chi/v5"
+	chimd "github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+)
+
+const (
+	maxAuditBodySize = 64 << 10
+	// auditBufferSize is how many audit entries wait for the writer before
+	// requests write their entry themselves.
+	auditBufferSize = 1024
+	// auditWriteTimeout bounds a single audit insert.
+	auditWriteTimeout = 5 * time.Second
+)
+
+var redactedAuditKeys = []string{"password", "secret", "token", "code"}
+
+type auditEntryKey struct{}
+
+type auditEntry struct {
+	record  AuditRecord
+	actorID string
+	actor   string
+	skip    bool
+}
+
+// AuditRecord lets a handler describe the change it made. Zero fields fall
+// back to what the audit middleware can derive from the route and request.
+type AuditRecord struct {
+	Action     string
+	EntityType string
+	EntityID   string
+	StoreID    string
+	Before     any
+	After      any
+}
+
+// AuditWriter stores audit entries in the background, so requests do not
+// wait for the insert.
+type AuditWriter struct {
+	auditRepo store.AuditRepo
+	entries   chan *models.AuditLog
+}
+
+type AuditWriterConfig struct {
+	AuditRepo store.AuditRepo
+}
+
+func NewAuditWriter(cfg AuditWriterConfig) *AuditWriter {
+	return &AuditWriter{
+		auditRepo: cfg.AuditRepo,
+		entries:   make(chan *models.AuditLog, auditBufferSize),
+	}
+}
+
+// Run writes queued entries until ctx is cancelled and then writes what is
+// still queued. Cancel ctx only after the server stopped taking requests.
+func (w *AuditWriter) Run(ctx context.Context) {
+	for {
+		select {
+		case entry := <-w.entries:
+			w.write(entry)
+		case <-ctx.Done():
+			for {
+				select {
+				case entry := <-w.entries:
+					w.write(entry)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// enqueue hands entry to the writer. When the queue is full the entry is
+// written right away instead of being dropped.
+func (w *AuditWriter) enqueue(entry *models.AuditLog) {
+	select {
+	case w.entries <- entry:
+	default:
+		w.write(entry)
+	}
+}
+
+func (w *AuditWriter) write(entry *models.AuditLog) {
+	ctx, cancel := context.WithTimeout(context.Background(), auditWriteTimeout)
+	defer cancel()
+	if err := w.auditRepo.Create(ctx, entry); err != nil {
+		log.Printf("[ERROR] Failed to write audit log for %s %s: %s", entry.Method, entry.Path, err.Error())
+	}
+}
+
+// Audit records every successful POST, PUT, PATCH and DELETE request through
+// writer.
+func Audit(writer *AuditWriter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isMutatingMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			entry := &auditEntry{actor: models.AuditActorAnonymous}
+			body := captureJSONBody(r)
+
+			ctx := context.WithValue(r.Context(), auditEntryKey{}, entry)
+			ww := chimd.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if entry.skip || status >= http.StatusBadRequest {
+				return
+			}
+
+			writer.enqueue(buildAuditLog(r, entry, body, status))
+		})
+	}
+}
+
+// RecordAudit attaches details about the current change to the audit entry.
+func RecordAudit(ctx context.Context, record AuditRecord) {
+	entry, ok := ctx.Value(auditEntryKey{}).(*auditEntry)
+	if !ok {
+		return
+	}
+
+	if record.Action != "" {
+		entry.record.Action = record.Action
+	}
+	if record.EntityType != "" {
+		entry.record.EntityType = record.EntityType
+	}
+	if record.EntityID != "" {
+		entry.record.EntityID = record.EntityID
+	}
+	if record.StoreID != "" {
+		entry.record.StoreID = record.StoreID
+	}
+	if record.Before != nil {
+		entry.record.Before = record.Before
+	}
+	if record.After != nil {
+		entry.record.After = record.After
+	}
+}
+
+// SkipAudit drops the audit entry of the current request, for endpoints that
+// only read data through a mutating method.
+func SkipAudit(ctx context.Context) {
+	if entry, ok := ctx.Value(auditEntryKey{}).(*auditEntry); ok {
+		entry.skip = true
+	}
+}
+
+func setAuditActor(ctx context.Context, actorType, actorID, storeID string) {
+	if entry, ok := ctx.Value(auditEntryKey{}).(*auditEntry); ok {
+		entry.actor = actorType
+		entry.actorID = actorID
+		if entry.record.StoreID == "" {
+			entry.record.StoreID = storeID
+		}
+	}
+}
+
+func buildAuditLog(r *http.Request, entry *auditEntry, body []byte, status int) *models.AuditLog {
+	id, _ := uuid.NewV7()
+	auditLog := &models.AuditLog{
+		ID:         id.String(),
+		ActorType:  entry.actor,
+		Action:     entry.record.Action,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		EntityType: entry.record.EntityType,
+		StatusCode: status,
+		StoreID:    optionalString(entry.record.StoreID),
+		ActorID:    optionalString(entry.actorID),
+		RequestID:  optionalString(chimd.GetReqID(r.Context())),
+		IPAddress:  optionalString(r.RemoteAddr),
+	}
+
+	pattern, params := routeInfo(r)
+	if auditLog.EntityType == "" {
+		auditLog.EntityType = entityTypeFromPattern(pattern)
+	}
+	if auditLog.Action == "" {
+		auditLog.Action = actionFromRequest(r.Method, pattern)
+	}
+
+	entityID := entry.record.EntityID
+	if entityID == "" && len(params) > 0 {
+		entityID = params[len(params)-1]
+	}
+	auditLog.EntityID = optionalString(entityID)
+
+	before, after := entry.record.Before, entry.record.After
+	if before == nil && after == nil && len(body) > 0 {
+		after = json.RawMessage(body)
+	}
+	auditLog.Before, auditLog.After = diffJSON(before, after)
+
+	return auditLog
+}
+
+func routeInfo(r *http.Request) (string, []string) {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return r.URL.Path, nil
+	}
+
+	params := []string{}
+	for _, value := range rctx.URLParams.Values {
+		if value != "" {
+			params = append(params, value)
+		}
+	}
+
+	return rctx.RoutePattern(), params
+}
+
+func entityTypeFromPattern(pattern string) string {
+	segments := staticSegments(pattern)
+	if len(segments) == 0 {
+		return "unknown"
+	}
+	return segments[0]
+}
+
+func actionFromRequest(method, pattern string) string {
+	segments := staticSegments(pattern)
+	if len(segments) > 1 {
+		return segments[len(segments)-1]
+	}
+
+	switch method {
+	case http.MethodPost:
+		return "create"
+	case http.MethodDelete:
+		return "delete"
+	default:
+		return "update"
+	}
+}
+
+// staticSegments returns the literal parts of a route pattern without the API
+// prefix, the telegram namespace and path parameters.
+func staticSegments(pattern string) []string {
+	segments := []string{}
+	for _, segment := range strings.Split(pattern, "/") {
+		switch {
+		case segment == "", segment == "*", segment == "api", segment == "v1", segment == "telegram":
+			continue
+		case strings.HasPrefix(segment, "{"):
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	return segments
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// captureJSONBody reads a JSON request body and puts it back so the handler
+// can still decode it. Multipart uploads and large bodies are not captured.
+func captureJSONBody(r *http.Request) []byte {
+	if r.Body == nil || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return nil
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r.Body, maxAuditBodySize+1))
+	if err != nil || len(buf) > maxAuditBodySize {
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(buf), r.Body))
+		return nil
+	}
+	r.Body = io.NopCloser(bytes.NewReader(buf))
+
+	if !json.Valid(buf) {
+		return nil
+	}
+	return buf
+}
+
+// diffJSON returns only the top-level fields that changed between before and
+// after. Sensitive fields are redacted on both sides.
+func diffJSON(before, after any) (json.RawMessage, json.RawMessage) {
+	beforeVal := toJSONValue(before)
+	afterVal := toJSONValue(after)
+
+	beforeMap, beforeIsMap := beforeVal.(map[string]any)
+	afterMap, afterIsMap := afterVal.(map[string]any)
+
+	if beforeIsMap && afterIsMap {
+		changedBefore := map[string]any{}
+		changedAfter := map[string]any{}
+		for key, value := range afterMap {
+			if old, ok := beforeMap[key]; !ok || !reflect.DeepEqual(old, value) {
+				changedAfter[key] = value
+				if ok {
+					changedBefore[key] = old
+				}
+			}
+		}
+		for key, old := range beforeMap {
+			if _, ok := afterMap[key]; !ok {
+				changedBefore[key] = old
+			}
+		}
+		return marshalJSON(changedBefore), marshalJSON(changedAfter)
+	}
+
+	return marshalJSON(beforeVal), marshalJSON(afterVal)
+}
+
+func toJSONValue(v any) any {
+	if v == nil {
+		return nil
+	}
+
+	raw, ok := v.(json.RawMessage)
+	if !ok {
+		var err error
+		raw, err = json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+	}
+
+	var out any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil
+	}
+	return redact(out)
+}
+
+func redact(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for key, inner := range val {
+			if isRedactedKey(key) {
+				val[key] = "[REDACTED]"
+				continue
+			}
+			val[key] = redact(inner)
+		}
+	case []any:
+		for i, inner := range val {
+			val[i] = redact(inner)
+		}
+	}
+	return v
+}
+
+func isRedactedKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, redacted := range redactedAuditKeys {
+		if strings.Contains(key, redacted) {
+			return true
+		}
+	}
+	return false
+}
+
+func marshalJSON(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	if m, ok := v.(map[string]any); ok && len(m) == 0 {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// AuditActor marks every request of a route group as made by the given kind
+// of actor, for groups that are not behind Auth such as the telegram bot.
+func AuditActor(actorType string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			setAuditActor(r.Context(), actorType, "", "")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
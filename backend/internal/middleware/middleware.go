@@ -2,18 +2,47 @@ package middleware
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
-	"os"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/Cakra17/imphnen/internal/store"
 	"github.com/Cakra17/imphnen/internal/utils"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type userClaimsKey struct{}
 
-func Auth(next http.Handler) http.Handler {
+type locationKey struct{}
+
+type AuthMiddleware struct {
+	keys            *utils.KeySet
+	accessTokenRepo store.AccessTokenRepo
+	userRepo        store.UserRepo
+}
+
+type AuthMiddlewareConfig struct {
+	Keys            *utils.KeySet
+	AccessTokenRepo store.AccessTokenRepo
+	UserRepo        store.UserRepo
+}
+
+func NewAuthMiddleware(cfg AuthMiddlewareConfig) AuthMiddleware {
+	return AuthMiddleware{
+		keys:            cfg.Keys,
+		accessTokenRepo: cfg.AccessTokenRepo,
+		userRepo:        cfg.UserRepo,
+	}
+}
+
+// Auth accepts either a JWT from /auth/login or a personal access token.
+// Both end up as claims with at least user_id and email, so handlers do not
+// need to know which one was used.
+func (m *AuthMiddleware) Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		authHeader := r.Header.Get("Authorization")
@@ -32,8 +61,12 @@ func Auth(next http.Handler) http.Handler {
 			return
 		}
 
-		secret := os.Getenv("JWT_SECRET")
-		token, err := utils.ValidateToken(tokenStr, secret)
+		if strings.HasPrefix(tokenStr, utils.AccessTokenPrefix) {
+			m.authAccessToken(w, r, next, tokenStr)
+			return
+		}
+
+		token, err := utils.ValidateToken(tokenStr, m.keys)
 		if err != nil {
 			log.Printf("%s", err.Error())
 			utils.ResponseJson(w, http.StatusUnauthorized, utils.Response{
@@ -50,14 +83,134 @@ func Auth(next http.Handler) http.Handler {
 			return
 		}
 
+		userID, _ := claims["user_id"].(string)
+		setAuditActor(r.Context(), models.AuditActorMerchant, userID, userID)
+
 		ctx := context.WithValue(r.Context(), userClaimsKey{}, claims)
+		ctx = m.withLocation(ctx, userID)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+func (m *AuthMiddleware) authAccessToken(w http.ResponseWriter, r *http.Request, next http.Handler, tokenStr string) {
+	ctx := r.Context()
+
+	token, err := m.accessTokenRepo.GetActiveAccessToken(ctx, utils.HashAccessToken(tokenStr))
+	if err != nil {
+		utils.ResponseJson(w, http.StatusUnauthorized, utils.Response{
+			Message: "Token Invalid",
+		})
+		return
+	}
+
+	if err := m.accessTokenRepo.TouchLastUsed(ctx, token.ID); err != nil {
+		log.Printf("[ERROR] Failed to track access token usage: %s", err.Error())
+	}
+
+	scopes := make([]any, len(token.Scopes))
+	for i, scope := range token.Scopes {
+		scopes[i] = scope
+	}
+
+	claims := jwt.MapClaims{
+		"user_id":  token.UserID,
+		"email":    token.UserEmail,
+		"token_id": token.ID,
+		"scopes":   scopes,
+	}
+
+	setAuditActor(ctx, models.AuditActorToken, token.ID, token.UserID)
+
+	ctx = context.WithValue(ctx, userClaimsKey{}, claims)
+	ctx = m.withLocation(ctx, token.UserID)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// withLocation stores the merchant's time zone in the context, so dates in
+// requests and "today" are evaluated where the merchant is.
+func (m *AuthMiddleware) withLocation(ctx context.Context, userID string) context.Context {
+	timezone, err := m.userRepo.GetTimezone(ctx, userID)
+	if err != nil {
+		timezone = utils.DefaultTimezone
+	}
+	return context.WithValue(ctx, locationKey{}, utils.LoadLocation(timezone))
+}
+
+// RequireScope limits personal access tokens to the resource of a route
+// group: reads need read:<resource>, everything else needs write:<resource>.
+// A write scope also grants reads. Login sessions are not restricted.
+func (m *AuthMiddleware) RequireScope(resource string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, _ := GetClaims(r.Context())
+			if !IsAccessToken(claims) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			writeScope := "write:" + resource
+			required := writeScope
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				required = "read:" + resource
+			}
+
+			scopes := tokenScopes(claims)
+			if !slices.Contains(scopes, required) && !slices.Contains(scopes, writeScope) {
+				utils.ResponseJson(w, http.StatusForbidden, utils.Response{
+					Message: fmt.Sprintf("Token tidak memiliki izin %s", required),
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSession rejects personal access tokens on routes that manage the
+// account itself, such as passwords, two-factor settings and tokens.
+func (m *AuthMiddleware) RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := GetClaims(r.Context())
+		if IsAccessToken(claims) {
+			utils.ResponseJson(w, http.StatusForbidden, utils.Response{
+				Message: "Endpoint ini hanya dapat diakses melalui login",
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func GetClaims(ctx context.Context) (jwt.MapClaims, bool) {
 	val := ctx.Value(userClaimsKey{})
 	claims, ok := val.(jwt.MapClaims)
 	return claims, ok
 }
+
+// GetLocation returns the authenticated merchant's time zone, or the default
+// zone outside authenticated routes.
+func GetLocation(ctx context.Context) *time.Location {
+	if loc, ok := ctx.Value(locationKey{}).(*time.Location); ok {
+		return loc
+	}
+	return utils.LoadLocation(utils.DefaultTimezone)
+}
+
+func IsAccessToken(claims jwt.MapClaims) bool {
+	_, ok := claims["token_id"]
+	return ok
+}
+
+func tokenScopes(claims jwt.MapClaims) []string {
+	raw, _ := claims["scopes"].([]any)
+	scopes := make([]string, 0, len(raw))
+	for _, scope := range raw {
+		if s, ok := scope.(string); ok {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
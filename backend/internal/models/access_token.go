@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+const (
+	ScopeReadTransactions  = "read:transactions"
+	ScopeWriteTransactions = "write:transactions"
+	ScopeReadProducts      = "read:products"
+	ScopeWriteProducts     = "write:products"
+	ScopeReadOrders        = "read:orders"
+	ScopeWriteOrders       = "write:orders"
+	ScopeReadReceipts      = "read:receipts"
+	ScopeWriteReceipts     = "write:receipts"
+	ScopeReadAuditLogs     = "read:audit_logs"
+	ScopeReadReports       = "read:reports"
+)
+
+var AccessTokenScopes = []string{
+	ScopeReadTransactions,
+	ScopeWriteTransactions,
+	ScopeReadProducts,
+	ScopeWriteProducts,
+	ScopeReadOrders,
+	ScopeWriteOrders,
+	ScopeReadReceipts,
+	ScopeWriteReceipts,
+	ScopeReadAuditLogs,
+	ScopeReadReports,
+}
+
+type PersonalAccessToken struct {
+	ID          string     `json:"id" db:"id"`
+	UserID      string     `json:"user_id" db:"user_id"`
+	Name        string     `json:"name" db:"name"`
+	TokenPrefix string     `json:"token_prefix" db:"token_prefix"`
+	TokenHash   string     `json:"-" db:"token_hash"`
+	Scopes      []string   `json:"scopes" db:"scopes"`
+	ExpiresAt   *time.Time `json:"expires_at" db:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at" db:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UserEmail   string     `json:"-" db:"-"`
+}
+
+type CreateAccessTokenPayload struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"gte=0,lte=3650"`
+}
+
+type AccessTokenResponse struct {
+	AccessToken PersonalAccessToken `json:"access_token"`
+}
+
+type CreatedAccessTokenResponse struct {
+	AccessToken PersonalAccessToken `json:"access_token"`
+	Token       string              `json:"token"`
+}
+
+type AccessTokenListResponse struct {
+	AccessTokens []PersonalAccessToken `json:"access_tokens"`
+}
@@ -0,0 +1,101 @@
+package models
+
+import "time"
+
+// Account is where a merchant keeps money, such as the cash drawer, a bank
+// account or an e-wallet. Balance is the opening balance plus the account's
+// non-voided transactions and its transfers. Transactions the system creates
+// without an account of their own go to the default account.
+type Account struct {
+	ID             string    `json:"id" db:"id"`
+	UserID         string    `json:"user_id" db:"user_id"`
+	Name           string    `json:"name" db:"name"`
+	Kind           string    `json:"kind" db:"kind"`
+	OpeningBalance float64   `json:"opening_balance" db:"opening_balance"`
+	Balance        float64   `json:"balance" db:"-"`
+	IsDefault      bool      `json:"is_default" db:"is_default"`
+	CreatedAt      time.Time `json:"created_at,omitempty" db:"created_at"`
+}
+
+// IsDefault makes the account the default one in place of the current
+// default. A merchant's first account is always the default.
+type CreateAccountPayload struct {
+	Name           string  `json:"name" validate:"required,max=100"`
+	Kind           string  `json:"kind" validate:"required,oneof=cash bank ewallet"`
+	OpeningBalance float64 `json:"opening_balance"`
+	IsDefault      bool    `json:"is_default"`
+}
+
+type UpdateAccountPayload struct {
+	Name           string  `json:"name" validate:"required,max=100"`
+	Kind           string  `json:"kind" validate:"required,oneof=cash bank ewallet"`
+	OpeningBalance float64 `json:"opening_balance"`
+	IsDefault      *bool   `json:"is_default,omitempty"`
+}
+
+type AccountResponse struct {
+	Account Account `json:"account"`
+}
+
+type AccountListResponse struct {
+	Accounts []Account `json:"accounts"`
+}
+
+// AccountTransfer moves money between two accounts of the same merchant. It
+// is not income or expense and does not show up in transaction statistics.
+type AccountTransfer struct {
+	ID            string    `json:"id" db:"id"`
+	UserID        string    `json:"user_id" db:"user_id"`
+	FromAccountID string    `json:"from_account_id" db:"from_account_id"`
+	ToAccountID   string    `json:"to_account_id" db:"to_account_id"`
+	Amount        float64   `json:"amount" db:"amount"`
+	TransferDate  time.Time `json:"transfer_date" db:"transfer_date"`
+	Note          *string   `json:"note" db:"note"`
+	CreatedAt     time.Time `json:"created_at,omitempty" db:"created_at"`
+}
+
+type CreateTransferPayload struct {
+	FromAccountID string  `json:"from_account_id" validate:"required"`
+	ToAccountID   string  `json:"to_account_id" validate:"required"`
+	Amount        float64 `json:"amount" validate:"required,gt=0"`
+	TransferDate  string  `json:"transfer_date" validate:"required"`
+	Note          string  `json:"note" validate:"max=255"`
+}
+
+type TransferResponse struct {
+	Transfer AccountTransfer `json:"transfer"`
+}
+
+type TransferListResponse struct {
+	Transfers []AccountTransfer `json:"transfers"`
+}
+
+const (
+	LedgerEntryIncome      = "income"
+	LedgerEntryExpense     = "expense"
+	LedgerEntryTransferIn  = "transfer_in"
+	LedgerEntryTransferOut = "transfer_out"
+)
+
+// AccountLedgerEntry is one movement on an account. Amount is negative for
+// money leaving the account and Balance is the running balance after it.
+// ReferenceID is the transaction or transfer ID, depending on Type.
+type AccountLedgerEntry struct {
+	Date        time.Time `json:"date"`
+	Type        string    `json:"type"`
+	ReferenceID string    `json:"reference_id"`
+	Description *string   `json:"description"`
+	Amount      float64   `json:"amount"`
+	Balance     float64   `json:"balance"`
+}
+
+// AccountLedger lists the movements of an account between From and To with
+// the balance before the first and after the last.
+type AccountLedger struct {
+	Account        Account              `json:"account"`
+	From           string               `json:"from"`
+	To             string               `json:"to"`
+	OpeningBalance float64              `json:"opening_balance"`
+	ClosingBalance float64              `json:"closing_balance"`
+	Entries        []AccountLedgerEntry `json:"entries"`
+}
@@ -0,0 +1,123 @@
+package models
+
+import "time"
+
+// ProductSales are the sales figures of one product. Units and Revenue cover
+// the requested range; the week figures are the seven days up to the end of
+// the range and the seven days before them. DailyVelocity is the average
+// number of units sold per day over the recent velocity window, and
+// DaysOfStock how long the current stock lasts at that pace, nil when the
+// product has not sold recently.
+type ProductSales struct {
+	ProductID          string   `json:"product_id"`
+	ProductName        string   `json:"product_name"`
+	Price              float64  `json:"price"`
+	Stock              int      `json:"stock"`
+	Rank               int      `json:"rank"`
+	Units              int      `json:"units"`
+	Revenue            float64  `json:"revenue"`
+	LastWeekUnits      int      `json:"last_week_units"`
+	PreviousWeekUnits  int      `json:"previous_week_units"`
+	WeekOverWeekChange *float64 `json:"week_over_week_change"`
+	RecentUnits        int      `json:"-"`
+	DailyVelocity      float64  `json:"daily_velocity"`
+	DaysOfStock        *float64 `json:"days_of_stock"`
+}
+
+// ProductSalesWindow are the instants the product sales figures are cut at.
+// Every end is exclusive.
+type ProductSalesWindow struct {
+	RangeStart        time.Time
+	RangeEnd          time.Time
+	LastWeekStart     time.Time
+	PreviousWeekStart time.Time
+	VelocityStart     time.Time
+	VelocityEnd       time.Time
+}
+
+type ProductAnalyticsReport struct {
+	StartDate    string         `json:"start_date"`
+	EndDate      string         `json:"end_date"`
+	VelocityDays int            `json:"velocity_days"`
+	Products     []ProductSales `json:"products"`
+}
+
+const (
+	SegmentChampions      = "champions"
+	SegmentLoyal          = "loyal"
+	SegmentPotentialLoyal = "potential_loyalist"
+	SegmentNew            = "new"
+	SegmentNeedAttention  = "need_attention"
+	SegmentAtRisk         = "at_risk"
+	SegmentHibernating    = "hibernating"
+	SegmentLost           = "lost"
+)
+
+// CustomerAnalytics are the figures of a customer at one merchant, from the
+// customer's confirmed orders. The recency, frequency and monetary scores are
+// quintiles among the merchant's customers, 5 being the most recent, most
+// frequent and highest spending.
+type CustomerAnalytics struct {
+	CustomerID         int       `json:"customer_id"`
+	Name               string    `json:"name"`
+	Phone              string    `json:"phone"`
+	Address            string    `json:"address"`
+	OrderCount         int       `json:"order_count"`
+	LifetimeValue      float64   `json:"lifetime_value"`
+	AverageBasket      float64   `json:"average_basket"`
+	FirstOrderDate     time.Time `json:"first_order_date"`
+	LastOrderDate      time.Time `json:"last_order_date"`
+	DaysSinceLastOrder int       `json:"days_since_last_order"`
+	RecencyScore       int       `json:"recency_score"`
+	FrequencyScore     int       `json:"frequency_score"`
+	MonetaryScore      int       `json:"monetary_score"`
+	Segment            string    `json:"segment"`
+}
+
+// CustomerAnalyticsFilter selects and orders customer analytics. Customers
+// whose last order is before InactiveSince are the ones that have not ordered
+// for a while.
+type CustomerAnalyticsFilter struct {
+	UserID        string
+	Segment       *string
+	InactiveSince *time.Time
+	MinOrders     int
+	Sort          string
+	Page          uint
+	PerPage       uint
+}
+
+type CustomerAnalyticsListResponse struct {
+	Customers []CustomerAnalytics `json:"customers"`
+}
+
+// HeatmapCell is the orders placed in one hour of one weekday. Weekday runs
+// from 1 for Monday to 7 for Sunday and Hour from 0 to 23, both in the
+// merchant's time zone.
+type HeatmapCell struct {
+	Weekday    int     `json:"weekday"`
+	Hour       int     `json:"hour"`
+	OrderCount int     `json:"order_count"`
+	Revenue    float64 `json:"revenue"`
+}
+
+// OrderHeatmapFilter selects the confirmed orders of a heatmap. With a
+// product or category only orders containing it count, and revenue only
+// covers its items. End is exclusive.
+type OrderHeatmapFilter struct {
+	UserID     string
+	Start      time.Time
+	End        time.Time
+	ProductID  *string
+	CategoryID *string
+}
+
+// OrderHeatmap has a cell for every hour of the week, Monday 00:00 first.
+type OrderHeatmap struct {
+	StartDate  string        `json:"start_date"`
+	EndDate    string        `json:"end_date"`
+	TimeZone   string        `json:"time_zone"`
+	ProductID  *string       `json:"product_id,omitempty"`
+	CategoryID *string       `json:"category_id,omitempty"`
+	Cells      []HeatmapCell `json:"cells"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActorAnonymous = "anonymous"
+	AuditActorMerchant  = "merchant"
+	AuditActorToken     = "access_token"
+	AuditActorTelegram  = "telegram"
+)
+
+type AuditLog struct {
+	ID         string          `json:"id" db:"id"`
+	StoreID    *string         `json:"store_id" db:"store_id"`
+	ActorID    *string         `json:"actor_id" db:"actor_id"`
+	ActorType  string          `json:"actor_type" db:"actor_type"`
+	Action     string          `json:"action" db:"action"`
+	Method     string          `json:"method" db:"method"`
+	Path       string          `json:"path" db:"path"`
+	EntityType string          `json:"entity_type" db:"entity_type"`
+	EntityID   *string         `json:"entity_id" db:"entity_id"`
+	Before     json.RawMessage `json:"before,omitempty" db:"before" swaggertype:"object"`
+	After      json.RawMessage `json:"after,omitempty" db:"after" swaggertype:"object"`
+	StatusCode int             `json:"status_code" db:"status_code"`
+	RequestID  *string         `json:"request_id" db:"request_id"`
+	IPAddress  *string         `json:"ip_address" db:"ip_address"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+}
+
+type AuditLogFilter struct {
+	StoreID    string
+	Action     *string
+	EntityType *string
+	EntityID   *string
+	ActorID    *string
+	StartDate  *time.Time
+	EndDate    *time.Time
+	Page       uint
+	PerPage    uint
+}
+
+type AuditLogListResponse struct {
+	AuditLogs []AuditLog `json:"audit_logs"`
+}
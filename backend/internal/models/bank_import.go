@@ -0,0 +1,99 @@
+package models
+
+import "time"
+
+const (
+	BankImportLinePending = "pending"
+	BankImportLineMatched = "matched"
+	BankImportLineCreated = "created"
+	BankImportLineIgnored = "ignored"
+)
+
+// BankImport is one uploaded bank statement. DuplicateCount is the number of
+// lines skipped because an earlier import into the account already had them.
+type BankImport struct {
+	ID             string    `json:"id" db:"id"`
+	UserID         string    `json:"user_id" db:"user_id"`
+	AccountID      string    `json:"account_id" db:"account_id"`
+	Filename       string    `json:"filename" db:"filename"`
+	Format         string    `json:"format" db:"format"`
+	LineCount      int       `json:"line_count" db:"line_count"`
+	DuplicateCount int       `json:"duplicate_count" db:"duplicate_count"`
+	PendingCount   int       `json:"pending_count" db:"-"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// BankImportLine is one mutation from a statement. Amount is positive for
+// money coming into the account and negative for money going out.
+// TransactionID is set once the line is matched or a transaction is created
+// from it.
+type BankImportLine struct {
+	ID            string            `json:"id" db:"id"`
+	ImportID      string            `json:"import_id" db:"import_id"`
+	UserID        string            `json:"user_id" db:"user_id"`
+	AccountID     string            `json:"account_id" db:"account_id"`
+	Date          time.Time         `json:"date" db:"line_date"`
+	Description   string            `json:"description" db:"description"`
+	Amount        float64           `json:"amount" db:"amount"`
+	Hash          string            `json:"-" db:"hash"`
+	Status        string            `json:"status" db:"status"`
+	TransactionID *string           `json:"transaction_id" db:"transaction_id"`
+	ResolvedAt    *time.Time        `json:"resolved_at,omitempty" db:"resolved_at"`
+	CreatedAt     time.Time         `json:"created_at" db:"created_at"`
+	Suggestions   []MatchSuggestion `json:"suggestions,omitempty" db:"-"`
+}
+
+// TransactionType is the type of the transaction the line corresponds to.
+func (l BankImportLine) TransactionType() string {
+	if l.Amount < 0 {
+		return "expense"
+	}
+	return "income"
+}
+
+const (
+	MatchKindTransaction = "transaction"
+	MatchKindReceipt     = "receipt"
+	MatchKindOrder       = "order"
+)
+
+// MatchSuggestion is a record the line may correspond to. Receipt suggestions
+// are the transactions recorded from receipts and carry both IDs; order
+// suggestions are confirmed orders without a transaction yet and are accepted
+// by order ID. Confidence is between 0 and 1.
+type MatchSuggestion struct {
+	Kind          string    `json:"kind"`
+	TransactionID *string   `json:"transaction_id,omitempty"`
+	ReceiptID     *string   `json:"receipt_id,omitempty"`
+	OrderID       *string   `json:"order_id,omitempty"`
+	Date          time.Time `json:"date"`
+	Amount        float64   `json:"amount"`
+	Description   string    `json:"description"`
+	Confidence    float64   `json:"confidence"`
+}
+
+// AcceptMatchPayload names the transaction or the order to match a line with.
+type AcceptMatchPayload struct {
+	TransactionID *string `json:"transaction_id,omitempty"`
+	OrderID       *string `json:"order_id,omitempty"`
+}
+
+type CreateFromLinePayload struct {
+	CategoryID *string `json:"category_id,omitempty"`
+}
+
+type BankImportResponse struct {
+	Import BankImport `json:"import"`
+}
+
+type BankImportListResponse struct {
+	Imports []BankImport `json:"imports"`
+}
+
+type BankImportLineResponse struct {
+	Line BankImportLine `json:"line"`
+}
+
+type BankImportLineListResponse struct {
+	Lines []BankImportLine `json:"lines"`
+}
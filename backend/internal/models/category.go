@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+type TransactionCategory struct {
+	ID        string    `json:"id" db:"id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Name      string    `json:"name" db:"name"`
+	Type      string    `json:"type" db:"type"`
+	IsDefault bool      `json:"is_default" db:"is_default"`
+	IsCOGS    bool      `json:"is_cogs" db:"is_cogs"`
+	CreatedAt time.Time `json:"created_at,omitempty" db:"created_at"`
+}
+
+// DefaultTransactionCategories are created for every new merchant.
+var DefaultTransactionCategories = []TransactionCategory{
+	{Name: "Bahan Baku", Type: "expense", IsCOGS: true},
+	{Name: "Sewa", Type: "expense"},
+	{Name: "Gaji", Type: "expense"},
+	{Name: "Utilitas", Type: "expense"},
+	{Name: "Lainnya", Type: "expense"},
+	{Name: "Penjualan", Type: "income"},
+	{Name: "Lainnya", Type: "income"},
+}
+
+// IsCOGS marks an expense category as stock purchases, which the profit and
+// loss report leaves out because cost of goods sold comes from the ordered
+// items. Income categories cannot be marked.
+type CreateCategoryPayload struct {
+	Name   string `json:"name" validate:"required,max=100"`
+	Type   string `json:"type" validate:"required,oneof=expense income"`
+	IsCOGS bool   `json:"is_cogs"`
+}
+
+type UpdateCategoryPayload struct {
+	Name   string `json:"name" validate:"required,max=100"`
+	IsCOGS bool   `json:"is_cogs"`
+}
+
+type CategoryResponse struct {
+	Category TransactionCategory `json:"category"`
+}
+
+type CategoryListResponse struct {
+	Categories []TransactionCategory `json:"categories"`
+}
+
+// CategoryStats is one row of the per-category breakdown. CategoryID is nil
+// for transactions without a category.
+type CategoryStats struct {
+	CategoryID       *string `json:"category_id"`
+	CategoryName     string  `json:"category_name"`
+	Type             string  `json:"type"`
+	TotalAmount      float64 `json:"total_amount"`
+	TransactionCount int64   `json:"transaction_count"`
+	Percentage       float64 `json:"percentage"`
+}
+
+type CategoryStatsResponse struct {
+	TotalIncome  float64         `json:"total_income"`
+	TotalExpense float64         `json:"total_expense"`
+	Categories   []CategoryStats `json:"categories"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// MarginFigures are the gross margin of confirmed order items. Cost is the
+// unit cost recorded on each item when it was sold. MarginPercent is gross
+// profit as a share of revenue, nil when there is no revenue.
+type MarginFigures struct {
+	Quantity      int      `json:"quantity"`
+	Revenue       float64  `json:"revenue"`
+	Cost          float64  `json:"cost"`
+	GrossProfit   float64  `json:"gross_profit"`
+	MarginPercent *float64 `json:"margin_percent"`
+}
+
+// Add accumulates other into f, including the derived profit lines.
+func (f *MarginFigures) Add(other MarginFigures) {
+	f.Quantity += other.Quantity
+	f.Revenue += other.Revenue
+	f.Cost += other.Cost
+	f.CalculateMargin()
+}
+
+func (f *MarginFigures) CalculateMargin() {
+	f.GrossProfit = f.Revenue - f.Cost
+	f.MarginPercent = nil
+	if f.Revenue != 0 {
+		margin := math.Round(f.GrossProfit/f.Revenue*10000) / 100
+		f.MarginPercent = &margin
+	}
+}
+
+type ProductMargin struct {
+	ProductID    string  `json:"product_id"`
+	ProductName  string  `json:"product_name"`
+	CategoryID   *string `json:"category_id"`
+	CategoryName string  `json:"category_name"`
+	MarginFigures
+}
+
+// CategoryMargin groups products by their current category.
+type CategoryMargin struct {
+	CategoryID   *string `json:"category_id"`
+	CategoryName string  `json:"category_name"`
+	MarginFigures
+}
+
+type MarginPeriod struct {
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	MarginFigures
+}
+
+type ProductMarginReport struct {
+	From     string          `json:"from"`
+	To       string          `json:"to"`
+	Products []ProductMargin `json:"products"`
+	Summary  MarginFigures   `json:"summary"`
+}
+
+type CategoryMarginReport struct {
+	From       string           `json:"from"`
+	To         string           `json:"to"`
+	Categories []CategoryMargin `json:"categories"`
+	Summary    MarginFigures    `json:"summary"`
+}
+
+type MarginPeriodReport struct {
+	From        string         `json:"from"`
+	To          string         `json:"to"`
+	Granularity string         `json:"granularity"`
+	Periods     []MarginPeriod `json:"periods"`
+	Summary     MarginFigures  `json:"summary"`
+}
@@ -30,6 +30,7 @@ type OrderItem struct {
 	ProductID  string    `json:"product_id" db:"product_id"`
 	Quantity   int       `json:"quantity" db:"quantity"`
 	TotalPrice float64   `json:"total_price" db:"total_price"`
+	UnitCost   float64   `json:"unit_cost,omitempty" db:"unit_cost"`
 	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 	Product    *Product  `json:"product,omitempty" db:"-"`
 }
@@ -77,6 +78,29 @@ type OrderFilter struct {
 	PerPage    uint
 }
 
+// OrderExportFilter selects the orders of an export. EndDate is exclusive.
+type OrderExportFilter struct {
+	UserID     string
+	StartDate  time.Time
+	EndDate    time.Time
+	CustomerID *string
+	Status     *OrderStatus
+}
+
+// OrderExportRow is one line item together with its order. Orders without
+// items come as a single row with empty item fields.
+type OrderExportRow struct {
+	OrderID       string
+	OrderDate     time.Time
+	Status        OrderStatus
+	CustomerName  *string
+	CustomerPhone *string
+	OrderTotal    float64
+	ProductName   *string
+	Quantity      *int
+	ItemTotal     *float64
+}
+
 type OrderListResponse struct {
 	Orders  []Order `json:"orders"`
 	Total   uint    `json:"total"`
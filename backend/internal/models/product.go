@@ -3,14 +3,40 @@ package models
 import "time"
 
 type Product struct {
-	ID        string    `json:"id" db:"id"`
-	UserID    string    `json:"user_id"`
-	Name      string    `json:"name" db:"name"`
-	Price     float64   `json:"price" db:"price"`
-	Stock     int       `json:"stock" db:"stock"`
-	ImageURL  string    `json:"image_url" db:"image_url"`
-	PublicID  string    `json:"public_id" db:"public_id"`
-	CreatedAt time.Time `json:"created_at" db:"created-at"`
+	ID         string    `json:"id" db:"id"`
+	UserID     string    `json:"user_id"`
+	Name       string    `json:"name" db:"name"`
+	Price      float64   `json:"price" db:"price"`
+	Stock      int       `json:"stock" db:"stock"`
+	ImageURL   string    `json:"image_url" db:"image_url"`
+	PublicID   string    `json:"public_id" db:"public_id"`
+	CostPrice  float64   `json:"cost_price,omitempty" db:"cost_price"`
+	CategoryID *string   `json:"category_id,omitempty" db:"category_id"`
+	CreatedAt  time.Time `json:"created_at" db:"created-at"`
+}
+
+// ProductRestock records stock added to a product and how it moved the
+// product's weighted average cost. ReceiptItemID is set when the stock was
+// bought on a receipt.
+type ProductRestock struct {
+	ID            string    `json:"id" db:"id"`
+	UserID        string    `json:"user_id" db:"user_id"`
+	ProductID     string    `json:"product_id" db:"product_id"`
+	ReceiptItemID *string   `json:"receipt_item_id" db:"receipt_item_id"`
+	Quantity      int       `json:"quantity" db:"quantity"`
+	UnitCost      float64   `json:"unit_cost" db:"unit_cost"`
+	StockBefore   int       `json:"stock_before" db:"stock_before"`
+	CostBefore    float64   `json:"cost_before" db:"cost_before"`
+	CostAfter     float64   `json:"cost_after" db:"cost_after"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// RestockProductPayload adds stock to a product. With a receipt item the unit
+// cost defaults to the item's price divided by the quantity.
+type RestockProductPayload struct {
+	Quantity      int      `json:"quantity" validate:"required,min=1"`
+	UnitCost      *float64 `json:"unit_cost,omitempty"`
+	ReceiptItemID *string  `json:"receipt_item_id,omitempty"`
 }
 
 type ProductListResponse struct {
@@ -2,25 +2,105 @@ package models
 
 import "time"
 
+// A receipt is processing from upload until a worker has read and stored the
+// image, and then a draft the merchant can correct. Its expense transaction is
+// only created, or brought up to date, when the receipt is confirmed. Editing
+// a confirmed receipt turns it back into a draft.
+const (
+	ReceiptProcessing = "processing"
+	ReceiptDraft      = "draft"
+	ReceiptConfirmed  = "confirmed"
+	ReceiptFailed     = "failed"
+)
+
 type Receipt struct {
-	ID         string    `json:"id" db:"id"`
-	UserID     string    `json:"user_id" db:"user_id"`
-	StoreName  string    `json:"store_name" db:"store_name"`
-	TotalItems uint32    `json:"total_items" db:"total_items"`
-	TotalPrice float64   `json:"total" db:"total_price"`
-	ImageURL   string    `json:"image_url" db:"image_url"`
-	PublicID   string    `json:"public_id" db:"public_id"`
-	CreatedAt  time.Time `json:"created_at,omitempty" db:"created_at"`
+	ID             string     `json:"id" db:"id"`
+	UserID         string     `json:"user_id" db:"user_id"`
+	StoreName      string     `json:"store_name" db:"store_name"`
+	TotalItems     uint32     `json:"total_items" db:"total_items"`
+	TotalPrice     float64    `json:"total" db:"total_price"`
+	ImageURL       string     `json:"image_url" db:"image_url"`
+	PublicID       string     `json:"public_id" db:"public_id"`
+	CategoryID     *string    `json:"category_id" db:"category_id"`
+	AccountID      *string    `json:"account_id" db:"account_id"`
+	InvoiceDate    *time.Time `json:"invoice_date" db:"invoice_date"`
+	Status         string     `json:"status" db:"status"`
+	FailureReason  *string    `json:"failure_reason,omitempty" db:"failure_reason"`
+	ConfirmedAt    *time.Time `json:"confirmed_at,omitempty" db:"confirmed_at"`
+	DuplicateOf    *string    `json:"duplicate_of,omitempty" db:"duplicate_of"`
+	AllowDuplicate bool       `json:"allow_duplicate" db:"allow_duplicate"`
+	ContentHash    *string    `json:"-" db:"content_hash"`
+	ImageHash      *int64     `json:"-" db:"image_hash"`
+	VoidedAt       *time.Time `json:"voided_at,omitempty" db:"voided_at"`
+	VoidReason     *string    `json:"void_reason,omitempty" db:"void_reason"`
+	CreatedAt      time.Time  `json:"created_at,omitempty" db:"created_at"`
 }
 
+// A receipt is a likely duplicate when its file is the same as an earlier
+// upload, its image looks the same, or its store, invoice date and total match
+// another receipt.
+const (
+	ReceiptDuplicateContent = "content"
+	ReceiptDuplicateImage   = "image"
+	ReceiptDuplicateFields  = "fields"
+)
+
+// ReceiptDuplicate points at the receipt another one seems to repeat.
+type ReceiptDuplicate struct {
+	ReceiptID string `json:"receipt_id"`
+	Match     string `json:"match"`
+}
+
+// ReceiptJob is an uploaded receipt image waiting for a worker. Attempts
+// includes the attempt the job was claimed for.
+type ReceiptJob struct {
+	ID        string
+	ReceiptID string
+	UserID    string
+	Image     []byte
+	Filename  string
+	Attempts  int
+}
+
+type ReceiptStatusResponse struct {
+	ID            string  `json:"id"`
+	Status        string  `json:"status"`
+	FailureReason *string `json:"failure_reason,omitempty"`
+}
+
+// ReceiptItem is a line of a receipt. Price is the line total as printed,
+// not the unit price.
 type ReceiptItem struct {
 	ID        string    `json:"id" db:"id"`
 	ReceiptID string    `json:"receipt_id" db:"receipt_id"`
 	Name      string    `json:"name" db:"name"`
+	Quantity  int       `json:"quantity" db:"quantity"`
 	Price     float64   `json:"price" db:"price"`
 	CreatedAt time.Time `json:"created_at,omitempty" db:"created_at"`
 }
 
+// ReceiptExportFilter selects receipts by their invoice date. EndDate is
+// exclusive.
+type ReceiptExportFilter struct {
+	UserID    string
+	StartDate time.Time
+	EndDate   time.Time
+}
+
+// ReceiptExportRow is one receipt item together with its receipt. The
+// invoice date comes from the receipt's transaction and falls back to the
+// upload time.
+type ReceiptExportRow struct {
+	ReceiptID    string
+	InvoiceDate  time.Time
+	StoreName    string
+	CategoryName *string
+	Total        float64
+	VoidedAt     *time.Time
+	ItemName     *string
+	ItemPrice    *float64
+}
+
 type IncomeAndExpenses struct {
 	Incomes  float64 `json:"incomes"`
 	Expenses float64 `json:"expenses"`
@@ -39,6 +119,25 @@ type CreateReceiptItemPayload struct {
 	Price float64 `json:"price" validate:"required"`
 }
 
+// UpdateReceiptPayload corrects what was read from a receipt. Fields that are
+// left out keep their value.
+type UpdateReceiptPayload struct {
+	StoreName   *string  `json:"store_name,omitempty"`
+	InvoiceDate *string  `json:"invoice_date,omitempty"`
+	TotalPrice  *float64 `json:"total,omitempty"`
+	AccountID   *string  `json:"account_id,omitempty"`
+}
+
+type ReceiptItemPayload struct {
+	Name     string  `json:"name" validate:"required,max=255"`
+	Quantity int     `json:"quantity" validate:"required,min=1"`
+	Price    float64 `json:"price" validate:"gte=0"`
+}
+
+type UpdateReceiptCategoryPayload struct {
+	CategoryID *string `json:"category_id"`
+}
+
 type ReceiptResponse struct {
 	Receipt Receipt       `json:"receipt"`
 	Items   []ReceiptItem `json:"items,omitempty"`
@@ -0,0 +1,151 @@
+package models
+
+import "time"
+
+const (
+	RecurringDaily    = "daily"
+	RecurringWeekly   = "weekly"
+	RecurringMonthly  = "monthly"
+	RecurringMonthEnd = "month_end"
+)
+
+// RecurringTransaction is a template the scheduler turns into transactions.
+// Occurrences are anchored on StartDate: weekly templates repeat on its
+// weekday, monthly templates on DayOfMonth, clamped to shorter months.
+// Occurrences go to AccountID, or the default account when it is nil.
+type RecurringTransaction struct {
+	ID          string     `json:"id" db:"id"`
+	UserID      string     `json:"user_id" db:"user_id"`
+	Type        string     `json:"type" db:"type"`
+	Amount      float64    `json:"amount" db:"amount"`
+	CategoryID  *string    `json:"category_id" db:"category_id"`
+	AccountID   *string    `json:"account_id" db:"account_id"`
+	Description *string    `json:"description" db:"description"`
+	Frequency   string     `json:"frequency" db:"frequency"`
+	Interval    int        `json:"interval" db:"interval_count"`
+	DayOfMonth  *int       `json:"day_of_month,omitempty" db:"day_of_month"`
+	StartDate   time.Time  `json:"start_date" db:"start_date"`
+	EndDate     *time.Time `json:"end_date" db:"end_date"`
+	NextRunDate time.Time  `json:"next_run_date" db:"next_run_date"`
+	PausedAt    *time.Time `json:"paused_at" db:"paused_at"`
+	CreatedAt   time.Time  `json:"created_at,omitempty" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// FirstOccurrence returns the first occurrence on or after StartDate.
+func (r RecurringTransaction) FirstOccurrence() time.Time {
+	start := dateOnly(r.StartDate)
+
+	switch r.Frequency {
+	case RecurringMonthly:
+		first := monthDay(start.Year(), start.Month(), r.dayOfMonth())
+		if first.Before(start) {
+			first = monthDay(start.Year(), start.Month()+1, r.dayOfMonth())
+		}
+		return first
+	case RecurringMonthEnd:
+		return monthDay(start.Year(), start.Month(), 31)
+	default:
+		return start
+	}
+}
+
+// NextOccurrence returns the occurrence that follows date, which must itself
+// be an occurrence of the schedule.
+func (r RecurringTransaction) NextOccurrence(date time.Time) time.Time {
+	date = dateOnly(date)
+	interval := max(r.Interval, 1)
+
+	switch r.Frequency {
+	case RecurringWeekly:
+		return date.AddDate(0, 0, 7*interval)
+	case RecurringMonthly:
+		return monthDay(date.Year(), date.Month()+time.Month(interval), r.dayOfMonth())
+	case RecurringMonthEnd:
+		return monthDay(date.Year(), date.Month()+time.Month(interval), 31)
+	default:
+		return date.AddDate(0, 0, interval)
+	}
+}
+
+// OccurrenceOnOrAfter returns the first occurrence that is not before date.
+func (r RecurringTransaction) OccurrenceOnOrAfter(date time.Time) time.Time {
+	date = dateOnly(date)
+	occurrence := r.FirstOccurrence()
+	for occurrence.Before(date) {
+		occurrence = r.NextOccurrence(occurrence)
+	}
+	return occurrence
+}
+
+// Ended reports whether date falls after the template's end date.
+func (r RecurringTransaction) Ended(date time.Time) bool {
+	return r.EndDate != nil && dateOnly(date).After(dateOnly(*r.EndDate))
+}
+
+func (r RecurringTransaction) dayOfMonth() int {
+	if r.DayOfMonth != nil {
+		return *r.DayOfMonth
+	}
+	return r.StartDate.Day()
+}
+
+// monthDay returns day of the given month, or the month's last day when it is
+// shorter. month may overflow into the following years.
+func monthDay(year int, month time.Month, day int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	return time.Date(first.Year(), first.Month(), min(day, last), 0, 0, 0, 0, time.UTC)
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+type CreateRecurringTransactionPayload struct {
+	Type        string  `json:"type" validate:"required,oneof=expense income"`
+	Amount      float64 `json:"amount" validate:"required,gt=0"`
+	CategoryID  *string `json:"category_id,omitempty"`
+	AccountID   *string `json:"account_id,omitempty"`
+	Description string  `json:"description,omitempty" validate:"max=255"`
+	Frequency   string  `json:"frequency" validate:"required,oneof=daily weekly monthly month_end"`
+	Interval    int     `json:"interval,omitempty" validate:"gte=0,max=365"`
+	DayOfMonth  *int    `json:"day_of_month,omitempty"`
+	StartDate   string  `json:"start_date" validate:"required"`
+	EndDate     *string `json:"end_date,omitempty"`
+}
+
+// UpdateRecurringTransactionPayload changes future occurrences only.
+// Transactions that were already created keep their values.
+type UpdateRecurringTransactionPayload struct {
+	Type        string  `json:"type" validate:"required,oneof=expense income"`
+	Amount      float64 `json:"amount" validate:"required,gt=0"`
+	CategoryID  *string `json:"category_id,omitempty"`
+	AccountID   *string `json:"account_id,omitempty"`
+	Description string  `json:"description,omitempty" validate:"max=255"`
+	Frequency   string  `json:"frequency" validate:"required,oneof=daily weekly monthly month_end"`
+	Interval    int     `json:"interval,omitempty" validate:"gte=0,max=365"`
+	DayOfMonth  *int    `json:"day_of_month,omitempty"`
+	EndDate     *string `json:"end_date,omitempty"`
+}
+
+type SkipOccurrencePayload struct {
+	Date string `json:"date" validate:"required"`
+}
+
+type RecurringOccurrence struct {
+	Date    string `json:"date"`
+	Skipped bool   `json:"skipped"`
+}
+
+type RecurringTransactionResponse struct {
+	RecurringTransaction RecurringTransaction `json:"recurring_transaction"`
+}
+
+type RecurringTransactionListResponse struct {
+	RecurringTransactions []RecurringTransaction `json:"recurring_transactions"`
+}
+
+type RecurringOccurrenceListResponse struct {
+	Occurrences []RecurringOccurrence `json:"occurrences"`
+}
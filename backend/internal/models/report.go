@@ -0,0 +1,92 @@
+package models
+
+import "time"
+
+// ProfitLossFigures are the lines of a profit and loss statement. Revenue
+// comes from confirmed orders, other income from income transactions that are
+// not linked to an order, and cost of goods sold from the cost price of the
+// ordered items. Expenses in categories marked as COGS buy the stock that
+// cost is taken from, so they are shown as stock purchases and left out of
+// the profit. Every other expense is an operating expense.
+type ProfitLossFigures struct {
+	Revenue           float64 `json:"revenue"`
+	OtherIncome       float64 `json:"other_income"`
+	CostOfGoodsSold   float64 `json:"cost_of_goods_sold"`
+	GrossProfit       float64 `json:"gross_profit"`
+	OperatingExpenses float64 `json:"operating_expenses"`
+	NetProfit         float64 `json:"net_profit"`
+	StockPurchases    float64 `json:"stock_purchases"`
+}
+
+// Add accumulates other into f, including the derived profit lines.
+func (f *ProfitLossFigures) Add(other ProfitLossFigures) {
+	f.Revenue += other.Revenue
+	f.OtherIncome += other.OtherIncome
+	f.CostOfGoodsSold += other.CostOfGoodsSold
+	f.OperatingExpenses += other.OperatingExpenses
+	f.StockPurchases += other.StockPurchases
+	f.CalculateProfit()
+}
+
+func (f *ProfitLossFigures) CalculateProfit() {
+	f.GrossProfit = f.Revenue - f.CostOfGoodsSold
+	f.NetProfit = f.GrossProfit + f.OtherIncome - f.OperatingExpenses
+}
+
+// ProfitLossChange is the percentage change against the previous period. A
+// field is nil when the previous value is zero.
+type ProfitLossChange struct {
+	Revenue     *float64 `json:"revenue"`
+	GrossProfit *float64 `json:"gross_profit"`
+	NetProfit   *float64 `json:"net_profit"`
+}
+
+type ExpenseByCategory struct {
+	CategoryID   *string `json:"category_id"`
+	CategoryName string  `json:"category_name"`
+	Amount       float64 `json:"amount"`
+}
+
+type ProfitLossPeriod struct {
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	ProfitLossFigures
+	Expenses []ExpenseByCategory `json:"expenses"`
+	Previous ProfitLossFigures   `json:"previous"`
+	Change   ProfitLossChange    `json:"change"`
+}
+
+type ProfitLossSummary struct {
+	ProfitLossFigures
+	Previous ProfitLossFigures `json:"previous"`
+	Change   ProfitLossChange  `json:"change"`
+}
+
+type ProfitLossReport struct {
+	From        string             `json:"from"`
+	To          string             `json:"to"`
+	Granularity string             `json:"granularity"`
+	Periods     []ProfitLossPeriod `json:"periods"`
+	Summary     ProfitLossSummary  `json:"summary"`
+}
+
+// StatementReceipt is a receipt listed as evidence in a financial statement.
+type StatementReceipt struct {
+	ID          string
+	InvoiceDate time.Time
+	StoreName   string
+	Total       float64
+	ImageURL    string
+}
+
+// FinancialStatement is everything rendered into the monthly PDF statement.
+// Month is midnight of the first day of the month in the merchant's zone.
+type FinancialStatement struct {
+	StoreName   string
+	Month       time.Time
+	GeneratedAt time.Time
+	Stats       TransactionStats
+	Days        []CashflowBucket
+	TopExpenses []CategoryStats
+	Receipts    []StatementReceipt
+}
@@ -1,17 +1,97 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Transaction struct {
-	ID              string    `json:"id" db:"id"`
-	UserID          string    `json:"user_id" db:"user_id"`
-	Type            string    `json:"type" db:"type"`
-	Source          string    `json:"source" db:"source"`
-	Amount          float64   `json:"amount" db:"amount"`
-	TransactionDate time.Time `json:"transaction_date" db:"transaction_date"`
-	ReceiptID       *string   `json:"receipt_id" db:"receipt_id"`
-	OrderID         *string   `json:"order_id" db:"order_id"`
-	CreatedAt       time.Time `json:"created_at,omitempty" db:"created_at"`
+	ID              string     `json:"id" db:"id"`
+	UserID          string     `json:"user_id" db:"user_id"`
+	Type            string     `json:"type" db:"type"`
+	Source          string     `json:"source" db:"source"`
+	Amount          float64    `json:"amount" db:"amount"`
+	TransactionDate time.Time  `json:"transaction_date" db:"transaction_date"`
+	ReceiptID       *string    `json:"receipt_id" db:"receipt_id"`
+	OrderID         *string    `json:"order_id" db:"order_id"`
+	CategoryID      *string    `json:"category_id" db:"category_id"`
+	AccountID       *string    `json:"account_id" db:"account_id"`
+	RecurringID     *string    `json:"recurring_id,omitempty" db:"recurring_id"`
+	VoidedAt        *time.Time `json:"voided_at,omitempty" db:"voided_at"`
+	VoidReason      *string    `json:"void_reason,omitempty" db:"void_reason"`
+	CreatedAt       time.Time  `json:"created_at,omitempty" db:"created_at"`
+}
+
+// HasParent reports whether the transaction was generated from a receipt or an
+// order. Those can only be changed through the parent entity.
+func (t Transaction) HasParent() bool {
+	return t.ReceiptID != nil || t.OrderID != nil
+}
+
+// TransactionFilter selects a user's transactions. Every field but UserID is
+// optional and the ones that are set are combined. EndDate is exclusive,
+// Search matches the category, the receipt's store, the order's customer and
+// the recurring transaction's description, and Voided picks only voided or
+// only active transactions.
+type TransactionFilter struct {
+	UserID     string
+	StartDate  *time.Time
+	EndDate    *time.Time
+	Type       *string
+	Source     *string
+	MinAmount  *float64
+	MaxAmount  *float64
+	CategoryID *string
+	AccountID  *string
+	Search     *string
+	Voided     *bool
+}
+
+const (
+	TransactionSortDateDesc   = "date_desc"
+	TransactionSortDateAsc    = "date_asc"
+	TransactionSortAmountDesc = "amount_desc"
+	TransactionSortAmountAsc  = "amount_asc"
+)
+
+// TransactionCursor points just past the last transaction of a page. Value is
+// the sort key of that transaction and Sort the order the page was read in.
+type TransactionCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// TransactionQuery is one page of a filtered transaction listing. Without a
+// cursor it is the first page.
+type TransactionQuery struct {
+	TransactionFilter
+	Sort   string
+	Cursor *TransactionCursor
+	Limit  int
+}
+
+// TransactionExportRow is a transaction with its category and account names
+// resolved.
+type TransactionExportRow struct {
+	Transaction
+	CategoryName *string
+	AccountName  *string
+}
+
+const (
+	TransactionRevisionUpdate = "update"
+	TransactionRevisionVoid   = "void"
+)
+
+type TransactionRevision struct {
+	ID            string          `json:"id" db:"id"`
+	TransactionID string          `json:"transaction_id" db:"transaction_id"`
+	UserID        string          `json:"user_id" db:"user_id"`
+	Action        string          `json:"action" db:"action"`
+	Previous      json.RawMessage `json:"previous" db:"previous" swaggertype:"object"`
+	Reason        *string         `json:"reason,omitempty" db:"reason"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
 }
 
 type CreateTransactionPayload struct {
@@ -21,16 +101,41 @@ type CreateTransactionPayload struct {
 	TransactionDate string  `json:"transaction_date" validate:"required"`
 	ReceiptID       *string `json:"receipt_id,omitempty"`
 	OrderID         *string `json:"order_id,omitempty"`
+	CategoryID      *string `json:"category_id,omitempty"`
+	AccountID       *string `json:"account_id,omitempty"`
+}
+
+type UpdateTransactionPayload struct {
+	Type            string  `json:"type" validate:"required,oneof=expense income"`
+	Amount          float64 `json:"amount" validate:"required,gte=0"`
+	TransactionDate string  `json:"transaction_date" validate:"required"`
+	CategoryID      *string `json:"category_id,omitempty"`
+	AccountID       *string `json:"account_id,omitempty"`
+}
+
+type VoidPayload struct {
+	Reason string `json:"reason" validate:"required,max=255"`
 }
 
 type TransactionResponse struct {
 	Transaction Transaction `json:"transaction"`
 }
 
+// TransactionPageResponse is a page of transactions. NextCursor is nil on the
+// last page.
+type TransactionPageResponse struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   *string       `json:"next_cursor"`
+}
+
 type TransactionListResponse struct {
 	Transactions []Transaction `json:"transactions"`
 }
 
+type TransactionRevisionListResponse struct {
+	Revisions []TransactionRevision `json:"revisions"`
+}
+
 type TransactionStats struct {
 	TotalIncome      float64 `json:"total_income"`
 	TotalExpense     float64 `json:"total_expense"`
@@ -39,7 +144,60 @@ type TransactionStats struct {
 	AverageAmount    float64 `json:"average_amount"`
 }
 
+// Stats comparison windows accepted by the compare parameter of the statistics
+// endpoints.
+const (
+	CompareNone           = ""
+	ComparePreviousPeriod = "previous_period"
+	ComparePreviousYear   = "previous_year"
+)
+
+// TransactionStatsChange is the difference against the compared window. The
+// percent fields are nil when the compared value is zero.
+type TransactionStatsChange struct {
+	TotalIncome             float64  `json:"total_income"`
+	TotalIncomePercent      *float64 `json:"total_income_percent"`
+	TotalExpense            float64  `json:"total_expense"`
+	TotalExpensePercent     *float64 `json:"total_expense_percent"`
+	NetAmount               float64  `json:"net_amount"`
+	NetAmountPercent        *float64 `json:"net_amount_percent"`
+	TransactionCount        int64    `json:"transaction_count"`
+	TransactionCountPercent *float64 `json:"transaction_count_percent"`
+}
+
+// TransactionStatsComparison holds the statistics of the window the requested
+// one is compared with. StartDate and EndDate are inclusive.
+type TransactionStatsComparison struct {
+	Compare   string                 `json:"compare"`
+	StartDate string                 `json:"start_date"`
+	EndDate   string                 `json:"end_date"`
+	Stats     TransactionStats       `json:"stats"`
+	Change    TransactionStatsChange `json:"change"`
+}
+
+// TransactionStatsResponse keeps the statistics at the top level so callers
+// that do not ask for a comparison see the same shape as before.
+type TransactionStatsResponse struct {
+	TransactionStats
+	Comparison *TransactionStatsComparison `json:"comparison,omitempty"`
+}
+
 type TransactionAnalytics struct {
 	Stats        TransactionStats `json:"stats"`
 	Transactions []Transaction    `json:"transactions,omitempty"`
 }
+
+type CashflowBucket struct {
+	PeriodStart      time.Time `json:"period_start"`
+	Income           float64   `json:"income"`
+	Expense          float64   `json:"expense"`
+	Net              float64   `json:"net"`
+	TransactionCount int64     `json:"transaction_count"`
+}
+
+type CashflowSeries struct {
+	From    string           `json:"from"`
+	To      string           `json:"to"`
+	Bucket  string           `json:"bucket"`
+	Buckets []CashflowBucket `json:"buckets"`
+}
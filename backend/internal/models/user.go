@@ -1,13 +1,28 @@
 package models
 
+import "time"
+
 type User struct {
-	ID           string `json:"id" db:"id"`
-	Email        string `json:"email" db:"email"`
-	FirstName    string `json:"firstname" db:"first_name"`
-	LastName     string `json:"lastname" db:"last_name"`
-	StoreName    string `json:"store_name" db:"store_name"`
-	PasswordHash string `json:"password_hash,omitempty" db:"password_hash"`
-	Created_At   string `json:"created_at,omitempty" db:"created_at"`
+	ID                  string     `json:"id" db:"id"`
+	Email               string     `json:"email" db:"email"`
+	FirstName           string     `json:"firstname" db:"first_name"`
+	LastName            string     `json:"lastname" db:"last_name"`
+	StoreName           string     `json:"store_name" db:"store_name"`
+	Timezone            string     `json:"timezone" db:"timezone"`
+	PasswordHash        string     `json:"password_hash,omitempty" db:"password_hash"`
+	TwoFactorEnabled    bool       `json:"two_factor_enabled" db:"totp_enabled"`
+	TOTPSecret          *string    `json:"-" db:"totp_secret"`
+	TOTPLastStep        *int64     `json:"-" db:"totp_last_step"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty" db:"deletion_scheduled_at"`
+	Created_At          string     `json:"created_at,omitempty" db:"created_at"`
+}
+
+type RecoveryCode struct {
+	ID        string  `json:"id" db:"id"`
+	UserID    string  `json:"user_id" db:"user_id"`
+	CodeHash  string  `json:"-" db:"code_hash"`
+	UsedAt    *string `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt string  `json:"created_at,omitempty" db:"created_at"`
 }
 
 type AuthPayload struct {
@@ -21,18 +36,52 @@ type RegisterPayload struct {
 	FistName  string `json:"firstname" validate:"required"`
 	LastName  string `json:"lastname" validate:"required"`
 	StoreName string `json:"store_name" validate:"required"`
+	// Timezone is an IANA zone name such as Asia/Makassar. Defaults to
+	// Asia/Jakarta.
+	Timezone string `json:"timezone,omitempty"`
 }
 
 type UpdateUserPayload struct {
 	FirstName string `json:"firstname" validate:"required"`
 	LastName  string `json:"lastname" validate:"required"`
 	StoreName string `json:"store_name" validate:"required"`
+	// Timezone is an IANA zone name. Left unchanged when empty.
+	Timezone string `json:"timezone,omitempty"`
 }
 
 type Token struct {
 	AccessToken string `json:"access_token"`
 }
 
+type TwoFactorCodePayload struct {
+	Code string `json:"code" validate:"required,len=6"`
+}
+
+type DisableTwoFactorPayload struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required,len=6"`
+}
+
+type TwoFactorLoginPayload struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code,omitempty"`
+	RecoveryCode   string `json:"recovery_code,omitempty"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+}
+
 type UserResponse struct {
 	Token Token `json:"token"`
 	User  User  `json:"user"`
@@ -42,6 +91,11 @@ type SessionResponse struct {
 	User User `json:"user"`
 }
 
+type AccountDeletionResponse struct {
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+	ExportURL           string    `json:"export_url"`
+}
+
 type Merchant struct {
 	MerchantID   string `json:"merchant_id" db:"id"`
 	MerchantName string `json:"merchant_name" db:"store_name"`
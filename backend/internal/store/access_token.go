@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/lib/pq"
+)
+
+type AccessTokenRepo struct {
+	db *sql.DB
+}
+
+func NewAccessTokenRepo(db *sql.DB) AccessTokenRepo {
+	return AccessTokenRepo{db: db}
+}
+
+func (r *AccessTokenRepo) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	query := `
+		INSERT INTO personal_access_tokens (id, user_id, name, token_prefix, token_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at
+	`
+	err := r.db.QueryRowContext(
+		ctx, query,
+		token.ID, token.UserID, token.Name,
+		token.TokenPrefix, token.TokenHash,
+		pq.Array(token.Scopes), token.ExpiresAt,
+	).Scan(&token.CreatedAt)
+	if err != nil {
+		log.Printf("[ERROR] Failed to create access token: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *AccessTokenRepo) GetAccessTokens(ctx context.Context, userID string) ([]models.PersonalAccessToken, error) {
+	query := `
+		SELECT id, user_id, name, token_prefix, scopes, expires_at, last_used_at, revoked_at, created_at
+		FROM personal_access_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get access tokens: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.PersonalAccessToken{}
+	for rows.Next() {
+		var token models.PersonalAccessToken
+		err := rows.Scan(
+			&token.ID, &token.UserID, &token.Name, &token.TokenPrefix,
+			pq.Array(&token.Scopes), &token.ExpiresAt, &token.LastUsedAt,
+			&token.RevokedAt, &token.CreatedAt,
+		)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan access token: %s", err.Error())
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+// GetActiveAccessToken looks a token up by its hash. Revoked and expired
+// tokens are treated as missing.
+func (r *AccessTokenRepo) GetActiveAccessToken(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	query := `
+		SELECT t.id, t.user_id, t.name, t.token_prefix, t.scopes, t.expires_at, t.last_used_at, t.created_at, u.email
+		FROM personal_access_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = $1
+			AND t.revoked_at IS NULL
+			AND (t.expires_at IS NULL OR t.expires_at > NOW())
+	`
+	var token models.PersonalAccessToken
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID, &token.UserID, &token.Name, &token.TokenPrefix,
+		pq.Array(&token.Scopes), &token.ExpiresAt, &token.LastUsedAt,
+		&token.CreatedAt, &token.UserEmail,
+	)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[ERROR] Failed to get access token: %s", err.Error())
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// TouchLastUsed updates last_used_at at most once a minute so busy scripts do
+// not turn every request into a write.
+func (r *AccessTokenRepo) TouchLastUsed(ctx context.Context, id string) error {
+	query := `
+		UPDATE personal_access_tokens SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`
+	_, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		log.Printf("[ERROR] Failed to update access token last use: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *AccessTokenRepo) Revoke(ctx context.Context, id string, userID string) (bool, error) {
+	query := `
+		UPDATE personal_access_tokens SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to revoke access token: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
@@ -0,0 +1,379 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrAccountExists = errors.New("account already exists")
+	ErrAccountInUse  = errors.New("account has transactions or transfers")
+)
+
+// accountBalance is the current balance of the account aliased a.
+const accountBalance = `
+	a.opening_balance
+	+ COALESCE((
+		SELECT SUM(CASE WHEN t.type = 'income' THEN t.amount ELSE -t.amount END)
+		FROM transactions t
+		WHERE t.account_id = a.id AND t.voided_at IS NULL
+	), 0)
+	+ COALESCE((SELECT SUM(amount) FROM account_transfers WHERE to_account_id = a.id), 0)
+	- COALESCE((SELECT SUM(amount) FROM account_transfers WHERE from_account_id = a.id), 0)
+`
+
+type AccountRepo struct {
+	db *sql.DB
+}
+
+func NewAccountRepo(db *sql.DB) AccountRepo {
+	return AccountRepo{db: db}
+}
+
+// Create adds an account. It becomes the default account when
+// account.IsDefault is set or the merchant has no default yet.
+func (r *AccountRepo) Create(ctx context.Context, account *models.Account) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return err
+	}
+	defer tx.Rollback()
+
+	if account.IsDefault {
+		if err := clearDefaultAccount(ctx, tx, account.UserID); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO accounts (id, user_id, name, kind, opening_balance, is_default)
+		VALUES ($1, $2, $3, $4, $5, $6 OR NOT EXISTS (SELECT 1 FROM accounts WHERE user_id = $2 AND is_default))
+		RETURNING is_default, created_at
+	`
+	err = tx.QueryRowContext(
+		ctx, query, account.ID, account.UserID, account.Name, account.Kind, account.OpeningBalance, account.IsDefault,
+	).Scan(&account.IsDefault, &account.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrAccountExists
+		}
+		log.Printf("[ERROR] Failed to create account: %s", err.Error())
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return err
+	}
+	account.Balance = account.OpeningBalance
+	return nil
+}
+
+func (r *AccountRepo) GetAccounts(ctx context.Context, userID string) ([]models.Account, error) {
+	query := `
+		SELECT a.id, a.user_id, a.name, a.kind, a.opening_balance, ` + accountBalance + `, a.is_default, a.created_at
+		FROM accounts a
+		WHERE a.user_id = $1
+		ORDER BY a.created_at
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get accounts: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []models.Account{}
+	for rows.Next() {
+		var account models.Account
+		err := rows.Scan(
+			&account.ID, &account.UserID, &account.Name, &account.Kind,
+			&account.OpeningBalance, &account.Balance, &account.IsDefault, &account.CreatedAt,
+		)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan account: %s", err.Error())
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate accounts: %s", err.Error())
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+func (r *AccountRepo) GetAccountByID(ctx context.Context, id string, userID string) (*models.Account, error) {
+	query := `
+		SELECT a.id, a.user_id, a.name, a.kind, a.opening_balance, ` + accountBalance + `, a.is_default, a.created_at
+		FROM accounts a
+		WHERE a.id = $1 AND a.user_id = $2
+	`
+	var account models.Account
+	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(
+		&account.ID, &account.UserID, &account.Name, &account.Kind,
+		&account.OpeningBalance, &account.Balance, &account.IsDefault, &account.CreatedAt,
+	)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[ERROR] Failed to get account: %s", err.Error())
+		}
+		return nil, err
+	}
+	return &account, nil
+}
+
+// UpdateAccount saves an account. Setting account.IsDefault moves the
+// default to it; clearing it leaves the merchant without a default.
+func (r *AccountRepo) UpdateAccount(ctx context.Context, account models.Account) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if account.IsDefault {
+		if err := clearDefaultAccount(ctx, tx, account.UserID); err != nil {
+			return false, err
+		}
+	}
+
+	query := `UPDATE accounts SET name = $1, kind = $2, opening_balance = $3, is_default = $4 WHERE id = $5 AND user_id = $6`
+	result, err := tx.ExecContext(
+		ctx, query, account.Name, account.Kind, account.OpeningBalance, account.IsDefault, account.ID, account.UserID,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return false, ErrAccountExists
+		}
+		log.Printf("[ERROR] Failed to update account: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return false, err
+	}
+	return true, nil
+}
+
+func clearDefaultAccount(ctx context.Context, tx *sql.Tx, userID string) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE accounts SET is_default = FALSE WHERE user_id = $1 AND is_default`, userID); err != nil {
+		log.Printf("[ERROR] Failed to clear default account: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// defaultAccountID is the SQL for the account a transaction is booked to:
+// accountID, or the default account of the merchant userID when it is NULL.
+// Both are column references or placeholders.
+func defaultAccountID(accountID, userID string) string {
+	return fmt.Sprintf(`COALESCE(%s, (SELECT d.id FROM accounts d WHERE d.user_id = %s AND d.is_default))`, accountID, userID)
+}
+
+// DeleteAccount removes an account that no transaction or transfer refers
+// to. Otherwise it returns ErrAccountInUse, so no history is lost.
+func (r *AccountRepo) DeleteAccount(ctx context.Context, id string, userID string) (bool, error) {
+	query := `DELETE FROM accounts WHERE id = $1 AND user_id = $2`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return false, ErrAccountInUse
+		}
+		log.Printf("[ERROR] Failed to delete account: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *AccountRepo) CreateTransfer(ctx context.Context, transfer *models.AccountTransfer) error {
+	query := `
+		INSERT INTO account_transfers (id, user_id, from_account_id, to_account_id, amount, transfer_date, note)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at
+	`
+	err := r.db.QueryRowContext(
+		ctx, query,
+		transfer.ID, transfer.UserID, transfer.FromAccountID, transfer.ToAccountID,
+		transfer.Amount, transfer.TransferDate, transfer.Note,
+	).Scan(&transfer.CreatedAt)
+	if err != nil {
+		log.Printf("[ERROR] Failed to create transfer: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// GetTransfers returns the transfers between start and end, end exclusive,
+// newest first. When accountID is set only transfers from or to that account
+// are returned.
+func (r *AccountRepo) GetTransfers(
+	ctx context.Context, userID string, accountID *string, start, end time.Time,
+) ([]models.AccountTransfer, error) {
+	query := `
+		SELECT id, user_id, from_account_id, to_account_id, amount, transfer_date, note, created_at
+		FROM account_transfers
+		WHERE user_id = $1 AND transfer_date >= $2 AND transfer_date < $3
+			AND ($4::uuid IS NULL OR from_account_id = $4::uuid OR to_account_id = $4::uuid)
+		ORDER BY transfer_date DESC, created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, start, end, accountID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get transfers: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := []models.AccountTransfer{}
+	for rows.Next() {
+		var transfer models.AccountTransfer
+		err := rows.Scan(
+			&transfer.ID, &transfer.UserID, &transfer.FromAccountID, &transfer.ToAccountID,
+			&transfer.Amount, &transfer.TransferDate, &transfer.Note, &transfer.CreatedAt,
+		)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan transfer: %s", err.Error())
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate transfers: %s", err.Error())
+		return nil, err
+	}
+
+	return transfers, nil
+}
+
+func (r *AccountRepo) DeleteTransfer(ctx context.Context, id string, userID string) (bool, error) {
+	query := `DELETE FROM account_transfers WHERE id = $1 AND user_id = $2`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to delete transfer: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// GetLedger returns the movements of an account between start and end, end
+// exclusive, oldest first, with the running balance after each. The returned
+// balance is the one at start.
+func (r *AccountRepo) GetLedger(
+	ctx context.Context, account models.Account, start, end time.Time,
+) ([]models.AccountLedgerEntry, float64, error) {
+	query := `
+		WITH entries AS (
+			SELECT
+				t.transaction_date AS entry_date, t.created_at, t.type::text AS entry_type, t.id AS reference_id,
+				c.name AS description,
+				CASE WHEN t.type = 'income' THEN t.amount ELSE -t.amount END AS amount
+			FROM transactions t
+			LEFT JOIN transaction_categories c ON c.id = t.category_id
+			WHERE t.account_id = $1 AND t.user_id = $2 AND t.voided_at IS NULL
+			UNION ALL
+			SELECT transfer_date, created_at, 'transfer_out', id, note, -amount
+			FROM account_transfers
+			WHERE from_account_id = $1 AND user_id = $2
+			UNION ALL
+			SELECT transfer_date, created_at, 'transfer_in', id, note, amount
+			FROM account_transfers
+			WHERE to_account_id = $1 AND user_id = $2
+		),
+		running AS (
+			SELECT
+				entry_date, created_at, entry_type, reference_id, description, amount,
+				SUM(amount) OVER (ORDER BY entry_date, created_at, reference_id ROWS UNBOUNDED PRECEDING) AS total
+			FROM entries
+			WHERE entry_date < $4
+		)
+		SELECT entry_date, entry_type, reference_id, description, amount, total
+		FROM running
+		WHERE entry_date >= $3
+		ORDER BY entry_date, created_at, reference_id
+	`
+	rows, err := r.db.QueryContext(ctx, query, account.ID, account.UserID, start, end)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get account ledger: %s", err.Error())
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []models.AccountLedgerEntry{}
+	for rows.Next() {
+		var entry models.AccountLedgerEntry
+		var total float64
+		err := rows.Scan(&entry.Date, &entry.Type, &entry.ReferenceID, &entry.Description, &entry.Amount, &total)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan account ledger entry: %s", err.Error())
+			return nil, 0, err
+		}
+		entry.Balance = account.OpeningBalance + total
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate account ledger: %s", err.Error())
+		return nil, 0, err
+	}
+
+	if len(entries) > 0 {
+		return entries, entries[0].Balance - entries[0].Amount, nil
+	}
+
+	// Without movements in the range the balance at start is everything before it.
+	var before float64
+	beforeQuery := `
+		SELECT
+			COALESCE((
+				SELECT SUM(CASE WHEN type = 'income' THEN amount ELSE -amount END)
+				FROM transactions
+				WHERE account_id = $1 AND user_id = $2 AND voided_at IS NULL AND transaction_date < $3
+			), 0)
+			+ COALESCE((SELECT SUM(amount) FROM account_transfers WHERE to_account_id = $1 AND transfer_date < $3), 0)
+			- COALESCE((SELECT SUM(amount) FROM account_transfers WHERE from_account_id = $1 AND transfer_date < $3), 0)
+	`
+	if err := r.db.QueryRowContext(ctx, beforeQuery, account.ID, account.UserID, start).Scan(&before); err != nil {
+		log.Printf("[ERROR] Failed to get account balance: %s", err.Error())
+		return nil, 0, err
+	}
+	return entries, account.OpeningBalance + before, nil
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
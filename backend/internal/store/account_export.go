@@ -0,0 +1,222 @@
+package store
+
+import (
+	"context"
+	"io"
+	"log"
+)
+
+// AccountExportDataset is one file of the account archive. Query takes the
+// user ID as $1 and returns a single JSON column per row.
+type AccountExportDataset struct {
+	Name  string
+	Query string
+}
+
+var AccountExportDatasets = []AccountExportDataset{
+	{
+		Name: "profile",
+		Query: `
+			SELECT json_build_object(
+				'id', id, 'email', email, 'firstname', first_name, 'lastname', last_name,
+				'store_name', store_name, 'timezone', timezone, 'two_factor_enabled', totp_enabled,
+				'created_at', created_at
+			)
+			FROM users WHERE id = $1
+		`,
+	},
+	{
+		Name: "categories",
+		Query: `
+			SELECT json_build_object(
+				'id', id, 'name', name, 'type', type, 'is_default', is_default, 'is_cogs', is_cogs,
+				'created_at', created_at
+			)
+			FROM transaction_categories WHERE user_id = $1
+			ORDER BY type, name
+		`,
+	},
+	{
+		Name: "accounts",
+		Query: `
+			SELECT json_build_object(
+				'id', id, 'name', name, 'kind', kind, 'opening_balance', opening_balance,
+				'created_at', created_at
+			)
+			FROM accounts WHERE user_id = $1
+			ORDER BY created_at
+		`,
+	},
+	{
+		Name: "account_transfers",
+		Query: `
+			SELECT json_build_object(
+				'id', id, 'from_account_id', from_account_id, 'to_account_id', to_account_id,
+				'amount', amount, 'transfer_date', transfer_date, 'note', note, 'created_at', created_at
+			)
+			FROM account_transfers WHERE user_id = $1
+			ORDER BY transfer_date
+		`,
+	},
+	{
+		Name: "recurring_transactions",
+		Query: `
+			SELECT json_build_object(
+				'id', id, 'type', type, 'amount', amount, 'category_id', category_id,
+				'description', description, 'frequency', frequency, 'interval', interval_count,
+				'day_of_month', day_of_month, 'start_date', start_date, 'end_date', end_date,
+				'next_run_date', next_run_date, 'paused_at', paused_at, 'created_at', created_at
+			)
+			FROM recurring_transactions WHERE user_id = $1
+			ORDER BY created_at
+		`,
+	},
+	{
+		Name: "products",
+		Query: `
+			SELECT json_build_object(
+				'id', id, 'name', name, 'price', price, 'stock', stock,
+				'cost_price', cost_price, 'category_id', category_id,
+				'image_url', image_url, 'created_at', created_at
+			)
+			FROM products WHERE user_id = $1
+			ORDER BY created_at
+		`,
+	},
+	{
+		Name: "product_restocks",
+		Query: `
+			SELECT json_build_object(
+				'id', id, 'product_id', product_id, 'receipt_item_id', receipt_item_id,
+				'quantity', quantity, 'unit_cost', unit_cost, 'stock_before', stock_before,
+				'cost_before', cost_before, 'cost_after', cost_after, 'created_at', created_at
+			)
+			FROM product_restocks WHERE user_id = $1
+			ORDER BY created_at
+		`,
+	},
+	{
+		Name: "receipts",
+		Query: `
+			SELECT json_build_object(
+				'id', r.id, 'store_name', r.store_name, 'total_items', r.total_items,
+				'total_price', r.total_price, 'image_url', r.image_url, 'category_id', r.category_id,
+				'invoice_date', r.invoice_date, 'status', r.status, 'failure_reason', r.failure_reason,
+				'confirmed_at', r.confirmed_at, 'voided_at', r.voided_at,
+				'void_reason', r.void_reason, 'created_at', r.created_at,
+				'items', COALESCE((
+					SELECT json_agg(json_build_object('id', ri.id, 'name', ri.name, 'quantity', ri.quantity, 'price', ri.price) ORDER BY ri.created_at)
+					FROM receipt_items ri WHERE ri.receipt_id = r.id
+				), '[]'::json)
+			)
+			FROM receipts r WHERE r.user_id = $1
+			ORDER BY r.created_at
+		`,
+	},
+	{
+		// Customers are shared between stores, so only those who ordered
+		// from this one are part of its data.
+		Name: "customers",
+		Query: `
+			SELECT json_build_object(
+				'id', c.id, 'name', c.name, 'address', c.address, 'phone', c.phone,
+				'created_at', c.created_at
+			)
+			FROM customers c
+			WHERE c.id IN (SELECT o.customer_id FROM orders o WHERE o.user_id = $1)
+			ORDER BY c.created_at
+		`,
+	},
+	{
+		Name: "orders",
+		Query: `
+			SELECT json_build_object(
+				'id', o.id, 'customer_id', o.customer_id, 'customer_name', c.name,
+				'total_price', o.total_price, 'status', o.status, 'order_date', o.order_date,
+				'created_at', o.created_at,
+				'items', COALESCE((
+					SELECT json_agg(json_build_object(
+						'id', oi.id, 'product_id', oi.product_id, 'product_name', p.name,
+						'quantity', oi.quantity, 'total_price', oi.total_price, 'unit_cost', oi.unit_cost
+					) ORDER BY oi.created_at)
+					FROM order_items oi
+					LEFT JOIN products p ON p.id = oi.product_id
+					WHERE oi.order_id = o.id
+				), '[]'::json)
+			)
+			FROM orders o
+			LEFT JOIN customers c ON c.id = o.customer_id
+			WHERE o.user_id = $1
+			ORDER BY o.order_date
+		`,
+	},
+	{
+		Name: "transactions",
+		Query: `
+			SELECT json_build_object(
+				'id', id, 'type', type, 'source', source, 'amount', amount,
+				'transaction_date', transaction_date, 'receipt_id', receipt_id,
+				'order_id', order_id, 'category_id', category_id, 'account_id', account_id,
+				'recurring_id', recurring_id, 'voided_at', voided_at, 'void_reason', void_reason,
+				'created_at', created_at
+			)
+			FROM transactions WHERE user_id = $1
+			ORDER BY transaction_date
+		`,
+	},
+	{
+		Name: "audit_logs",
+		Query: `
+			SELECT json_build_object(
+				'id', id, 'actor_type', actor_type, 'actor_id', actor_id, 'action', action,
+				'method', method, 'path', path, 'entity_type', entity_type, 'entity_id', entity_id,
+				'before', before, 'after', after, 'created_at', created_at
+			)
+			FROM audit_logs WHERE store_id = $1
+			ORDER BY created_at
+		`,
+	},
+}
+
+// WriteAccountExport streams one dataset as a JSON array without loading the
+// whole result into memory.
+func (r *UserRepo) WriteAccountExport(ctx context.Context, userID string, dataset AccountExportDataset, w io.Writer) error {
+	rows, err := r.db.QueryContext(ctx, dataset.Query, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to export %s: %s", dataset.Name, err.Error())
+		return err
+	}
+	defer rows.Close()
+
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	first := true
+	for rows.Next() {
+		var row []byte
+		if err := rows.Scan(&row); err != nil {
+			log.Printf("[ERROR] Failed to scan %s export row: %s", dataset.Name, err.Error())
+			return err
+		}
+
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate %s export rows: %s", dataset.Name, err.Error())
+		return err
+	}
+
+	_, err = io.WriteString(w, "]")
+	return err
+}
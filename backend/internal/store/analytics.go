@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/models"
+)
+
+type AnalyticsRepo struct {
+	db *sql.DB
+}
+
+func NewAnalyticsRepo(db *sql.DB) AnalyticsRepo {
+	return AnalyticsRepo{db: db}
+}
+
+// GetProductSales returns the sales figures of every product of the user from
+// confirmed orders, best sellers in the range first. Rank, the week over week
+// change, velocity and days of stock are left for the caller to fill in from
+// RecentUnits.
+func (r *AnalyticsRepo) GetProductSales(ctx context.Context, userID string, window models.ProductSalesWindow) ([]models.ProductSales, error) {
+	query := `
+		SELECT
+			p.id, p.name, p.price, p.stock,
+			COALESCE(SUM(oi.quantity) FILTER (WHERE o.order_date >= $2 AND o.order_date < $3), 0) AS units,
+			COALESCE(SUM(oi.total_price) FILTER (WHERE o.order_date >= $2 AND o.order_date < $3), 0) AS revenue,
+			COALESCE(SUM(oi.quantity) FILTER (WHERE o.order_date >= $4 AND o.order_date < $3), 0),
+			COALESCE(SUM(oi.quantity) FILTER (WHERE o.order_date >= $5 AND o.order_date < $4), 0),
+			COALESCE(SUM(oi.quantity) FILTER (WHERE o.order_date >= $6 AND o.order_date < $7), 0)
+		FROM products p
+		LEFT JOIN order_items oi ON oi.product_id = p.id
+		LEFT JOIN orders o ON o.id = oi.order_id AND o.status = 'confirmed'
+		WHERE p.user_id = $1
+		GROUP BY p.id, p.name, p.price, p.stock
+		ORDER BY units DESC, revenue DESC, p.name
+	`
+	rows, err := r.db.QueryContext(
+		ctx, query,
+		userID, window.RangeStart, window.RangeEnd, window.LastWeekStart, window.PreviousWeekStart,
+		window.VelocityStart, window.VelocityEnd,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get product sales: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []models.ProductSales{}
+	for rows.Next() {
+		var product models.ProductSales
+		err := rows.Scan(
+			&product.ProductID, &product.ProductName, &product.Price, &product.Stock,
+			&product.Units, &product.Revenue, &product.LastWeekUnits, &product.PreviousWeekUnits,
+			&product.RecentUnits,
+		)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan product sales: %s", err.Error())
+			return nil, err
+		}
+		products = append(products, product)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate product sales: %s", err.Error())
+		return nil, err
+	}
+	return products, nil
+}
+
+// customerAnalyticsSorts maps a sort option to the column customer analytics
+// are ordered by, largest first.
+var customerAnalyticsSorts = map[string]string{
+	"lifetime_value":  "lifetime_value",
+	"order_count":     "order_count",
+	"average_basket":  "lifetime_value / order_count",
+	"last_order_date": "last_order",
+}
+
+// GetCustomerAnalytics returns the figures and RFM segment of the merchant's
+// customers with a confirmed order, together with the number of customers
+// matching the filter. Scores are computed over all of the merchant's
+// customers before the filter is applied, so a customer's segment does not
+// depend on the filter.
+func (r *AnalyticsRepo) GetCustomerAnalytics(ctx context.Context, filter models.CustomerAnalyticsFilter) ([]models.CustomerAnalytics, uint, error) {
+	sort, ok := customerAnalyticsSorts[filter.Sort]
+	if !ok {
+		sort = customerAnalyticsSorts["lifetime_value"]
+	}
+
+	query := `
+		WITH figures AS (
+			SELECT
+				c.id, c.name, c.phone, c.address,
+				COUNT(*) AS order_count,
+				SUM(o.total_price) AS lifetime_value,
+				MIN(o.order_date) AS first_order,
+				MAX(o.order_date) AS last_order
+			FROM orders o
+			JOIN customers c ON c.id = o.customer_id
+			WHERE o.user_id = $1 AND o.status = 'confirmed'
+			GROUP BY c.id, c.name, c.phone, c.address
+		),
+		scored AS (
+			SELECT
+				f.*,
+				NTILE(5) OVER (ORDER BY last_order) AS recency,
+				NTILE(5) OVER (ORDER BY order_count, last_order) AS frequency,
+				NTILE(5) OVER (ORDER BY lifetime_value) AS monetary
+			FROM figures f
+		),
+		segmented AS (
+			SELECT
+				s.*,
+				CASE
+					WHEN recency >= 4 AND frequency >= 4 THEN 'champions'
+					WHEN recency >= 3 AND frequency >= 3 THEN 'loyal'
+					WHEN recency >= 4 AND frequency = 2 THEN 'potential_loyalist'
+					WHEN recency >= 4 THEN 'new'
+					WHEN recency = 3 THEN 'need_attention'
+					WHEN frequency >= 3 THEN 'at_risk'
+					WHEN recency = 2 THEN 'hibernating'
+					ELSE 'lost'
+				END AS segment
+			FROM scored s
+		)
+		SELECT
+			id, name, phone, address, order_count, lifetime_value, first_order, last_order,
+			recency, frequency, monetary, segment, COUNT(*) OVER ()
+		FROM segmented
+		WHERE ($2::text IS NULL OR segment = $2::text)
+			AND ($3::timestamptz IS NULL OR last_order < $3::timestamptz)
+			AND order_count >= $4
+		ORDER BY ` + sort + ` DESC, id
+		LIMIT $5 OFFSET $6
+	`
+	offset := (filter.Page - 1) * filter.PerPage
+	rows, err := r.db.QueryContext(
+		ctx, query,
+		filter.UserID, filter.Segment, filter.InactiveSince, filter.MinOrders, filter.PerPage, offset,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get customer analytics: %s", err.Error())
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	customers := []models.CustomerAnalytics{}
+	var total uint
+	for rows.Next() {
+		var customer models.CustomerAnalytics
+		err := rows.Scan(
+			&customer.CustomerID, &customer.Name, &customer.Phone, &customer.Address,
+			&customer.OrderCount, &customer.LifetimeValue, &customer.FirstOrderDate, &customer.LastOrderDate,
+			&customer.RecencyScore, &customer.FrequencyScore, &customer.MonetaryScore, &customer.Segment,
+			&total,
+		)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan customer analytics: %s", err.Error())
+			return nil, 0, err
+		}
+		customers = append(customers, customer)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate customer analytics: %s", err.Error())
+		return nil, 0, err
+	}
+
+	// A page past the end has no rows to carry the total.
+	if len(customers) == 0 && filter.Page > 1 {
+		countFilter := filter
+		countFilter.Page, countFilter.PerPage = 1, 1
+		_, total, err = r.GetCustomerAnalytics(ctx, countFilter)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return customers, total, nil
+}
+
+// GetOrderHeatmap returns the hours of the week, in loc, that have orders
+// matching filter. Hours without orders are left out.
+func (r *AnalyticsRepo) GetOrderHeatmap(ctx context.Context, filter models.OrderHeatmapFilter, loc *time.Location) ([]models.HeatmapCell, error) {
+	from := "FROM orders o"
+	revenue := "SUM(o.total_price)"
+	whereConditions := []string{"o.user_id = $1", "o.status = 'confirmed'", "o.order_date >= $2", "o.order_date < $3"}
+	args := []any{filter.UserID, filter.Start, filter.End, loc.String()}
+
+	if filter.ProductID != nil || filter.CategoryID != nil {
+		from = `
+			FROM orders o
+			JOIN order_items oi ON oi.order_id = o.id
+			JOIN products p ON p.id = oi.product_id
+		`
+		revenue = "SUM(oi.total_price)"
+	}
+	if filter.ProductID != nil {
+		args = append(args, *filter.ProductID)
+		whereConditions = append(whereConditions, fmt.Sprintf("p.id = $%d", len(args)))
+	}
+	if filter.CategoryID != nil {
+		args = append(args, *filter.CategoryID)
+		whereConditions = append(whereConditions, fmt.Sprintf("p.category_id = $%d", len(args)))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			EXTRACT(ISODOW FROM o.order_date AT TIME ZONE $4)::int,
+			EXTRACT(HOUR FROM o.order_date AT TIME ZONE $4)::int,
+			COUNT(DISTINCT o.id),
+			COALESCE(%s, 0)
+		%s
+		WHERE %s
+		GROUP BY 1, 2
+	`, revenue, from, strings.Join(whereConditions, " AND "))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get order heatmap: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	cells := []models.HeatmapCell{}
+	for rows.Next() {
+		var cell models.HeatmapCell
+		if err := rows.Scan(&cell.Weekday, &cell.Hour, &cell.OrderCount, &cell.Revenue); err != nil {
+			log.Printf("[ERROR] Failed to scan order heatmap cell: %s", err.Error())
+			return nil, err
+		}
+		cells = append(cells, cell)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate order heatmap: %s", err.Error())
+		return nil, err
+	}
+	return cells, nil
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Cakra17/imphnen/internal/models"
+)
+
+type AuditRepo struct {
+	db *sql.DB
+}
+
+func NewAuditRepo(db *sql.DB) AuditRepo {
+	return AuditRepo{db: db}
+}
+
+func (r *AuditRepo) Create(ctx context.Context, entry *models.AuditLog) error {
+	query := `
+		INSERT INTO audit_logs (
+			id, store_id, actor_id, actor_type, action, method, path,
+			entity_type, entity_id, before, after, status_code, request_id, ip_address
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING created_at
+	`
+	err := r.db.QueryRowContext(
+		ctx, query,
+		entry.ID, entry.StoreID, entry.ActorID, entry.ActorType,
+		entry.Action, entry.Method, entry.Path,
+		entry.EntityType, entry.EntityID,
+		nullableJSON(entry.Before), nullableJSON(entry.After),
+		entry.StatusCode, entry.RequestID, entry.IPAddress,
+	).Scan(&entry.CreatedAt)
+	if err != nil {
+		log.Printf("[ERROR] Failed to create audit log: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *AuditRepo) GetAuditLogs(ctx context.Context, filter models.AuditLogFilter) ([]models.AuditLog, uint, error) {
+	whereConditions := []string{"store_id = $1"}
+	args := []interface{}{filter.StoreID}
+	argCount := 1
+
+	if filter.Action != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("action = $%d", argCount))
+		args = append(args, *filter.Action)
+	}
+
+	if filter.EntityType != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("entity_type = $%d", argCount))
+		args = append(args, *filter.EntityType)
+	}
+
+	if filter.EntityID != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("entity_id = $%d", argCount))
+		args = append(args, *filter.EntityID)
+	}
+
+	if filter.ActorID != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("actor_id = $%d", argCount))
+		args = append(args, *filter.ActorID)
+	}
+
+	if filter.StartDate != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("created_at >= $%d", argCount))
+		args = append(args, *filter.StartDate)
+	}
+
+	if filter.EndDate != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("created_at <= $%d", argCount))
+		args = append(args, *filter.EndDate)
+	}
+
+	whereClause := strings.Join(whereConditions, " AND ")
+
+	var totalCount uint
+	countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM audit_logs WHERE %s`, whereClause)
+	err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&totalCount)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get total count: %s", err.Error())
+		return nil, 0, err
+	}
+
+	offset := (filter.Page - 1) * filter.PerPage
+	argCount++
+	limitArg := argCount
+	argCount++
+	offsetArg := argCount
+
+	query := fmt.Sprintf(`
+		SELECT
+			id, store_id, actor_id, actor_type, action, method, path,
+			entity_type, entity_id, before, after, status_code, request_id, ip_address, created_at
+		FROM audit_logs
+		WHERE %s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, whereClause, limitArg, offsetArg)
+
+	args = append(args, filter.PerPage, offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get audit logs: %s", err.Error())
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	logs := []models.AuditLog{}
+	for rows.Next() {
+		var entry models.AuditLog
+		var before, after []byte
+		err := rows.Scan(
+			&entry.ID, &entry.StoreID, &entry.ActorID, &entry.ActorType,
+			&entry.Action, &entry.Method, &entry.Path,
+			&entry.EntityType, &entry.EntityID, &before, &after,
+			&entry.StatusCode, &entry.RequestID, &entry.IPAddress, &entry.CreatedAt,
+		)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan audit log: %s", err.Error())
+			return nil, 0, err
+		}
+		entry.Before = before
+		entry.After = after
+		logs = append(logs, entry)
+	}
+
+	return logs, totalCount, nil
+}
+
+// nullableJSON keeps empty payloads as SQL NULL instead of an invalid JSONB value.
+func nullableJSON(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
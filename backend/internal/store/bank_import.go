@@ -0,0 +1,454 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/bankimport"
+	"github.com/Cakra17/imphnen/internal/models"
+)
+
+var (
+	ErrImportLineResolved = errors.New("import line already resolved")
+	ErrMatchMismatch      = errors.New("transaction does not match import line")
+	ErrAlreadyMatched     = errors.New("transaction already matched to another line")
+	ErrOrderNotMatchable  = errors.New("order cannot be matched")
+)
+
+type BankImportRepo struct {
+	db *sql.DB
+}
+
+func NewBankImportRepo(db *sql.DB) BankImportRepo {
+	return BankImportRepo{db: db}
+}
+
+// CreateImport stores an import with its lines. Lines whose hash the account
+// already has are skipped and counted in DuplicateCount.
+func (r *BankImportRepo) CreateImport(ctx context.Context, bankImport *models.BankImport, lines []models.BankImportLine) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return err
+	}
+	defer tx.Rollback()
+
+	importQuery := `
+		INSERT INTO bank_imports (id, user_id, account_id, filename, format)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`
+	err = tx.QueryRowContext(
+		ctx, importQuery,
+		bankImport.ID, bankImport.UserID, bankImport.AccountID, bankImport.Filename, bankImport.Format,
+	).Scan(&bankImport.CreatedAt)
+	if err != nil {
+		log.Printf("[ERROR] Failed to create bank import: %s", err.Error())
+		return err
+	}
+
+	lineQuery := `
+		INSERT INTO bank_import_lines (id, import_id, user_id, account_id, line_date, description, amount, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (account_id, hash) DO NOTHING
+	`
+	imported := 0
+	for _, line := range lines {
+		result, err := tx.ExecContext(
+			ctx, lineQuery,
+			line.ID, bankImport.ID, bankImport.UserID, bankImport.AccountID,
+			line.Date, line.Description, line.Amount, line.Hash,
+		)
+		if err != nil {
+			log.Printf("[ERROR] Failed to create bank import line: %s", err.Error())
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+			return err
+		}
+		imported += int(rowsAffected)
+	}
+
+	bankImport.LineCount = imported
+	bankImport.DuplicateCount = len(lines) - imported
+	bankImport.PendingCount = imported
+
+	countQuery := `UPDATE bank_imports SET line_count = $1, duplicate_count = $2 WHERE id = $3`
+	if _, err := tx.ExecContext(ctx, countQuery, bankImport.LineCount, bankImport.DuplicateCount, bankImport.ID); err != nil {
+		log.Printf("[ERROR] Failed to update bank import: %s", err.Error())
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+const bankImportColumns = `
+	i.id, i.user_id, i.account_id, i.filename, i.format, i.line_count, i.duplicate_count,
+	(SELECT COUNT(*) FROM bank_import_lines l WHERE l.import_id = i.id AND l.status = 'pending'),
+	i.created_at
+`
+
+func scanBankImport(row interface{ Scan(...any) error }) (models.BankImport, error) {
+	var bankImport models.BankImport
+	err := row.Scan(
+		&bankImport.ID, &bankImport.UserID, &bankImport.AccountID, &bankImport.Filename, &bankImport.Format,
+		&bankImport.LineCount, &bankImport.DuplicateCount, &bankImport.PendingCount, &bankImport.CreatedAt,
+	)
+	return bankImport, err
+}
+
+// GetImports returns the user's imports, newest first.
+func (r *BankImportRepo) GetImports(ctx context.Context, userID string) ([]models.BankImport, error) {
+	query := `SELECT ` + bankImportColumns + ` FROM bank_imports i WHERE i.user_id = $1 ORDER BY i.created_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get bank imports: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	imports := []models.BankImport{}
+	for rows.Next() {
+		bankImport, err := scanBankImport(rows)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan bank import: %s", err.Error())
+			return nil, err
+		}
+		imports = append(imports, bankImport)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate bank imports: %s", err.Error())
+		return nil, err
+	}
+	return imports, nil
+}
+
+func (r *BankImportRepo) GetImportByID(ctx context.Context, id string, userID string) (*models.BankImport, error) {
+	query := `SELECT ` + bankImportColumns + ` FROM bank_imports i WHERE i.id = $1 AND i.user_id = $2`
+	bankImport, err := scanBankImport(r.db.QueryRowContext(ctx, query, id, userID))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[ERROR] Failed to get bank import: %s", err.Error())
+		}
+		return nil, err
+	}
+	return &bankImport, nil
+}
+
+const bankImportLineColumns = `
+	id, import_id, user_id, account_id, line_date, description, amount, hash,
+	status, transaction_id, resolved_at, created_at
+`
+
+func scanBankImportLine(row interface{ Scan(...any) error }) (models.BankImportLine, error) {
+	var line models.BankImportLine
+	err := row.Scan(
+		&line.ID, &line.ImportID, &line.UserID, &line.AccountID, &line.Date, &line.Description, &line.Amount,
+		&line.Hash, &line.Status, &line.TransactionID, &line.ResolvedAt, &line.CreatedAt,
+	)
+	return line, err
+}
+
+// GetLines returns the lines of an import in statement order, optionally only
+// those with the given status.
+func (r *BankImportRepo) GetLines(ctx context.Context, importID string, userID string, status *string) ([]models.BankImportLine, error) {
+	query := `
+		SELECT ` + bankImportLineColumns + `
+		FROM bank_import_lines
+		WHERE import_id = $1 AND user_id = $2 AND ($3::text IS NULL OR status = $3::text)
+		ORDER BY line_date, created_at, id
+	`
+	rows, err := r.db.QueryContext(ctx, query, importID, userID, status)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get bank import lines: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []models.BankImportLine{}
+	for rows.Next() {
+		line, err := scanBankImportLine(rows)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan bank import line: %s", err.Error())
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate bank import lines: %s", err.Error())
+		return nil, err
+	}
+	return lines, nil
+}
+
+func (r *BankImportRepo) GetLine(ctx context.Context, id string, userID string) (*models.BankImportLine, error) {
+	query := `SELECT ` + bankImportLineColumns + ` FROM bank_import_lines WHERE id = $1 AND user_id = $2`
+	line, err := scanBankImportLine(r.db.QueryRowContext(ctx, query, id, userID))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[ERROR] Failed to get bank import line: %s", err.Error())
+		}
+		return nil, err
+	}
+	return &line, nil
+}
+
+// GetSuggestions finds records with the line's amount within
+// bankimport.MatchWindowDays days of it, in the merchant's time zone:
+// non-voided transactions of the same type that are not in another account
+// and not matched yet, and, for money coming in, confirmed orders without a
+// transaction. Confidence is left for the caller to fill in.
+func (r *BankImportRepo) GetSuggestions(ctx context.Context, line models.BankImportLine, loc *time.Location) ([]models.MatchSuggestion, error) {
+	transactionQuery := `
+		SELECT
+			t.id, t.receipt_id, t.order_id, t.transaction_date, t.amount,
+			CONCAT_WS(' ', c.name, r.store_name, cu.name)
+		FROM transactions t
+		LEFT JOIN transaction_categories c ON c.id = t.category_id
+		LEFT JOIN receipts r ON r.id = t.receipt_id
+		LEFT JOIN orders o ON o.id = t.order_id
+		LEFT JOIN customers cu ON cu.id = o.customer_id
+		WHERE t.user_id = $1 AND t.voided_at IS NULL AND t.type = $2 AND t.amount = $3
+			AND (t.account_id IS NULL OR t.account_id = $4)
+			AND (t.transaction_date AT TIME ZONE $5)::date BETWEEN $6::date - $7::int AND $6::date + $7::int
+			AND NOT EXISTS (SELECT 1 FROM bank_import_lines l WHERE l.transaction_id = t.id)
+		ORDER BY t.transaction_date
+	`
+	amount := line.Amount
+	if amount < 0 {
+		amount = -amount
+	}
+	day := line.Date.Format("2006-01-02")
+
+	rows, err := r.db.QueryContext(
+		ctx, transactionQuery,
+		line.UserID, line.TransactionType(), amount, line.AccountID, loc.String(), day, bankimport.MatchWindowDays,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get match suggestions: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []models.MatchSuggestion{}
+	for rows.Next() {
+		suggestion := models.MatchSuggestion{Kind: models.MatchKindTransaction}
+		err := rows.Scan(
+			&suggestion.TransactionID, &suggestion.ReceiptID, &suggestion.OrderID,
+			&suggestion.Date, &suggestion.Amount, &suggestion.Description,
+		)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan match suggestion: %s", err.Error())
+			return nil, err
+		}
+		switch {
+		case suggestion.ReceiptID != nil:
+			suggestion.Kind = models.MatchKindReceipt
+		case suggestion.OrderID != nil:
+			suggestion.Kind = models.MatchKindOrder
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate match suggestions: %s", err.Error())
+		return nil, err
+	}
+
+	if line.Amount < 0 {
+		return suggestions, nil
+	}
+
+	orderQuery := `
+		SELECT o.id, o.order_date, o.total_price, COALESCE(cu.name, '')
+		FROM orders o
+		LEFT JOIN customers cu ON cu.id = o.customer_id
+		WHERE o.user_id = $1 AND o.status = 'confirmed' AND o.total_price = $2
+			AND (o.order_date AT TIME ZONE $3)::date BETWEEN $4::date - $5::int AND $4::date + $5::int
+			AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.order_id = o.id AND t.voided_at IS NULL)
+		ORDER BY o.order_date
+	`
+	orderRows, err := r.db.QueryContext(ctx, orderQuery, line.UserID, amount, loc.String(), day, bankimport.MatchWindowDays)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get order match suggestions: %s", err.Error())
+		return nil, err
+	}
+	defer orderRows.Close()
+
+	for orderRows.Next() {
+		suggestion := models.MatchSuggestion{Kind: models.MatchKindOrder}
+		err := orderRows.Scan(&suggestion.OrderID, &suggestion.Date, &suggestion.Amount, &suggestion.Description)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan order match suggestion: %s", err.Error())
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	if err = orderRows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate order match suggestions: %s", err.Error())
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// MatchTransaction links a pending line to an existing transaction and moves
+// the transaction into the line's account. The transaction must not be voided
+// or in another account and must have the line's type and amount.
+func (r *BankImportRepo) MatchTransaction(ctx context.Context, line models.BankImportLine, transactionID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return err
+	}
+	defer tx.Rollback()
+
+	amount := line.Amount
+	if amount < 0 {
+		amount = -amount
+	}
+
+	updateQuery := `
+		UPDATE transactions SET account_id = $1
+		WHERE id = $2 AND user_id = $3 AND voided_at IS NULL AND type = $4 AND amount = $5
+			AND (account_id IS NULL OR account_id = $1)
+	`
+	result, err := tx.ExecContext(ctx, updateQuery, line.AccountID, transactionID, line.UserID, line.TransactionType(), amount)
+	if err != nil {
+		log.Printf("[ERROR] Failed to assign transaction account: %s", err.Error())
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrMatchMismatch
+	}
+
+	if err := resolveLine(ctx, tx, line.ID, models.BankImportLineMatched, &transactionID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// CreateFromLine records transaction, in the line's account, and resolves the
+// line with it. A transaction for an order counts as a match; the order must
+// be confirmed and not have a transaction yet.
+func (r *BankImportRepo) CreateFromLine(ctx context.Context, line models.BankImportLine, transaction *models.Transaction) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return err
+	}
+	defer tx.Rollback()
+
+	status := models.BankImportLineCreated
+	if transaction.OrderID != nil {
+		orderQuery := `
+			SELECT o.id FROM orders o
+			WHERE o.id = $1 AND o.user_id = $2 AND o.status = 'confirmed' AND o.total_price = $3
+				AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.order_id = o.id AND t.voided_at IS NULL)
+			FOR UPDATE
+		`
+		var orderID string
+		err := tx.QueryRowContext(ctx, orderQuery, *transaction.OrderID, transaction.UserID, transaction.Amount).Scan(&orderID)
+		if err == sql.ErrNoRows {
+			return ErrOrderNotMatchable
+		}
+		if err != nil {
+			log.Printf("[ERROR] Failed to lock order: %s", err.Error())
+			return err
+		}
+		status = models.BankImportLineMatched
+	}
+
+	insertQuery := `
+		INSERT INTO transactions (id, user_id, type, source, amount, transaction_date, order_id, category_id, account_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at
+	`
+	err = tx.QueryRowContext(
+		ctx, insertQuery,
+		transaction.ID, transaction.UserID, transaction.Type, transaction.Source, transaction.Amount,
+		transaction.TransactionDate, transaction.OrderID, transaction.CategoryID, transaction.AccountID,
+	).Scan(&transaction.CreatedAt)
+	if err != nil {
+		log.Printf("[ERROR] Failed to create transaction: %s", err.Error())
+		return err
+	}
+
+	if err := resolveLine(ctx, tx, line.ID, status, &transaction.ID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// IgnoreLine marks a pending line as not needing a transaction.
+func (r *BankImportRepo) IgnoreLine(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := resolveLine(ctx, tx, id, models.BankImportLineIgnored, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// resolveLine moves a pending line to status. It returns
+// ErrImportLineResolved when the line was resolved in the meantime and
+// ErrAlreadyMatched when another line already has the transaction.
+func resolveLine(ctx context.Context, tx *sql.Tx, id, status string, transactionID *string) error {
+	query := `
+		UPDATE bank_import_lines SET status = $1, transaction_id = $2, resolved_at = NOW()
+		WHERE id = $3 AND status = 'pending'
+	`
+	result, err := tx.ExecContext(ctx, query, status, transactionID, id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrAlreadyMatched
+		}
+		log.Printf("[ERROR] Failed to resolve bank import line: %s", err.Error())
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrImportLineResolved
+	}
+	return nil
+}
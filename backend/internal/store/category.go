@@ -0,0 +1,202 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/lib/pq"
+)
+
+var ErrCategoryExists = errors.New("category already exists")
+
+type CategoryRepo struct {
+	db *sql.DB
+}
+
+func NewCategoryRepo(db *sql.DB) CategoryRepo {
+	return CategoryRepo{db: db}
+}
+
+func (r *CategoryRepo) Create(ctx context.Context, category *models.TransactionCategory) error {
+	query := `
+		INSERT INTO transaction_categories (id, user_id, name, type, is_cogs)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`
+	err := r.db.QueryRowContext(ctx, query, category.ID, category.UserID, category.Name, category.Type, category.IsCOGS).
+		Scan(&category.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrCategoryExists
+		}
+		log.Printf("[ERROR] Failed to create category: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *CategoryRepo) GetCategories(ctx context.Context, userID string, categoryType *string) ([]models.TransactionCategory, error) {
+	query := `
+		SELECT id, user_id, name, type, is_default, is_cogs, created_at
+		FROM transaction_categories
+		WHERE user_id = $1 AND ($2::type IS NULL OR type = $2::type)
+		ORDER BY type, is_default DESC, name
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, categoryType)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get categories: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.TransactionCategory{}
+	for rows.Next() {
+		var category models.TransactionCategory
+		err := rows.Scan(
+			&category.ID, &category.UserID, &category.Name,
+			&category.Type, &category.IsDefault, &category.IsCOGS, &category.CreatedAt,
+		)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan category: %s", err.Error())
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate categories: %s", err.Error())
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+func (r *CategoryRepo) GetCategoryByID(ctx context.Context, id string, userID string) (*models.TransactionCategory, error) {
+	query := `
+		SELECT id, user_id, name, type, is_default, is_cogs, created_at
+		FROM transaction_categories
+		WHERE id = $1 AND user_id = $2
+	`
+	var category models.TransactionCategory
+	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(
+		&category.ID, &category.UserID, &category.Name,
+		&category.Type, &category.IsDefault, &category.IsCOGS, &category.CreatedAt,
+	)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[ERROR] Failed to get category: %s", err.Error())
+		}
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *CategoryRepo) UpdateCategory(ctx context.Context, id string, userID string, name string, isCOGS bool) (bool, error) {
+	query := `UPDATE transaction_categories SET name = $1, is_cogs = $2 WHERE id = $3 AND user_id = $4`
+	result, err := r.db.ExecContext(ctx, query, name, isCOGS, id, userID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return false, ErrCategoryExists
+		}
+		log.Printf("[ERROR] Failed to update category: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// DeleteCategory removes a category. Transactions and receipts that used it
+// become uncategorised through ON DELETE SET NULL.
+func (r *CategoryRepo) DeleteCategory(ctx context.Context, id string, userID string) (bool, error) {
+	query := `DELETE FROM transaction_categories WHERE id = $1 AND user_id = $2`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to delete category: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// GetCategoryStats totals non-voided transactions per category and type.
+// Transactions without a category are grouped under a nil category ID. endDate
+// is exclusive.
+func (r *CategoryRepo) GetCategoryStats(ctx context.Context, userID string, startDate, endDate time.Time) ([]models.CategoryStats, error) {
+	query := `
+		SELECT c.id, COALESCE(c.name, 'Tanpa Kategori'), t.type, SUM(t.amount), COUNT(*)
+		FROM transactions t
+		LEFT JOIN transaction_categories c ON c.id = t.category_id
+		WHERE t.user_id = $1 AND t.transaction_date >= $2 AND t.transaction_date < $3 AND t.voided_at IS NULL
+		GROUP BY c.id, c.name, t.type
+		ORDER BY t.type, SUM(t.amount) DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, startDate, endDate)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get category stats: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []models.CategoryStats{}
+	for rows.Next() {
+		var stat models.CategoryStats
+		err := rows.Scan(&stat.CategoryID, &stat.CategoryName, &stat.Type, &stat.TotalAmount, &stat.TransactionCount)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan category stats: %s", err.Error())
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate category stats: %s", err.Error())
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// seedDefaultCategories creates models.DefaultTransactionCategories for a new
+// user inside the registration transaction.
+func seedDefaultCategories(ctx context.Context, tx *sql.Tx, userID string) error {
+	names := make([]string, len(models.DefaultTransactionCategories))
+	types := make([]string, len(models.DefaultTransactionCategories))
+	cogs := make([]bool, len(models.DefaultTransactionCategories))
+	for i, category := range models.DefaultTransactionCategories {
+		names[i] = category.Name
+		types[i] = category.Type
+		cogs[i] = category.IsCOGS
+	}
+
+	query := `
+		INSERT INTO transaction_categories (user_id, name, type, is_default, is_cogs)
+		SELECT $1, d.name, d.type::type, TRUE, d.is_cogs
+		FROM unnest($2::text[], $3::text[], $4::boolean[]) AS d(name, type, is_cogs)
+		ON CONFLICT (user_id, name, type) DO NOTHING
+	`
+	_, err := tx.ExecContext(ctx, query, userID, pq.Array(names), pq.Array(types), pq.Array(cogs))
+	if err != nil {
+		log.Printf("[ERROR] Failed to create default categories: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
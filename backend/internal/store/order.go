@@ -43,7 +43,7 @@ func (r *OrderRepo) CreateOrder(ctx context.Context, order *models.Order, items
 	}
 
 	query := `
-		SELECT id, stock, price 
+		SELECT id, stock, price, cost_price
 		FROM products 
 		WHERE id = ANY($1) AND user_id = $2
 		FOR UPDATE
@@ -57,18 +57,20 @@ func (r *OrderRepo) CreateOrder(ctx context.Context, order *models.Order, items
 
 	productStockMap := make(map[string]int)
 	productPriceMap := make(map[string]float64)
+	productCostMap := make(map[string]float64)
 	scannedProducts := 0
 
 	for rows.Next() {
 		var productID string
 		var stock int
-		var price float64
-		if err := rows.Scan(&productID, &stock, &price); err != nil {
+		var price, cost float64
+		if err := rows.Scan(&productID, &stock, &price, &cost); err != nil {
 			log.Printf("[ERROR] Failed to scan product: %s", err.Error())
 			return err
 		}
 		productStockMap[productID] = stock
 		productPriceMap[productID] = price
+		productCostMap[productID] = cost
 		scannedProducts++
 	}
 
@@ -103,6 +105,7 @@ func (r *OrderRepo) CreateOrder(ctx context.Context, order *models.Order, items
 	for i := range items {
 		price := productPriceMap[items[i].ProductID]
 		items[i].TotalPrice = price * float64(items[i].Quantity)
+		items[i].UnitCost = productCostMap[items[i].ProductID]
 		totalPrice += items[i].TotalPrice
 
 		updateStockQuery := `
@@ -147,13 +150,13 @@ func (r *OrderRepo) CreateOrder(ctx context.Context, order *models.Order, items
 
 	for _, item := range items {
 		itemQuery := `
-			INSERT INTO order_items(id, order_id, product_id, quantity, total_price, created_at)
-			VALUES ($1, $2, $3, $4, $5, $6)
+			INSERT INTO order_items(id, order_id, product_id, quantity, total_price, unit_cost, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
 		`
 		_, err = tx.ExecContext(
 			ctx, itemQuery,
 			item.ID, order.ID, item.ProductID,
-			item.Quantity, item.TotalPrice, item.CreatedAt,
+			item.Quantity, item.TotalPrice, item.UnitCost, item.CreatedAt,
 		)
 		if err != nil {
 			log.Printf("[ERROR] Failed to create order item: %s", err.Error())
@@ -209,7 +212,7 @@ func (r *OrderRepo) GetOrderByID(ctx context.Context, orderID string) (*models.O
 func (r *OrderRepo) getOrderItems(ctx context.Context, orderID string) ([]models.OrderItem, error) {
 	query := `
 		SELECT 
-			oi.id, oi.order_id, oi.product_id, oi.quantity, oi.total_price, oi.created_at,
+			oi.id, oi.order_id, oi.product_id, oi.quantity, oi.total_price, oi.unit_cost, oi.created_at,
 			p.id, p.name, p.price, p.stock, p.image_url
 		FROM order_items oi
 		LEFT JOIN products p ON oi.product_id = p.id
@@ -233,7 +236,7 @@ func (r *OrderRepo) getOrderItems(ctx context.Context, orderID string) ([]models
 		var productStock sql.NullInt32
 
 		err := rows.Scan(
-			&item.ID, &item.OrderID, &item.ProductID, &item.Quantity, &item.TotalPrice, &item.CreatedAt,
+			&item.ID, &item.OrderID, &item.ProductID, &item.Quantity, &item.TotalPrice, &item.UnitCost, &item.CreatedAt,
 			&productID, &productName, &productPrice, &productStock, &productImageURL,
 		)
 		if err != nil {
@@ -518,6 +521,12 @@ func (r *OrderRepo) UpdateOrderStatus(ctx context.Context, orderID string, newSt
 		}
 	}
 
+	if newStatus == models.OrderStatusCancelled {
+		if err = voidLinkedTransactions(ctx, tx, "order_id", orderID, "Pesanan dibatalkan"); err != nil {
+			return err
+		}
+	}
+
 	if err = tx.Commit(); err != nil {
 		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
 		return err
@@ -526,6 +535,8 @@ func (r *OrderRepo) UpdateOrderStatus(ctx context.Context, orderID string, newSt
 	return nil
 }
 
+// DeleteOrder removes a pending or cancelled order and its items. The voided
+// transactions of a cancelled order stay in the books without the order link.
 func (r *OrderRepo) DeleteOrder(ctx context.Context, orderID string) error {
 	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
@@ -639,3 +650,66 @@ func (r *OrderRepo) GetCustomerByID(ctx context.Context, customerID int) (*model
 
 	return &customer, nil
 }
+
+// ExportOrders streams the line items of the orders matching filter to fn,
+// one row per item, ordered by order date. It stops at the first error
+// returned by fn.
+func (r *OrderRepo) ExportOrders(
+	ctx context.Context, filter models.OrderExportFilter, fn func(models.OrderExportRow) error,
+) error {
+	whereConditions := []string{"o.user_id = $1", "o.order_date >= $2", "o.order_date < $3"}
+	args := []any{filter.UserID, filter.StartDate, filter.EndDate}
+	argCount := 3
+
+	if filter.CustomerID != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("o.customer_id = $%d", argCount))
+		args = append(args, *filter.CustomerID)
+	}
+
+	if filter.Status != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("o.status = $%d", argCount))
+		args = append(args, *filter.Status)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			o.id, o.order_date, o.status, c.name, c.phone, o.total_price,
+			p.name, oi.quantity, oi.total_price
+		FROM orders o
+		LEFT JOIN customers c ON c.id = o.customer_id
+		LEFT JOIN order_items oi ON oi.order_id = o.id
+		LEFT JOIN products p ON p.id = oi.product_id
+		WHERE %s
+		ORDER BY o.order_date, o.id, oi.created_at
+	`, strings.Join(whereConditions, " AND "))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("[ERROR] Failed to export orders: %s", err.Error())
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row models.OrderExportRow
+		err := rows.Scan(
+			&row.OrderID, &row.OrderDate, &row.Status, &row.CustomerName, &row.CustomerPhone,
+			&row.OrderTotal, &row.ProductName, &row.Quantity, &row.ItemTotal,
+		)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan exported order: %s", err.Error())
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate exported orders: %s", err.Error())
+		return err
+	}
+	return nil
+}
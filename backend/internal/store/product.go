@@ -3,11 +3,14 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"log"
 
 	"github.com/Cakra17/imphnen/internal/models"
 )
 
+var ErrReceiptItemRestocked = errors.New("receipt item already restocked")
+
 type ProductRepo struct {
 	db *sql.DB
 }
@@ -19,8 +22,8 @@ func NewProductRepo(db *sql.DB) ProductRepo {
 func (r *ProductRepo) AddProduct(ctx context.Context, product *models.Product) error {
 	query := `
 		INSERT INTO 
-			products (id, user_id, name, price, stock, image_url, public_id)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			products (id, user_id, name, price, stock, image_url, public_id, cost_price, category_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING created_at
 	`
 	err := r.db.QueryRowContext(
@@ -28,7 +31,8 @@ func (r *ProductRepo) AddProduct(ctx context.Context, product *models.Product) e
 		product.ID, product.UserID,
 		product.Name, product.Price,
 		product.Stock, product.ImageURL,
-		product.PublicID,
+		product.PublicID, product.CostPrice,
+		product.CategoryID,
 	).Scan(&product.CreatedAt)
 	if err != nil {
 		log.Printf("[ERROR] Failed to add product: %s", err.Error())
@@ -40,7 +44,7 @@ func (r *ProductRepo) AddProduct(ctx context.Context, product *models.Product) e
 func (r *ProductRepo) GetUserProductsPaginated(ctx context.Context, userID string, page, perPage uint) ([]models.Product, uint, error) {
 	offset := (page - 1) * perPage
 	query := `
-		SELECT id, user_id, name, price, stock, image_url, public_id
+		SELECT id, user_id, name, price, stock, image_url, public_id, cost_price, category_id
 		FROM products
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -61,7 +65,8 @@ func (r *ProductRepo) GetUserProductsPaginated(ctx context.Context, userID strin
 			&product.ID, &product.UserID,
 			&product.Name, &product.Price,
 			&product.Stock, &product.ImageURL,
-			&product.PublicID,
+			&product.PublicID, &product.CostPrice,
+			&product.CategoryID,
 		)
 		if err != nil {
 			log.Printf("[ERROR] Failed to scan receipt: %s", err.Error())
@@ -84,7 +89,7 @@ func (r *ProductRepo) GetUserProductsPaginated(ctx context.Context, userID strin
 
 func (r *ProductRepo) GetProductByID(ctx context.Context, productID string) (models.Product, error) {
 	query := `
-		SELECT id, user_id, name, price, stock, image_url, public_id
+		SELECT id, user_id, name, price, stock, image_url, public_id, cost_price, category_id
 		FROM products
 		WHERE id = $1
 		LIMIT 1
@@ -94,7 +99,8 @@ func (r *ProductRepo) GetProductByID(ctx context.Context, productID string) (mod
 		&product.ID, &product.UserID,
 		&product.Name, &product.Price,
 		&product.Stock, &product.ImageURL,
-		&product.PublicID,
+		&product.PublicID, &product.CostPrice,
+		&product.CategoryID,
 	)
 	if err != nil {
 		log.Printf("[ERROR] Failed to get product: %s", err.Error())
@@ -106,10 +112,14 @@ func (r *ProductRepo) GetProductByID(ctx context.Context, productID string) (mod
 func (r *ProductRepo) UpdateProduct(ctx context.Context, product models.Product) error {
 	query := `
 		UPDATE products 
-		SET name=$1, price=$2, stock=$3, image_url=$4, public_id=$5
-		WHERE id = $6
+		SET name=$1, price=$2, stock=$3, image_url=$4, public_id=$5, cost_price=$6, category_id=$7
+		WHERE id = $8
 	`
-	_, err := r.db.ExecContext(ctx, query, product.Name, product.Price, product.Stock, product.ImageURL, product.PublicID, product.ID)
+	_, err := r.db.ExecContext(
+		ctx, query,
+		product.Name, product.Price, product.Stock, product.ImageURL, product.PublicID,
+		product.CostPrice, product.CategoryID, product.ID,
+	)
 	if err != nil {
 		log.Printf("[ERROR] Failed to update product: %s", err.Error())
 		return err
@@ -128,3 +138,105 @@ func (r *ProductRepo) DeleteProduct(ctx context.Context, productID string) error
 	}
 	return nil
 }
+
+// RestockProduct adds restock.Quantity units to the product and moves its
+// cost price to the weighted average of the units in stock and the new ones.
+// When the product has no stock left the new unit cost replaces it. The
+// stock and cost before and after are filled into restock.
+func (r *ProductRepo) RestockProduct(ctx context.Context, restock *models.ProductRestock) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return err
+	}
+	defer tx.Rollback()
+
+	lockQuery := `SELECT stock, cost_price FROM products WHERE id = $1 AND user_id = $2 FOR UPDATE`
+	err = tx.QueryRowContext(ctx, lockQuery, restock.ProductID, restock.UserID).Scan(&restock.StockBefore, &restock.CostBefore)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[ERROR] Failed to lock product: %s", err.Error())
+		}
+		return err
+	}
+
+	updateQuery := `
+		UPDATE products
+		SET
+			cost_price = CASE
+				WHEN stock > 0 THEN ROUND((stock * cost_price + $1 * $2) / (stock + $1), 2)
+				ELSE $2
+			END,
+			stock = stock + $1
+		WHERE id = $3
+		RETURNING cost_price
+	`
+	err = tx.QueryRowContext(ctx, updateQuery, restock.Quantity, restock.UnitCost, restock.ProductID).Scan(&restock.CostAfter)
+	if err != nil {
+		log.Printf("[ERROR] Failed to restock product: %s", err.Error())
+		return err
+	}
+
+	insertQuery := `
+		INSERT INTO product_restocks
+			(id, user_id, product_id, receipt_item_id, quantity, unit_cost, stock_before, cost_before, cost_after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at
+	`
+	err = tx.QueryRowContext(
+		ctx, insertQuery,
+		restock.ID, restock.UserID, restock.ProductID, restock.ReceiptItemID, restock.Quantity,
+		restock.UnitCost, restock.StockBefore, restock.CostBefore, restock.CostAfter,
+	).Scan(&restock.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrReceiptItemRestocked
+		}
+		log.Printf("[ERROR] Failed to record product restock: %s", err.Error())
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// GetRestocks returns the restocks of a product, newest first.
+func (r *ProductRepo) GetRestocks(ctx context.Context, productID string, userID string) ([]models.ProductRestock, error) {
+	query := `
+		SELECT
+			id, user_id, product_id, receipt_item_id, quantity, unit_cost,
+			stock_before, cost_before, cost_after, created_at
+		FROM product_restocks
+		WHERE product_id = $1 AND user_id = $2
+		ORDER BY created_at DESC, id DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, productID, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get product restocks: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	restocks := []models.ProductRestock{}
+	for rows.Next() {
+		var restock models.ProductRestock
+		err := rows.Scan(
+			&restock.ID, &restock.UserID, &restock.ProductID, &restock.ReceiptItemID, &restock.Quantity,
+			&restock.UnitCost, &restock.StockBefore, &restock.CostBefore, &restock.CostAfter, &restock.CreatedAt,
+		)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan product restock: %s", err.Error())
+			return nil, err
+		}
+		restocks = append(restocks, restock)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate product restocks: %s", err.Error())
+		return nil, err
+	}
+	return restocks, nil
+}
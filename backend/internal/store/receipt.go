@@ -3,9 +3,18 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"time"
 
 	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrReceiptNotProcessing  = errors.New("receipt is not processing")
+	ErrReceiptNotConfirmable = errors.New("receipt is not a complete draft")
 )
 
 type ReceiptRepo struct {
@@ -16,30 +25,118 @@ func NewReceiptRepo(db *sql.DB) ReceiptRepo {
 	return ReceiptRepo{db: db}
 }
 
-func (r *ReceiptRepo) Create(ctx context.Context, receipt *models.Receipt) error {
+// EnqueueReceipt stores a processing receipt, with the hashes of its image,
+// together with the job that reads and stores the image.
+func (r *ReceiptRepo) EnqueueReceipt(ctx context.Context, receipt *models.Receipt, job models.ReceiptJob) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
 		INSERT INTO 
-			receipts (id, user_id, total_items, total_price, store_name, image_url, public_id) 
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING created_at
+			receipts (
+				id, user_id, total_items, total_price, store_name, image_url, public_id, category_id, account_id,
+				status, content_hash, image_hash, allow_duplicate
+			)
+			VALUES ($1, $2, 0, 0, '', '', '', $3, $4, 'processing', $5, $6, $7)
+		RETURNING status, created_at
 	`
-	err := r.db.QueryRowContext(ctx, query,
-		receipt.ID, receipt.UserID, receipt.TotalItems,
-		receipt.TotalPrice, receipt.StoreName,
-		receipt.ImageURL, receipt.PublicID,
-	).Scan(&receipt.CreatedAt)
+	err = tx.QueryRowContext(
+		ctx, query, receipt.ID, receipt.UserID, receipt.CategoryID, receipt.AccountID,
+		receipt.ContentHash, receipt.ImageHash, receipt.AllowDuplicate,
+	).Scan(&receipt.Status, &receipt.CreatedAt)
 	if err != nil {
 		log.Printf("[ERROR] Failed to create receipt: %s", err.Error())
 		return err
 	}
+
+	jobQuery := `
+		INSERT INTO receipt_jobs (id, receipt_id, user_id, image, filename)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err = tx.ExecContext(ctx, jobQuery, job.ID, receipt.ID, receipt.UserID, job.Image, job.Filename)
+	if err != nil {
+		log.Printf("[ERROR] Failed to create receipt job: %s", err.Error())
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return err
+	}
 	return nil
 }
 
-func (r *ReceiptRepo) CreateItems(ctx context.Context, items []models.ReceiptItem) error {
+// FindUploadDuplicate returns the receipt of the merchant that an upload
+// seems to repeat: one with the same file, or, when imageHash is set, one
+// whose image hash is at most maxDistance bits away. Voided and failed
+// receipts are skipped. It returns nil when there is none.
+func (r *ReceiptRepo) FindUploadDuplicate(ctx context.Context, userID string, contentHash string, imageHash *int64, maxDistance int) (*models.ReceiptDuplicate, error) {
+	query := `
+		SELECT id, COALESCE(content_hash = $2, FALSE) FROM receipts
+		WHERE user_id = $1 AND voided_at IS NULL AND status <> 'failed'
+			AND (
+				content_hash = $2
+				OR length(replace((image_hash # $3::bigint)::bit(64)::text, '0', '')) <= $4
+			)
+		ORDER BY 2 DESC, created_at
+		LIMIT 1
+	`
+	var receiptID string
+	var sameContent bool
+	err := r.db.QueryRowContext(ctx, query, userID, contentHash, imageHash, maxDistance).Scan(&receiptID, &sameContent)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to find duplicate receipt: %s", err.Error())
+		return nil, err
+	}
+
+	duplicate := models.ReceiptDuplicate{ReceiptID: receiptID, Match: models.ReceiptDuplicateImage}
+	if sameContent {
+		duplicate.Match = models.ReceiptDuplicateContent
+	}
+	return &duplicate, nil
+}
+
+// ClaimReceiptJob locks the oldest due job for lease and counts the attempt.
+// A job whose lock has expired is due again, so a job is not lost when its
+// worker dies. It returns nil when no job is due.
+func (r *ReceiptRepo) ClaimReceiptJob(ctx context.Context, lease time.Duration) (*models.ReceiptJob, error) {
 	query := `
-		INSERT INTO receipt_items (id, receipt_id, name, price) 
-		VALUES ($1, $2, $3, $4)
+		UPDATE receipt_jobs
+		SET attempts = attempts + 1, locked_until = NOW() + $1::interval
+		WHERE id = (
+			SELECT id FROM receipt_jobs
+			WHERE run_at <= NOW() AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, receipt_id, user_id, image, filename, attempts
 	`
+	var job models.ReceiptJob
+	err := r.db.QueryRowContext(ctx, query, fmt.Sprintf("%d seconds", int(lease.Seconds()))).Scan(
+		&job.ID, &job.ReceiptID, &job.UserID, &job.Image, &job.Filename, &job.Attempts,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to claim receipt job: %s", err.Error())
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CompleteReceiptJob fills in the receipt read by a job as a draft, flags it
+// when it repeats another receipt, creates its items and removes the job, all
+// or nothing. It returns ErrReceiptNotProcessing when the receipt is gone.
+func (r *ReceiptRepo) CompleteReceiptJob(ctx context.Context, jobID string, receipt *models.Receipt, items []models.ReceiptItem) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
@@ -47,19 +144,86 @@ func (r *ReceiptRepo) CreateItems(ctx context.Context, items []models.ReceiptIte
 	}
 	defer tx.Rollback()
 
-	for _, item := range items {
-		_, err := tx.ExecContext(ctx, query, item.ID, item.ReceiptID, item.Name, item.Price)
-		if err != nil {
-			log.Printf("[ERROR] Failed to create receipt item: %s", err.Error())
+	query := `
+		UPDATE receipts
+		SET store_name = $1, total_items = $2, total_price = $3, image_url = $4, public_id = $5,
+			invoice_date = $6, status = 'draft', failure_reason = NULL,
+			duplicate_of = ` + receiptDuplicateOf("$1", "$6", "$3") + `
+		WHERE id = $7 AND status = 'processing'
+		RETURNING category_id, status, duplicate_of, allow_duplicate, created_at
+	`
+	err = tx.QueryRowContext(
+		ctx, query,
+		receipt.StoreName, receipt.TotalItems, receipt.TotalPrice, receipt.ImageURL, receipt.PublicID,
+		receipt.InvoiceDate, receipt.ID,
+	).Scan(&receipt.CategoryID, &receipt.Status, &receipt.DuplicateOf, &receipt.AllowDuplicate, &receipt.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrReceiptNotProcessing
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to complete receipt: %s", err.Error())
+		return err
+	}
+
+	for i := range items {
+		if err := insertReceiptItem(ctx, tx, &items[i]); err != nil {
 			return err
 		}
 	}
 
+	if _, err := tx.ExecContext(ctx, `DELETE FROM receipt_jobs WHERE id = $1`, jobID); err != nil {
+		log.Printf("[ERROR] Failed to delete receipt job: %s", err.Error())
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
 		return err
 	}
+	return nil
+}
+
+// RetryReceiptJob releases a job so it is claimed again at runAt.
+func (r *ReceiptRepo) RetryReceiptJob(ctx context.Context, jobID string, runAt time.Time, lastError string) error {
+	query := `
+		UPDATE receipt_jobs SET run_at = $1, locked_until = NULL, last_error = $2
+		WHERE id = $3
+	`
+	if _, err := r.db.ExecContext(ctx, query, runAt, lastError, jobID); err != nil {
+		log.Printf("[ERROR] Failed to reschedule receipt job: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// FailReceiptJob marks the receipt of a job that ran out of attempts as
+// failed and removes the job.
+func (r *ReceiptRepo) FailReceiptJob(ctx context.Context, job models.ReceiptJob, reason string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return err
+	}
+	defer tx.Rollback()
 
+	query := `
+		UPDATE receipts SET status = 'failed', failure_reason = $1
+		WHERE id = $2 AND status = 'processing'
+	`
+	if _, err := tx.ExecContext(ctx, query, reason, job.ReceiptID); err != nil {
+		log.Printf("[ERROR] Failed to mark receipt as failed: %s", err.Error())
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM receipt_jobs WHERE id = $1`, job.ID); err != nil {
+		log.Printf("[ERROR] Failed to delete receipt job: %s", err.Error())
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return err
+	}
 	return nil
 }
 
@@ -67,7 +231,10 @@ func (r *ReceiptRepo) GetReceiptsPaginate(ctx context.Context, userID string, pa
 	offset := (page - 1) * perPage
 
 	query := `
-		SELECT id, user_id, total_items, total_price, store_name, image_url, created_at
+		SELECT
+			id, user_id, total_items, total_price, store_name, image_url, category_id, account_id,
+			invoice_date, status, failure_reason, confirmed_at, duplicate_of, allow_duplicate,
+			voided_at, void_reason, created_at
 		FROM receipts
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -87,7 +254,10 @@ func (r *ReceiptRepo) GetReceiptsPaginate(ctx context.Context, userID string, pa
 		err := rows.Scan(
 			&receipt.ID, &receipt.UserID, &receipt.TotalItems,
 			&receipt.TotalPrice, &receipt.StoreName,
-			&receipt.ImageURL, &receipt.CreatedAt,
+			&receipt.ImageURL, &receipt.CategoryID, &receipt.AccountID, &receipt.InvoiceDate,
+			&receipt.Status, &receipt.FailureReason, &receipt.ConfirmedAt,
+			&receipt.DuplicateOf, &receipt.AllowDuplicate, &receipt.VoidedAt, &receipt.VoidReason,
+			&receipt.CreatedAt,
 		)
 		if err != nil {
 			log.Printf("[ERROR] Failed to scan receipt: %s", err.Error())
@@ -110,7 +280,10 @@ func (r *ReceiptRepo) GetReceiptsPaginate(ctx context.Context, userID string, pa
 
 func (r *ReceiptRepo) GetReceiptByID(ctx context.Context, receiptID string, userID string) (*models.Receipt, error) {
 	query := `
-		SELECT id, user_id, total_items, total_price, store_name, image_url, created_at
+		SELECT
+			id, user_id, total_items, total_price, store_name, image_url, category_id, account_id,
+			invoice_date, status, failure_reason, confirmed_at, duplicate_of, allow_duplicate,
+			voided_at, void_reason, created_at
 		FROM receipts
 		WHERE id = $1 AND user_id = $2
 	`
@@ -119,7 +292,10 @@ func (r *ReceiptRepo) GetReceiptByID(ctx context.Context, receiptID string, user
 	err := r.db.QueryRowContext(ctx, query, receiptID, userID).Scan(
 		&receipt.ID, &receipt.UserID, &receipt.TotalItems,
 		&receipt.TotalPrice, &receipt.StoreName,
-		&receipt.ImageURL, &receipt.CreatedAt,
+		&receipt.ImageURL, &receipt.CategoryID, &receipt.AccountID, &receipt.InvoiceDate,
+		&receipt.Status, &receipt.FailureReason, &receipt.ConfirmedAt,
+		&receipt.DuplicateOf, &receipt.AllowDuplicate, &receipt.VoidedAt, &receipt.VoidReason,
+		&receipt.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
 		log.Printf("[ERROR] Receipt not found: %s", err.Error())
@@ -133,9 +309,449 @@ func (r *ReceiptRepo) GetReceiptByID(ctx context.Context, receiptID string, user
 	return &receipt, nil
 }
 
+// VoidReceipt marks a receipt as voided together with the expense it
+// generated. It reports false when the receipt does not exist, is already
+// voided or is still processing.
+func (r *ReceiptRepo) VoidReceipt(ctx context.Context, receiptID string, userID string, reason string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE receipts SET voided_at = NOW(), void_reason = $1
+		WHERE id = $2 AND user_id = $3 AND voided_at IS NULL AND status <> 'processing'
+	`
+	result, err := tx.ExecContext(ctx, query, reason, receiptID, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to void receipt: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	if err := voidLinkedTransactions(ctx, tx, "receipt_id", receiptID, reason); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SetReceiptCategory moves a receipt and the transactions it generated to a
+// new category. category is not a financial value, so this is allowed even
+// though linked transactions are otherwise read-only.
+func (r *ReceiptRepo) SetReceiptCategory(ctx context.Context, receiptID string, userID string, categoryID *string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE receipts SET category_id = $1 WHERE id = $2 AND user_id = $3`, categoryID, receiptID, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to update receipt category: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE transactions SET category_id = $1 WHERE receipt_id = $2`, categoryID, receiptID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to update transaction category: %s", err.Error())
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return false, err
+	}
+
+	return true, nil
+}
+
+// UpdateReceipt saves the store name, invoice date, total and account of a
+// draft or confirmed receipt, turns it into a draft and checks it for duplicates
+// again. The expense of a confirmed receipt is voided until it is confirmed
+// again. It reports false when the receipt does not exist or cannot be
+// edited.
+func (r *ReceiptRepo) UpdateReceipt(ctx context.Context, receipt *models.Receipt) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE receipts SET store_name = $1, invoice_date = $2, total_price = $3, account_id = $4, status = 'draft',
+			duplicate_of = ` + receiptDuplicateOf("$1", "$2", "$3") + `
+		WHERE id = $5 AND user_id = $6 AND status IN ('draft', 'confirmed') AND voided_at IS NULL
+		RETURNING status, duplicate_of
+	`
+	err = tx.QueryRowContext(
+		ctx, query, receipt.StoreName, receipt.InvoiceDate, receipt.TotalPrice, receipt.AccountID, receipt.ID, receipt.UserID,
+	).Scan(&receipt.Status, &receipt.DuplicateOf)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to update receipt: %s", err.Error())
+		return false, err
+	}
+
+	if err := voidLinkedTransactions(ctx, tx, "receipt_id", receipt.ID, receiptReopenedReason); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return false, err
+	}
+	return true, nil
+}
+
+// AddReceiptItem adds an item to a draft or confirmed receipt, turns it into
+// a draft and recomputes its totals. It reports false when the receipt does not exist or cannot be
+// edited.
+func (r *ReceiptRepo) AddReceiptItem(ctx context.Context, item *models.ReceiptItem, userID string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return false, err
+	}
+	defer tx.Rollback()
+
+	reopened, err := reopenReceipt(ctx, tx, item.ReceiptID, userID)
+	if err != nil || !reopened {
+		return false, err
+	}
+
+	if err := insertReceiptItem(ctx, tx, item); err != nil {
+		return false, err
+	}
+
+	if err := updateReceiptTotals(ctx, tx, item.ReceiptID); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return false, err
+	}
+	return true, nil
+}
+
+// UpdateReceiptItem changes an item of a draft or confirmed receipt, turns
+// the receipt into a draft and recomputes its totals. It reports false when the item does not exist or
+// its receipt cannot be edited.
+func (r *ReceiptRepo) UpdateReceiptItem(ctx context.Context, item *models.ReceiptItem, userID string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return false, err
+	}
+	defer tx.Rollback()
+
+	reopened, err := reopenReceipt(ctx, tx, item.ReceiptID, userID)
+	if err != nil || !reopened {
+		return false, err
+	}
+
+	query := `
+		UPDATE receipt_items SET name = $1, quantity = $2, price = $3
+		WHERE id = $4 AND receipt_id = $5
+		RETURNING created_at
+	`
+	err = tx.QueryRowContext(ctx, query, item.Name, item.Quantity, item.Price, item.ID, item.ReceiptID).Scan(&item.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to update receipt item: %s", err.Error())
+		return false, err
+	}
+
+	if err := updateReceiptTotals(ctx, tx, item.ReceiptID); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteReceiptItem removes an item from a draft or confirmed receipt, turns
+// the receipt into a draft and recomputes its totals. It reports false when the item does not
+// exist or its receipt cannot be edited.
+func (r *ReceiptRepo) DeleteReceiptItem(ctx context.Context, receiptID string, itemID string, userID string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return false, err
+	}
+	defer tx.Rollback()
+
+	reopened, err := reopenReceipt(ctx, tx, receiptID, userID)
+	if err != nil || !reopened {
+		return false, err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM receipt_items WHERE id = $1 AND receipt_id = $2`, itemID, receiptID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to delete receipt item: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	if err := updateReceiptTotals(ctx, tx, receiptID); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return false, err
+	}
+	return true, nil
+}
+
+// ConfirmReceipt posts a draft receipt to the books by creating its expense
+// transaction. Reopening a receipt voids its expense, so every confirmation
+// creates a new one; an expense a draft still has from before is brought up
+// to date with a revision of its previous values. The transaction is dated at
+// midnight of the invoice date in loc and goes to the receipt's account, or
+// the default account when the receipt has none. A receipt flagged as a duplicate is
+// only confirmed with allowDuplicate, which also keeps it from being flagged
+// again. It returns ErrReceiptNotConfirmable when the receipt is not a
+// draft, is voided, has no invoice date or total, or is a duplicate that was
+// not allowed.
+func (r *ReceiptRepo) ConfirmReceipt(ctx context.Context, receiptID string, userID string, allowDuplicate bool, loc *time.Location) (*models.Transaction, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	receiptQuery := `
+		SELECT total_price, invoice_date, category_id, ` + defaultAccountID("receipts.account_id", "receipts.user_id") + `
+		FROM receipts
+		WHERE id = $1 AND user_id = $2 AND status = 'draft' AND voided_at IS NULL
+			AND invoice_date IS NOT NULL AND total_price > 0 AND (duplicate_of IS NULL OR $3)
+		FOR UPDATE
+	`
+	var invoiceDate time.Time
+	transaction := models.Transaction{
+		ReceiptID: &receiptID,
+		UserID:    userID,
+		Type:      "expense",
+		Source:    "receipt",
+	}
+	err = tx.QueryRowContext(ctx, receiptQuery, receiptID, userID, allowDuplicate).Scan(
+		&transaction.Amount, &invoiceDate, &transaction.CategoryID, &transaction.AccountID,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrReceiptNotConfirmable
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to lock receipt: %s", err.Error())
+		return nil, err
+	}
+	transaction.TransactionDate = inLocation(invoiceDate, loc)
+
+	existingQuery := `
+		SELECT id FROM transactions
+		WHERE receipt_id = $1 AND voided_at IS NULL
+		FOR UPDATE
+	`
+	err = tx.QueryRowContext(ctx, existingQuery, receiptID).Scan(&transaction.ID)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("[ERROR] Failed to get receipt transaction: %s", err.Error())
+		return nil, err
+	}
+
+	if err == sql.ErrNoRows {
+		id, _ := uuid.NewV7()
+		transaction.ID = id.String()
+		insertQuery := `
+			INSERT INTO transactions (id, user_id, type, source, amount, transaction_date, receipt_id, category_id, account_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING created_at
+		`
+		err = tx.QueryRowContext(
+			ctx, insertQuery,
+			transaction.ID, transaction.UserID, transaction.Type, transaction.Source, transaction.Amount,
+			transaction.TransactionDate, transaction.ReceiptID, transaction.CategoryID, transaction.AccountID,
+		).Scan(&transaction.CreatedAt)
+		if err != nil {
+			log.Printf("[ERROR] Failed to create transaction: %s", err.Error())
+			return nil, err
+		}
+	} else {
+		revisionQuery := `
+			INSERT INTO transaction_revisions (transaction_id, user_id, action, previous)
+			SELECT t.id, t.user_id, $2, to_jsonb(t) FROM transactions t WHERE t.id = $1
+		`
+		if _, err := tx.ExecContext(ctx, revisionQuery, transaction.ID, models.TransactionRevisionUpdate); err != nil {
+			log.Printf("[ERROR] Failed to record transaction revision: %s", err.Error())
+			return nil, err
+		}
+
+		updateQuery := `
+			UPDATE transactions SET amount = $1, transaction_date = $2, category_id = $3, account_id = $4
+			WHERE id = $5
+			RETURNING created_at
+		`
+		err = tx.QueryRowContext(
+			ctx, updateQuery,
+			transaction.Amount, transaction.TransactionDate, transaction.CategoryID, transaction.AccountID, transaction.ID,
+		).Scan(&transaction.CreatedAt)
+		if err != nil {
+			log.Printf("[ERROR] Failed to update transaction: %s", err.Error())
+			return nil, err
+		}
+	}
+
+	confirmQuery := `
+		UPDATE receipts
+		SET status = 'confirmed', confirmed_at = NOW(),
+			allow_duplicate = allow_duplicate OR $2, duplicate_of = CASE WHEN $2 THEN NULL ELSE duplicate_of END
+		WHERE id = $1
+	`
+	if _, err := tx.ExecContext(ctx, confirmQuery, receiptID, allowDuplicate); err != nil {
+		log.Printf("[ERROR] Failed to confirm receipt: %s", err.Error())
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// receiptReopenedReason is the void reason of the expense of a confirmed
+// receipt that is edited again.
+const receiptReopenedReason = "Struk dibuka kembali untuk diubah"
+
+// reopenReceipt locks a draft or confirmed receipt for an edit and turns it
+// into a draft. The expense of a confirmed receipt is voided, as only
+// confirmed receipts are in the books. It reports false when the receipt does
+// not exist or cannot be edited.
+func reopenReceipt(ctx context.Context, tx *sql.Tx, receiptID string, userID string) (bool, error) {
+	query := `
+		UPDATE receipts SET status = 'draft'
+		WHERE id = $1 AND user_id = $2 AND status IN ('draft', 'confirmed') AND voided_at IS NULL
+	`
+	result, err := tx.ExecContext(ctx, query, receiptID, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to reopen receipt: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	if err := voidLinkedTransactions(ctx, tx, "receipt_id", receiptID, receiptReopenedReason); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// receiptDuplicateOf is the SQL for the duplicate_of column in an UPDATE of a
+// receipt whose new store name, invoice date and total are in the given
+// placeholders. It picks the earliest earlier receipt of the merchant with
+// the same store, date and total, and is NULL when the merchant allowed the
+// duplicate or the receipt lacks a store, date or total.
+func receiptDuplicateOf(storeName, invoiceDate, total string) string {
+	return fmt.Sprintf(`CASE WHEN receipts.allow_duplicate THEN NULL ELSE (
+			SELECT d.id FROM receipts d
+			WHERE d.user_id = receipts.user_id AND d.id <> receipts.id AND d.created_at < receipts.created_at
+				AND d.status IN ('draft', 'confirmed') AND d.voided_at IS NULL
+				AND btrim(%[1]s::text) <> '' AND lower(btrim(d.store_name)) = lower(btrim(%[1]s::text))
+				AND d.invoice_date = %[2]s AND %[3]s > 0 AND d.total_price = %[3]s
+			ORDER BY d.created_at
+			LIMIT 1
+		) END`, storeName, invoiceDate, total)
+}
+
+func insertReceiptItem(ctx context.Context, tx *sql.Tx, item *models.ReceiptItem) error {
+	query := `
+		INSERT INTO receipt_items (id, receipt_id, name, quantity, price)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`
+	err := tx.QueryRowContext(ctx, query, item.ID, item.ReceiptID, item.Name, item.Quantity, item.Price).Scan(&item.CreatedAt)
+	if err != nil {
+		log.Printf("[ERROR] Failed to create receipt item: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// updateReceiptTotals sets the item count and total of a receipt from its
+// items after one of them changed, and checks it for duplicates again with
+// the new total. price is the line total of an item.
+func updateReceiptTotals(ctx context.Context, tx *sql.Tx, receiptID string) error {
+	query := `
+		WITH items AS (
+			SELECT COUNT(*) AS count, COALESCE(SUM(price), 0) AS total
+			FROM receipt_items
+			WHERE receipt_id = $1
+		)
+		UPDATE receipts
+		SET total_items = items.count, total_price = items.total,
+			duplicate_of = ` + receiptDuplicateOf("receipts.store_name", "receipts.invoice_date", "items.total") + `
+		FROM items
+		WHERE receipts.id = $1
+	`
+	if _, err := tx.ExecContext(ctx, query, receiptID); err != nil {
+		log.Printf("[ERROR] Failed to update receipt totals: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
 func (r *ReceiptRepo) GetReceiptItemsByReceiptID(ctx context.Context, receiptID string) ([]models.ReceiptItem, error) {
 	query := `
-		SELECT id, receipt_id, name, price, created_at
+		SELECT id, receipt_id, name, quantity, price, created_at
 		FROM receipt_items
 		WHERE receipt_id = $1
 		ORDER BY created_at ASC
@@ -151,7 +767,7 @@ func (r *ReceiptRepo) GetReceiptItemsByReceiptID(ctx context.Context, receiptID
 	items := []models.ReceiptItem{}
 	for rows.Next() {
 		var item models.ReceiptItem
-		err := rows.Scan(&item.ID, &item.ReceiptID, &item.Name, &item.Price, &item.CreatedAt)
+		err := rows.Scan(&item.ID, &item.ReceiptID, &item.Name, &item.Quantity, &item.Price, &item.CreatedAt)
 		if err != nil {
 			log.Printf("[ERROR] Failed to scan receipt item: %s", err.Error())
 			return nil, err
@@ -161,3 +777,122 @@ func (r *ReceiptRepo) GetReceiptItemsByReceiptID(ctx context.Context, receiptID
 
 	return items, nil
 }
+
+// GetReceiptItemByID returns an item of one of the user's confirmed,
+// non-voided receipts.
+func (r *ReceiptRepo) GetReceiptItemByID(ctx context.Context, itemID string, userID string) (*models.ReceiptItem, error) {
+	query := `
+		SELECT ri.id, ri.receipt_id, ri.name, ri.quantity, ri.price, ri.created_at
+		FROM receipt_items ri
+		JOIN receipts r ON r.id = ri.receipt_id
+		WHERE ri.id = $1 AND r.user_id = $2 AND r.status = 'confirmed' AND r.voided_at IS NULL
+	`
+
+	var item models.ReceiptItem
+	err := r.db.QueryRowContext(ctx, query, itemID, userID).Scan(
+		&item.ID, &item.ReceiptID, &item.Name, &item.Quantity, &item.Price, &item.CreatedAt,
+	)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[ERROR] Failed to get receipt item: %s", err.Error())
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetStatementReceipts returns the confirmed, non-voided receipts whose
+// invoice date falls between start and end, end exclusive, oldest first.
+func (r *ReceiptRepo) GetStatementReceipts(ctx context.Context, userID string, start, end time.Time) ([]models.StatementReceipt, error) {
+	query := `
+		SELECT id, invoice_date, store_name, total_price, image_url
+		FROM (
+			SELECT
+				r.id, r.store_name, r.total_price, r.image_url, r.created_at,
+				COALESCE(
+					(SELECT MIN(t.transaction_date) FROM transactions t WHERE t.receipt_id = r.id),
+					r.created_at
+				) AS invoice_date
+			FROM receipts r
+			WHERE r.user_id = $1 AND r.voided_at IS NULL AND r.status = 'confirmed'
+		) rd
+		WHERE invoice_date >= $2 AND invoice_date < $3
+		ORDER BY invoice_date, created_at
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, start, end)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get statement receipts: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	receipts := []models.StatementReceipt{}
+	for rows.Next() {
+		var receipt models.StatementReceipt
+		err := rows.Scan(&receipt.ID, &receipt.InvoiceDate, &receipt.StoreName, &receipt.Total, &receipt.ImageURL)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan statement receipt: %s", err.Error())
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate statement receipts: %s", err.Error())
+		return nil, err
+	}
+	return receipts, nil
+}
+
+// ExportReceipts streams the items of the confirmed receipts matching filter
+// to fn, one row per item, ordered by invoice date. It stops at the first error returned
+// by fn.
+func (r *ReceiptRepo) ExportReceipts(
+	ctx context.Context, filter models.ReceiptExportFilter, fn func(models.ReceiptExportRow) error,
+) error {
+	query := `
+		WITH receipt_dates AS (
+			SELECT
+				r.id, r.store_name, r.total_price, r.category_id, r.voided_at, r.created_at,
+				COALESCE(
+					(SELECT MIN(t.transaction_date) FROM transactions t WHERE t.receipt_id = r.id),
+					r.created_at
+				) AS invoice_date
+			FROM receipts r
+			WHERE r.user_id = $1 AND r.status = 'confirmed'
+		)
+		SELECT rd.id, rd.invoice_date, rd.store_name, c.name, rd.total_price, rd.voided_at, ri.name, ri.price
+		FROM receipt_dates rd
+		LEFT JOIN transaction_categories c ON c.id = rd.category_id
+		LEFT JOIN receipt_items ri ON ri.receipt_id = rd.id
+		WHERE rd.invoice_date >= $2 AND rd.invoice_date < $3
+		ORDER BY rd.invoice_date, rd.created_at, ri.created_at
+	`
+	rows, err := r.db.QueryContext(ctx, query, filter.UserID, filter.StartDate, filter.EndDate)
+	if err != nil {
+		log.Printf("[ERROR] Failed to export receipts: %s", err.Error())
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row models.ReceiptExportRow
+		err := rows.Scan(
+			&row.ReceiptID, &row.InvoiceDate, &row.StoreName, &row.CategoryName,
+			&row.Total, &row.VoidedAt, &row.ItemName, &row.ItemPrice,
+		)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan exported receipt: %s", err.Error())
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate exported receipts: %s", err.Error())
+		return err
+	}
+	return nil
+}
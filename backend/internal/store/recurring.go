@@ -0,0 +1,384 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/Cakra17/imphnen/internal/utils"
+)
+
+type RecurringTransactionRepo struct {
+	db *sql.DB
+}
+
+func NewRecurringTransactionRepo(db *sql.DB) RecurringTransactionRepo {
+	return RecurringTransactionRepo{db: db}
+}
+
+const recurringTransactionColumns = `
+	id, user_id, type, amount, category_id, account_id, description, frequency, interval_count,
+	day_of_month, start_date, end_date, next_run_date, paused_at, created_at, updated_at
+`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRecurringTransaction(row rowScanner) (models.RecurringTransaction, error) {
+	var recurring models.RecurringTransaction
+	err := row.Scan(
+		&recurring.ID, &recurring.UserID, &recurring.Type, &recurring.Amount,
+		&recurring.CategoryID, &recurring.AccountID, &recurring.Description, &recurring.Frequency, &recurring.Interval,
+		&recurring.DayOfMonth, &recurring.StartDate, &recurring.EndDate, &recurring.NextRunDate,
+		&recurring.PausedAt, &recurring.CreatedAt, &recurring.UpdatedAt,
+	)
+	return recurring, err
+}
+
+func (r *RecurringTransactionRepo) Create(ctx context.Context, recurring *models.RecurringTransaction) error {
+	query := `
+		INSERT INTO recurring_transactions (
+			id, user_id, type, amount, category_id, account_id, description, frequency,
+			interval_count, day_of_month, start_date, end_date, next_run_date
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING created_at, updated_at
+	`
+	err := r.db.QueryRowContext(
+		ctx, query,
+		recurring.ID, recurring.UserID, recurring.Type, recurring.Amount,
+		recurring.CategoryID, recurring.AccountID, recurring.Description, recurring.Frequency,
+		recurring.Interval, recurring.DayOfMonth, recurring.StartDate,
+		recurring.EndDate, recurring.NextRunDate,
+	).Scan(&recurring.CreatedAt, &recurring.UpdatedAt)
+	if err != nil {
+		log.Printf("[ERROR] Failed to create recurring transaction: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *RecurringTransactionRepo) GetRecurringTransactions(ctx context.Context, userID string) ([]models.RecurringTransaction, error) {
+	query := `
+		SELECT ` + recurringTransactionColumns + `
+		FROM recurring_transactions
+		WHERE user_id = $1
+		ORDER BY next_run_date, created_at
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get recurring transactions: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	recurrings := []models.RecurringTransaction{}
+	for rows.Next() {
+		recurring, err := scanRecurringTransaction(rows)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan recurring transaction: %s", err.Error())
+			return nil, err
+		}
+		recurrings = append(recurrings, recurring)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate recurring transactions: %s", err.Error())
+		return nil, err
+	}
+
+	return recurrings, nil
+}
+
+func (r *RecurringTransactionRepo) GetRecurringTransactionByID(ctx context.Context, id string, userID string) (*models.RecurringTransaction, error) {
+	query := `
+		SELECT ` + recurringTransactionColumns + `
+		FROM recurring_transactions
+		WHERE id = $1 AND user_id = $2
+	`
+	recurring, err := scanRecurringTransaction(r.db.QueryRowContext(ctx, query, id, userID))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[ERROR] Failed to get recurring transaction: %s", err.Error())
+		}
+		return nil, err
+	}
+	return &recurring, nil
+}
+
+// UpdateRecurringTransaction changes the template used for future
+// occurrences. Transactions that were already created are left untouched.
+func (r *RecurringTransactionRepo) UpdateRecurringTransaction(ctx context.Context, recurring models.RecurringTransaction) (bool, error) {
+	query := `
+		UPDATE recurring_transactions
+		SET type = $1, amount = $2, category_id = $3, account_id = $4, description = $5, frequency = $6,
+			interval_count = $7, day_of_month = $8, end_date = $9, next_run_date = $10,
+			updated_at = NOW()
+		WHERE id = $11 AND user_id = $12
+	`
+	result, err := r.db.ExecContext(
+		ctx, query,
+		recurring.Type, recurring.Amount, recurring.CategoryID, recurring.AccountID, recurring.Description,
+		recurring.Frequency, recurring.Interval, recurring.DayOfMonth, recurring.EndDate,
+		recurring.NextRunDate, recurring.ID, recurring.UserID,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to update recurring transaction: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// DeleteRecurringTransaction removes a template. Transactions it created stay
+// in the books and lose their link through ON DELETE SET NULL.
+func (r *RecurringTransactionRepo) DeleteRecurringTransaction(ctx context.Context, id string, userID string) (bool, error) {
+	query := `DELETE FROM recurring_transactions WHERE id = $1 AND user_id = $2`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to delete recurring transaction: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// PauseRecurringTransaction stops the scheduler from creating transactions.
+// It reports false when the template does not exist or is already paused.
+func (r *RecurringTransactionRepo) PauseRecurringTransaction(ctx context.Context, id string, userID string) (bool, error) {
+	query := `
+		UPDATE recurring_transactions SET paused_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND paused_at IS NULL
+	`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to pause recurring transaction: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// ResumeRecurringTransaction restarts a paused template from nextRunDate, so
+// occurrences that fell inside the pause are not created afterwards.
+func (r *RecurringTransactionRepo) ResumeRecurringTransaction(ctx context.Context, id string, userID string, nextRunDate time.Time) (bool, error) {
+	query := `
+		UPDATE recurring_transactions SET paused_at = NULL, next_run_date = $1, updated_at = NOW()
+		WHERE id = $2 AND user_id = $3 AND paused_at IS NOT NULL
+	`
+	result, err := r.db.ExecContext(ctx, query, nextRunDate, id, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to resume recurring transaction: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *RecurringTransactionRepo) SkipOccurrence(ctx context.Context, id string, date time.Time) error {
+	query := `
+		INSERT INTO recurring_transaction_skips (recurring_id, occurrence_date)
+		VALUES ($1, $2)
+		ON CONFLICT (recurring_id, occurrence_date) DO NOTHING
+	`
+	if _, err := r.db.ExecContext(ctx, query, id, date); err != nil {
+		log.Printf("[ERROR] Failed to skip recurring occurrence: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// GetSkippedDates returns the skipped occurrences on or after from.
+func (r *RecurringTransactionRepo) GetSkippedDates(ctx context.Context, id string, from time.Time) (map[time.Time]bool, error) {
+	return getSkippedDates(ctx, r.db, id, `occurrence_date >= $2`, from)
+}
+
+// GetDueRecurringIDs returns the templates whose next occurrence is due by
+// today in their merchant's time zone.
+func (r *RecurringTransactionRepo) GetDueRecurringIDs(ctx context.Context) ([]string, error) {
+	query := `
+		SELECT rt.id FROM recurring_transactions rt
+		JOIN users u ON u.id = rt.user_id
+		WHERE rt.paused_at IS NULL AND rt.next_run_date <= (NOW() AT TIME ZONE u.timezone)::date
+			AND (rt.end_date IS NULL OR rt.next_run_date <= rt.end_date)
+		ORDER BY rt.next_run_date
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get due recurring transactions: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("[ERROR] Failed to scan recurring transaction id: %s", err.Error())
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate due recurring transactions: %s", err.Error())
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// MaterializeDue creates every occurrence of a template up to today in the
+// merchant's time zone, catching up on runs missed while the server was down,
+// and moves next_run_date past today. Occurrences are dated at midnight in
+// that zone and go to the template's account or the default account. The template row is locked with SKIP LOCKED so concurrent schedulers
+// never work on the same template, and the unique occurrence index makes a
+// repeated run a no-op. It returns the number of transactions created.
+func (r *RecurringTransactionRepo) MaterializeDue(ctx context.Context, id string) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var timezone string
+	timezoneQuery := `
+		SELECT u.timezone FROM recurring_transactions rt
+		JOIN users u ON u.id = rt.user_id
+		WHERE rt.id = $1
+	`
+	err = tx.QueryRowContext(ctx, timezoneQuery, id).Scan(&timezone)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to get recurring transaction timezone: %s", err.Error())
+		return 0, err
+	}
+
+	loc := utils.LoadLocation(timezone)
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	query := `
+		SELECT ` + recurringTransactionColumns + `
+		FROM recurring_transactions
+		WHERE id = $1 AND paused_at IS NULL AND next_run_date <= $2
+		FOR UPDATE SKIP LOCKED
+	`
+	recurring, err := scanRecurringTransaction(tx.QueryRowContext(ctx, query, id, today))
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to lock recurring transaction: %s", err.Error())
+		return 0, err
+	}
+
+	skipped, err := getSkippedDates(ctx, tx, id, `occurrence_date BETWEEN $2 AND $3`, recurring.NextRunDate, today)
+	if err != nil {
+		return 0, err
+	}
+
+	insertQuery := `
+		INSERT INTO transactions (
+			id, user_id, type, source, amount, transaction_date,
+			category_id, recurring_id, occurrence_date, account_id
+		)
+		VALUES (gen_random_uuid(), $1, $2, 'recurring', $3, $4, $5, $6, $7, ` + defaultAccountID("$8::uuid", "$1") + `)
+		ON CONFLICT (recurring_id, occurrence_date) WHERE recurring_id IS NOT NULL DO NOTHING
+	`
+
+	created := 0
+	next := recurring.OccurrenceOnOrAfter(recurring.NextRunDate)
+	for !next.After(today) && !recurring.Ended(next) {
+		if !skipped[next] {
+			result, err := tx.ExecContext(
+				ctx, insertQuery,
+				recurring.UserID, recurring.Type, recurring.Amount,
+				inLocation(next, loc), recurring.CategoryID, recurring.ID, next, recurring.AccountID,
+			)
+			if err != nil {
+				log.Printf("[ERROR] Failed to create recurring occurrence: %s", err.Error())
+				return 0, err
+			}
+			rowsAffected, err := result.RowsAffected()
+			if err != nil {
+				log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+				return 0, err
+			}
+			created += int(rowsAffected)
+		}
+		next = recurring.NextOccurrence(next)
+	}
+
+	updateQuery := `UPDATE recurring_transactions SET next_run_date = $1 WHERE id = $2`
+	if _, err := tx.ExecContext(ctx, updateQuery, next, recurring.ID); err != nil {
+		log.Printf("[ERROR] Failed to advance recurring transaction: %s", err.Error())
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return 0, err
+	}
+
+	return created, nil
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func getSkippedDates(ctx context.Context, q queryer, id string, condition string, args ...any) (map[time.Time]bool, error) {
+	query := `
+		SELECT occurrence_date FROM recurring_transaction_skips
+		WHERE recurring_id = $1 AND ` + condition
+	rows, err := q.QueryContext(ctx, query, append([]any{id}, args...)...)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get skipped occurrences: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	skipped := map[time.Time]bool{}
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			log.Printf("[ERROR] Failed to scan skipped occurrence: %s", err.Error())
+			return nil, err
+		}
+		skipped[time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate skipped occurrences: %s", err.Error())
+		return nil, err
+	}
+
+	return skipped, nil
+}
@@ -0,0 +1,282 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/models"
+)
+
+type ReportRepo struct {
+	db *sql.DB
+}
+
+func NewReportRepo(db *sql.DB) ReportRepo {
+	return ReportRepo{db: db}
+}
+
+// GetProfitLossPeriods returns one row per period between start and end, with
+// periods that have no activity filled with zeros. granularity is a
+// date_trunc unit and step the matching interval; start and end are calendar
+// dates in loc, start must be aligned to a period boundary and end is
+// exclusive. Periods are cut at midnight in loc. Cost of goods sold is the
+// cost of the items sold, as in the margin reports, so expenses in COGS
+// categories are stock purchases and count as neither COGS nor operating
+// expenses.
+func (r *ReportRepo) GetProfitLossPeriods(
+	ctx context.Context, userID string, granularity, step string, start, end time.Time, loc *time.Location,
+) ([]models.ProfitLossPeriod, error) {
+	query := `
+		WITH periods AS (
+			SELECT generate_series($2::timestamp, $3::timestamp - $5::interval, $5::interval) AS period_start
+		),
+		revenue AS (
+			SELECT date_trunc($6, o.order_date AT TIME ZONE $4) AS period_start, SUM(o.total_price) AS amount
+			FROM orders o
+			WHERE o.user_id = $1 AND o.status = 'confirmed'
+				AND o.order_date >= ($2::timestamp AT TIME ZONE $4)
+				AND o.order_date < ($3::timestamp AT TIME ZONE $4)
+			GROUP BY 1
+		),
+		sales AS (
+			SELECT date_trunc($6, o.order_date AT TIME ZONE $4) AS period_start, ` + marginFigures +
+		marginOrderItems + `
+			GROUP BY 1
+		),
+		ledger AS (
+			SELECT
+				date_trunc($6, t.transaction_date AT TIME ZONE $4) AS period_start,
+				SUM(t.amount) FILTER (WHERE t.type = 'income' AND t.order_id IS NULL) AS other_income,
+				SUM(t.amount) FILTER (WHERE t.type = 'expense' AND c.is_cogs) AS stock_purchases,
+				SUM(t.amount) FILTER (WHERE t.type = 'expense' AND c.is_cogs IS NOT TRUE) AS operating_expenses
+			FROM transactions t
+			LEFT JOIN transaction_categories c ON c.id = t.category_id
+			WHERE t.user_id = $1 AND t.voided_at IS NULL
+				AND t.transaction_date >= ($2::timestamp AT TIME ZONE $4)
+				AND t.transaction_date < ($3::timestamp AT TIME ZONE $4)
+			GROUP BY 1
+		)
+		SELECT
+			p.period_start,
+			COALESCE(rv.amount, 0),
+			COALESCE(l.other_income, 0),
+			COALESCE(s.cost, 0),
+			COALESCE(l.operating_expenses, 0),
+			COALESCE(l.stock_purchases, 0)
+		FROM periods p
+		LEFT JOIN revenue rv ON rv.period_start = p.period_start
+		LEFT JOIN sales s ON s.period_start = p.period_start
+		LEFT JOIN ledger l ON l.period_start = p.period_start
+		ORDER BY p.period_start
+	`
+	from, to, timezone := start.Format("2006-01-02"), end.Format("2006-01-02"), loc.String()
+	rows, err := r.db.QueryContext(ctx, query, userID, from, to, timezone, step, granularity)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get profit and loss: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	periods := []models.ProfitLossPeriod{}
+	index := map[time.Time]int{}
+	for rows.Next() {
+		period := models.ProfitLossPeriod{Expenses: []models.ExpenseByCategory{}}
+		err := rows.Scan(
+			&period.PeriodStart, &period.Revenue, &period.OtherIncome,
+			&period.CostOfGoodsSold, &period.OperatingExpenses, &period.StockPurchases,
+		)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan profit and loss period: %s", err.Error())
+			return nil, err
+		}
+		period.PeriodStart = inLocation(period.PeriodStart, loc)
+		period.CalculateProfit()
+		index[period.PeriodStart] = len(periods)
+		periods = append(periods, period)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate profit and loss periods: %s", err.Error())
+		return nil, err
+	}
+
+	expenseQuery := `
+		SELECT date_trunc($2, t.transaction_date AT TIME ZONE $5), c.id, COALESCE(c.name, 'Tanpa Kategori'), SUM(t.amount)
+		FROM transactions t
+		LEFT JOIN transaction_categories c ON c.id = t.category_id
+		WHERE t.user_id = $1 AND t.voided_at IS NULL AND t.type = 'expense' AND c.is_cogs IS NOT TRUE
+			AND t.transaction_date >= ($3::timestamp AT TIME ZONE $5)
+			AND t.transaction_date < ($4::timestamp AT TIME ZONE $5)
+		GROUP BY 1, c.id, c.name
+		ORDER BY 1, SUM(t.amount) DESC
+	`
+	expenseRows, err := r.db.QueryContext(ctx, expenseQuery, userID, granularity, from, to, timezone)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get expenses by category: %s", err.Error())
+		return nil, err
+	}
+	defer expenseRows.Close()
+
+	for expenseRows.Next() {
+		var periodStart time.Time
+		var expense models.ExpenseByCategory
+		if err := expenseRows.Scan(&periodStart, &expense.CategoryID, &expense.CategoryName, &expense.Amount); err != nil {
+			log.Printf("[ERROR] Failed to scan expense by category: %s", err.Error())
+			return nil, err
+		}
+		if i, ok := index[inLocation(periodStart, loc)]; ok {
+			periods[i].Expenses = append(periods[i].Expenses, expense)
+		}
+	}
+
+	if err = expenseRows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate expenses by category: %s", err.Error())
+		return nil, err
+	}
+
+	return periods, nil
+}
+
+// marginOrderItems are the items of the user's confirmed orders placed
+// between $2 and $3, calendar dates in the zone $4 with $3 exclusive.
+const marginOrderItems = `
+	FROM order_items oi
+	JOIN orders o ON o.id = oi.order_id
+	JOIN products p ON p.id = oi.product_id
+	LEFT JOIN transaction_categories c ON c.id = p.category_id
+	WHERE o.user_id = $1 AND o.status = 'confirmed'
+		AND o.order_date >= ($2::timestamp AT TIME ZONE $4)
+		AND o.order_date < ($3::timestamp AT TIME ZONE $4)
+`
+
+const marginFigures = `
+	COALESCE(SUM(oi.quantity), 0) AS quantity,
+	COALESCE(SUM(oi.total_price), 0) AS revenue,
+	COALESCE(SUM(oi.unit_cost * oi.quantity), 0) AS cost
+`
+
+// GetProductMargins returns the gross margin of every product sold between
+// start and end, calendar dates in loc with end exclusive, most profitable
+// first.
+func (r *ReportRepo) GetProductMargins(ctx context.Context, userID string, start, end time.Time, loc *time.Location) ([]models.ProductMargin, error) {
+	query := `
+		SELECT p.id, p.name, p.category_id, COALESCE(c.name, 'Tanpa Kategori'), ` + marginFigures +
+		marginOrderItems + `
+		GROUP BY p.id, p.name, p.category_id, c.name
+		ORDER BY SUM(oi.total_price) - SUM(oi.unit_cost * oi.quantity) DESC, p.name
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, start.Format("2006-01-02"), end.Format("2006-01-02"), loc.String())
+	if err != nil {
+		log.Printf("[ERROR] Failed to get product margins: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	margins := []models.ProductMargin{}
+	for rows.Next() {
+		var margin models.ProductMargin
+		err := rows.Scan(
+			&margin.ProductID, &margin.ProductName, &margin.CategoryID, &margin.CategoryName,
+			&margin.Quantity, &margin.Revenue, &margin.Cost,
+		)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan product margin: %s", err.Error())
+			return nil, err
+		}
+		margin.CalculateMargin()
+		margins = append(margins, margin)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate product margins: %s", err.Error())
+		return nil, err
+	}
+	return margins, nil
+}
+
+// GetCategoryMargins returns the gross margin of the products sold between
+// start and end grouped by the products' category, most profitable first.
+func (r *ReportRepo) GetCategoryMargins(ctx context.Context, userID string, start, end time.Time, loc *time.Location) ([]models.CategoryMargin, error) {
+	query := `
+		SELECT p.category_id, COALESCE(c.name, 'Tanpa Kategori'), ` + marginFigures +
+		marginOrderItems + `
+		GROUP BY p.category_id, c.name
+		ORDER BY SUM(oi.total_price) - SUM(oi.unit_cost * oi.quantity) DESC, c.name
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, start.Format("2006-01-02"), end.Format("2006-01-02"), loc.String())
+	if err != nil {
+		log.Printf("[ERROR] Failed to get category margins: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	margins := []models.CategoryMargin{}
+	for rows.Next() {
+		var margin models.CategoryMargin
+		err := rows.Scan(&margin.CategoryID, &margin.CategoryName, &margin.Quantity, &margin.Revenue, &margin.Cost)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan category margin: %s", err.Error())
+			return nil, err
+		}
+		margin.CalculateMargin()
+		margins = append(margins, margin)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate category margins: %s", err.Error())
+		return nil, err
+	}
+	return margins, nil
+}
+
+// GetMarginPeriods returns the gross margin per period between start and end,
+// with periods without sales filled with zeros. granularity, step, start and
+// end are as for GetProfitLossPeriods.
+func (r *ReportRepo) GetMarginPeriods(
+	ctx context.Context, userID string, granularity, step string, start, end time.Time, loc *time.Location,
+) ([]models.MarginPeriod, error) {
+	query := `
+		WITH periods AS (
+			SELECT generate_series($2::timestamp, $3::timestamp - $5::interval, $5::interval) AS period_start
+		),
+		sales AS (
+			SELECT date_trunc($6, o.order_date AT TIME ZONE $4) AS period_start, ` + marginFigures +
+		marginOrderItems + `
+			GROUP BY 1
+		)
+		SELECT p.period_start, COALESCE(s.quantity, 0), COALESCE(s.revenue, 0), COALESCE(s.cost, 0)
+		FROM periods p
+		LEFT JOIN sales s ON s.period_start = p.period_start
+		ORDER BY p.period_start
+	`
+	rows, err := r.db.QueryContext(
+		ctx, query,
+		userID, start.Format("2006-01-02"), end.Format("2006-01-02"), loc.String(), step, granularity,
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get margin periods: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	periods := []models.MarginPeriod{}
+	for rows.Next() {
+		var period models.MarginPeriod
+		err := rows.Scan(&period.PeriodStart, &period.Quantity, &period.Revenue, &period.Cost)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan margin period: %s", err.Error())
+			return nil, err
+		}
+		period.PeriodStart = inLocation(period.PeriodStart, loc)
+		period.CalculateMargin()
+		periods = append(periods, period)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate margin periods: %s", err.Error())
+		return nil, err
+	}
+	return periods, nil
+}
@@ -3,10 +3,14 @@ package store
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/Cakra17/imphnen/internal/utils"
 )
 
 type TransactionRepo struct {
@@ -19,9 +23,9 @@ func NewTransactionRepo(db *sql.DB) TransactionRepo {
 
 func (s *TransactionRepo) AddTransaction(ctx context.Context, transaction *models.Transaction) error {
 	query := `
-		INSERT INTO transactions (id, user_id, type, source, amount, transaction_date, receipt_id, order_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING created_at
+		INSERT INTO transactions (id, user_id, type, source, amount, transaction_date, receipt_id, order_id, category_id, account_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, ` + defaultAccountID("$10::uuid", "$2") + `)
+		RETURNING created_at, account_id
 	`
 
 	err := s.db.QueryRowContext(
@@ -30,7 +34,8 @@ func (s *TransactionRepo) AddTransaction(ctx context.Context, transaction *model
 		transaction.Type, transaction.Source,
 		transaction.Amount, transaction.TransactionDate,
 		transaction.ReceiptID, transaction.OrderID,
-	).Scan(&transaction.CreatedAt)
+		transaction.CategoryID, transaction.AccountID,
+	).Scan(&transaction.CreatedAt, &transaction.AccountID)
 
 	if err != nil {
 		log.Printf("[ERROR] Failed to create transaction: %s", err.Error())
@@ -40,55 +45,213 @@ func (s *TransactionRepo) AddTransaction(ctx context.Context, transaction *model
 	return nil
 }
 
+// GetTransactionsByDate returns the transactions of the day starting at
+// date, which must be midnight in the merchant's time zone.
 func (s *TransactionRepo) GetTransactionsByDate(ctx context.Context, userID string, date time.Time) ([]models.Transaction, error) {
+	return s.GetTransactionsByRange(ctx, userID, date, date.AddDate(0, 0, 1))
+}
+
+// GetTransactionsByRange returns transactions from startDate up to, but not
+// including, endDate.
+func (s *TransactionRepo) GetTransactionsByRange(ctx context.Context, userID string, startDate, endDate time.Time) ([]models.Transaction, error) {
+	return s.findTransactions(ctx, models.TransactionFilter{
+		UserID:    userID,
+		StartDate: &startDate,
+		EndDate:   &endDate,
+	})
+}
+
+// GetTransactionsByDays returns the transactions of today and the days
+// before it, counted in loc.
+func (s *TransactionRepo) GetTransactionsByDays(ctx context.Context, userID string, days int, loc *time.Location) ([]models.Transaction, error) {
+	endDate := utils.StartOfDay(time.Now(), loc).AddDate(0, 0, 1)
+	startDate := endDate.AddDate(0, 0, -days-1)
+	return s.GetTransactionsByRange(ctx, userID, startDate, endDate)
+}
+
+// GetTransactionStats totals non-voided transactions from startDate up to,
+// but not including, endDate.
+func (s *TransactionRepo) GetTransactionStats(ctx context.Context, userID string, startDate, endDate time.Time) (models.TransactionStats, error) {
 	query := `
-		SELECT id, user_id, type, source, amount, transaction_date, receipt_id, order_id, created_at
+		SELECT 
+			COALESCE(SUM(CASE WHEN type = 'income' THEN amount ELSE 0 END), 0) as total_income,
+			COALESCE(SUM(CASE WHEN type = 'expense' THEN amount ELSE 0 END), 0) as total_expense,
+			COUNT(*) as transaction_count,
+			COALESCE(AVG(amount), 0) as average_amount
 		FROM transactions
-		WHERE user_id = $1 AND DATE(transaction_date) = $2
-		ORDER BY transaction_date DESC
+		WHERE user_id = $1 AND transaction_date >= $2 AND transaction_date < $3 AND voided_at IS NULL
 	`
-	rows, err := s.db.QueryContext(ctx, query, userID, date)
+
+	var stats models.TransactionStats
+	err := s.db.QueryRowContext(ctx, query, userID, startDate, endDate).Scan(
+		&stats.TotalIncome,
+		&stats.TotalExpense,
+		&stats.TransactionCount,
+		&stats.AverageAmount,
+	)
 	if err != nil {
+		return models.TransactionStats{}, err
+	}
+
+	stats.NetAmount = stats.TotalIncome - stats.TotalExpense
+	return stats, nil
+}
+
+// GetTransactionSeries totals non-voided transactions per bucket between
+// start and end, including buckets without transactions. bucket is a
+// date_trunc unit and step the matching interval; start and end are calendar
+// dates in loc, start must be aligned to a bucket boundary and end is
+// exclusive. Buckets are cut at midnight in loc.
+func (s *TransactionRepo) GetTransactionSeries(
+	ctx context.Context, userID string, bucket, step string, start, end time.Time, loc *time.Location,
+) ([]models.CashflowBucket, error) {
+	query := `
+		WITH buckets AS (
+			SELECT generate_series($3::timestamp, $4::timestamp - $5::interval, $5::interval) AS period_start
+		),
+		totals AS (
+			SELECT
+				date_trunc($2, transaction_date AT TIME ZONE $6) AS period_start,
+				SUM(amount) FILTER (WHERE type = 'income') AS income,
+				SUM(amount) FILTER (WHERE type = 'expense') AS expense,
+				COUNT(*) AS transaction_count
+			FROM transactions
+			WHERE user_id = $1 AND voided_at IS NULL
+				AND transaction_date >= ($3::timestamp AT TIME ZONE $6)
+				AND transaction_date < ($4::timestamp AT TIME ZONE $6)
+			GROUP BY 1
+		)
+		SELECT b.period_start, COALESCE(t.income, 0), COALESCE(t.expense, 0), COALESCE(t.transaction_count, 0)
+		FROM buckets b
+		LEFT JOIN totals t ON t.period_start = b.period_start
+		ORDER BY b.period_start
+	`
+	rows, err := s.db.QueryContext(
+		ctx, query, userID, bucket,
+		start.Format("2006-01-02"), end.Format("2006-01-02"), step, loc.String(),
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get transaction series: %s", err.Error())
 		return nil, err
 	}
 	defer rows.Close()
 
-	var transactions []models.Transaction
+	buckets := []models.CashflowBucket{}
 	for rows.Next() {
-		var transaction models.Transaction
-		err := rows.Scan(
-			&transaction.ID,
-			&transaction.UserID,
-			&transaction.Type,
-			&transaction.Source,
-			&transaction.Amount,
-			&transaction.TransactionDate,
-			&transaction.ReceiptID,
-			&transaction.OrderID,
-			&transaction.CreatedAt,
-		)
-		if err != nil {
+		var b models.CashflowBucket
+		if err := rows.Scan(&b.PeriodStart, &b.Income, &b.Expense, &b.TransactionCount); err != nil {
+			log.Printf("[ERROR] Failed to scan transaction series: %s", err.Error())
 			return nil, err
 		}
-		transactions = append(transactions, transaction)
+		b.PeriodStart = inLocation(b.PeriodStart, loc)
+		b.Net = b.Income - b.Expense
+		buckets = append(buckets, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate transaction series: %s", err.Error())
+		return nil, err
 	}
-	return transactions, nil
+
+	return buckets, nil
 }
 
-func (s *TransactionRepo) GetTransactionsByRange(ctx context.Context, userID string, startDate, endDate time.Time) ([]models.Transaction, error) {
-	query := `
-		SELECT id, user_id, type, source, amount, transaction_date, receipt_id, order_id, created_at
-		FROM transactions
-		WHERE user_id = $1 AND DATE(transaction_date) BETWEEN $2 AND $3
-		ORDER BY transaction_date DESC
-	`
-	rows, err := s.db.QueryContext(ctx, query, userID, startDate, endDate)
+func (s *TransactionRepo) GetTransactionsByType(
+	ctx context.Context, userID string, transactionType string, startDate, endDate time.Time,
+) ([]models.Transaction, error) {
+	return s.findTransactions(ctx, models.TransactionFilter{
+		UserID:    userID,
+		StartDate: &startDate,
+		EndDate:   &endDate,
+		Type:      &transactionType,
+	})
+}
+
+func (s *TransactionRepo) GetTransactionsBySource(
+	ctx context.Context, userID string, source string, startDate, endDate time.Time,
+) ([]models.Transaction, error) {
+	return s.findTransactions(ctx, models.TransactionFilter{
+		UserID:    userID,
+		StartDate: &startDate,
+		EndDate:   &endDate,
+		Source:    &source,
+	})
+}
+
+// findTransactions returns every transaction matching filter, newest first.
+func (s *TransactionRepo) findTransactions(ctx context.Context, filter models.TransactionFilter) ([]models.Transaction, error) {
+	transactions, _, err := s.QueryTransactions(ctx, models.TransactionQuery{TransactionFilter: filter})
+	return transactions, err
+}
+
+// transactionSorts maps a sort option to the column transactions are
+// ordered by and whether the order is descending. Ties are broken by id in
+// the same direction so every transaction has a unique position for the
+// cursor.
+var transactionSorts = map[string]struct {
+	column string
+	cast   string
+	desc   bool
+}{
+	models.TransactionSortDateDesc:   {"t.transaction_date", "timestamptz", true},
+	models.TransactionSortDateAsc:    {"t.transaction_date", "timestamptz", false},
+	models.TransactionSortAmountDesc: {"t.amount", "numeric", true},
+	models.TransactionSortAmountAsc:  {"t.amount", "numeric", false},
+}
+
+// QueryTransactions returns the page of transactions described by query and
+// the cursor of the next page, nil when this is the last one. A Limit of zero
+// returns every matching transaction.
+func (s *TransactionRepo) QueryTransactions(
+	ctx context.Context, query models.TransactionQuery,
+) ([]models.Transaction, *models.TransactionCursor, error) {
+	if query.Sort == "" {
+		query.Sort = models.TransactionSortDateDesc
+	}
+	sort, ok := transactionSorts[query.Sort]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown transaction sort %q", query.Sort)
+	}
+
+	whereConditions, args := transactionConditions(query.TransactionFilter)
+
+	direction, comparison := "ASC", ">"
+	if sort.desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if query.Cursor != nil {
+		args = append(args, query.Cursor.Value, query.Cursor.ID)
+		whereConditions = append(whereConditions, fmt.Sprintf(
+			"(%s, t.id) %s ($%d::%s, $%d::uuid)", sort.column, comparison, len(args)-1, sort.cast, len(args),
+		))
+	}
+
+	limit := ""
+	if query.Limit > 0 {
+		// One extra row tells whether there is a next page.
+		args = append(args, query.Limit+1)
+		limit = fmt.Sprintf("LIMIT $%d", len(args))
+	}
+
+	sqlQuery := fmt.Sprintf(`
+		SELECT
+			t.id, t.user_id, t.type, t.source, t.amount, t.transaction_date, t.receipt_id, t.order_id,
+			t.category_id, t.account_id, t.recurring_id, t.voided_at, t.void_reason, t.created_at
+		FROM transactions t
+		WHERE %s
+		ORDER BY %s %s, t.id %s
+		%s
+	`, strings.Join(whereConditions, " AND "), sort.column, direction, direction, limit)
+
+	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
-		return nil, err
+		log.Printf("[ERROR] Failed to query transactions: %s", err.Error())
+		return nil, nil, err
 	}
 	defer rows.Close()
 
-	var transactions []models.Transaction
+	transactions := []models.Transaction{}
 	for rows.Next() {
 		var transaction models.Transaction
 		err := rows.Scan(
@@ -100,123 +263,353 @@ func (s *TransactionRepo) GetTransactionsByRange(ctx context.Context, userID str
 			&transaction.TransactionDate,
 			&transaction.ReceiptID,
 			&transaction.OrderID,
+			&transaction.CategoryID,
+			&transaction.AccountID,
+			&transaction.RecurringID,
+			&transaction.VoidedAt,
+			&transaction.VoidReason,
 			&transaction.CreatedAt,
 		)
 		if err != nil {
-			return nil, err
+			log.Printf("[ERROR] Failed to scan transaction: %s", err.Error())
+			return nil, nil, err
 		}
 		transactions = append(transactions, transaction)
 	}
-	return transactions, nil
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate transactions: %s", err.Error())
+		return nil, nil, err
+	}
+
+	if query.Limit == 0 || len(transactions) <= query.Limit {
+		return transactions, nil, nil
+	}
+
+	transactions = transactions[:query.Limit]
+	last := transactions[len(transactions)-1]
+	cursor := &models.TransactionCursor{Sort: query.Sort, ID: last.ID}
+	if sort.cast == "numeric" {
+		cursor.Value = strconv.FormatFloat(last.Amount, 'f', -1, 64)
+	} else {
+		cursor.Value = last.TransactionDate.UTC().Format(time.RFC3339Nano)
+	}
+	return transactions, cursor, nil
 }
 
-func (s *TransactionRepo) GetTransactionsByDays(ctx context.Context, userID string, days int) ([]models.Transaction, error) {
-	endDate := time.Now()
-	startDate := endDate.AddDate(0, 0, -days)
-	return s.GetTransactionsByRange(ctx, userID, startDate, endDate)
+// transactionConditions builds the conditions selecting filter over the
+// transactions table aliased t, with arguments numbered from $1.
+func transactionConditions(filter models.TransactionFilter) ([]string, []any) {
+	whereConditions := []string{"t.user_id = $1"}
+	args := []any{filter.UserID}
+
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		whereConditions = append(whereConditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.StartDate != nil {
+		add("t.transaction_date >= $%d", *filter.StartDate)
+	}
+	if filter.EndDate != nil {
+		add("t.transaction_date < $%d", *filter.EndDate)
+	}
+	if filter.Type != nil {
+		add("t.type = $%d", *filter.Type)
+	}
+	if filter.Source != nil {
+		add("t.source = $%d", *filter.Source)
+	}
+	if filter.MinAmount != nil {
+		add("t.amount >= $%d", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		add("t.amount <= $%d", *filter.MaxAmount)
+	}
+	if filter.CategoryID != nil {
+		add("t.category_id = $%d", *filter.CategoryID)
+	}
+	if filter.AccountID != nil {
+		add("t.account_id = $%d", *filter.AccountID)
+	}
+	if filter.Voided != nil {
+		if *filter.Voided {
+			whereConditions = append(whereConditions, "t.voided_at IS NOT NULL")
+		} else {
+			whereConditions = append(whereConditions, "t.voided_at IS NULL")
+		}
+	}
+	if filter.Search != nil {
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(*filter.Search) + "%"
+		add(`(
+			EXISTS (SELECT 1 FROM transaction_categories c WHERE c.id = t.category_id AND c.name ILIKE $%[1]d)
+			OR EXISTS (SELECT 1 FROM receipts r WHERE r.id = t.receipt_id AND r.store_name ILIKE $%[1]d)
+			OR EXISTS (
+				SELECT 1 FROM orders o JOIN customers cu ON cu.id = o.customer_id
+				WHERE o.id = t.order_id AND cu.name ILIKE $%[1]d
+			)
+			OR EXISTS (
+				SELECT 1 FROM recurring_transactions rt WHERE rt.id = t.recurring_id AND rt.description ILIKE $%[1]d
+			)
+		)`, pattern)
+	}
+
+	return whereConditions, args
 }
 
-func (s *TransactionRepo) GetTransactionStats(ctx context.Context, userID string, startDate, endDate time.Time) (models.TransactionStats, error) {
+func (s *TransactionRepo) GetTransactionByID(ctx context.Context, id string, userID string) (*models.Transaction, error) {
 	query := `
-		SELECT 
-			COALESCE(SUM(CASE WHEN type = 'income' THEN amount ELSE 0 END), 0) as total_income,
-			COALESCE(SUM(CASE WHEN type = 'expense' THEN amount ELSE 0 END), 0) as total_expense,
-			COUNT(*) as transaction_count,
-			COALESCE(AVG(amount), 0) as average_amount
+		SELECT id, user_id, type, source, amount, transaction_date, receipt_id, order_id, category_id, account_id, recurring_id, voided_at, void_reason, created_at
 		FROM transactions
-		WHERE user_id = $1 AND DATE(transaction_date) BETWEEN $2 AND $3
+		WHERE id = $1 AND user_id = $2
 	`
-
-	var stats models.TransactionStats
-	err := s.db.QueryRowContext(ctx, query, userID, startDate, endDate).Scan(
-		&stats.TotalIncome,
-		&stats.TotalExpense,
-		&stats.TransactionCount,
-		&stats.AverageAmount,
+	var transaction models.Transaction
+	err := s.db.QueryRowContext(ctx, query, id, userID).Scan(
+		&transaction.ID,
+		&transaction.UserID,
+		&transaction.Type,
+		&transaction.Source,
+		&transaction.Amount,
+		&transaction.TransactionDate,
+		&transaction.ReceiptID,
+		&transaction.OrderID,
+		&transaction.CategoryID,
+		&transaction.AccountID,
+		&transaction.RecurringID,
+		&transaction.VoidedAt,
+		&transaction.VoidReason,
+		&transaction.CreatedAt,
 	)
 	if err != nil {
-		return models.TransactionStats{}, err
+		if err != sql.ErrNoRows {
+			log.Printf("[ERROR] Failed to get transaction: %s", err.Error())
+		}
+		return nil, err
 	}
+	return &transaction, nil
+}
 
-	stats.NetAmount = stats.TotalIncome - stats.TotalExpense
-	return stats, nil
+// UpdateTransaction changes the editable fields of a standalone, non-voided
+// transaction and records its previous values. It reports false when no such
+// transaction exists.
+func (s *TransactionRepo) UpdateTransaction(ctx context.Context, transaction *models.Transaction) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return false, err
+	}
+	defer tx.Rollback()
+
+	found, err := recordRevision(ctx, tx, transaction.ID, transaction.UserID, models.TransactionRevisionUpdate, nil)
+	if err != nil || !found {
+		return false, err
+	}
+
+	query := `
+		UPDATE transactions
+		SET type = $1, amount = $2, transaction_date = $3, category_id = $4,
+			account_id = ` + defaultAccountID("$5::uuid", "transactions.user_id") + `
+		WHERE id = $6
+		RETURNING account_id
+	`
+	err = tx.QueryRowContext(
+		ctx, query,
+		transaction.Type, transaction.Amount, transaction.TransactionDate,
+		transaction.CategoryID, transaction.AccountID, transaction.ID,
+	).Scan(&transaction.AccountID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to update transaction: %s", err.Error())
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return false, err
+	}
+
+	return true, nil
 }
 
-func (s *TransactionRepo) GetTransactionStatsByDays(ctx context.Context, userID string, days int) (models.TransactionStats, error) {
-	endDate := time.Now()
-	startDate := endDate.AddDate(0, 0, -days)
-	return s.GetTransactionStats(ctx, userID, startDate, endDate)
+// VoidTransaction keeps a standalone transaction in the books but excludes it
+// from statistics.
+func (s *TransactionRepo) VoidTransaction(ctx context.Context, id string, userID string, reason string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return false, err
+	}
+	defer tx.Rollback()
+
+	found, err := recordRevision(ctx, tx, id, userID, models.TransactionRevisionVoid, &reason)
+	if err != nil || !found {
+		return false, err
+	}
+
+	query := `UPDATE transactions SET voided_at = NOW(), void_reason = $1 WHERE id = $2`
+	if _, err := tx.ExecContext(ctx, query, reason, id); err != nil {
+		log.Printf("[ERROR] Failed to void transaction: %s", err.Error())
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return false, err
+	}
+
+	return true, nil
 }
 
-func (s *TransactionRepo) GetTransactionsByType(
-	ctx context.Context, userID string, transactionType string, startDate, endDate time.Time,
-) ([]models.Transaction, error) {
+func (s *TransactionRepo) GetTransactionRevisions(ctx context.Context, transactionID string, userID string) ([]models.TransactionRevision, error) {
 	query := `
-		SELECT id, user_id, type, source, amount, transaction_date, receipt_id, created_at
-		FROM transactions
-		WHERE user_id = $1 AND type = $2 AND DATE(transaction_date) BETWEEN $3 AND $4
-		ORDER BY transaction_date DESC
+		SELECT id, transaction_id, user_id, action, previous, reason, created_at
+		FROM transaction_revisions
+		WHERE transaction_id = $1 AND user_id = $2
+		ORDER BY created_at DESC
 	`
-	rows, err := s.db.QueryContext(ctx, query, userID, transactionType, startDate, endDate)
+	rows, err := s.db.QueryContext(ctx, query, transactionID, userID)
 	if err != nil {
+		log.Printf("[ERROR] Failed to get transaction revisions: %s", err.Error())
 		return nil, err
 	}
 	defer rows.Close()
 
-	var transactions []models.Transaction
+	revisions := []models.TransactionRevision{}
 	for rows.Next() {
-		var transaction models.Transaction
+		var revision models.TransactionRevision
+		var previous []byte
 		err := rows.Scan(
-			&transaction.ID,
-			&transaction.UserID,
-			&transaction.Type,
-			&transaction.Source,
-			&transaction.Amount,
-			&transaction.TransactionDate,
-			&transaction.ReceiptID,
-			&transaction.CreatedAt,
+			&revision.ID, &revision.TransactionID, &revision.UserID,
+			&revision.Action, &previous, &revision.Reason, &revision.CreatedAt,
 		)
 		if err != nil {
+			log.Printf("[ERROR] Failed to scan transaction revision: %s", err.Error())
 			return nil, err
 		}
-		transactions = append(transactions, transaction)
+		revision.Previous = previous
+		revisions = append(revisions, revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate transaction revisions: %s", err.Error())
+		return nil, err
 	}
-	return transactions, nil
+
+	return revisions, nil
 }
 
-func (s *TransactionRepo) GetTransactionsBySource(
-	ctx context.Context, userID string, source string, startDate, endDate time.Time,
-) ([]models.Transaction, error) {
+// recordRevision locks a standalone transaction that is not voided and stores
+// its current values. It reports false when there is no matching transaction.
+func recordRevision(ctx context.Context, tx *sql.Tx, id, userID, action string, reason *string) (bool, error) {
 	query := `
-		SELECT id, user_id, type, source, amount, transaction_date, receipt_id, order_id, created_at
-		FROM transactions
-		WHERE user_id = $1 AND source = $2 AND DATE(transaction_date) BETWEEN $3 AND $4
-		ORDER BY transaction_date DESC
+		SELECT to_jsonb(t)
+		FROM transactions t
+		WHERE t.id = $1 AND t.user_id = $2
+			AND t.receipt_id IS NULL AND t.order_id IS NULL
+			AND t.voided_at IS NULL
+		FOR UPDATE
 	`
-	rows, err := s.db.QueryContext(ctx, query, userID, source, startDate, endDate)
+	var previous []byte
+	err := tx.QueryRowContext(ctx, query, id, userID).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
 	if err != nil {
-		return nil, err
+		log.Printf("[ERROR] Failed to lock transaction: %s", err.Error())
+		return false, err
+	}
+
+	insertQuery := `
+		INSERT INTO transaction_revisions (transaction_id, user_id, action, previous, reason)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err = tx.ExecContext(ctx, insertQuery, id, userID, action, previous, reason)
+	if err != nil {
+		log.Printf("[ERROR] Failed to record transaction revision: %s", err.Error())
+		return false, err
+	}
+
+	return true, nil
+}
+
+// voidLinkedTransactions voids every transaction generated by a receipt or an
+// order, inside the transaction that changes the parent. column is either
+// receipt_id or order_id.
+func voidLinkedTransactions(ctx context.Context, tx *sql.Tx, column, parentID, reason string) error {
+	revisionQuery := fmt.Sprintf(`
+		INSERT INTO transaction_revisions (transaction_id, user_id, action, previous, reason)
+		SELECT t.id, t.user_id, $2, to_jsonb(t), $3
+		FROM transactions t
+		WHERE t.%s = $1 AND t.voided_at IS NULL
+	`, column)
+	_, err := tx.ExecContext(ctx, revisionQuery, parentID, models.TransactionRevisionVoid, reason)
+	if err != nil {
+		log.Printf("[ERROR] Failed to record transaction revisions: %s", err.Error())
+		return err
+	}
+
+	voidQuery := fmt.Sprintf(`
+		UPDATE transactions SET voided_at = NOW(), void_reason = $2
+		WHERE %s = $1 AND voided_at IS NULL
+	`, column)
+	if _, err := tx.ExecContext(ctx, voidQuery, parentID, reason); err != nil {
+		log.Printf("[ERROR] Failed to void linked transactions: %s", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// ExportTransactions streams the transactions matching filter to fn, oldest
+// first, without loading them all into memory. It stops at the first error
+// returned by fn.
+func (s *TransactionRepo) ExportTransactions(
+	ctx context.Context, filter models.TransactionFilter, fn func(models.TransactionExportRow) error,
+) error {
+	whereConditions, args := transactionConditions(filter)
+
+	query := fmt.Sprintf(`
+		SELECT
+			t.id, t.user_id, t.type, t.source, t.amount, t.transaction_date, t.receipt_id, t.order_id,
+			t.category_id, t.account_id, t.recurring_id, t.voided_at, t.void_reason, t.created_at, c.name, a.name
+		FROM transactions t
+		LEFT JOIN transaction_categories c ON c.id = t.category_id
+		LEFT JOIN accounts a ON a.id = t.account_id
+		WHERE %s
+		ORDER BY t.transaction_date, t.created_at
+	`, strings.Join(whereConditions, " AND "))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("[ERROR] Failed to export transactions: %s", err.Error())
+		return err
 	}
 	defer rows.Close()
 
-	var transactions []models.Transaction
 	for rows.Next() {
-		var transaction models.Transaction
+		var row models.TransactionExportRow
 		err := rows.Scan(
-			&transaction.ID,
-			&transaction.UserID,
-			&transaction.Type,
-			&transaction.Source,
-			&transaction.Amount,
-			&transaction.TransactionDate,
-			&transaction.ReceiptID,
-			&transaction.OrderID,
-			&transaction.CreatedAt,
+			&row.ID, &row.UserID, &row.Type, &row.Source, &row.Amount, &row.TransactionDate,
+			&row.ReceiptID, &row.OrderID, &row.CategoryID, &row.AccountID, &row.RecurringID,
+			&row.VoidedAt, &row.VoidReason, &row.CreatedAt, &row.CategoryName, &row.AccountName,
 		)
 		if err != nil {
-			return nil, err
+			log.Printf("[ERROR] Failed to scan exported transaction: %s", err.Error())
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
 		}
-		transactions = append(transactions, transaction)
 	}
-	return transactions, nil
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate exported transactions: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// inLocation reinterprets a wall-clock time read from a TIMESTAMP column as a
+// time in loc.
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
 }
@@ -4,9 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
+	"time"
 
 	"github.com/Cakra17/imphnen/internal/models"
+	"github.com/Cakra17/imphnen/internal/utils"
 )
 
 type UserRepo struct {
@@ -18,34 +21,80 @@ func NewUserRepo(db *sql.DB) UserRepo {
 }
 
 func (r *UserRepo) Create(ctx context.Context, user models.User) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
 		INSERT INTO 
-			users (id, email, password_hash, first_name, last_name, store_name) 
-			VALUES ($1, $2, $3, $4, $5, $6)
+			users (id, email, password_hash, first_name, last_name, store_name, timezone) 
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING created_at 
 	`
-	err := r.db.QueryRowContext(
+	err = tx.QueryRowContext(
 		ctx, query,
 		user.ID, user.Email,
 		user.PasswordHash, user.FirstName,
 		user.LastName, user.StoreName,
+		user.Timezone,
 	).Scan(&user.Created_At)
 	if err != nil {
 		log.Printf("[ERROR] Failed to create user: %s", err.Error())
 		return err
 	}
+
+	if err := seedDefaultCategories(ctx, tx, user.ID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return err
+	}
 	return nil
 }
 
 func (r *UserRepo) GetUserbyEmail(ctx context.Context, email string) (*models.User, error) {
 	query := `
 	SELECT 
-		id, email, password_hash, first_name, last_name, store_name 
+		id, email, password_hash, first_name, last_name, store_name, timezone,
+		totp_enabled, totp_secret, totp_last_step, deletion_scheduled_at
 	FROM users 
 	WHERE email = $1`
 
 	user := &models.User{}
-	err := r.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.FirstName, &user.LastName, &user.StoreName)
+	err := r.db.QueryRowContext(ctx, query, email).Scan(
+		&user.ID, &user.Email, &user.PasswordHash, &user.FirstName, &user.LastName, &user.StoreName, &user.Timezone,
+		&user.TwoFactorEnabled, &user.TOTPSecret, &user.TOTPLastStep, &user.DeletionScheduledAt,
+	)
+	if err == sql.ErrNoRows {
+		log.Printf("[ERROR] Failed to get user: %s", err.Error())
+		return nil, errors.New("User not found")
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to get user: %s", err.Error())
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (r *UserRepo) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	query := `
+	SELECT 
+		id, email, password_hash, first_name, last_name, store_name, timezone,
+		totp_enabled, totp_secret, totp_last_step, deletion_scheduled_at
+	FROM users 
+	WHERE id = $1`
+
+	user := &models.User{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&user.ID, &user.Email, &user.PasswordHash, &user.FirstName, &user.LastName, &user.StoreName, &user.Timezone,
+		&user.TwoFactorEnabled, &user.TOTPSecret, &user.TOTPLastStep, &user.DeletionScheduledAt,
+	)
 	if err == sql.ErrNoRows {
 		log.Printf("[ERROR] Failed to get user: %s", err.Error())
 		return nil, errors.New("User not found")
@@ -58,11 +107,18 @@ func (r *UserRepo) GetUserbyEmail(ctx context.Context, email string) (*models.Us
 	return user, nil
 }
 
-func (r *UserRepo) UpdateUser(ctx context.Context, id string, user models.User) error {
+// UpdateUser keeps the current time zone when user.Timezone is empty and
+// writes the resulting zone back into user.
+func (r *UserRepo) UpdateUser(ctx context.Context, id string, user *models.User) error {
 	query := `
-		UPDATE users SET first_name = $1, last_name = $2, store_name = $3 WHERE id = $4
+		UPDATE users
+		SET first_name = $1, last_name = $2, store_name = $3, timezone = COALESCE(NULLIF($4, ''), timezone)
+		WHERE id = $5
+		RETURNING timezone
 	`
-	_, err := r.db.ExecContext(ctx, query, user.FirstName, user.LastName, user.StoreName, id)
+	err := r.db.QueryRowContext(
+		ctx, query, user.FirstName, user.LastName, user.StoreName, user.Timezone, id,
+	).Scan(&user.Timezone)
 	if err != nil {
 		log.Printf("[ERROR] Failed to update user: %s", err.Error())
 		return err
@@ -70,20 +126,160 @@ func (r *UserRepo) UpdateUser(ctx context.Context, id string, user models.User)
 	return nil
 }
 
-func (r *UserRepo) DeleteUser(ctx context.Context, id string) error {
-	query := `DELETE FROM users WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
+// GetTimezone returns the IANA zone the merchant's dates are evaluated in.
+func (r *UserRepo) GetTimezone(ctx context.Context, id string) (string, error) {
+	query := `SELECT timezone FROM users WHERE id = $1`
+
+	var timezone string
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&timezone); err != nil {
+		log.Printf("[ERROR] Failed to get user timezone: %s", err.Error())
+		return "", err
+	}
+	return timezone, nil
+}
+
+// DeleteUser only removes the account while its deletion is still due, so a
+// cancellation that lands during the purge keeps the account. It reports
+// whether the account was deleted.
+func (r *UserRepo) DeleteUser(ctx context.Context, id string) (bool, error) {
+	query := `
+		DELETE FROM users
+		WHERE id = $1 AND deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= NOW()
+	`
+	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		log.Printf("[ERROR] Failed to delete user: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// IsDueForDeletion tells whether the account's deletion is scheduled and its
+// grace period has ended.
+func (r *UserRepo) IsDueForDeletion(ctx context.Context, id string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM users
+			WHERE id = $1 AND deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= NOW()
+		)
+	`
+	var due bool
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&due); err != nil {
+		log.Printf("[ERROR] Failed to check user deletion: %s", err.Error())
+		return false, err
+	}
+	return due, nil
+}
+
+// ScheduleDeletion marks the account for removal at the given time. The data
+// stays untouched until the purge job runs, so the request can be cancelled.
+func (r *UserRepo) ScheduleDeletion(ctx context.Context, id string, at time.Time) error {
+	query := `
+		UPDATE users SET deletion_requested_at = NOW(), deletion_scheduled_at = $1
+		WHERE id = $2
+	`
+	_, err := r.db.ExecContext(ctx, query, at, id)
+	if err != nil {
+		log.Printf("[ERROR] Failed to schedule user deletion: %s", err.Error())
 		return err
 	}
 	return nil
 }
 
+func (r *UserRepo) CancelDeletion(ctx context.Context, id string) (bool, error) {
+	query := `
+		UPDATE users SET deletion_requested_at = NULL, deletion_scheduled_at = NULL
+		WHERE id = $1 AND deletion_scheduled_at IS NOT NULL
+	`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		log.Printf("[ERROR] Failed to cancel user deletion: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *UserRepo) GetUsersDueForDeletion(ctx context.Context, now time.Time) ([]string, error) {
+	query := `
+		SELECT id FROM users
+		WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= $1
+		ORDER BY deletion_scheduled_at
+	`
+	rows, err := r.db.QueryContext(ctx, query, now)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get users due for deletion: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("[ERROR] Failed to scan user: %s", err.Error())
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate users: %s", err.Error())
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// GetMediaPublicIDs lists the Cloudinary public IDs of every image the user
+// uploaded, so they can be removed before the rows that reference them.
+func (r *UserRepo) GetMediaPublicIDs(ctx context.Context, id string) ([]string, error) {
+	query := `
+		SELECT public_id FROM receipts WHERE user_id = $1 AND public_id <> ''
+		UNION
+		SELECT public_id FROM products WHERE user_id = $1 AND public_id <> ''
+	`
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get media public ids: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var publicIDs []string
+	for rows.Next() {
+		var publicID string
+		if err := rows.Scan(&publicID); err != nil {
+			log.Printf("[ERROR] Failed to scan media public id: %s", err.Error())
+			return nil, err
+		}
+		publicIDs = append(publicIDs, publicID)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate media public ids: %s", err.Error())
+		return nil, err
+	}
+
+	return publicIDs, nil
+}
+
 func (r *UserRepo) GetAllUsers(ctx context.Context) ([]models.Merchant, error) {
 	query := `
 		SELECT id, store_name 
 		FROM users 
+		WHERE deletion_scheduled_at IS NULL
 		ORDER BY created_at DESC
 	`
 
@@ -112,3 +308,247 @@ func (r *UserRepo) GetAllUsers(ctx context.Context) ([]models.Merchant, error) {
 
 	return merchants, nil
 }
+
+// SetTOTPSecret stores a secret sealed with the server's SecretBox. It must
+// never be given the plaintext seed.
+func (r *UserRepo) SetTOTPSecret(ctx context.Context, id string, secret string) error {
+	query := `
+		UPDATE users SET totp_secret = $1, totp_enabled = FALSE, totp_last_step = NULL WHERE id = $2
+	`
+	_, err := r.db.ExecContext(ctx, query, secret, id)
+	if err != nil {
+		log.Printf("[ERROR] Failed to set totp secret: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// SealTOTPSecrets encrypts the TOTP secrets that were stored before
+// encryption at rest was introduced and returns how many it sealed. A row is
+// only updated while it still holds the plaintext value, so it is safe to run
+// on several instances at once.
+func (r *UserRepo) SealTOTPSecrets(ctx context.Context, box *utils.SecretBox) (int, error) {
+	query := `
+		SELECT id, totp_secret FROM users
+		WHERE totp_secret IS NOT NULL AND totp_secret NOT LIKE 'v1:%'
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get plaintext totp secrets: %s", err.Error())
+		return 0, err
+	}
+	defer rows.Close()
+
+	plain := map[string]string{}
+	for rows.Next() {
+		var id, secret string
+		if err := rows.Scan(&id, &secret); err != nil {
+			log.Printf("[ERROR] Failed to scan totp secret: %s", err.Error())
+			return 0, err
+		}
+		plain[id] = secret
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to iterate totp secrets: %s", err.Error())
+		return 0, err
+	}
+
+	update := `UPDATE users SET totp_secret = $1 WHERE id = $2 AND totp_secret = $3`
+	sealed := 0
+	for id, secret := range plain {
+		value, err := box.Seal(secret)
+		if err != nil {
+			log.Printf("[ERROR] Failed to seal totp secret: %s", err.Error())
+			return sealed, err
+		}
+
+		res, err := r.db.ExecContext(ctx, update, value, id, secret)
+		if err != nil {
+			log.Printf("[ERROR] Failed to update totp secret: %s", err.Error())
+			return sealed, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			sealed++
+		}
+	}
+	return sealed, nil
+}
+
+// EnableTOTP activates two-factor authentication and replaces any previous
+// recovery codes in a single transaction.
+func (r *UserRepo) EnableTOTP(ctx context.Context, id string, step int64, codes []models.RecoveryCode) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET totp_enabled = TRUE, totp_last_step = $1 WHERE id = $2`
+	if _, err := tx.ExecContext(ctx, query, step, id); err != nil {
+		log.Printf("[ERROR] Failed to enable totp: %s", err.Error())
+		return err
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, id, codes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepo) DisableTOTP(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE users SET totp_enabled = FALSE, totp_secret = NULL, totp_last_step = NULL WHERE id = $1
+	`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		log.Printf("[ERROR] Failed to disable totp: %s", err.Error())
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, id); err != nil {
+		log.Printf("[ERROR] Failed to delete recovery codes: %s", err.Error())
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// ConsumeTOTPStep records step as used. It returns false when the step (or a
+// later one) was already used, which blocks replaying an intercepted code.
+func (r *UserRepo) ConsumeTOTPStep(ctx context.Context, id string, step int64) (bool, error) {
+	query := `
+		UPDATE users SET totp_last_step = $1
+		WHERE id = $2 AND (totp_last_step IS NULL OR totp_last_step < $1)
+	`
+	result, err := r.db.ExecContext(ctx, query, step, id)
+	if err != nil {
+		log.Printf("[ERROR] Failed to consume totp step: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *UserRepo) ReplaceRecoveryCodes(ctx context.Context, id string, codes []models.RecoveryCode) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %s", err.Error())
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, id, codes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit transaction: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// ReserveTwoFactorAttempt counts a second factor login attempt before its
+// code is checked, so concurrent guesses are counted too. The attempt that
+// reaches maxAttempts locks the second factor for lockout, and it reports
+// false while the lock lasts. An expired lock starts a new count.
+func (r *UserRepo) ReserveTwoFactorAttempt(ctx context.Context, id string, maxAttempts int, lockout time.Duration) (bool, error) {
+	query := `
+		UPDATE users SET
+			two_factor_attempts = CASE WHEN two_factor_locked_until IS NULL THEN two_factor_attempts + 1 ELSE 1 END,
+			two_factor_locked_until = CASE
+				WHEN (CASE WHEN two_factor_locked_until IS NULL THEN two_factor_attempts + 1 ELSE 1 END) >= $2
+				THEN NOW() + $3::interval
+			END
+		WHERE id = $1 AND (two_factor_locked_until IS NULL OR two_factor_locked_until <= NOW())
+	`
+	result, err := r.db.ExecContext(ctx, query, id, maxAttempts, fmt.Sprintf("%d seconds", int(lockout.Seconds())))
+	if err != nil {
+		log.Printf("[ERROR] Failed to count two-factor attempt: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// ResetTwoFactorAttempts clears the attempt count and lock after a
+// successful second factor login.
+func (r *UserRepo) ResetTwoFactorAttempts(ctx context.Context, id string) error {
+	query := `UPDATE users SET two_factor_attempts = 0, two_factor_locked_until = NULL WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		log.Printf("[ERROR] Failed to reset two-factor attempts: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// UseRecoveryCode marks a matching unused recovery code as used and reports
+// whether one was found.
+func (r *UserRepo) UseRecoveryCode(ctx context.Context, id string, codeHash string) (bool, error) {
+	query := `
+		UPDATE user_recovery_codes SET used_at = NOW()
+		WHERE id = (
+			SELECT id FROM user_recovery_codes
+			WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+			LIMIT 1
+		)
+	`
+	result, err := r.db.ExecContext(ctx, query, id, codeHash)
+	if err != nil {
+		log.Printf("[ERROR] Failed to use recovery code: %s", err.Error())
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("[ERROR] Failed to check rows affected: %s", err.Error())
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID string, codes []models.RecoveryCode) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		log.Printf("[ERROR] Failed to delete recovery codes: %s", err.Error())
+		return err
+	}
+
+	query := `
+		INSERT INTO user_recovery_codes (id, user_id, code_hash)
+		VALUES ($1, $2, $3)
+	`
+	for _, code := range codes {
+		if _, err := tx.ExecContext(ctx, query, code.ID, userID, code.CodeHash); err != nil {
+			log.Printf("[ERROR] Failed to create recovery code: %s", err.Error())
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// differenceHashSamples caps the pixels read per cell along each axis, so a
+// large photo costs about as much to hash as a small one.
+const differenceHashSamples = 16
+
+// differenceHashMaxPixels caps the size of the images DifferenceHash decodes.
+// A small compressed file can declare huge dimensions and decoding it would
+// allocate gigabytes, so larger images are only matched on their content.
+const differenceHashMaxPixels = 40_000_000
+
+var ErrImageTooLarge = errors.New("image too large to hash")
+
+// DifferenceHash decodes a JPEG or PNG image and returns its 64 bit
+// difference hash. The image is shrunk to 9x8 grayscale cells and every bit
+// tells whether a cell is brighter than its right neighbour, so two photos
+// of the same receipt differ in only a few bits while unrelated images
+// differ in about half of them. Images above differenceHashMaxPixels are
+// rejected with ErrImageTooLarge before their pixels are decoded.
+func DifferenceHash(data []byte) (uint64, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width > differenceHashMaxPixels/config.Height {
+		return 0, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+
+	bounds := img.Bounds()
+	var cells [8][9]float64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 9; x++ {
+			cells[y][x] = averageLuma(img, image.Rect(
+				bounds.Min.X+x*bounds.Dx()/9, bounds.Min.Y+y*bounds.Dy()/8,
+				bounds.Min.X+(x+1)*bounds.Dx()/9, bounds.Min.Y+(y+1)*bounds.Dy()/8,
+			))
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if cells[y][x] > cells[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash, nil
+}
+
+func averageLuma(img image.Image, cell image.Rectangle) float64 {
+	stepX := max(cell.Dx()/differenceHashSamples, 1)
+	stepY := max(cell.Dy()/differenceHashSamples, 1)
+
+	var sum float64
+	var count int
+	for y := cell.Min.Y; y < cell.Max.Y; y += stepY {
+		for x := cell.Min.X; x < cell.Max.X; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingKey is one entry of a KeySet. private is nil for keys that are only
+// kept around to verify tokens signed before a rotation.
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// KeySet holds the keys used to sign and verify access tokens. Tokens are
+// always signed with the active key; every key in the set, plus the legacy
+// HS256 secret until legacyUntil, is accepted for verification.
+type KeySet struct {
+	active       *signingKey
+	keys         map[string]*signingKey
+	legacySecret []byte
+	legacyUntil  time.Time
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// LoadKeySet reads every <kid>.pem file in dir. Private keys (PKCS#8 Ed25519
+// or RSA, or PKCS#1 RSA) can sign, public keys (PKIX) only verify. activeKid
+// picks the signing key and must name a private key. dir may only be empty
+// with allowEphemeral, which generates an Ed25519 key for development: its
+// tokens stop verifying after a restart and on every other instance.
+//
+// legacySecret verifies HS256 tokens issued before key-based signing, but only
+// those that expire by legacyUntil, which must be set along with it. Past
+// legacyUntil the secret is ignored.
+func LoadKeySet(dir, activeKid, legacySecret string, legacyUntil time.Time, allowEphemeral bool) (*KeySet, error) {
+	ks := &KeySet{keys: map[string]*signingKey{}}
+	if legacySecret != "" {
+		if legacyUntil.IsZero() {
+			return nil, errors.New("JWT_SECRET is set without JWT_LEGACY_UNTIL, set it to the rollout of key-based signing plus the access token lifetime or unset JWT_SECRET")
+		}
+
+		if time.Now().Before(legacyUntil) {
+			ks.legacySecret = []byte(legacySecret)
+			ks.legacyUntil = legacyUntil
+		} else {
+			log.Println("[WARN] JWT_LEGACY_UNTIL has passed, legacy HS256 tokens are rejected and JWT_SECRET can be removed")
+		}
+	}
+
+	if dir == "" {
+		if !allowEphemeral {
+			return nil, errors.New("JWT_KEYS_DIR is not set, set JWT_EPHEMERAL_KEY=true to sign with a throwaway key in development")
+		}
+
+		key, err := generateEphemeralKey()
+		if err != nil {
+			return nil, err
+		}
+		log.Println("[WARN] JWT_KEYS_DIR is not set, signing tokens with an ephemeral key")
+		ks.keys[key.kid] = key
+		ks.active = key
+		return ks, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := loadPEMKey(kid, path)
+		if err != nil {
+			return nil, fmt.Errorf("load key %s: %w", kid, err)
+		}
+		ks.keys[kid] = key
+	}
+
+	if len(ks.keys) == 0 {
+		return nil, fmt.Errorf("no *.pem keys found in %s", dir)
+	}
+
+	if activeKid == "" && len(ks.keys) == 1 {
+		for kid := range ks.keys {
+			activeKid = kid
+		}
+	}
+
+	active, ok := ks.keys[activeKid]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found in %s", activeKid, dir)
+	}
+	if active.private == nil {
+		return nil, fmt.Errorf("active key %q has no private key", activeKid)
+	}
+	ks.active = active
+
+	return ks, nil
+}
+
+func generateEphemeralKey() (*signingKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(public)
+	return &signingKey{
+		kid:     "dev-" + base64.RawURLEncoding.EncodeToString(sum[:6]),
+		method:  jwt.SigningMethodEdDSA,
+		private: private,
+		public:  public,
+	}, nil
+}
+
+func loadPEMKey(kid, path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{kid: kid}
+	switch k := parsed.(type) {
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, k.Public()
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	if rsaKey, ok := key.public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < 2048 {
+		return nil, errors.New("RSA keys must be at least 2048 bits")
+	}
+
+	return key, nil
+}
+
+func (ks *KeySet) sign(typ string, claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	token.Header["kid"] = ks.active.kid
+	token.Header["typ"] = typ
+	return token.SignedString(ks.active.private)
+}
+
+func (ks *KeySet) keyFunc(t *jwt.Token) (any, error) {
+	kid, ok := t.Header["kid"].(string)
+	if !ok {
+		if ks.legacySecret != nil && t.Method == jwt.SigningMethodHS256 {
+			return ks.legacyKey(t)
+		}
+		return nil, errors.New("token has no kid header")
+	}
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	if t.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// legacyKey returns the HS256 secret for tokens that expire by legacyUntil.
+// Every token signed with the secret before the rollout does, so anything
+// else was minted afterwards and is refused.
+func (ks *KeySet) legacyKey(t *jwt.Token) (any, error) {
+	if !time.Now().Before(ks.legacyUntil) {
+		return nil, errors.New("legacy tokens are no longer accepted")
+	}
+
+	exp, err := t.Claims.GetExpirationTime()
+	if err != nil || exp == nil || exp.After(ks.legacyUntil) {
+		return nil, errors.New("legacy token expires after JWT_LEGACY_UNTIL")
+	}
+	return ks.legacySecret, nil
+}
+
+func (ks *KeySet) validMethods() []string {
+	methods := []string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}
+	if ks.legacySecret != nil {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	return methods
+}
+
+// JWKS returns the public half of every key in the set, so other services can
+// verify tokens without sharing a secret. The legacy HS256 secret is never
+// published.
+func (ks *KeySet) JWKS() JWKS {
+	kids := make([]string, 0, len(ks.keys))
+	for kid := range ks.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	jwks := JWKS{Keys: make([]JWK, 0, len(kids))}
+	for _, kid := range kids {
+		key := ks.keys[kid]
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.method.Alg()}
+
+		switch k := key.public.(type) {
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(k)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix marks values sealed by a SecretBox, so rows written before
+// encryption was introduced can be told apart and sealed on startup.
+const sealedPrefix = "v1:"
+
+var ErrInvalidSealedValue = errors.New("invalid sealed value")
+
+// SecretBox encrypts small secrets, such as TOTP seeds, with AES-256-GCM
+// before they are stored.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox takes a base64 encoded 32 byte key.
+func NewSecretBox(key string) (*SecretBox, error) {
+	if key == "" {
+		return nil, errors.New("TOTP_ENCRYPTION_KEY is not set, generate one with `openssl rand -base64 32`")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("TOTP_ENCRYPTION_KEY is not valid base64: %w", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("TOTP_ENCRYPTION_KEY must be 32 bytes, got %d", len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+func (b *SecretBox) Seal(plain string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plain), nil)
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (b *SecretBox) Open(sealed string) (string, error) {
+	if !IsSealed(sealed) {
+		return "", ErrInvalidSealedValue
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, sealedPrefix))
+	if err != nil || len(raw) < b.aead.NonceSize() {
+		return "", ErrInvalidSealedValue
+	}
+
+	nonce, ciphertext := raw[:b.aead.NonceSize()], raw[b.aead.NonceSize():]
+	plain, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrInvalidSealedValue
+	}
+	return string(plain), nil
+}
+
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
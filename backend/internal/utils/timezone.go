@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultTimezone is the zone of merchants that never picked one. Dates
+// stored before time zones were configurable were written in WIB.
+const DefaultTimezone = "Asia/Jakarta"
+
+var locations sync.Map
+
+// ValidTimezone reports whether name is an IANA zone name. "Local" is
+// rejected because it depends on the server rather than the merchant.
+func ValidTimezone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// LoadLocation returns the location of an IANA zone name, falling back to
+// DefaultTimezone when the name is unknown. Loaded zones are cached.
+func LoadLocation(name string) *time.Location {
+	if cached, ok := locations.Load(name); ok {
+		return cached.(*time.Location)
+	}
+
+	if !ValidTimezone(name) {
+		if name != DefaultTimezone {
+			log.Printf("[ERROR] Unknown time zone %q, using %s", name, DefaultTimezone)
+			return LoadLocation(DefaultTimezone)
+		}
+		return time.UTC
+	}
+
+	loc, _ := time.LoadLocation(name)
+	locations.Store(name, loc)
+	return loc
+}
+
+// StartOfDay returns midnight of t's calendar day in loc.
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
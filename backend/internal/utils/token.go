@@ -1,23 +1,63 @@
 package utils
 
 import (
-	"fmt"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func GenerateToken(userId string, email string, duration time.Duration, secret string) (string, error) {
+// TokenPurposeTwoFactor marks the short lived token returned by Login when the
+// account still has to pass its second factor. It is not an access token.
+const TokenPurposeTwoFactor = "2fa_challenge"
+
+// Access tokens and challenge tokens are signed with the same keys, so they
+// differ in audience and typ header. Services verifying tokens against the
+// JWKS must require AccessTokenAudience.
+const (
+	AccessTokenAudience    = "imphnen"
+	ChallengeTokenAudience = "imphnen-2fa-challenge"
+
+	accessTokenType    = "at+jwt"
+	challengeTokenType = "2fa-challenge+jwt"
+)
+
+var ErrWrongTokenType = errors.New("token is not of the expected type")
+
+func GenerateToken(userId string, email string, duration time.Duration, keys *KeySet) (string, error) {
 	now := time.Now()
 	claims := jwt.MapClaims{
 		"user_id": userId,
 		"email":   email,
+		"aud":     AccessTokenAudience,
+		"iat":     now.Unix(),
+		"exp":     now.Add(duration).Unix(),
+	}
+
+	tokenStr, err := keys.sign(accessTokenType, claims)
+	if err != nil {
+		return "", err
+	}
+
+	return tokenStr, nil
+}
+
+func GenerateChallengeToken(userId string, duration time.Duration, keys *KeySet) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"user_id": userId,
+		"purpose": TokenPurposeTwoFactor,
+		"aud":     ChallengeTokenAudience,
 		"iat":     now.Unix(),
 		"exp":     now.Add(duration).Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, err := token.SignedString([]byte(secret))
+	tokenStr, err := keys.sign(challengeTokenType, claims)
 	if err != nil {
 		return "", err
 	}
@@ -25,14 +65,63 @@ func GenerateToken(userId string, email string, duration time.Duration, secret s
 	return tokenStr, nil
 }
 
-func ValidateToken(token string, secret string) (*jwt.Token, error) {
-	return jwt.Parse(token, func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
-		}
-		return []byte(secret), nil
-	},
+// ValidateToken parses an access token and rejects challenge tokens. Access
+// tokens issued before they carried an audience are accepted until they
+// expire.
+func ValidateToken(token string, keys *KeySet) (*jwt.Token, error) {
+	parsed, err := jwt.Parse(token, keys.keyFunc,
 		jwt.WithExpirationRequired(),
-		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}),
+		jwt.WithValidMethods(keys.validMethods()),
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || parsed.Header["typ"] == challengeTokenType || claims["purpose"] != nil {
+		return nil, ErrWrongTokenType
+	}
+	if aud, _ := claims.GetAudience(); len(aud) > 0 && !slices.Contains(aud, AccessTokenAudience) {
+		return nil, ErrWrongTokenType
+	}
+	return parsed, nil
+}
+
+// ValidateChallengeToken parses a token returned by Login for the second
+// factor.
+func ValidateChallengeToken(token string, keys *KeySet) (*jwt.Token, error) {
+	parsed, err := jwt.Parse(token, keys.keyFunc,
+		jwt.WithExpirationRequired(),
+		jwt.WithValidMethods(keys.validMethods()),
+		jwt.WithAudience(ChallengeTokenAudience),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || parsed.Header["typ"] != challengeTokenType || claims["purpose"] != TokenPurposeTwoFactor {
+		return nil, ErrWrongTokenType
+	}
+	return parsed, nil
+}
+
+// AccessTokenPrefix starts every personal access token so Auth can tell them
+// apart from JWTs without parsing.
+const AccessTokenPrefix = "imp_pat_"
+
+func GenerateAccessToken() (token string, displayPrefix string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+
+	token = AccessTokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	displayPrefix = token[:len(AccessTokenPrefix)+4]
+	return token, displayPrefix, HashAccessToken(token), nil
+}
+
+func HashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
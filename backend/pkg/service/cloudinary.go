@@ -2,16 +2,18 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"mime/multipart"
 
 	"github.com/cloudinary/cloudinary-go/v2"
 	"github.com/cloudinary/cloudinary-go/v2/api"
 	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
 )
 
+var ErrMediaNotFound = errors.New("media not found")
+
 type CloudinaryService struct {
 	cld          *cloudinary.Cloudinary
 	parentFolder string
@@ -25,7 +27,7 @@ func NewCloudinaryService(name, apiKey, secret, parentFolder string) (Cloudinary
 	return CloudinaryService{cld: cld, parentFolder: parentFolder}, nil
 }
 
-func (s *CloudinaryService) UploadMedia(ctx context.Context, subFolder string, image multipart.File) (secureURL, publidID string, err error) {
+func (s *CloudinaryService) UploadMedia(ctx context.Context, subFolder string, image io.Reader) (secureURL, publidID string, err error) {
 	if seeker, ok := image.(io.Seeker); ok {
 		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
 			log.Printf("[ERROR] Failed to seek file: %v", err)
@@ -46,13 +48,22 @@ func (s *CloudinaryService) UploadMedia(ctx context.Context, subFolder string, i
 	return resp.SecureURL, resp.PublicID, nil
 }
 
+// DeleteMedia removes an uploaded image. It returns ErrMediaNotFound when
+// Cloudinary has no image with publicID, which callers cleaning up can treat
+// as already deleted.
 func (s *CloudinaryService) DeleteMedia(ctx context.Context, publicID string) error {
-	_, err := s.cld.Upload.Destroy(ctx, uploader.DestroyParams{
+	resp, err := s.cld.Upload.Destroy(ctx, uploader.DestroyParams{
 		PublicID: publicID,
 	})
 	if err != nil {
 		return fmt.Errorf("Failed to delete image: %s", err.Error())
 	}
+	if resp.Result == "not found" {
+		return ErrMediaNotFound
+	}
+	if resp.Error.Message != "" {
+		return fmt.Errorf("Failed to delete image: %s", resp.Error.Message)
+	}
 
 	return nil
 }
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Cakra17/imphnen/internal/models"
+)
+
+// FakeOCRService answers from JSON fixtures instead of reading the image, so
+// receipt flows can run offline. The fixture named after the uploaded file,
+// without its extension, is used when it exists and default.json otherwise.
+// A fixture of the form {"error": "..."} makes the call fail.
+type FakeOCRService struct {
+	FixturesDir string
+}
+
+func NewFakeOCRService(fixturesDir string) FakeOCRService {
+	return FakeOCRService{FixturesDir: fixturesDir}
+}
+
+func (s *FakeOCRService) Name() string {
+	return "fake"
+}
+
+func (s *FakeOCRService) OCRForm(ctx context.Context, image io.Reader, filename string) (models.OCR, error) {
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+
+	data, err := os.ReadFile(filepath.Join(s.FixturesDir, name+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		data, err = os.ReadFile(filepath.Join(s.FixturesDir, "default.json"))
+	}
+	if err != nil {
+		return models.OCR{}, fmt.Errorf("failed to read OCR fixture: %w", err)
+	}
+
+	var fixture struct {
+		models.OCR
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return models.OCR{}, fmt.Errorf("failed to decode OCR fixture: %w", err)
+	}
+	if fixture.Error != "" {
+		return models.OCR{}, errors.New(fixture.Error)
+	}
+
+	return fixture.OCR, nil
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,15 +15,27 @@ import (
 	"github.com/Cakra17/imphnen/internal/models"
 )
 
+const DefaultKolosalBaseURL = "https://api.kolosal.ai"
+
 type KolosalService struct {
 	API_KEY string
+	BaseURL string
+}
+
+// NewKolosalService talks to the Kolosal API at baseURL, falling back to
+// DefaultKolosalBaseURL when it is empty.
+func NewKolosalService(apiKey, baseURL string) KolosalService {
+	if baseURL == "" {
+		baseURL = DefaultKolosalBaseURL
+	}
+	return KolosalService{API_KEY: apiKey, BaseURL: strings.TrimSuffix(baseURL, "/")}
 }
 
-func NewKolosalService(apiKey string) KolosalService {
-	return KolosalService{API_KEY: apiKey}
+func (s *KolosalService) Name() string {
+	return "kolosal"
 }
 
-func (s *KolosalService) OCRForm(image multipart.File, filename string) (models.OCR, error) {
+func (s *KolosalService) OCRForm(ctx context.Context, image io.Reader, filename string) (models.OCR, error) {
 	if s.API_KEY == "" {
 		return models.OCR{}, fmt.Errorf("KOLOSAL_API_KEY not set")
 	}
@@ -75,7 +88,7 @@ func (s *KolosalService) OCRForm(image multipart.File, filename string) (models.
 		return models.OCR{}, fmt.Errorf("failed to close multipart writer: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.kolosal.ai/ocr/form", &body)
+	req, err := http.NewRequestWithContext(ctx, "POST", s.BaseURL+"/ocr/form", &body)
 	if err != nil {
 		return models.OCR{}, fmt.Errorf("failed to create request: %w", err)
 	}
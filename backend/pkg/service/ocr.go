@@ -0,0 +1,100 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"time"
+
+	"github.com/Cakra17/imphnen/internal/models"
+)
+
+// OCRProvider reads the invoice printed on a receipt image.
+type OCRProvider interface {
+	Name() string
+	OCRForm(ctx context.Context, image io.Reader, filename string) (models.OCR, error)
+}
+
+var ErrLowConfidence = errors.New("OCR output has low confidence")
+
+// OCRChain asks its providers in order and returns the first confident
+// result. A provider that errors or returns low-confidence output is skipped;
+// when none is confident the first low-confidence result is returned, so
+// the receipt can still be corrected by hand.
+type OCRChain struct {
+	providers []OCRProvider
+}
+
+func NewOCRChain(providers ...OCRProvider) OCRChain {
+	return OCRChain{providers: providers}
+}
+
+func (c *OCRChain) Name() string {
+	return "chain"
+}
+
+func (c *OCRChain) OCRForm(ctx context.Context, image io.Reader, filename string) (models.OCR, error) {
+	if len(c.providers) == 0 {
+		return models.OCR{}, fmt.Errorf("no OCR provider configured")
+	}
+
+	// Every provider reads the image from the start.
+	data, err := io.ReadAll(image)
+	if err != nil {
+		return models.OCR{}, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	var fallback *models.OCR
+	var errs []error
+	for _, provider := range c.providers {
+		resp, err := provider.OCRForm(ctx, bytes.NewReader(data), filename)
+		if err != nil {
+			log.Printf("[ERROR] OCR provider %s failed: %s", provider.Name(), err.Error())
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+			continue
+		}
+
+		if err := CheckOCRConfidence(resp); err != nil {
+			log.Printf("[INFO] OCR provider %s returned low-confidence output: %s", provider.Name(), err.Error())
+			if fallback == nil {
+				fallback = &resp
+			}
+			continue
+		}
+		return resp, nil
+	}
+
+	if fallback != nil {
+		return *fallback, nil
+	}
+	return models.OCR{}, errors.Join(errs...)
+}
+
+// CheckOCRConfidence returns an error wrapping ErrLowConfidence when resp
+// looks misread: no total, no items, an unreadable invoice date or item
+// prices that do not add up to the subtotal or total.
+func CheckOCRConfidence(resp models.OCR) error {
+	if resp.Total <= 0 {
+		return fmt.Errorf("%w: no total", ErrLowConfidence)
+	}
+	if len(resp.InvoiceItems) == 0 {
+		return fmt.Errorf("%w: no items", ErrLowConfidence)
+	}
+	if _, err := time.Parse("2006-01-02", resp.InvoiceDate); err != nil {
+		return fmt.Errorf("%w: invoice date %q", ErrLowConfidence, resp.InvoiceDate)
+	}
+
+	var sum float64
+	for _, item := range resp.InvoiceItems {
+		sum += item.Total
+	}
+	// Rounding on the receipt is allowed for, a missed or misread item is not.
+	if math.Abs(sum-resp.Subtotal) > 1 && math.Abs(sum-resp.Total) > 1 {
+		return fmt.Errorf("%w: items add up to %.2f, total is %.2f", ErrLowConfidence, sum, resp.Total)
+	}
+	return nil
+}